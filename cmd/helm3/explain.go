@@ -0,0 +1,17 @@
+package main
+
+import (
+	"github.com/MChorfa/porter-helm3/pkg/helm3"
+	"github.com/spf13/cobra"
+)
+
+func buildExplainCommand(m *helm3.Mixin) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "explain",
+		Short: "Print the parameters, credentials and outputs of the helm3 steps in the bundle",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return m.PrintExplain(cmd.Context())
+		},
+	}
+	return cmd
+}
@@ -0,0 +1,17 @@
+package main
+
+import (
+	"github.com/MChorfa/porter-helm3/pkg/helm3"
+	"github.com/spf13/cobra"
+)
+
+func buildLintCommand(m *helm3.Mixin) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "lint",
+		Short: "Check the helm3 steps in the bundle for problems",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return m.PrintLintResults(cmd.Context())
+		},
+	}
+	return cmd
+}
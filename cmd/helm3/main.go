@@ -1,9 +1,12 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/MChorfa/porter-helm3/pkg/helm3"
 	"github.com/spf13/cobra"
@@ -15,9 +18,13 @@ func main() {
 		fmt.Printf("err: %s\n", err)
 		os.Exit(1)
 	}
-	if err := cmd.Execute(); err != nil {
+	// Cancel the action when porter or the runtime stops the mixin, so that helm is interrupted cleanly
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	err = cmd.ExecuteContext(ctx)
+	stop()
+	if err != nil {
 		fmt.Printf("err: %s\n", err)
-		os.Exit(1)
+		os.Exit(helm3.ExitCode(err))
 	}
 }
 
@@ -37,10 +44,13 @@ func buildRootCommand(in io.Reader) (*cobra.Command, error) {
 	}
 
 	cmd.PersistentFlags().BoolVar(&m.DebugMode, "debug", false, "Enable debug logging")
+	cmd.PersistentFlags().BoolVar(&m.PlanMode, "plan", false, "Print the helm commands instead of executing them")
 
 	cmd.AddCommand(buildVersionCommand(m))
 	cmd.AddCommand(buildSchemaCommand(m))
 	cmd.AddCommand(buildBuildCommand(m))
+	cmd.AddCommand(buildLintCommand(m))
+	cmd.AddCommand(buildExplainCommand(m))
 	cmd.AddCommand(buildInstallCommand(m))
 	cmd.AddCommand(buildInvokeCommand(m))
 	cmd.AddCommand(buildUpgradeCommand(m))
@@ -1,12 +1,16 @@
 package helm3
 
 import (
+	"strings"
+
 	"get.porter.sh/porter/pkg/exec/builder"
+	"github.com/pkg/errors"
 )
 
 var _ builder.ExecutableAction = Action{}
 var _ builder.BuildableAction = Action{}
 var _ builder.ExecutableStep = ExecuteStep{}
+var _ builder.HasEnvironmentVars = ExecuteStep{}
 
 type Action struct {
 	// Name of the action: install, upgrade, invoke, uninstall.
@@ -16,10 +20,11 @@ type Action struct {
 
 // MarshalYAML converts the action back to a YAML representation
 // install:
-//   exec:
-//     ...
-//   helm3:
-//     ...
+//
+//	exec:
+//	  ...
+//	helm3:
+//	  ...
 func (a Action) MarshalYAML() (interface{}, error) {
 	return map[string]interface{}{a.Name: a.Steps}, nil
 }
@@ -68,6 +73,50 @@ type ExecuteStep struct {
 	Namespace string        `yaml:"namespace,omitempty"`
 	Arguments []string      `yaml:"arguments,omitempty"`
 	Flags     builder.Flags `yaml:"flags,omitempty"`
+
+	// Plugin is the name of a helm plugin run by the step, the arguments are passed to the plugin
+	Plugin string `yaml:"plugin,omitempty"`
+
+	// RevisionDiff prints the difference between the manifests of two revisions of a release, instead of running a command
+	RevisionDiff *RevisionDiff `yaml:"revisionDiff,omitempty"`
+
+	// DependencyList prints the dependencies of a local chart as json, instead of running a command
+	DependencyList *DependencyList `yaml:"dependencyList,omitempty"`
+
+	// Verify checks the provenance of a packaged chart with helm verify, instead of running a command
+	Verify *ProvenanceVerification `yaml:"verify,omitempty"`
+
+	// Promote switches the traffic of a blue/green deployment to the other color, instead of running a command
+	Promote *Promotion `yaml:"promote,omitempty"`
+
+	// Status prints the status of a release as json, with its resources by kind, instead of running a command
+	Status *ReleaseStatus `yaml:"status,omitempty"`
+
+	// Exists prints true when a release is installed and false otherwise, instead of running a command
+	Exists *ReleaseExistence `yaml:"exists,omitempty"`
+
+	// GetHooks prints the hooks registered by a release as json, instead of running a command
+	GetHooks *ReleaseHooks `yaml:"getHooks,omitempty"`
+
+	// GcHistory deletes the old revisions of releases and prints them as json, instead of running a command
+	GcHistory *HistoryGC `yaml:"gcHistory,omitempty"`
+
+	// Diagnostics prints the helm and kubectl installation of the invocation image as json, instead of running a command
+	Diagnostics bool `yaml:"diagnostics,omitempty"`
+
+	// SkipSchemaValidation ignores the values.schema.json of charts in helm install, upgrade, template and lint,
+	// it requires helm 3.16 or later
+	SkipSchemaValidation bool `yaml:"skipSchemaValidation,omitempty"`
+
+	// KubeVersion is the kubernetes version used by helm template to render charts off-cluster
+	KubeVersion string `yaml:"kubeVersion,omitempty"`
+	// APIVersions are the kubernetes api versions available to the capabilities of charts rendered by helm template
+	APIVersions []string `yaml:"apiVersions,omitempty"`
+
+	// command is the name of the helm binary, set when the action is loaded
+	command string
+	// globalFlags are the global flags of the step, set before the command runs
+	globalFlags builder.Flags
 }
 
 func (s ExecuteStep) GetWorkingDir() string {
@@ -75,13 +124,101 @@ func (s ExecuteStep) GetWorkingDir() string {
 }
 
 func (s ExecuteStep) GetCommand() string {
-	return "helm3"
+	if s.command != "" {
+		return s.command
+	}
+	return defaultClientBinary
 }
 
 func (s ExecuteStep) GetArguments() []string {
+	if s.Plugin != "" {
+		return append([]string{s.Plugin}, s.Arguments...)
+	}
 	return s.Arguments
 }
 
 func (s ExecuteStep) GetFlags() builder.Flags {
-	return s.Flags
+	if s.KubeVersion == "" && len(s.APIVersions) == 0 && !s.SkipSchemaValidation && len(s.globalFlags) == 0 {
+		return s.Flags
+	}
+
+	flags := make(builder.Flags, 0, len(s.Flags)+len(s.globalFlags)+3)
+	flags = append(flags, s.Flags...)
+	flags = append(flags, s.globalFlags...)
+	if s.KubeVersion != "" {
+		flags = append(flags, builder.NewFlag("kube-version", s.KubeVersion))
+	}
+	if len(s.APIVersions) > 0 {
+		flags = append(flags, builder.NewFlag("api-versions", s.APIVersions...))
+	}
+	if s.SkipSchemaValidation {
+		flags = append(flags, builder.NewFlag("skip-schema-validation"))
+	}
+	return flags
+}
+
+// validateOperation checks that the step runs at most one of the operations that replace the helm command
+func (s ExecuteStep) validateOperation() error {
+	var operations []string
+	if s.RevisionDiff != nil {
+		operations = append(operations, "revisionDiff")
+	}
+	if s.DependencyList != nil {
+		operations = append(operations, "dependencyList")
+	}
+	if s.Verify != nil {
+		operations = append(operations, "verify")
+	}
+	if s.Promote != nil {
+		operations = append(operations, "promote")
+	}
+	if s.Status != nil {
+		operations = append(operations, "status")
+	}
+	if s.GetHooks != nil {
+		operations = append(operations, "getHooks")
+	}
+	if s.Exists != nil {
+		operations = append(operations, "exists")
+	}
+	if s.GcHistory != nil {
+		operations = append(operations, "gcHistory")
+	}
+	if s.Diagnostics {
+		operations = append(operations, "diagnostics")
+	}
+	if len(operations) > 1 {
+		return errors.Errorf("only one of revisionDiff, dependencyList, verify, promote, status, getHooks, exists, gcHistory and diagnostics can be set, got %s",
+			strings.Join(operations, ", "))
+	}
+	return nil
+}
+
+// schemaValidationCommands are the helm commands that validate the values of charts against their schema
+var schemaValidationCommands = map[string]bool{"install": true, "upgrade": true, "template": true, "lint": true}
+
+// validateSkipSchemaValidation checks that skipSchemaValidation is only set on helm commands that validate values
+func (s ExecuteStep) validateSkipSchemaValidation() error {
+	if !s.SkipSchemaValidation {
+		return nil
+	}
+	if command, ok := helmCommandName(s.GetArguments()); s.Plugin != "" || !ok || !schemaValidationCommands[command] {
+		return errors.New("skipSchemaValidation can only be set when the step runs the install, upgrade, template or lint command")
+	}
+	return nil
+}
+
+// validateRenderTarget checks that the render target of the cluster is only set on helm template
+func (s ExecuteStep) validateRenderTarget() error {
+	if s.KubeVersion == "" && len(s.APIVersions) == 0 {
+		return nil
+	}
+	if command, ok := helmCommandName(s.GetArguments()); !ok || command != "template" {
+		return errors.New("kubeVersion and apiVersions can only be set when the step runs the template command")
+	}
+	return nil
+}
+
+func (s ExecuteStep) GetEnvironmentVars() map[string]string {
+	return s.Env
 }
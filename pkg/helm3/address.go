@@ -0,0 +1,55 @@
+package helm3
+
+import (
+	"bufio"
+	"context"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// addressJSONPath prints the hostname and ip of each load balancer ingress of an Ingress or a LoadBalancer Service, one per line
+const addressJSONPath = `{range .status.loadBalancer.ingress[*]}{.hostname}{" "}{.ip}{"\n"}{end}`
+
+// defaultAddressWaitTimeout is how long an address output waits for the load balancer without a waitTimeout
+const defaultAddressWaitTimeout = "5m"
+
+// addressResourceTypes are the resource types that are assigned an address by a load balancer
+var addressResourceTypes = map[string]bool{
+	"ingress": true, "ingresses": true, "ing": true,
+	"service": true, "services": true, "svc": true,
+}
+
+// getAddressOutput waits until the Ingress or LoadBalancer Service of an output is assigned an address by its load
+// balancer, and returns its first hostname, or its first ip when it has no hostname
+func (m *Mixin) getAddressOutput(ctx context.Context, output HelmOutput) ([]byte, error) {
+	if !addressResourceTypes[strings.ToLower(output.ResourceType)] || output.ResourceName == "" {
+		return nil, errors.Errorf("address output %s requires the resourceType and resourceName of an ingress or a service", output.Name)
+	}
+	if output.JSONPath != "" {
+		return nil, errors.Errorf("address output %s cannot be set together with jsonPath", output.Name)
+	}
+
+	output.JSONPath = addressJSONPath
+	if output.WaitTimeout == "" {
+		output.WaitTimeout = defaultAddressWaitTimeout
+	}
+	val, err := m.waitForOutput(ctx, output)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(parseAddress(string(val))), nil
+}
+
+// parseAddress returns the first hostname, or ip, printed by addressJSONPath
+func parseAddress(out string) string {
+	scanner := bufio.NewScanner(strings.NewReader(out))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) > 0 {
+			// the hostname is printed before the ip, and is preferred when the load balancer has both
+			return fields[0]
+		}
+	}
+	return ""
+}
@@ -0,0 +1,71 @@
+package helm3
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"get.porter.sh/porter/pkg/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseAddress(t *testing.T) {
+	assert.Equal(t, "a1b2.elb.amazonaws.com", parseAddress("a1b2.elb.amazonaws.com \n"))
+	assert.Equal(t, "203.0.113.10", parseAddress(" 203.0.113.10\n 203.0.113.11\n"))
+	assert.Equal(t, "web.example.com", parseAddress("web.example.com 203.0.113.10\n"))
+	assert.Equal(t, "", parseAddress(" \n"))
+}
+
+func TestMixin_GetAddressOutput(t *testing.T) {
+	defer func(interval time.Duration) { outputPollInterval = interval }(outputPollInterval)
+	outputPollInterval = 10 * time.Millisecond
+
+	output := HelmOutput{
+		Name:         "url",
+		ResourceType: "ingress",
+		ResourceName: "web",
+		Namespace:    "web",
+		Address:      true,
+		WaitTimeout:  "50ms",
+	}
+
+	defer os.Unsetenv(test.ExpectedCommandEnv)
+	defer os.Unsetenv(test.ExpectedCommandOutputEnv)
+	os.Setenv(test.ExpectedCommandEnv, "kubectl get ingress web -o=jsonpath="+addressJSONPath+" --namespace=web")
+
+	t.Run("assigned", func(t *testing.T) {
+		os.Setenv(test.ExpectedCommandOutputEnv, " 203.0.113.10\n")
+
+		h := NewTestMixin(t)
+		val, err := h.getAddressOutput(context.Background(), output)
+		require.NoError(t, err)
+		assert.Equal(t, "203.0.113.10", string(val))
+	})
+
+	t.Run("pending", func(t *testing.T) {
+		os.Setenv(test.ExpectedCommandOutputEnv, "")
+
+		h := NewTestMixin(t)
+		_, err := h.getAddressOutput(context.Background(), output)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "timed out after 50ms waiting for output url")
+	})
+
+	t.Run("unsupported resource", func(t *testing.T) {
+		h := NewTestMixin(t)
+		invalid := output
+		invalid.ResourceType = "deployment"
+		_, err := h.getAddressOutput(context.Background(), invalid)
+		require.EqualError(t, err, "address output url requires the resourceType and resourceName of an ingress or a service")
+	})
+
+	t.Run("jsonPath", func(t *testing.T) {
+		h := NewTestMixin(t)
+		invalid := output
+		invalid.JSONPath = "{.status}"
+		_, err := h.getAddressOutput(context.Background(), invalid)
+		require.EqualError(t, err, "address output url cannot be set together with jsonPath")
+	})
+}
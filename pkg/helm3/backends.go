@@ -0,0 +1,157 @@
+package helm3
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/jsonpath"
+)
+
+const (
+	// cliExecutionBackend runs the helm CLI installed in the invocation image
+	cliExecutionBackend = "cli"
+	// kubectlResourceBackend reads resources with the kubectl CLI installed in the invocation image
+	kubectlResourceBackend = "kubectl"
+	// clientResourceBackend reads resources with the kubernetes client, using the same kubeconfig as kubectl
+	clientResourceBackend = "client"
+)
+
+// resourceBackendEnv records the resource backend selected when the bundle was built
+const resourceBackendEnv = "PORTER_HELM3_RESOURCE_BACKEND"
+
+// supportedExecutionBackends are the backends that can be selected with executionBackend
+var supportedExecutionBackends = []string{cliExecutionBackend}
+
+// supportedResourceBackends are the backends that can be selected with resourceBackend
+var supportedResourceBackends = []string{kubectlResourceBackend, clientResourceBackend}
+
+func isSupportedBackend(supportedBackends []string, backend string) bool {
+	for _, supported := range supportedBackends {
+		if backend == supported {
+			return true
+		}
+	}
+	return false
+}
+
+// Executor creates the commands that run helm
+type Executor interface {
+	HelmCommand(ctx context.Context, args ...string) *exec.Cmd
+}
+
+// ResourceReader evaluates a jsonpath expression against a resource of the cluster, to read an output
+type ResourceReader interface {
+	ReadField(ctx context.Context, resourceType, resourceName, namespace, jsonPath string) ([]byte, error)
+}
+
+// executor returns the Executor set on the mixin, or the helm CLI
+func (m *Mixin) executor() Executor {
+	if m.Executor != nil {
+		return m.Executor
+	}
+	return cliExecutor{m: m}
+}
+
+// resourceReader returns the ResourceReader set on the mixin, or the backend selected when the bundle was built
+func (m *Mixin) resourceReader() ResourceReader {
+	if m.ResourceReader != nil {
+		return m.ResourceReader
+	}
+	if m.Getenv(resourceBackendEnv) == clientResourceBackend {
+		return clientReader{m: m}
+	}
+	return kubectlReader{m: m}
+}
+
+// helmCommand creates a command that runs helm with the arguments
+func (m *Mixin) helmCommand(ctx context.Context, args ...string) *exec.Cmd {
+	return m.executor().HelmCommand(ctx, args...)
+}
+
+// cliExecutor runs the helm binary installed in the invocation image
+type cliExecutor struct {
+	m *Mixin
+}
+
+func (e cliExecutor) HelmCommand(ctx context.Context, args ...string) *exec.Cmd {
+	// global flags come first, so that they apply to plugins and commands alike
+	return e.m.NewCommand(ctx, e.m.helmBinary(), append(e.m.globalFlagArgs(), args...)...)
+}
+
+// kubectlReader reads resources with kubectl get
+type kubectlReader struct {
+	m *Mixin
+}
+
+func (r kubectlReader) ReadField(ctx context.Context, resourceType, resourceName, namespace, jsonPath string) ([]byte, error) {
+	args := []string{"get", resourceType, resourceName}
+	args = append(args, fmt.Sprintf("-o=jsonpath=%s", jsonPath))
+	if namespace != "" {
+		args = append(args, fmt.Sprintf("--namespace=%s", namespace))
+	}
+	cmd := r.m.NewCommand(ctx, "kubectl", args...)
+	cmd.Stderr = r.m.Err
+	out, err := cmd.Output()
+	if err != nil {
+		prettyCmd := formatPlannedCommand(cmd.Args)
+		return nil, errors.Wrap(err, fmt.Sprintf("couldn't run command %s", prettyCmd))
+	}
+	return out, nil
+}
+
+// clientReader reads secrets and configmaps with the kubernetes client, for invocation images built without kubectl
+type clientReader struct {
+	m *Mixin
+}
+
+func (r clientReader) ReadField(ctx context.Context, resourceType, resourceName, namespace, jsonPath string) ([]byte, error) {
+	client, err := r.m.getKubernetesClient()
+	if err != nil {
+		return nil, errors.Wrap(err, "couldn't get kubernetes client")
+	}
+	if namespace == "" {
+		namespace = "default"
+	}
+
+	var resource interface{}
+	switch strings.ToLower(resourceType) {
+	case "secret", "secrets":
+		resource, err = client.CoreV1().Secrets(namespace).Get(ctx, resourceName, metav1.GetOptions{})
+	case "configmap", "configmaps", "cm":
+		resource, err = client.CoreV1().ConfigMaps(namespace).Get(ctx, resourceName, metav1.GetOptions{})
+	default:
+		return nil, errors.Errorf("output resourceType %s requires kubectl, only secret and configmap outputs are supported without it", resourceType)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "couldn't get %s %s/%s", resourceType, namespace, resourceName)
+	}
+
+	// The resource is converted to json first, so that the expression sees the same fields as with kubectl
+	b, err := json.Marshal(resource)
+	if err != nil {
+		return nil, errors.Wrapf(err, "couldn't read %s %s/%s", resourceType, namespace, resourceName)
+	}
+	var data interface{}
+	err = json.Unmarshal(b, &data)
+	if err != nil {
+		return nil, errors.Wrapf(err, "couldn't read %s %s/%s", resourceType, namespace, resourceName)
+	}
+
+	parser := jsonpath.New(resourceName).AllowMissingKeys(true)
+	err = parser.Parse(jsonPath)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid jsonPath %s", jsonPath)
+	}
+	result := &bytes.Buffer{}
+	err = parser.Execute(result, data)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not evaluate jsonPath %s against %s %s/%s", jsonPath, resourceType, namespace, resourceName)
+	}
+	return result.Bytes(), nil
+}
@@ -0,0 +1,171 @@
+package helm3
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	blueColor  = "blue"
+	greenColor = "green"
+
+	defaultActiveValue = "activeColor"
+	defaultColorValue  = "color"
+)
+
+// colorPlaceholder is replaced by the promoted color in the rollouts of a promotion, for example deployment/web-{{ color }}
+var colorPlaceholder = regexp.MustCompile(`{{\s*color\s*}}`)
+
+// BlueGreen maintains a -blue and a -green release of a chart, the traffic is sent to the active color
+// by a value of a router release, for example the selector of a service
+type BlueGreen struct {
+	// Router is the release that sends the traffic to the active color
+	Router string `yaml:"router"`
+	// ActiveValue is the value of the router holding the active color, activeColor by default
+	ActiveValue string `yaml:"activeValue,omitempty"`
+	// ColorValue is the value of the colored releases receiving their color, color by default
+	ColorValue string `yaml:"colorValue,omitempty"`
+}
+
+func (b BlueGreen) activeValue() string {
+	if b.ActiveValue == "" {
+		return defaultActiveValue
+	}
+	return b.ActiveValue
+}
+
+func (b BlueGreen) colorValue() string {
+	if b.ColorValue == "" {
+		return defaultColorValue
+	}
+	return b.ColorValue
+}
+
+// otherColor returns the color that does not receive the traffic, blue when no color is active yet
+func otherColor(active string) string {
+	if active == blueColor {
+		return greenColor
+	}
+	return blueColor
+}
+
+// activeColor reads the active color from the values of the router release,
+// there is no active color until the router is deployed
+func (m *Mixin) activeColor(ctx context.Context, bg BlueGreen, namespace string) (string, error) {
+	if m.planMode() {
+		m.printPlannedCommand(m.releaseValuesCommand(ctx, bg.Router, namespace))
+		return "", nil
+	}
+
+	values, err := m.releaseValues(ctx, bg.Router, namespace)
+	if err != nil {
+		return "", err
+	}
+	color, _ := lookupValue(values, bg.activeValue()).(string)
+	switch color {
+	case "", blueColor, greenColor:
+		return color, nil
+	default:
+		return "", errors.Errorf("the router release %s has an unknown active color %q, expected blue or green", bg.Router, color)
+	}
+}
+
+// blueGreenRelease returns the arguments that upgrade the color of a release that does not receive the traffic
+func (m *Mixin) blueGreenRelease(ctx context.Context, bg BlueGreen, release UpgradeArguments) (UpgradeArguments, error) {
+	active, err := m.activeColor(ctx, bg, release.Namespace)
+	if err != nil {
+		return release, err
+	}
+	color := otherColor(active)
+	fmt.Fprintf(m.Out, "Upgrading the %s color of release %s\n", color, release.Name)
+
+	set := make(map[string]string, len(release.Set)+1)
+	for k, v := range release.Set {
+		set[k] = v
+	}
+	set[bg.colorValue()] = color
+	release.Name = fmt.Sprintf("%s-%s", release.Name, color)
+	release.Set = set
+	return release, nil
+}
+
+// Promotion switches the traffic of a blue/green deployment to the other color
+type Promotion struct {
+	BlueGreen `yaml:",inline"`
+	// Chart of the router release, which is upgraded with the values of its last release and the promoted color
+	Chart   string `yaml:"chart"`
+	Version string `yaml:"version,omitempty"`
+	// Color is the color to promote, the color that does not receive the traffic by default
+	Color string `yaml:"color,omitempty"`
+	// Rollouts are the resources of the promoted color checked with kubectl rollout status before the traffic is switched
+	Rollouts []string `yaml:"rollouts,omitempty"`
+	Timeout  string   `yaml:"timeout,omitempty"`
+}
+
+// promote checks the rollout of the promoted color and switches the traffic to it, and returns the promoted color
+func (m *Mixin) promote(ctx context.Context, step ExecuteStep) (string, error) {
+	promotion := *step.Promote
+	if len(step.Arguments) > 0 || step.Plugin != "" {
+		return "", errors.New("promote cannot be set together with arguments or plugin")
+	}
+	if promotion.Router == "" || promotion.Chart == "" {
+		return "", errors.New("promote requires the router release and its chart")
+	}
+	if promotion.Color != "" && promotion.Color != blueColor && promotion.Color != greenColor {
+		return "", errors.Errorf("promote color %q must be blue or green", promotion.Color)
+	}
+	if _, err := parseTimeout("promote.timeout", promotion.Timeout); err != nil {
+		return "", err
+	}
+
+	active, err := m.activeColor(ctx, promotion.BlueGreen, step.Namespace)
+	if err != nil {
+		return "", err
+	}
+	color := promotion.Color
+	if color == "" {
+		color = otherColor(active)
+	}
+	if color == active {
+		fmt.Fprintf(m.Out, "The %s color of %s is already active\n", color, promotion.Router)
+		return color, nil
+	}
+
+	for _, rollout := range promotion.Rollouts {
+		resource := colorPlaceholder.ReplaceAllLiteralString(rollout, color)
+		rolloutCtx, cancel := withTimeout(ctx, promotion.Timeout)
+		cmd := m.NewCommand(rolloutCtx, "kubectl", "rollout", "status", resource)
+		if step.Namespace != "" {
+			cmd.Args = append(cmd.Args, "--namespace", step.Namespace)
+		}
+		cmd.Args = appendTimeout(cmd.Args, promotion.Timeout)
+		err = m.runCommand(cmd)
+		cancel()
+		if err != nil {
+			return "", errors.Wrapf(err, "the rollout of %s is not ready, the traffic was not switched to %s", resource, color)
+		}
+	}
+
+	ctx, cancel := withTimeout(ctx, promotion.Timeout)
+	defer cancel()
+	cmd := m.helmCommand(ctx, "upgrade", promotion.Router, promotion.Chart)
+	if step.Namespace != "" {
+		cmd.Args = append(cmd.Args, "--namespace", step.Namespace)
+	}
+	if promotion.Version != "" {
+		cmd.Args = append(cmd.Args, "--version", promotion.Version)
+	}
+	cmd.Args = append(cmd.Args, "--reuse-values", "--set", fmt.Sprintf("%s=%s", promotion.activeValue(), color), m.helmFlag("--atomic"), "--wait")
+	cmd.Args = appendTimeout(cmd.Args, promotion.Timeout)
+	m.setStepEnv(cmd, step.Env)
+	err = m.runCommand(cmd)
+	if err != nil {
+		return "", errors.Wrapf(err, "could not switch the traffic of %s to %s", promotion.Router, color)
+	}
+
+	fmt.Fprintf(m.Out, "Switched the traffic of %s to %s\n", promotion.Router, color)
+	return color, nil
+}
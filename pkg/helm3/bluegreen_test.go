@@ -0,0 +1,123 @@
+package helm3
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"get.porter.sh/porter/pkg/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestLookupValue(t *testing.T) {
+	values := map[string]interface{}{
+		"activeColor": "blue",
+		"router":      map[string]interface{}{"activeColor": "green"},
+	}
+	assert.Equal(t, "blue", lookupValue(values, "activeColor"))
+	assert.Equal(t, "green", lookupValue(values, "router.activeColor"))
+	assert.Nil(t, lookupValue(values, "activeColor.name"))
+	assert.Nil(t, lookupValue(values, "missing"))
+}
+
+func TestMixin_UpgradeBlueGreen(t *testing.T) {
+	ctx := context.Background()
+	defer os.Unsetenv(test.ExpectedCommandEnv)
+	defer os.Unsetenv(test.ExpectedCommandOutputEnv)
+	os.Setenv(test.ExpectedCommandEnv, strings.Join([]string{
+		"helm3 get values frontend-router --output json --namespace web",
+		"helm3 upgrade --install frontend-green myrepo/frontend --namespace web --atomic --create-namespace --output json --set color=green --set image.tag=2.0.0",
+	}, "\n"))
+	os.Setenv(test.ExpectedCommandOutputEnv, `{"activeColor":"blue"}`)
+
+	action := UpgradeAction{Steps: []UpgradeStep{{
+		UpgradeArguments: UpgradeArguments{
+			Step:      Step{Description: "Upgrade the frontend"},
+			Name:      "frontend",
+			Chart:     "myrepo/frontend",
+			Namespace: "web",
+			Set:       map[string]string{"image.tag": "2.0.0"},
+			BlueGreen: &BlueGreen{Router: "frontend-router"},
+		},
+	}}}
+	b, _ := yaml.Marshal(action)
+
+	h := NewTestMixin(t)
+	h.In = bytes.NewReader(b)
+
+	err := h.Upgrade(ctx)
+	require.NoError(t, err)
+	assert.Contains(t, h.TestContext.GetOutput(), "Upgrading the green color of release frontend")
+}
+
+func TestMixin_ExecutePromote(t *testing.T) {
+	ctx := context.Background()
+	defer os.Unsetenv(test.ExpectedCommandEnv)
+	defer os.Unsetenv(test.ExpectedCommandOutputEnv)
+	os.Setenv(test.ExpectedCommandEnv, strings.Join([]string{
+		"helm3 get values frontend-router --output json --namespace web",
+		"kubectl rollout status deployment/frontend-green --namespace web --timeout 5m",
+		"helm3 upgrade frontend-router myrepo/router --namespace web --reuse-values --set activeColor=green --atomic --wait --timeout 5m",
+	}, "\n"))
+	os.Setenv(test.ExpectedCommandOutputEnv, `{"activeColor":"blue"}`)
+
+	executeAction := Action{
+		Steps: []ExecuteSteps{
+			{
+				ExecuteStep: ExecuteStep{
+					Step:      Step{Description: "Promote the frontend"},
+					Namespace: "web",
+					Promote: &Promotion{
+						BlueGreen: BlueGreen{Router: "frontend-router"},
+						Chart:     "myrepo/router",
+						Rollouts:  []string{"deployment/frontend-{{ color }}"},
+						Timeout:   "5m",
+					},
+				},
+			},
+		},
+	}
+	b, _ := yaml.Marshal(executeAction)
+
+	h := NewTestMixin(t)
+	h.In = bytes.NewReader(b)
+
+	err := h.Execute(ctx)
+	require.NoError(t, err)
+	assert.Contains(t, h.TestContext.GetOutput(), "Switched the traffic of frontend-router to green")
+}
+
+func TestMixin_ExecutePromoteActiveColor(t *testing.T) {
+	ctx := context.Background()
+	defer os.Unsetenv(test.ExpectedCommandEnv)
+	defer os.Unsetenv(test.ExpectedCommandOutputEnv)
+	os.Setenv(test.ExpectedCommandEnv, "helm3 get values frontend-router --output json")
+	os.Setenv(test.ExpectedCommandOutputEnv, `{"router":{"color":"green"}}`)
+
+	executeAction := Action{
+		Steps: []ExecuteSteps{
+			{
+				ExecuteStep: ExecuteStep{
+					Step: Step{Description: "Promote the frontend"},
+					Promote: &Promotion{
+						BlueGreen: BlueGreen{Router: "frontend-router", ActiveValue: "router.color"},
+						Chart:     "myrepo/router",
+						Color:     "green",
+					},
+				},
+			},
+		},
+	}
+	b, _ := yaml.Marshal(executeAction)
+
+	h := NewTestMixin(t)
+	h.In = bytes.NewReader(b)
+
+	err := h.Execute(ctx)
+	require.NoError(t, err)
+	assert.Contains(t, h.TestContext.GetOutput(), "The green color of frontend-router is already active")
+}
@@ -3,6 +3,7 @@ package helm3
 import (
 	"context"
 	"fmt"
+	"path"
 	"sort"
 	"strings"
 
@@ -16,30 +17,164 @@ import (
 // Currently, this mixin only supports Helm clients versioned v3.x.x
 const clientVersionConstraint string = "^v3.x"
 
+// experimentalOCIConstraint represents the Helm client versions that need HELM_EXPERIMENTAL_OCI to use OCI registries
+const experimentalOCIConstraint string = "< v3.8.0"
+
+// supportedStorageDrivers are the helm release storage drivers that can be selected with storageDriver
+var supportedStorageDrivers = []string{"secret", "configmap", "sql"}
+
 // BuildInput represents stdin passed to the mixin for the build command.
 type BuildInput struct {
 	Config MixinConfig
 }
 
+// strictBuildInput is used to check the mixin configuration for unknown fields,
+// while ignoring the actions that are also passed to the build command.
+type strictBuildInput struct {
+	Config  MixinConfig            `yaml:"config"`
+	Actions map[string]interface{} `yaml:",inline"`
+}
+
 // MixinConfig represents configuration that can be set on the helm3 mixin in porter.yaml
 // mixins:
 // - helm3:
 // 	  clientVersion: v3.8.2
+// 	  experimentalHelm4: false
+// 	  globalFlags:
+// 	    --burst-limit: "200"
 // 	  clientPlatfrom: linux
 // 	  clientArchitecture: amd64 | arm64 | arm | i386
+//	  clientBinary: helm3
+//	  kubectlVersion: v1.22.1 | stable
+//	  installKubectl: true
+//	  executionBackend: cli
+//	  resourceBackend: kubectl | client
+//	  clientAliases:
+//	    - helm
 //	  repositories:
 //	    stable:
 //		  url: "https://charts.helm.sh/stable"
+//	  skipRepoUpdate: false
+//	  repoUpdateTTL: 6h
+//	  failOnRepoUpdateFail: false
+//	  failOnUndeclaredRepositories: false
+//	  allowUnknownFields: false
+//	  storageDriver: secret | configmap | sql
+//	  helmSecrets:
+//	    version: v4.1.1
+//	    sopsVersion: v3.7.3
+//	  cosign:
+//	    version: v2.2.4
+//	  conftest:
+//	    version: v0.45.0
+//	  helmSource:
+//	    url: https://artifacts.example.com/helm-fips.tar.gz
+//	    path: linux-amd64/helm
+//	    checksum: SHA256
+//	    signature: https://artifacts.example.com/helm-fips.tar.gz.asc
+//	    key: https://artifacts.example.com/release-key.asc
+//	  kubectlSource:
+//	    url: https://artifacts.example.com/kubectl-fips
+//	    checksum: SHA256
+//	  registryAuth:
+//	    - ecr | acr | gar
+//	  dockerConfig: /root/.docker/config.json
+//	  tools:
+//	    - jq | yq
+//	  kubeAuthPlugins:
+//	    - aws-iam-authenticator | kubelogin | gke-gcloud-auth-plugin
+//	  allowedCommands:
+//	    - unittest
+//	  plugins:
+//	    mapkubeapis:
+//	      url: https://github.com/helm/helm-mapkubeapis
+//	      version: v0.4.1
+//	  vendorPinnedCharts: false
 
 type MixinConfig struct {
-	ClientVersion      string `yaml:"clientVersion,omitempty"`
-	ClientPlatfrom     string `yaml:"clientPlatfrom,omitempty"`
-	ClientArchitecture string `yaml:"clientArchitecture,omitempty"`
+	ClientVersion      string   `yaml:"clientVersion,omitempty"`
+	ClientPlatfrom     string   `yaml:"clientPlatfrom,omitempty"`
+	ClientArchitecture string   `yaml:"clientArchitecture,omitempty"`
+	ClientBinary       string   `yaml:"clientBinary,omitempty"`
+	ClientAliases      []string `yaml:"clientAliases,omitempty"`
+	KubectlVersion     string   `yaml:"kubectlVersion,omitempty"`
 	Repositories       map[string]Repository
+	SkipRepoUpdate     bool         `yaml:"skipRepoUpdate,omitempty"`
+	AllowUnknownFields bool         `yaml:"allowUnknownFields,omitempty"`
+	StorageDriver      string       `yaml:"storageDriver,omitempty"`
+	HelmSecrets        *HelmSecrets `yaml:"helmSecrets,omitempty"`
+	Cosign             *Cosign      `yaml:"cosign,omitempty"`
+	RegistryAuth       []string     `yaml:"registryAuth,omitempty"`
+	DockerConfig       string       `yaml:"dockerConfig,omitempty"`
+	Tools              []string     `yaml:"tools,omitempty"`
+	// KubeAuthPlugins are the exec auth plugins installed for the kubeconfigs of managed clusters
+	KubeAuthPlugins []string `yaml:"kubeAuthPlugins,omitempty"`
+	// AllowedCommands are helm commands, such as plugins, that custom actions may run in addition to the default ones
+	AllowedCommands []string `yaml:"allowedCommands,omitempty"`
+	// Plugins are helm plugins installed in the invocation image, they are allowed in custom actions
+	Plugins map[string]Plugin `yaml:"plugins,omitempty"`
+	// Conftest is installed to evaluate the policy of install and upgrade steps
+	Conftest *Conftest `yaml:"conftest,omitempty"`
+	// HelmSource and KubectlSource install alternate artifacts instead of the upstream helm and kubectl releases
+	HelmSource    *BinarySource `yaml:"helmSource,omitempty"`
+	KubectlSource *BinarySource `yaml:"kubectlSource,omitempty"`
+	// GlobalFlags are added to every helm command at runtime, mapping each flag to its value, empty for a flag without value
+	GlobalFlags map[string]string `yaml:"globalFlags,omitempty"`
+	// RepositoryConfig and RepositoryCache override the paths of the repository definitions and cache at runtime,
+	// for example to use repositories mounted from a volume
+	RepositoryConfig string `yaml:"repositoryConfig,omitempty"`
+	RepositoryCache  string `yaml:"repositoryCache,omitempty"`
+	// RepoUpdateTTL updates the repositories of the charts of install and upgrade steps at runtime,
+	// when their cached index is older than the duration
+	RepoUpdateTTL string `yaml:"repoUpdateTTL,omitempty"`
+	// FailOnRepoUpdateFail fails the repository updates when a repository can't be updated, instead of using its cached index
+	FailOnRepoUpdateFail bool `yaml:"failOnRepoUpdateFail,omitempty"`
+	// FailOnUndeclaredRepositories fails the build when the chart of a step references a repository that is not
+	// declared, instead of printing a warning
+	FailOnUndeclaredRepositories bool `yaml:"failOnUndeclaredRepositories,omitempty"`
+	// Events records a kubernetes event in the namespace of each release installed, upgraded or uninstalled by the bundle
+	Events bool `yaml:"events,omitempty"`
+	// NamespaceScoped avoids every cluster-scoped call, so that the bundle can run with credentials limited to namespaces
+	NamespaceScoped bool `yaml:"namespaceScoped,omitempty"`
+	// ExperimentalHelm4 allows clientVersion to be a helm 4 release, including alphas, to test bundles with helm 4
+	ExperimentalHelm4 bool `yaml:"experimentalHelm4,omitempty"`
+	// InstallKubectl can be set to false to build the invocation image without kubectl,
+	// secret and configmap outputs are then read with the kubernetes client
+	InstallKubectl *bool `yaml:"installKubectl,omitempty"`
+	// ExecutionBackend selects how helm is run, and ResourceBackend how outputs are read from the cluster
+	ExecutionBackend string `yaml:"executionBackend,omitempty"`
+	ResourceBackend  string `yaml:"resourceBackend,omitempty"`
+	// VendorPinnedCharts pulls the charts pinned to a digest into the invocation image, verifying their digest and the
+	// signature of the steps with verify when the bundle is built
+	VendorPinnedCharts bool `yaml:"vendorPinnedCharts,omitempty"`
+}
+
+// resourceBackend returns the backend that reads outputs, the kubernetes client when kubectl is not installed
+func (c MixinConfig) resourceBackend() string {
+	if c.ResourceBackend != "" {
+		return c.ResourceBackend
+	}
+	if !c.installKubectl() {
+		return clientResourceBackend
+	}
+	return kubectlResourceBackend
+}
+
+// installKubectl checks if kubectl is installed in the invocation image, which is the default
+func (c MixinConfig) installKubectl() bool {
+	return c.InstallKubectl == nil || *c.InstallKubectl
 }
 
 type Repository struct {
 	URL string `yaml:"url,omitempty"`
+	// Private repositories are added with the credentials from the helm-repo-creds build secret
+	Private bool `yaml:"private,omitempty"`
+	// Runtime repositories of a step are added when the step runs instead of when the bundle is built,
+	// with the Username and Password of the step, usually templated from bundle credentials. Private repositories
+	// are added again with them when the step runs, since their credentials are removed when the bundle is built.
+	Runtime  bool   `yaml:"runtime,omitempty"`
+	Username string `yaml:"username,omitempty"`
+	Password string `yaml:"password,omitempty"`
 }
 
 // Build will generate the necessary Dockerfile lines
@@ -48,23 +183,43 @@ func (m *Mixin) Build(ctx context.Context) error {
 
 	// Create new Builder.
 	var input BuildInput
+	var repositories map[string]Repository
+	var actions []byte
 	err := builder.LoadAction(ctx, m.RuntimeConfig, "", func(contents []byte) (interface{}, error) {
 		err := yaml.Unmarshal(contents, &input)
-		return &input, err
+		if err != nil {
+			return &input, err
+		}
+		actions = contents
+		repositories, err = buildRepositories(contents, input.Config)
+		if err != nil || input.Config.AllowUnknownFields {
+			return &input, err
+		}
+		return &input, m.unmarshal(contents, &strictBuildInput{})
 	})
 	if err != nil {
 		return err
 	}
 
 	suppliedClientVersion := input.Config.ClientVersion
+	helm4 := false
 	if suppliedClientVersion != "" {
 		ok, err := validate(suppliedClientVersion, clientVersionConstraint)
 		if err != nil {
 			return err
 		}
+		constraint := clientVersionConstraint
+		if !ok && input.Config.ExperimentalHelm4 {
+			constraint = fmt.Sprintf("%s || %s", clientVersionConstraint, helm4ClientVersionConstraint)
+			helm4, err = validate(suppliedClientVersion, helm4ClientVersionConstraint)
+			if err != nil {
+				return err
+			}
+			ok = helm4
+		}
 		if !ok {
 			return errors.Errorf("supplied clientVersion %q does not meet semver constraint %q",
-				suppliedClientVersion, clientVersionConstraint)
+				suppliedClientVersion, constraint)
 		}
 		m.HelmClientVersion = suppliedClientVersion
 	}
@@ -76,28 +231,280 @@ func (m *Mixin) Build(ctx context.Context) error {
 	if input.Config.ClientArchitecture != "" {
 		m.HelmClientArchitecture = input.Config.ClientArchitecture
 	}
+
+	helmBinary := defaultClientBinary
+	if input.Config.ClientBinary != "" {
+		helmBinary = input.Config.ClientBinary
+	}
+	for _, name := range append([]string{helmBinary}, input.Config.ClientAliases...) {
+		if !isValidBinaryName(name) {
+			return errors.Errorf("supplied helm binary name %q is not a valid file name", name)
+		}
+	}
+
+	if !input.Config.installKubectl() && (input.Config.KubectlVersion != "" || input.Config.KubectlSource != nil) {
+		return errors.New("kubectlVersion and kubectlSource cannot be set when installKubectl is false")
+	}
+	if input.Config.ExecutionBackend != "" && !isSupportedBackend(supportedExecutionBackends, input.Config.ExecutionBackend) {
+		return errors.Errorf("supplied executionBackend %q is not supported, allowed values are: %s",
+			input.Config.ExecutionBackend, strings.Join(supportedExecutionBackends, ", "))
+	}
+	if !isSupportedBackend(supportedResourceBackends, input.Config.resourceBackend()) {
+		return errors.Errorf("supplied resourceBackend %q is not supported, allowed values are: %s",
+			input.Config.ResourceBackend, strings.Join(supportedResourceBackends, ", "))
+	}
+	if !input.Config.installKubectl() && input.Config.resourceBackend() == kubectlResourceBackend {
+		return errors.New("resourceBackend kubectl cannot be used when installKubectl is false")
+	}
+	var kubectlVersion string
+	if input.Config.installKubectl() {
+		kubectlVersion, err = resolveKubectlVersion(ctx, input.Config.KubectlVersion)
+		if err != nil {
+			return err
+		}
+	}
+
+	if input.Config.StorageDriver != "" && !isSupportedStorageDriver(input.Config.StorageDriver) {
+		return errors.Errorf("supplied storageDriver %q is not supported, allowed values are: %s",
+			input.Config.StorageDriver, strings.Join(supportedStorageDrivers, ", "))
+	}
+	if input.Config.DockerConfig != "" && !path.IsAbs(input.Config.DockerConfig) {
+		return errors.Errorf("supplied dockerConfig %q must be an absolute path", input.Config.DockerConfig)
+	}
+	for _, tool := range input.Config.Tools {
+		if !isSupportedTool(tool) {
+			return errors.Errorf("supplied tool %q is not supported, allowed values are: %s",
+				tool, strings.Join(supportedTools, ", "))
+		}
+	}
+	for _, plugin := range input.Config.KubeAuthPlugins {
+		if !isSupportedKubeAuthPlugin(plugin) {
+			return errors.Errorf("supplied kubeAuthPlugins %q is not supported, allowed values are: %s",
+				plugin, strings.Join(supportedKubeAuthPlugins, ", "))
+		}
+	}
+	err = m.validateKubeAuthPlatform(input.Config.KubeAuthPlugins)
+	if err != nil {
+		return err
+	}
+	for _, command := range input.Config.AllowedCommands {
+		if !isValidCommandName(command) {
+			return errors.Errorf("supplied allowed command %q is not a valid helm command name", command)
+		}
+	}
+	err = validatePlugins(input.Config.Plugins)
+	if err != nil {
+		return err
+	}
+	if input.Config.Conftest != nil {
+		err = m.validateConftestPlatform()
+		if err != nil {
+			return err
+		}
+	}
+	if input.Config.HelmSource != nil {
+		err = input.Config.HelmSource.validate("helmSource")
+		if err != nil {
+			return err
+		}
+	}
+	if input.Config.KubectlSource != nil {
+		err = input.Config.KubectlSource.validate("kubectlSource")
+		if err != nil {
+			return err
+		}
+	}
+	err = validateGlobalFlags(input.Config.GlobalFlags)
+	if err != nil {
+		return err
+	}
+	err = validateRepositoryPaths(input.Config.RepositoryConfig, input.Config.RepositoryCache)
+	if err != nil {
+		return err
+	}
+	err = m.validateRepoUpdate(input.Config.RepoUpdateTTL, input.Config.FailOnRepoUpdateFail)
+	if err != nil {
+		return err
+	}
+	for _, helper := range input.Config.RegistryAuth {
+		if _, ok := registryAuthHelpers[helper]; !ok {
+			return errors.Errorf("supplied registryAuth %q is not supported, allowed values are: %s",
+				helper, strings.Join(supportedRegistryAuthHelpers, ", "))
+		}
+	}
+	err = m.validateChartRepositories(actions, repositories, input.Config.FailOnUndeclaredRepositories)
+	if err != nil {
+		return err
+	}
+	var vendoredCharts []vendoredChart
+	if input.Config.VendorPinnedCharts {
+		vendoredCharts, err = pinnedCharts(actions)
+		if err != nil {
+			return err
+		}
+		for _, chart := range vendoredCharts {
+			if len(chart.Verified) > 0 && input.Config.Cosign == nil {
+				return errors.Errorf("chart %s@%s is verified when it is vendored, which requires cosign in the mixin configuration", chart.Chart, chart.Digest)
+			}
+		}
+	}
 	// Install helm3
-	fmt.Fprint(m.Out, "ENV HELM_EXPERIMENTAL_OCI=1")
-	fmt.Fprintf(m.Out, "\nRUN apt-get update && apt-get install -y curl")
-	fmt.Fprintf(m.Out, "\nRUN curl https://get.helm.sh/helm-%s-%s-%s.tar.gz --output helm3.tar.gz",
-		m.HelmClientVersion, m.HelmClientPlatfrom, m.HelmClientArchitecture)
-	fmt.Fprintf(m.Out, "\nRUN tar -xvf helm3.tar.gz && rm helm3.tar.gz")
-	fmt.Fprintf(m.Out, "\nRUN mv linux-amd64/helm /usr/local/bin/helm3")
-	fmt.Fprintf(m.Out, "\nRUN curl -o kubectl https://storage.googleapis.com/kubernetes-release/release/v1.22.1/bin/linux/amd64/kubectl &&\\")
-	fmt.Fprintf(m.Out, "\n    mv kubectl /usr/local/bin && chmod a+x /usr/local/bin/kubectl\n")
-	if len(input.Config.Repositories) > 0 {
+	experimentalOCI, err := validate(m.HelmClientVersion, experimentalOCIConstraint)
+	if err != nil {
+		return err
+	}
+	if experimentalOCI {
+		// OCI support is only generally available since helm 3.8
+		fmt.Fprintln(m.Out, "ENV HELM_EXPERIMENTAL_OCI=1")
+	}
+	if input.Config.AllowUnknownFields {
+		// Relax the decoding of the steps at runtime as well
+		fmt.Fprintf(m.Out, "ENV %s=true\n", allowUnknownFieldsEnv)
+	}
+	if input.Config.StorageDriver != "" {
+		fmt.Fprintf(m.Out, "ENV %s=%s\n", helmDriverEnv, input.Config.StorageDriver)
+	}
+	if len(input.Config.GlobalFlags) > 0 {
+		fmt.Fprintf(m.Out, "ENV %s=%s\n", globalFlagsEnv, encodeGlobalFlags(input.Config.GlobalFlags))
+	}
+	if input.Config.RepositoryConfig != "" {
+		fmt.Fprintf(m.Out, "ENV %s=%s\n", repositoryConfigEnv, input.Config.RepositoryConfig)
+	}
+	if input.Config.RepositoryCache != "" {
+		fmt.Fprintf(m.Out, "ENV %s=%s\n", repositoryCacheEnv, input.Config.RepositoryCache)
+	}
+	if input.Config.RepoUpdateTTL != "" {
+		fmt.Fprintf(m.Out, "ENV %s=%s\n", repoUpdateTTLEnv, input.Config.RepoUpdateTTL)
+	}
+	if input.Config.FailOnRepoUpdateFail {
+		fmt.Fprintf(m.Out, "ENV %s=true\n", failOnRepoUpdateFailEnv)
+	}
+	if input.Config.Events {
+		fmt.Fprintf(m.Out, "ENV %s=true\n", eventsEnv)
+	}
+	if input.Config.NamespaceScoped {
+		fmt.Fprintf(m.Out, "ENV %s=true\n", namespaceScopedEnv)
+	}
+	if helm4 {
+		// Rename the flags of the mixin that changed in helm 4 at runtime
+		fmt.Fprintf(m.Out, "ENV %s=4\n", clientMajorVersionEnv)
+	}
+	if helmBinary != defaultClientBinary {
+		fmt.Fprintf(m.Out, "ENV %s=%s\n", clientBinaryEnv, helmBinary)
+	}
+	if input.Config.KubectlVersion != "" {
+		// Record the resolved version so that it is reported by the version command
+		fmt.Fprintf(m.Out, "ENV %s=%s\n", kubectlVersionEnv, kubectlVersion)
+	}
+	if input.Config.resourceBackend() != kubectlResourceBackend {
+		fmt.Fprintf(m.Out, "ENV %s=%s\n", resourceBackendEnv, input.Config.resourceBackend())
+	}
+	if allowed := append(input.Config.AllowedCommands, pluginNames(input.Config.Plugins)...); len(allowed) > 0 {
+		fmt.Fprintf(m.Out, "ENV %s=%s\n", allowedCommandsEnv, strings.Join(allowed, ","))
+	}
+	if input.Config.DockerConfig != "" {
+		// Helm reads registry logins from the docker config credential mounted at this path
+		fmt.Fprintf(m.Out, "ENV %s=%s\n", helmRegistryConfigEnv, input.Config.DockerConfig)
+	}
+
+	// Download, verify and install helm and kubectl in a single layer, without leaving the archives behind
+	packages := []string{"ca-certificates", "curl"}
+	if (input.Config.HelmSource != nil && input.Config.HelmSource.Signature != "") ||
+		(input.Config.KubectlSource != nil && input.Config.KubectlSource.Signature != "") {
+		packages = append(packages, "gnupg")
+	}
+	install := []string{aptInstall(packages...), "cd /tmp"}
+	var cleanup []string
+	if input.Config.HelmSource != nil {
+		commands, files := input.Config.HelmSource.installCommands("helm-source", "/usr/local/bin/"+helmBinary)
+		install = append(install, commands...)
+		cleanup = append(cleanup, files...)
+	} else {
+		helmArchive := fmt.Sprintf("helm-%s-%s-%s.tar.gz", m.HelmClientVersion, m.HelmClientPlatfrom, m.HelmClientArchitecture)
+		helmDir := fmt.Sprintf("%s-%s", m.HelmClientPlatfrom, m.HelmClientArchitecture)
+		install = append(install,
+			"curl -fsSLO https://get.helm.sh/"+helmArchive,
+			"curl -fsSL https://get.helm.sh/"+helmArchive+".sha256sum | sha256sum -c -",
+			"tar -xzf "+helmArchive+" "+helmDir+"/helm",
+			"mv "+helmDir+"/helm /usr/local/bin/"+helmBinary,
+		)
+		cleanup = append(cleanup, helmArchive, helmDir)
+	}
+	for _, alias := range input.Config.ClientAliases {
+		install = append(install, fmt.Sprintf("ln -s /usr/local/bin/%s /usr/local/bin/%s", helmBinary, alias))
+	}
+	if input.Config.KubectlSource != nil {
+		commands, files := input.Config.KubectlSource.installCommands("kubectl-source", "/usr/local/bin/kubectl")
+		install = append(install, commands...)
+		cleanup = append(cleanup, files...)
+	} else if input.Config.installKubectl() {
+		kubectlURL := fmt.Sprintf("https://storage.googleapis.com/kubernetes-release/release/%s/bin/%s/%s/kubectl",
+			kubectlVersion, m.HelmClientPlatfrom, m.HelmClientArchitecture)
+		install = append(install,
+			"curl -fsSLO "+kubectlURL,
+			`echo "$(curl -fsSL `+kubectlURL+`.sha256)  kubectl" | sha256sum -c -`,
+			"install -m 0755 kubectl /usr/local/bin/kubectl",
+		)
+		cleanup = append(cleanup, "kubectl")
+	}
+	install = append(install, "rm -rf "+strings.Join(cleanup, " "))
+	fmt.Fprintf(m.Out, "RUN %s\n", strings.Join(install, " &&\\\n    "))
+	if input.Config.HelmSecrets != nil {
+		m.buildHelmSecrets(*input.Config.HelmSecrets, helmBinary)
+	}
+	if len(input.Config.Plugins) > 0 {
+		m.buildPlugins(input.Config.Plugins, helmBinary)
+	}
+	if input.Config.Cosign != nil {
+		m.buildCosign(*input.Config.Cosign)
+	}
+	if input.Config.Conftest != nil {
+		m.buildConftest(*input.Config.Conftest)
+	}
+	if len(input.Config.Tools) > 0 {
+		m.buildTools(input.Config.Tools)
+	}
+	if len(input.Config.KubeAuthPlugins) > 0 {
+		m.buildKubeAuthPlugins(input.Config.KubeAuthPlugins)
+	}
+	if len(input.Config.RegistryAuth) > 0 {
+		m.buildRegistryAuth(input.Config.RegistryAuth)
+	}
+	if len(vendoredCharts) > 0 {
+		err = m.buildVendoredCharts(helmBinary, vendoredCharts)
+		if err != nil {
+			return err
+		}
+	}
+	if len(repositories) > 0 {
 		// Switch to a non-root user so helm is configured for the user the container will execute as
 		fmt.Fprintln(m.Out, "USER ${BUNDLE_USER}")
 
 		// Go through repositories
-		names := make([]string, 0, len(input.Config.Repositories))
-		for name := range input.Config.Repositories {
+		names := make([]string, 0, len(repositories))
+		for name := range repositories {
 			names = append(names, name)
 		}
 		sort.Strings(names) //sort by key
+		// Only the repositories that can be added are updated
+		var added []string
 		for _, name := range names {
-			url := input.Config.Repositories[name].URL
-			repositoryCommand, err := getRepositoryCommand(name, url)
+			if repositories[name].URL != "" {
+				added = append(added, name)
+			}
+		}
+		updateCommand, err := m.repoUpdateCommand(helmBinary, added, input.Config.SkipRepoUpdate, input.Config.FailOnRepoUpdateFail)
+		if err != nil {
+			return err
+		}
+		var private []string
+		for _, name := range names {
+			if repositories[name].Private {
+				private = append(private, name)
+				continue
+			}
+			url := repositories[name].URL
+			repositoryCommand, err := getRepositoryCommand(helmBinary, name, url)
 			if err != nil {
 				if m.DebugMode {
 					fmt.Fprintf(m.Err, "DEBUG: addition of repository failed: %s\n", err.Error())
@@ -106,9 +513,14 @@ func (m *Mixin) Build(ctx context.Context) error {
 				fmt.Fprintln(m.Out, strings.Join(repositoryCommand, " "))
 			}
 		}
-		// Make sure we update  the helm repositories
-		// So we don\'t have to do it later
-		fmt.Fprintln(m.Out, "RUN helm3 repo update")
+		if len(private) > 0 {
+			// The private repositories are updated along with the others while their credentials are mounted
+			m.buildPrivateRepositories(helmBinary, private, repositories, updateCommand)
+		} else if updateCommand != "" {
+			// Make sure we update  the helm repositories
+			// So we don\'t have to do it later
+			fmt.Fprintf(m.Out, "RUN %s\n", updateCommand)
+		}
 
 		// Switch back to root so that subsequent mixins can install things
 		fmt.Fprintln(m.Out, "USER root")
@@ -117,7 +529,27 @@ func (m *Mixin) Build(ctx context.Context) error {
 	return nil
 }
 
-func getRepositoryCommand(name, url string) (repositoryCommand []string, err error) {
+func isSupportedStorageDriver(driver string) bool {
+	for _, supported := range supportedStorageDrivers {
+		if driver == supported {
+			return true
+		}
+	}
+	return false
+}
+
+// aptInstall returns the shell command that installs the packages, without keeping the apt lists in the image
+func aptInstall(packages ...string) string {
+	return fmt.Sprintf("apt-get update && apt-get install -y --no-install-recommends %s && rm -rf /var/lib/apt/lists/*",
+		strings.Join(packages, " "))
+}
+
+// isValidBinaryName checks that the helm binary name can be installed in /usr/local/bin
+func isValidBinaryName(name string) bool {
+	return name != "" && name != "." && name != ".." && !strings.ContainsAny(name, "/ \t")
+}
+
+func getRepositoryCommand(helmBinary, name, url string) (repositoryCommand []string, err error) {
 
 	var commandBuilder []string
 
@@ -125,7 +557,7 @@ func getRepositoryCommand(name, url string) (repositoryCommand []string, err err
 		return commandBuilder, fmt.Errorf("repository url must be supplied")
 	}
 
-	commandBuilder = append(commandBuilder, "RUN", "helm3", "repo", "add", name, url)
+	commandBuilder = append(commandBuilder, "RUN", helmBinary, "repo", "add", name, url)
 
 	return commandBuilder, nil
 }
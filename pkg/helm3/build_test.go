@@ -5,6 +5,8 @@ import (
 	"context"
 	"fmt"
 	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -18,13 +20,16 @@ func TestMixin_Build(t *testing.T) {
 	err := m.Build(ctx)
 	require.NoError(t, err)
 
-	buildOutput := `ENV HELM_EXPERIMENTAL_OCI=1
-RUN apt-get update && apt-get install -y curl
-RUN curl https://get.helm.sh/helm-%s-%s-%s.tar.gz --output helm3.tar.gz
-RUN tar -xvf helm3.tar.gz && rm helm3.tar.gz
-RUN mv linux-amd64/helm /usr/local/bin/helm3
-RUN curl -o kubectl https://storage.googleapis.com/kubernetes-release/release/v1.22.1/bin/linux/amd64/kubectl &&\
-    mv kubectl /usr/local/bin && chmod a+x /usr/local/bin/kubectl
+	buildOutput := `RUN apt-get update && apt-get install -y --no-install-recommends ca-certificates curl && rm -rf /var/lib/apt/lists/* &&\
+    cd /tmp &&\
+    curl -fsSLO https://get.helm.sh/helm-%[1]s-%[2]s-%[3]s.tar.gz &&\
+    curl -fsSL https://get.helm.sh/helm-%[1]s-%[2]s-%[3]s.tar.gz.sha256sum | sha256sum -c - &&\
+    tar -xzf helm-%[1]s-%[2]s-%[3]s.tar.gz %[2]s-%[3]s/helm &&\
+    mv %[2]s-%[3]s/helm /usr/local/bin/helm3 &&\
+    curl -fsSLO https://storage.googleapis.com/kubernetes-release/release/v1.22.1/bin/%[2]s/%[3]s/kubectl &&\
+    echo "$(curl -fsSL https://storage.googleapis.com/kubernetes-release/release/v1.22.1/bin/%[2]s/%[3]s/kubectl.sha256)  kubectl" | sha256sum -c - &&\
+    install -m 0755 kubectl /usr/local/bin/kubectl &&\
+    rm -rf helm-%[1]s-%[2]s-%[3]s.tar.gz %[2]s-%[3]s kubectl
 `
 
 	t.Run("build with a valid config", func(t *testing.T) {
@@ -41,13 +46,48 @@ RUN curl -o kubectl https://storage.googleapis.com/kubernetes-release/release/v1
 		wantOutput := fmt.Sprintf(buildOutput, m.HelmClientVersion, m.HelmClientPlatfrom, m.HelmClientArchitecture) +
 			`USER ${BUNDLE_USER}
 RUN helm3 repo add stable kubernetes-charts
-RUN helm3 repo update
+RUN helm3 repo update stable
+USER root
+`
+		gotOutput := m.TestContext.GetOutput()
+		assert.Equal(t, wantOutput, gotOutput)
+	})
+
+	t.Run("build with repository updates", func(t *testing.T) {
+		b, err := ioutil.ReadFile("testdata/build-input-with-valid-config.yaml")
+		require.NoError(t, err)
+		b = bytes.Replace(b, []byte("config:\n"), []byte("config:\n  repoUpdateTTL: 1h\n  failOnRepoUpdateFail: true\n"), 1)
+
+		m := NewTestMixin(t)
+		m.DebugMode = false
+		m.In = bytes.NewReader(b)
+
+		err = m.Build(ctx)
+		require.NoError(t, err, "build failed")
+
+		wantOutput := "ENV PORTER_HELM3_REPO_UPDATE_TTL=1h\nENV PORTER_HELM3_FAIL_ON_REPO_UPDATE_FAIL=true\n" +
+			fmt.Sprintf(buildOutput, m.HelmClientVersion, m.HelmClientPlatfrom, m.HelmClientArchitecture) +
+			`USER ${BUNDLE_USER}
+RUN helm3 repo add stable kubernetes-charts
+RUN helm3 repo update stable --fail-on-repo-update-fail
 USER root
 `
 		gotOutput := m.TestContext.GetOutput()
 		assert.Equal(t, wantOutput, gotOutput)
 	})
 
+	t.Run("build with an invalid repository update ttl", func(t *testing.T) {
+		b, err := ioutil.ReadFile("testdata/build-input-with-valid-config.yaml")
+		require.NoError(t, err)
+		b = bytes.Replace(b, []byte("config:\n"), []byte("config:\n  repoUpdateTTL: 60\n"), 1)
+
+		m := NewTestMixin(t)
+		m.In = bytes.NewReader(b)
+
+		err = m.Build(ctx)
+		require.EqualError(t, err, `invalid repoUpdateTTL "60", expected a duration such as 1h`)
+	})
+
 	t.Run("build with a valid config and multiple repositories", func(t *testing.T) {
 		b, err := ioutil.ReadFile("testdata/build-input-with-valid-config-multi-repos.yaml")
 		require.NoError(t, err)
@@ -64,7 +104,7 @@ USER root
 RUN helm3 repo add harbor https://helm.getharbor.io
 RUN helm3 repo add jetstack https://charts.jetstack.io
 RUN helm3 repo add stable kubernetes-charts
-RUN helm3 repo update
+RUN helm3 repo update harbor jetstack stable
 USER root
 `
 		gotOutput := m.TestContext.GetOutput()
@@ -105,6 +145,23 @@ USER root
 		assert.Equal(t, wantOutput, gotOutput)
 	})
 
+	t.Run("build with a helm client version that needs experimental OCI support", func(t *testing.T) {
+
+		b, err := ioutil.ReadFile("testdata/build-input-with-version.yaml")
+		require.NoError(t, err)
+		b = bytes.Replace(b, []byte("clientVersion: v3.8.2"), []byte("clientVersion: v3.7.2"), 1)
+
+		m := NewTestMixin(t)
+		m.DebugMode = false
+		m.In = bytes.NewReader(b)
+		err = m.Build(ctx)
+		require.NoError(t, err, "build failed")
+		wantOutput := "ENV HELM_EXPERIMENTAL_OCI=1\n" +
+			fmt.Sprintf(buildOutput, m.HelmClientVersion, m.HelmClientPlatfrom, m.HelmClientArchitecture)
+		gotOutput := m.TestContext.GetOutput()
+		assert.Equal(t, wantOutput, gotOutput)
+	})
+
 	t.Run("build with a defined helm client version that does not meet the semver constraint", func(t *testing.T) {
 
 		b, err := ioutil.ReadFile("testdata/build-input-with-unsupported-client-version.yaml")
@@ -117,6 +174,87 @@ USER root
 		require.EqualError(t, err, `supplied clientVersion "v2.16.1" does not meet semver constraint "^v3.x"`)
 	})
 
+	t.Run("build with an experimental helm 4 client version", func(t *testing.T) {
+
+		b, err := ioutil.ReadFile("testdata/build-input-with-version.yaml")
+		require.NoError(t, err)
+		b = bytes.Replace(b, []byte("clientVersion: v3.8.2"), []byte("clientVersion: v4.0.0-alpha.1\n  experimentalHelm4: true"), 1)
+
+		m := NewTestMixin(t)
+		m.DebugMode = false
+		m.In = bytes.NewReader(b)
+		err = m.Build(ctx)
+		require.NoError(t, err, "build failed")
+		wantOutput := "ENV PORTER_HELM3_CLIENT_MAJOR_VERSION=4\n" +
+			fmt.Sprintf(buildOutput, "v4.0.0-alpha.1", m.HelmClientPlatfrom, m.HelmClientArchitecture)
+		gotOutput := m.TestContext.GetOutput()
+		assert.Equal(t, wantOutput, gotOutput)
+	})
+
+	t.Run("build with global flags", func(t *testing.T) {
+
+		b, err := ioutil.ReadFile("testdata/build-input-with-version.yaml")
+		require.NoError(t, err)
+		b = bytes.Replace(b, []byte("clientVersion: v3.8.2"), []byte("clientVersion: v3.8.2\n  globalFlags:\n    --burst-limit: \"200\"\n    --kube-insecure-skip-tls-verify: \"\""), 1)
+
+		m := NewTestMixin(t)
+		m.DebugMode = false
+		m.In = bytes.NewReader(b)
+		err = m.Build(ctx)
+		require.NoError(t, err, "build failed")
+		wantOutput := "ENV PORTER_HELM3_GLOBAL_FLAGS=--burst-limit=200,--kube-insecure-skip-tls-verify\n" +
+			fmt.Sprintf(buildOutput, m.HelmClientVersion, m.HelmClientPlatfrom, m.HelmClientArchitecture)
+		gotOutput := m.TestContext.GetOutput()
+		assert.Equal(t, wantOutput, gotOutput)
+	})
+
+	t.Run("build with events", func(t *testing.T) {
+
+		b, err := ioutil.ReadFile("testdata/build-input-with-version.yaml")
+		require.NoError(t, err)
+		b = bytes.Replace(b, []byte("clientVersion: v3.8.2"), []byte("clientVersion: v3.8.2\n  events: true"), 1)
+
+		m := NewTestMixin(t)
+		m.DebugMode = false
+		m.In = bytes.NewReader(b)
+		err = m.Build(ctx)
+		require.NoError(t, err, "build failed")
+		wantOutput := "ENV PORTER_HELM3_EVENTS=true\n" +
+			fmt.Sprintf(buildOutput, m.HelmClientVersion, m.HelmClientPlatfrom, m.HelmClientArchitecture)
+		gotOutput := m.TestContext.GetOutput()
+		assert.Equal(t, wantOutput, gotOutput)
+	})
+
+	t.Run("build namespace scoped", func(t *testing.T) {
+
+		b, err := ioutil.ReadFile("testdata/build-input-with-version.yaml")
+		require.NoError(t, err)
+		b = bytes.Replace(b, []byte("clientVersion: v3.8.2"), []byte("clientVersion: v3.8.2\n  namespaceScoped: true"), 1)
+
+		m := NewTestMixin(t)
+		m.DebugMode = false
+		m.In = bytes.NewReader(b)
+		err = m.Build(ctx)
+		require.NoError(t, err, "build failed")
+		wantOutput := "ENV PORTER_HELM3_NAMESPACE_SCOPED=true\n" +
+			fmt.Sprintf(buildOutput, m.HelmClientVersion, m.HelmClientPlatfrom, m.HelmClientArchitecture)
+		gotOutput := m.TestContext.GetOutput()
+		assert.Equal(t, wantOutput, gotOutput)
+	})
+
+	t.Run("build with a helm 4 client version without experimentalHelm4", func(t *testing.T) {
+
+		b, err := ioutil.ReadFile("testdata/build-input-with-version.yaml")
+		require.NoError(t, err)
+		b = bytes.Replace(b, []byte("clientVersion: v3.8.2"), []byte("clientVersion: v4.0.0"), 1)
+
+		m := NewTestMixin(t)
+		m.DebugMode = false
+		m.In = bytes.NewReader(b)
+		err = m.Build(ctx)
+		require.EqualError(t, err, `supplied clientVersion "v4.0.0" does not meet semver constraint "^v3.x"`)
+	})
+
 	t.Run("build with a defined helm client version that does not parse as valid semver", func(t *testing.T) {
 
 		b, err := ioutil.ReadFile("testdata/build-input-with-invalid-client-version.yaml")
@@ -128,4 +266,658 @@ USER root
 		err = m.Build(ctx)
 		require.EqualError(t, err, `supplied client version "v3.8.2.0" cannot be parsed as semver: Invalid Semantic Version`)
 	})
+
+	t.Run("build with an unknown config field", func(t *testing.T) {
+
+		b, err := ioutil.ReadFile("testdata/build-input-with-unknown-config.yaml")
+		require.NoError(t, err)
+
+		m := NewTestMixin(t)
+		m.DebugMode = false
+		m.In = bytes.NewReader(b)
+		err = m.Build(ctx)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `unknown field "repositorys"`)
+	})
+
+	t.Run("build with unknown config fields allowed", func(t *testing.T) {
+
+		b, err := ioutil.ReadFile("testdata/build-input-with-allow-unknown-fields.yaml")
+		require.NoError(t, err)
+
+		m := NewTestMixin(t)
+		m.DebugMode = false
+		m.In = bytes.NewReader(b)
+		err = m.Build(ctx)
+		require.NoError(t, err, "build failed")
+		gotOutput := m.TestContext.GetOutput()
+		assert.Contains(t, gotOutput, "ENV PORTER_HELM3_ALLOW_UNKNOWN_FIELDS=true\n")
+	})
+
+	t.Run("build with a storage driver", func(t *testing.T) {
+
+		b, err := ioutil.ReadFile("testdata/build-input-with-storage-driver.yaml")
+		require.NoError(t, err)
+
+		m := NewTestMixin(t)
+		m.DebugMode = false
+		m.In = bytes.NewReader(b)
+		err = m.Build(ctx)
+		require.NoError(t, err, "build failed")
+		gotOutput := m.TestContext.GetOutput()
+		assert.Contains(t, gotOutput, "ENV HELM_DRIVER=sql\n")
+	})
+
+	t.Run("build with a client binary", func(t *testing.T) {
+
+		b, err := ioutil.ReadFile("testdata/build-input-with-client-binary.yaml")
+		require.NoError(t, err)
+
+		m := NewTestMixin(t)
+		m.DebugMode = false
+		m.In = bytes.NewReader(b)
+		err = m.Build(ctx)
+		require.NoError(t, err, "build failed")
+		wantOutput := fmt.Sprintf(`ENV PORTER_HELM3_CLIENT_BINARY=helm
+RUN apt-get update && apt-get install -y --no-install-recommends ca-certificates curl && rm -rf /var/lib/apt/lists/* &&\
+    cd /tmp &&\
+    curl -fsSLO https://get.helm.sh/helm-%[1]s-%[2]s-%[3]s.tar.gz &&\
+    curl -fsSL https://get.helm.sh/helm-%[1]s-%[2]s-%[3]s.tar.gz.sha256sum | sha256sum -c - &&\
+    tar -xzf helm-%[1]s-%[2]s-%[3]s.tar.gz %[2]s-%[3]s/helm &&\
+    mv %[2]s-%[3]s/helm /usr/local/bin/helm &&\
+    ln -s /usr/local/bin/helm /usr/local/bin/helm3 &&\
+    curl -fsSLO https://storage.googleapis.com/kubernetes-release/release/v1.22.1/bin/%[2]s/%[3]s/kubectl &&\
+    echo "$(curl -fsSL https://storage.googleapis.com/kubernetes-release/release/v1.22.1/bin/%[2]s/%[3]s/kubectl.sha256)  kubectl" | sha256sum -c - &&\
+    install -m 0755 kubectl /usr/local/bin/kubectl &&\
+    rm -rf helm-%[1]s-%[2]s-%[3]s.tar.gz %[2]s-%[3]s kubectl
+USER ${BUNDLE_USER}
+RUN helm repo add stable https://charts.helm.sh/stable
+RUN helm repo update stable
+USER root
+`, m.HelmClientVersion, m.HelmClientPlatfrom, m.HelmClientArchitecture)
+		gotOutput := m.TestContext.GetOutput()
+		assert.Equal(t, wantOutput, gotOutput)
+	})
+
+	t.Run("build with an invalid client binary", func(t *testing.T) {
+
+		b, err := ioutil.ReadFile("testdata/build-input-with-client-binary.yaml")
+		require.NoError(t, err)
+		b = bytes.Replace(b, []byte("clientBinary: helm"), []byte("clientBinary: bin/helm"), 1)
+
+		m := NewTestMixin(t)
+		m.In = bytes.NewReader(b)
+		err = m.Build(ctx)
+		require.EqualError(t, err, `supplied helm binary name "bin/helm" is not a valid file name`)
+	})
+
+	t.Run("build with tools", func(t *testing.T) {
+
+		b, err := ioutil.ReadFile("testdata/build-input-with-tools.yaml")
+		require.NoError(t, err)
+
+		m := NewTestMixin(t)
+		m.DebugMode = false
+		m.In = bytes.NewReader(b)
+		err = m.Build(ctx)
+		require.NoError(t, err, "build failed")
+		wantOutput := fmt.Sprintf(buildOutput, m.HelmClientVersion, m.HelmClientPlatfrom, m.HelmClientArchitecture) +
+			`RUN apt-get update && apt-get install -y --no-install-recommends jq && rm -rf /var/lib/apt/lists/*
+RUN curl -L https://github.com/mikefarah/yq/releases/download/v4.30.8/yq_linux_amd64 -o /usr/local/bin/yq &&\
+    chmod a+x /usr/local/bin/yq
+`
+		gotOutput := m.TestContext.GetOutput()
+		assert.Equal(t, wantOutput, gotOutput)
+	})
+
+	t.Run("build with an unsupported tool", func(t *testing.T) {
+
+		b, err := ioutil.ReadFile("testdata/build-input-with-tools.yaml")
+		require.NoError(t, err)
+		b = bytes.Replace(b, []byte("- yq"), []byte("- xq"), 1)
+
+		m := NewTestMixin(t)
+		m.In = bytes.NewReader(b)
+		err = m.Build(ctx)
+		require.EqualError(t, err, `supplied tool "xq" is not supported, allowed values are: jq, yq`)
+	})
+
+	t.Run("build with kube auth plugins", func(t *testing.T) {
+
+		b, err := ioutil.ReadFile("testdata/build-input-with-kube-auth-plugins.yaml")
+		require.NoError(t, err)
+
+		m := NewTestMixin(t)
+		m.DebugMode = false
+		m.In = bytes.NewReader(b)
+		err = m.Build(ctx)
+		require.NoError(t, err, "build failed")
+		wantOutput := fmt.Sprintf(buildOutput, m.HelmClientVersion, m.HelmClientPlatfrom, m.HelmClientArchitecture) +
+			`RUN curl -fsSLO https://github.com/kubernetes-sigs/aws-iam-authenticator/releases/download/v0.6.14/aws-iam-authenticator_0.6.14_linux_amd64 &&\
+    curl -fsSL https://github.com/kubernetes-sigs/aws-iam-authenticator/releases/download/v0.6.14/authenticator_0.6.14_checksums.txt | grep ' aws-iam-authenticator_0.6.14_linux_amd64$' | sha256sum -c - &&\
+    install -m 0755 aws-iam-authenticator_0.6.14_linux_amd64 /usr/local/bin/aws-iam-authenticator &&\
+    rm aws-iam-authenticator_0.6.14_linux_amd64
+RUN apt-get update && apt-get install -y --no-install-recommends unzip && rm -rf /var/lib/apt/lists/* &&\
+    curl -fsSLO https://github.com/Azure/kubelogin/releases/download/v0.1.1/kubelogin-linux-amd64.zip &&\
+    echo "$(curl -fsSL https://github.com/Azure/kubelogin/releases/download/v0.1.1/kubelogin-linux-amd64.zip.sha256 | cut -d ' ' -f1)  kubelogin-linux-amd64.zip" | sha256sum -c - &&\
+    unzip -j kubelogin-linux-amd64.zip bin/linux_amd64/kubelogin -d /usr/local/bin &&\
+    rm kubelogin-linux-amd64.zip
+RUN apt-get update && apt-get install -y --no-install-recommends gnupg && rm -rf /var/lib/apt/lists/* &&\
+    curl -fsSL https://packages.cloud.google.com/apt/doc/apt-key.gpg | gpg --dearmor -o /usr/share/keyrings/cloud.google.gpg &&\
+    echo "deb [signed-by=/usr/share/keyrings/cloud.google.gpg] https://packages.cloud.google.com/apt cloud-sdk main" > /etc/apt/sources.list.d/google-cloud-sdk.list &&\
+    apt-get update && apt-get install -y --no-install-recommends google-cloud-cli-gke-gcloud-auth-plugin && rm -rf /var/lib/apt/lists/*
+ENV USE_GKE_GCLOUD_AUTH_PLUGIN=True
+`
+		gotOutput := m.TestContext.GetOutput()
+		assert.Equal(t, wantOutput, gotOutput)
+	})
+
+	t.Run("build with an unsupported kube auth plugin", func(t *testing.T) {
+
+		b, err := ioutil.ReadFile("testdata/build-input-with-kube-auth-plugins.yaml")
+		require.NoError(t, err)
+		b = bytes.Replace(b, []byte("- kubelogin"), []byte("- oidc-login"), 1)
+
+		m := NewTestMixin(t)
+		m.In = bytes.NewReader(b)
+		err = m.Build(ctx)
+		require.EqualError(t, err, `supplied kubeAuthPlugins "oidc-login" is not supported, allowed values are: aws-iam-authenticator, kubelogin, gke-gcloud-auth-plugin`)
+	})
+
+	t.Run("build kube auth plugins for an unsupported architecture", func(t *testing.T) {
+
+		for _, arch := range []string{"arm", "386"} {
+			b, err := ioutil.ReadFile("testdata/build-input-with-kube-auth-plugins.yaml")
+			require.NoError(t, err)
+			b = bytes.Replace(b, []byte("config:\n"), []byte("config:\n  clientArchitecture: "+arch+"\n"), 1)
+
+			m := NewTestMixin(t)
+			m.In = bytes.NewReader(b)
+			err = m.Build(ctx)
+			require.EqualError(t, err, `supplied kubeAuthPlugins "aws-iam-authenticator" is not available for the `+arch+
+				` architecture, supported architectures are: amd64, arm64`)
+		}
+	})
+
+	t.Run("build with allowed commands", func(t *testing.T) {
+
+		b, err := ioutil.ReadFile("testdata/build-input-with-allowed-commands.yaml")
+		require.NoError(t, err)
+
+		m := NewTestMixin(t)
+		m.DebugMode = false
+		m.In = bytes.NewReader(b)
+		err = m.Build(ctx)
+		require.NoError(t, err, "build failed")
+		wantOutput := "ENV PORTER_HELM3_ALLOWED_COMMANDS=unittest,cm-push\n" +
+			fmt.Sprintf(buildOutput, m.HelmClientVersion, m.HelmClientPlatfrom, m.HelmClientArchitecture)
+		gotOutput := m.TestContext.GetOutput()
+		assert.Equal(t, wantOutput, gotOutput)
+	})
+
+	t.Run("build with an invalid allowed command", func(t *testing.T) {
+
+		b, err := ioutil.ReadFile("testdata/build-input-with-allowed-commands.yaml")
+		require.NoError(t, err)
+		b = bytes.Replace(b, []byte("- cm-push"), []byte("- cm-push; rm"), 1)
+
+		m := NewTestMixin(t)
+		m.In = bytes.NewReader(b)
+		err = m.Build(ctx)
+		require.EqualError(t, err, `supplied allowed command "cm-push; rm" is not a valid helm command name`)
+	})
+
+	t.Run("build with plugins", func(t *testing.T) {
+
+		b, err := ioutil.ReadFile("testdata/build-input-with-plugins.yaml")
+		require.NoError(t, err)
+
+		m := NewTestMixin(t)
+		m.DebugMode = false
+		m.In = bytes.NewReader(b)
+		err = m.Build(ctx)
+		require.NoError(t, err, "build failed")
+		wantOutput := "ENV PORTER_HELM3_ALLOWED_COMMANDS=diff,mapkubeapis\n" +
+			fmt.Sprintf(buildOutput, m.HelmClientVersion, m.HelmClientPlatfrom, m.HelmClientArchitecture) +
+			`USER ${BUNDLE_USER}
+RUN helm3 plugin install https://github.com/databus23/helm-diff
+RUN helm3 plugin install https://github.com/helm/helm-mapkubeapis --version v0.4.1
+USER root
+`
+		gotOutput := m.TestContext.GetOutput()
+		assert.Equal(t, wantOutput, gotOutput)
+	})
+
+	t.Run("build with a plugin without url", func(t *testing.T) {
+
+		b, err := ioutil.ReadFile("testdata/build-input-with-plugins.yaml")
+		require.NoError(t, err)
+		b = bytes.Replace(b, []byte("url: https://github.com/databus23/helm-diff"), []byte("version: v3.6.0"), 1)
+
+		m := NewTestMixin(t)
+		m.In = bytes.NewReader(b)
+		err = m.Build(ctx)
+		require.EqualError(t, err, `supplied plugin "diff" must set the url it is installed from`)
+	})
+
+	t.Run("build with the stable kubectl version", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprintln(w, "v1.25.4")
+		}))
+		defer server.Close()
+		defer func(url string) { kubectlStableURL = url }(kubectlStableURL)
+		kubectlStableURL = server.URL
+
+		b, err := ioutil.ReadFile("testdata/build-input-with-kubectl-stable.yaml")
+		require.NoError(t, err)
+
+		m := NewTestMixin(t)
+		m.DebugMode = false
+		m.In = bytes.NewReader(b)
+		err = m.Build(ctx)
+		require.NoError(t, err, "build failed")
+		gotOutput := m.TestContext.GetOutput()
+		assert.Contains(t, gotOutput, "ENV PORTER_HELM3_KUBECTL_VERSION=v1.25.4\n")
+		assert.Contains(t, gotOutput, "curl -fsSLO https://storage.googleapis.com/kubernetes-release/release/v1.25.4/bin/linux/amd64/kubectl &&")
+	})
+
+	t.Run("build with an invalid kubectl version", func(t *testing.T) {
+
+		b, err := ioutil.ReadFile("testdata/build-input-with-kubectl-stable.yaml")
+		require.NoError(t, err)
+		b = bytes.Replace(b, []byte("kubectlVersion: stable"), []byte("kubectlVersion: latest"), 1)
+
+		m := NewTestMixin(t)
+		m.In = bytes.NewReader(b)
+		err = m.Build(ctx)
+		require.EqualError(t, err, `supplied kubectlVersion "latest" must be stable or a version starting with v`)
+	})
+
+	t.Run("build with step repositories", func(t *testing.T) {
+
+		b, err := ioutil.ReadFile("testdata/build-input-with-step-repositories.yaml")
+		require.NoError(t, err)
+
+		m := NewTestMixin(t)
+		m.DebugMode = false
+		m.In = bytes.NewReader(b)
+		err = m.Build(ctx)
+		require.NoError(t, err, "build failed")
+		wantOutput := fmt.Sprintf(buildOutput, m.HelmClientVersion, m.HelmClientPlatfrom, m.HelmClientArchitecture) +
+			`USER ${BUNDLE_USER}
+RUN helm3 repo add bitnami https://charts.bitnami.com/bitnami
+RUN helm3 repo add stable https://charts.helm.sh/stable
+RUN helm3 repo update bitnami stable
+USER root
+`
+		gotOutput := m.TestContext.GetOutput()
+		assert.Equal(t, wantOutput, gotOutput)
+	})
+
+	t.Run("build with runtime step repositories", func(t *testing.T) {
+
+		b, err := ioutil.ReadFile("testdata/build-input-with-step-repositories.yaml")
+		require.NoError(t, err)
+		b = bytes.Replace(b, []byte("      repositories:\n"), []byte(`      repositories:
+        private:
+          url: "https://charts.example.com"
+          runtime: true
+          username: "{{ bundle.credentials.chart-username }}"
+          password: "{{ bundle.credentials.chart-password }}"
+`), -1)
+
+		m := NewTestMixin(t)
+		m.DebugMode = false
+		m.In = bytes.NewReader(b)
+		err = m.Build(ctx)
+		require.NoError(t, err, "build failed")
+		wantOutput := fmt.Sprintf(buildOutput, m.HelmClientVersion, m.HelmClientPlatfrom, m.HelmClientArchitecture) +
+			`USER ${BUNDLE_USER}
+RUN helm3 repo add bitnami https://charts.bitnami.com/bitnami
+RUN helm3 repo add stable https://charts.helm.sh/stable
+RUN helm3 repo update bitnami stable
+USER root
+`
+		gotOutput := m.TestContext.GetOutput()
+		assert.Equal(t, wantOutput, gotOutput)
+	})
+
+	t.Run("build with a runtime repository in the mixin configuration", func(t *testing.T) {
+
+		b, err := ioutil.ReadFile("testdata/build-input-with-step-repositories.yaml")
+		require.NoError(t, err)
+		b = bytes.Replace(b, []byte(`url: "https://charts.helm.sh/stable"`), []byte(`url: "https://charts.helm.sh/stable"
+      runtime: true`), 1)
+
+		m := NewTestMixin(t)
+		m.In = bytes.NewReader(b)
+		err = m.Build(ctx)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `repository "stable" of the mixin configuration cannot be added at runtime, declare it on the steps that use it`)
+	})
+
+	t.Run("build with a runtime repository named after a build repository", func(t *testing.T) {
+
+		b, err := ioutil.ReadFile("testdata/build-input-with-step-repositories.yaml")
+		require.NoError(t, err)
+		b = bytes.Replace(b, []byte("      repositories:\n"), []byte(`      repositories:
+        stable:
+          url: "https://charts.example.com"
+          runtime: true
+`), 1)
+
+		m := NewTestMixin(t)
+		m.In = bytes.NewReader(b)
+		err = m.Build(ctx)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `runtime repository "stable" of step 1 (Install MySQL) of the install action has the name of a repository added when the bundle is built`)
+	})
+
+	t.Run("build with a chart from an undeclared repository", func(t *testing.T) {
+
+		b, err := ioutil.ReadFile("testdata/build-input-with-version.yaml")
+		require.NoError(t, err)
+
+		m := NewTestMixin(t)
+		m.DebugMode = false
+		m.In = bytes.NewReader(b)
+		err = m.Build(ctx)
+		require.NoError(t, err, "build failed")
+		assert.Contains(t, m.TestContext.GetError(), `WARNING: the chart "stable/mysql" of step 1 (Install MySQL) of the install action references the repository "stable", which is not declared in the repositories of the mixin configuration or of the step`)
+	})
+
+	t.Run("build failing on a chart from an undeclared repository", func(t *testing.T) {
+
+		b, err := ioutil.ReadFile("testdata/build-input-with-undeclared-repository.yaml")
+		require.NoError(t, err)
+
+		m := NewTestMixin(t)
+		m.In = bytes.NewReader(b)
+		err = m.Build(ctx)
+		require.EqualError(t, err, `the chart "bitnami/mysql" of step 1 (Install MySQL) of the install action references the repository "bitnami", which is not declared in the repositories of the mixin configuration or of the step`)
+	})
+
+	t.Run("build with an uninstall step", func(t *testing.T) {
+
+		b, err := ioutil.ReadFile("testdata/build-input-with-version.yaml")
+		require.NoError(t, err)
+		b = append(b, []byte(`uninstall:
+  - helm3:
+      description: "Uninstall MySQL"
+      releases:
+        - mysql
+`)...)
+
+		m := NewTestMixin(t)
+		m.DebugMode = false
+		m.In = bytes.NewReader(b)
+		err = m.Build(ctx)
+		require.NoError(t, err, "build failed")
+	})
+
+	t.Run("build with a local chart", func(t *testing.T) {
+
+		b, err := ioutil.ReadFile("testdata/build-input-with-undeclared-repository.yaml")
+		require.NoError(t, err)
+		b = bytes.Replace(b, []byte("chart: bitnami/mysql"), []byte("chart: charts/mysql"), 1)
+
+		m := NewTestMixin(t)
+		m.DebugMode = false
+		m.In = bytes.NewReader(b)
+		require.NoError(t, m.FileSystem.MkdirAll("charts/mysql", 0700))
+		err = m.Build(ctx)
+		require.NoError(t, err, "build failed")
+	})
+
+	t.Run("build with conflicting step repositories", func(t *testing.T) {
+
+		b, err := ioutil.ReadFile("testdata/build-input-with-step-repositories.yaml")
+		require.NoError(t, err)
+		b = bytes.Replace(b, []byte("https://charts.bitnami.com/bitnami"), []byte("https://example.com/bitnami"), 1)
+
+		m := NewTestMixin(t)
+		m.In = bytes.NewReader(b)
+		err = m.Build(ctx)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `repository "bitnami" is declared with different urls: https://example.com/bitnami and https://charts.bitnami.com/bitnami`)
+	})
+
+	t.Run("build with private repositories", func(t *testing.T) {
+
+		b, err := ioutil.ReadFile("testdata/build-input-with-private-repositories.yaml")
+		require.NoError(t, err)
+
+		m := NewTestMixin(t)
+		m.DebugMode = false
+		m.In = bytes.NewReader(b)
+		err = m.Build(ctx)
+		require.NoError(t, err, "build failed")
+		wantOutput := fmt.Sprintf(buildOutput, m.HelmClientVersion, m.HelmClientPlatfrom, m.HelmClientArchitecture) +
+			`USER ${BUNDLE_USER}
+RUN helm3 repo add stable https://charts.helm.sh/stable
+RUN --mount=type=secret,id=helm-repo-creds,mode=0444 . /run/secrets/helm-repo-creds &&\
+    printf '%s' "$HELM_REPO_MY_CHARTS_PASSWORD" | helm3 repo add my-charts https://charts.example.com --username "$HELM_REPO_MY_CHARTS_USERNAME" --password-stdin &&\
+    helm3 repo update my-charts stable &&\
+    sed -i -e 's/^  password: .*/  password: ""/' -e 's/^  username: .*/  username: ""/' "$(helm3 env HELM_REPOSITORY_CONFIG)"
+USER root
+`
+		gotOutput := m.TestContext.GetOutput()
+		assert.Equal(t, wantOutput, gotOutput)
+	})
+
+	t.Run("build without repository update", func(t *testing.T) {
+
+		b, err := ioutil.ReadFile("testdata/build-input-with-skip-repo-update.yaml")
+		require.NoError(t, err)
+
+		m := NewTestMixin(t)
+		m.DebugMode = false
+		m.In = bytes.NewReader(b)
+		err = m.Build(ctx)
+		require.NoError(t, err, "build failed")
+		wantOutput := fmt.Sprintf(buildOutput, m.HelmClientVersion, m.HelmClientPlatfrom, m.HelmClientArchitecture) +
+			`USER ${BUNDLE_USER}
+RUN helm3 repo add stable https://charts.helm.sh/stable
+USER root
+`
+		gotOutput := m.TestContext.GetOutput()
+		assert.Equal(t, wantOutput, gotOutput)
+	})
+
+	t.Run("build with a client that updates every repository", func(t *testing.T) {
+
+		b, err := ioutil.ReadFile("testdata/build-input-with-skip-repo-update.yaml")
+		require.NoError(t, err)
+		b = bytes.Replace(b, []byte("skipRepoUpdate: true"), []byte("clientVersion: v3.6.3"), 1)
+
+		m := NewTestMixin(t)
+		m.DebugMode = false
+		m.In = bytes.NewReader(b)
+		err = m.Build(ctx)
+		require.NoError(t, err, "build failed")
+		gotOutput := m.TestContext.GetOutput()
+		assert.Contains(t, gotOutput, "RUN helm3 repo add stable https://charts.helm.sh/stable\nRUN helm3 repo update\nUSER root\n")
+	})
+
+	t.Run("build with a docker config", func(t *testing.T) {
+
+		b, err := ioutil.ReadFile("testdata/build-input-with-docker-config.yaml")
+		require.NoError(t, err)
+
+		m := NewTestMixin(t)
+		m.DebugMode = false
+		m.In = bytes.NewReader(b)
+		err = m.Build(ctx)
+		require.NoError(t, err, "build failed")
+		gotOutput := m.TestContext.GetOutput()
+		assert.Contains(t, gotOutput, "ENV HELM_REGISTRY_CONFIG=/root/.docker/config.json\n")
+	})
+
+	t.Run("build with an unsupported storage driver", func(t *testing.T) {
+
+		b, err := ioutil.ReadFile("testdata/build-input-with-unsupported-storage-driver.yaml")
+		require.NoError(t, err)
+
+		m := NewTestMixin(t)
+		m.DebugMode = false
+		m.In = bytes.NewReader(b)
+		err = m.Build(ctx)
+		require.EqualError(t, err, `supplied storageDriver "etcd" is not supported, allowed values are: secret, configmap, sql`)
+	})
+
+	t.Run("build with binary sources", func(t *testing.T) {
+
+		b, err := ioutil.ReadFile("testdata/build-input-with-binary-sources.yaml")
+		require.NoError(t, err)
+
+		m := NewTestMixin(t)
+		m.DebugMode = false
+		m.In = bytes.NewReader(b)
+		err = m.Build(ctx)
+		require.NoError(t, err, "build failed")
+		wantOutput := `ENV PORTER_HELM3_CLIENT_BINARY=helm
+RUN apt-get update && apt-get install -y --no-install-recommends ca-certificates curl gnupg && rm -rf /var/lib/apt/lists/* &&\
+    cd /tmp &&\
+    curl -fsSL -o helm-source https://artifacts.example.com/helm/helm-v3.8.2-fips-linux-amd64.tar.gz &&\
+    echo '9a9b3a3f7b9a6f8a0f8c8c4f2f0c4b8e3a1a2b3c4d5e6f708192a3b4c5d6e7f8  helm-source' | sha256sum -c - &&\
+    curl -fsSL -o helm-source.asc https://artifacts.example.com/helm/helm-v3.8.2-fips-linux-amd64.tar.gz.asc &&\
+    curl -fsSL https://artifacts.example.com/keys/release.asc | gpg --batch --import &&\
+    gpg --batch --verify helm-source.asc helm-source &&\
+    tar -xzf helm-source linux-amd64/helm &&\
+    install -m 0755 linux-amd64/helm /usr/local/bin/helm &&\
+    curl -fsSL -o kubectl-source https://artifacts.example.com/kubectl/kubectl-v1.22.1-fips &&\
+    echo '0f1e2d3c4b5a69788796a5b4c3d2e1f00f1e2d3c4b5a69788796a5b4c3d2e1f0  kubectl-source' | sha256sum -c - &&\
+    install -m 0755 kubectl-source /usr/local/bin/kubectl &&\
+    rm -rf helm-source helm-source.asc linux-amd64/helm kubectl-source
+`
+		gotOutput := m.TestContext.GetOutput()
+		assert.Equal(t, wantOutput, gotOutput)
+	})
+
+	t.Run("build with an invalid binary source", func(t *testing.T) {
+
+		b, err := ioutil.ReadFile("testdata/build-input-with-invalid-binary-source.yaml")
+		require.NoError(t, err)
+
+		m := NewTestMixin(t)
+		m.DebugMode = false
+		m.In = bytes.NewReader(b)
+		err = m.Build(ctx)
+		require.EqualError(t, err, "supplied helmSource requires both a signature and a key to verify the signature")
+	})
+
+	t.Run("build without kubectl", func(t *testing.T) {
+
+		b, err := ioutil.ReadFile("testdata/build-input-without-kubectl.yaml")
+		require.NoError(t, err)
+
+		m := NewTestMixin(t)
+		m.DebugMode = false
+		m.In = bytes.NewReader(b)
+		err = m.Build(ctx)
+		require.NoError(t, err, "build failed")
+		gotOutput := m.TestContext.GetOutput()
+		assert.Contains(t, gotOutput, "ENV PORTER_HELM3_RESOURCE_BACKEND=client\n")
+		assert.NotContains(t, gotOutput, "kubectl ")
+		assert.Contains(t, gotOutput, "rm -rf helm-v")
+	})
+
+	t.Run("build with an unsupported backend", func(t *testing.T) {
+
+		b, err := ioutil.ReadFile("testdata/build-input-with-unsupported-backend.yaml")
+		require.NoError(t, err)
+
+		m := NewTestMixin(t)
+		m.DebugMode = false
+		m.In = bytes.NewReader(b)
+		err = m.Build(ctx)
+		require.EqualError(t, err, `supplied executionBackend "sdk" is not supported, allowed values are: cli`)
+	})
+
+	t.Run("build with helm-secrets", func(t *testing.T) {
+
+		b, err := ioutil.ReadFile("testdata/build-input-with-helm-secrets.yaml")
+		require.NoError(t, err)
+
+		m := NewTestMixin(t)
+		m.DebugMode = false
+		m.In = bytes.NewReader(b)
+		err = m.Build(ctx)
+		require.NoError(t, err, "build failed")
+		wantOutput := fmt.Sprintf(buildOutput, m.HelmClientVersion, m.HelmClientPlatfrom, m.HelmClientArchitecture) +
+			`RUN apt-get update && apt-get install -y --no-install-recommends gnupg && rm -rf /var/lib/apt/lists/*
+RUN curl -L https://github.com/mozilla/sops/releases/download/v3.7.3/sops-v3.7.3.linux.amd64 -o /usr/local/bin/sops &&\
+    chmod a+x /usr/local/bin/sops
+USER ${BUNDLE_USER}
+RUN helm3 plugin install https://github.com/jkroepke/helm-secrets/releases/download/v4.1.1/helm-secrets.tar.gz
+USER root
+`
+		gotOutput := m.TestContext.GetOutput()
+		assert.Equal(t, wantOutput, gotOutput)
+	})
+
+	t.Run("build with cosign", func(t *testing.T) {
+
+		b, err := ioutil.ReadFile("testdata/build-input-with-cosign.yaml")
+		require.NoError(t, err)
+
+		m := NewTestMixin(t)
+		m.DebugMode = false
+		m.In = bytes.NewReader(b)
+		err = m.Build(ctx)
+		require.NoError(t, err, "build failed")
+		wantOutput := fmt.Sprintf(buildOutput, m.HelmClientVersion, m.HelmClientPlatfrom, m.HelmClientArchitecture) +
+			`RUN curl -L https://github.com/sigstore/cosign/releases/download/v2.2.4/cosign-linux-amd64 -o /usr/local/bin/cosign &&\
+    chmod a+x /usr/local/bin/cosign
+`
+		gotOutput := m.TestContext.GetOutput()
+		assert.Equal(t, wantOutput, gotOutput)
+	})
+
+	t.Run("build with conftest", func(t *testing.T) {
+
+		b, err := ioutil.ReadFile("testdata/build-input-with-conftest.yaml")
+		require.NoError(t, err)
+
+		m := NewTestMixin(t)
+		m.DebugMode = false
+		m.In = bytes.NewReader(b)
+		err = m.Build(ctx)
+		require.NoError(t, err, "build failed")
+		wantOutput := fmt.Sprintf(buildOutput, m.HelmClientVersion, m.HelmClientPlatfrom, m.HelmClientArchitecture) +
+			`RUN curl -fsSL https://github.com/open-policy-agent/conftest/releases/download/v0.45.0/conftest_0.45.0_Linux_x86_64.tar.gz |\
+    tar -xz -C /usr/local/bin conftest
+`
+		gotOutput := m.TestContext.GetOutput()
+		assert.Equal(t, wantOutput, gotOutput)
+	})
+
+	t.Run("build with registry auth", func(t *testing.T) {
+
+		b, err := ioutil.ReadFile("testdata/build-input-with-registry-auth.yaml")
+		require.NoError(t, err)
+
+		m := NewTestMixin(t)
+		m.DebugMode = false
+		m.In = bytes.NewReader(b)
+		err = m.Build(ctx)
+		require.NoError(t, err, "build failed")
+		wantOutput := fmt.Sprintf(buildOutput, m.HelmClientVersion, m.HelmClientPlatfrom, m.HelmClientArchitecture) +
+			`ENV PORTER_HELM3_REGISTRY_AUTH=ecr,acr
+RUN apt-get update && apt-get install -y --no-install-recommends awscli && rm -rf /var/lib/apt/lists/*
+`
+		gotOutput := m.TestContext.GetOutput()
+		assert.Equal(t, wantOutput, gotOutput)
+	})
+
+	t.Run("build with unsupported registry auth", func(t *testing.T) {
+
+		b, err := ioutil.ReadFile("testdata/build-input-with-unsupported-registry-auth.yaml")
+		require.NoError(t, err)
+
+		m := NewTestMixin(t)
+		m.In = bytes.NewReader(b)
+		err = m.Build(ctx)
+		require.EqualError(t, err, `supplied registryAuth "quay" is not supported, allowed values are: ecr, acr, gar`)
+	})
 }
@@ -0,0 +1,143 @@
+package helm3
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultCanarySuffix      = "canary"
+	defaultCanaryWeightValue = "canary.weight"
+)
+
+// Canary upgrades a release by deploying the new chart version as a parallel release first, which receives a weight
+// of the traffic through the chart values, and promoting it to the primary release once it is healthy
+type Canary struct {
+	// Suffix is appended to the name of the release to name the canary release, canary by default
+	Suffix string `yaml:"suffix,omitempty"`
+	// Weight is the percentage of the traffic sent to the canary release
+	Weight int `yaml:"weight,omitempty"`
+	// WeightValue is the chart value that receives the weight, canary.weight by default
+	WeightValue string `yaml:"weightValue,omitempty"`
+	// Set are additional values of the canary release
+	Set map[string]string `yaml:"set,omitempty"`
+	// Timeout is the time to wait for the canary release to become ready
+	Timeout string `yaml:"timeout,omitempty"`
+	// Test runs the tests of the chart against the canary release before it is promoted
+	Test bool `yaml:"test,omitempty"`
+}
+
+// validate checks the canary settings
+func (c Canary) validate() error {
+	if c.Weight < 0 || c.Weight > 100 {
+		return errors.Errorf("canary weight %d must be between 0 and 100", c.Weight)
+	}
+	if !isValidReleaseSuffix(c.suffix()) {
+		return errors.Errorf("canary suffix %q must only contain lowercase letters, digits and dashes", c.Suffix)
+	}
+	return nil
+}
+
+func (c Canary) suffix() string {
+	if c.Suffix == "" {
+		return defaultCanarySuffix
+	}
+	return c.Suffix
+}
+
+// release returns the arguments of the canary release of a release
+func (c Canary) release(primary UpgradeArguments) UpgradeArguments {
+	canary := primary
+	canary.Name = fmt.Sprintf("%s-%s", primary.Name, c.suffix())
+	// the canary is always a new release, deployed once its resources are ready
+	installIfMissing := true
+	canary.InstallIfMissing = &installIfMissing
+	canary.ResetValues, canary.ReuseValues, canary.ResetThenReuseValues = false, false, false
+	canary.Wait = true
+	if c.Timeout != "" {
+		canary.Timeout = c.Timeout
+	}
+
+	weightValue := c.WeightValue
+	if weightValue == "" {
+		weightValue = defaultCanaryWeightValue
+	}
+	canary.Set = make(map[string]string, len(primary.Set)+len(c.Set)+1)
+	for k, v := range primary.Set {
+		canary.Set[k] = v
+	}
+	for k, v := range c.Set {
+		canary.Set[k] = v
+	}
+	canary.Set[weightValue] = strconv.Itoa(c.Weight)
+	return canary
+}
+
+func isValidReleaseSuffix(suffix string) bool {
+	return !invalidReleaseNameChars.MatchString(suffix)
+}
+
+// deployCanary deploys the canary release of a release and waits until it is healthy,
+// the canary release is removed when it fails so that the primary release keeps all the traffic
+func (m *Mixin) deployCanary(ctx context.Context, canary Canary, primary UpgradeArguments) error {
+	release := canary.release(primary)
+	fmt.Fprintf(m.Out, "Deploying the canary release %s of release %s\n", release.Name, primary.Name)
+
+	_, err := m.upgrade(ctx, release)
+	if err == nil && canary.Test {
+		err = m.testCanary(ctx, release)
+	}
+	if err != nil {
+		return m.abortCanary(ctx, release, errors.Wrapf(err, "canary release %s failed, the upgrade of release %s was aborted", release.Name, primary.Name))
+	}
+	return nil
+}
+
+// testCanary runs the tests of the chart against the canary release
+func (m *Mixin) testCanary(ctx context.Context, release UpgradeArguments) error {
+	ctx, cancel := withTimeout(ctx, release.Timeout)
+	defer cancel()
+
+	cmd := m.helmCommand(ctx, "test", release.Name)
+	if release.Namespace != "" {
+		cmd.Args = append(cmd.Args, "--namespace", release.Namespace)
+	}
+	cmd.Args = appendTimeout(cmd.Args, release.Timeout)
+	m.setStepEnv(cmd, release.Env)
+	return m.runCommand(cmd)
+}
+
+// abortCanary removes the canary release after a failure, and returns the failure
+func (m *Mixin) abortCanary(ctx context.Context, release UpgradeArguments, failure error) error {
+	fmt.Fprintf(m.Out, "Removing the canary release %s\n", release.Name)
+	err := m.removeCanary(ctx, release)
+	if err != nil {
+		return errors.Wrapf(failure, "the canary release %s could not be removed: %s", release.Name, err)
+	}
+	return failure
+}
+
+// removeCanary uninstalls the canary release once the primary release was upgraded, or the canary failed
+func (m *Mixin) removeCanary(ctx context.Context, release UpgradeArguments) error {
+	return m.delete(ctx, release.Name, UninstallArguments{
+		Step:      Step{Env: release.Env},
+		Namespace: release.Namespace,
+		Wait:      true,
+		Timeout:   release.Timeout,
+	})
+}
+
+// promoteCanary removes the canary release once the primary release was upgraded to the same chart version,
+// or aborts the canary when the upgrade of the primary release failed
+func (m *Mixin) promoteCanary(ctx context.Context, canary Canary, primary UpgradeArguments, upgradeErr error) error {
+	release := canary.release(primary)
+	if upgradeErr != nil {
+		return m.abortCanary(ctx, release, upgradeErr)
+	}
+
+	fmt.Fprintf(m.Out, "Promoted the canary release %s to release %s, removing it\n", release.Name, primary.Name)
+	return errors.Wrapf(m.removeCanary(ctx, release), "could not remove the canary release %s", release.Name)
+}
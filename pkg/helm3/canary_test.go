@@ -0,0 +1,97 @@
+package helm3
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"get.porter.sh/porter/pkg/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestCanary_Validate(t *testing.T) {
+	require.NoError(t, Canary{Weight: 10}.validate())
+	require.EqualError(t, Canary{Weight: 110}.validate(), "canary weight 110 must be between 0 and 100")
+	require.EqualError(t, Canary{Suffix: "Canary_1"}.validate(), `canary suffix "Canary_1" must only contain lowercase letters, digits and dashes`)
+}
+
+func TestCanary_Release(t *testing.T) {
+	primary := UpgradeArguments{
+		Name:        "mysql",
+		Chart:       "stable/mysql",
+		ReuseValues: true,
+		Set:         map[string]string{"replicaCount": "3"},
+	}
+	canary := Canary{Suffix: "next", Weight: 20, WeightValue: "traffic.weight", Set: map[string]string{"replicaCount": "1"}, Timeout: "2m"}.release(primary)
+
+	assert.Equal(t, "mysql-next", canary.Name)
+	assert.True(t, canary.Wait)
+	assert.False(t, canary.ReuseValues)
+	assert.True(t, canary.installIfMissing())
+	assert.Equal(t, "2m", canary.Timeout)
+	assert.Equal(t, map[string]string{"replicaCount": "1", "traffic.weight": "20"}, canary.Set)
+	assert.Equal(t, map[string]string{"replicaCount": "3"}, primary.Set, "the values of the primary release should not change")
+}
+
+func TestMixin_UpgradeCanary(t *testing.T) {
+	ctx := context.Background()
+	defer os.Unsetenv(test.ExpectedCommandEnv)
+	os.Setenv(test.ExpectedCommandEnv, strings.Join([]string{
+		"helm3 upgrade --install mysql-canary stable/mysql --namespace db --wait --atomic --create-namespace --output json --set canary.weight=10",
+		"helm3 test mysql-canary --namespace db",
+		"helm3 upgrade --install mysql stable/mysql --namespace db --atomic --create-namespace --output json",
+		"helm3 uninstall mysql-canary --namespace db --wait",
+	}, "\n"))
+
+	action := UpgradeAction{Steps: []UpgradeStep{{
+		UpgradeArguments: UpgradeArguments{
+			Step:      Step{Description: "Upgrade MySQL"},
+			Name:      "mysql",
+			Chart:     "stable/mysql",
+			Namespace: "db",
+			Canary:    &Canary{Weight: 10, Test: true},
+		},
+	}}}
+	b, _ := yaml.Marshal(action)
+
+	h := NewTestMixin(t)
+	h.In = bytes.NewReader(b)
+
+	err := h.Upgrade(ctx)
+	require.NoError(t, err)
+	assert.Contains(t, h.TestContext.GetOutput(), "Promoted the canary release mysql-canary to release mysql, removing it")
+}
+
+func TestMixin_UpgradeCanaryAborted(t *testing.T) {
+	ctx := context.Background()
+	defer os.Unsetenv(test.ExpectedCommandEnv)
+	defer os.Unsetenv(test.ExpectedCommandExitCodeEnv)
+	os.Setenv(test.ExpectedCommandEnv, strings.Join([]string{
+		"helm3 upgrade --install mysql-canary stable/mysql --wait --atomic --create-namespace --output json --set canary.weight=0",
+		"helm3 uninstall mysql-canary --wait",
+	}, "\n"))
+	os.Setenv(test.ExpectedCommandExitCodeEnv, "1")
+
+	action := UpgradeAction{Steps: []UpgradeStep{{
+		UpgradeArguments: UpgradeArguments{
+			Step:   Step{Description: "Upgrade MySQL"},
+			Name:   "mysql",
+			Chart:  "stable/mysql",
+			Canary: &Canary{},
+		},
+	}}}
+	b, _ := yaml.Marshal(action)
+
+	h := NewTestMixin(t)
+	h.In = bytes.NewReader(b)
+
+	err := h.Upgrade(ctx)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "canary release mysql-canary failed, the upgrade of release mysql was aborted")
+	assert.Contains(t, h.TestContext.GetOutput(), "Removing the canary release mysql-canary")
+	assert.NotContains(t, h.TestContext.GetOutput(), "upgrade --install mysql stable/mysql")
+}
@@ -0,0 +1,140 @@
+package helm3
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// interruptGracePeriod is the time given to helm to exit once it is interrupted, before it is killed
+var interruptGracePeriod = 10 * time.Second
+
+// interruptStatusTimeout bounds the time spent reading the status of a release after helm was interrupted
+const interruptStatusTimeout = 15 * time.Second
+
+// uncancelledContext keeps the values of a context without its cancellation, so that helm is not killed
+// when the action is cancelled, and can be interrupted by waitInterruptible instead
+type uncancelledContext struct {
+	context.Context
+}
+
+func (uncancelledContext) Deadline() (time.Time, bool) { return time.Time{}, false }
+
+func (uncancelledContext) Done() <-chan struct{} { return nil }
+
+func (uncancelledContext) Err() error { return nil }
+
+// waitInterruptible waits for a started command. When the context is cancelled, for example when porter stops
+// the action, the command is interrupted so that helm can release the lock of the release, and killed when it
+// has not exited after interruptGracePeriod. It returns whether the command was interrupted.
+func waitInterruptible(ctx context.Context, cmd *exec.Cmd) (bool, error) {
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+
+	select {
+	case err := <-done:
+		return false, err
+	case <-ctx.Done():
+	}
+
+	// interrupt is not supported on windows, the command is killed right away
+	if err := cmd.Process.Signal(os.Interrupt); err != nil {
+		cmd.Process.Kill()
+	}
+	select {
+	case <-done:
+	case <-time.After(interruptGracePeriod):
+		cmd.Process.Kill()
+		<-done
+	}
+	return true, ctx.Err()
+}
+
+// interruptedError reports the status of a release that helm was working on when it was interrupted,
+// since the release may be left pending and locked
+func (m *Mixin) interruptedError(operation string, release string, namespace string, cause error) error {
+	status, err := m.interruptedStatus(release, namespace)
+	if err != nil {
+		return errors.Wrapf(cause, "the %s of release %s was interrupted, and its status could not be read", operation, release)
+	}
+	return errors.Wrapf(cause, "the %s of release %s was interrupted, the release is %s at revision %d",
+		operation, release, status.Info.Status, status.Revision)
+}
+
+// interruptedStatus reads the status of a release after helm was interrupted, once the context of the step is cancelled
+func (m *Mixin) interruptedStatus(release string, namespace string) (releaseResult, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), interruptStatusTimeout)
+	defer cancel()
+
+	cmd := m.helmCommand(ctx, "status", release, "--output", "json")
+	if namespace != "" {
+		cmd.Args = append(cmd.Args, "--namespace", namespace)
+	}
+	var status releaseResult
+	err := readOutput(cmd, func(r io.Reader) error {
+		return decodeRelease(r, &status, nil)
+	})
+	if err == nil && status.Info.Status == "" {
+		err = errors.Errorf("helm did not print the status of release %s", release)
+	}
+	return status, err
+}
+
+const (
+	// onInterruptNone leaves an interrupted upgrade as helm left it, which is the default
+	onInterruptNone = "none"
+	// onInterruptRollback rolls an interrupted upgrade back to the revision deployed before it
+	onInterruptRollback = "rollback"
+)
+
+// defaultInterruptRollbackTimeout bounds the rollback of an interrupted upgrade whose step has no timeout
+const defaultInterruptRollbackTimeout = 5 * time.Minute
+
+// validateOnInterrupt checks the policy applied to an interrupted upgrade
+func validateOnInterrupt(policy string) error {
+	switch policy {
+	case "", onInterruptNone, onInterruptRollback:
+		return nil
+	}
+	return errors.Errorf("unsupported onInterrupt %s, expected one of %s, %s", policy, onInterruptNone, onInterruptRollback)
+}
+
+// rollbackInterrupted rolls an interrupted upgrade back to the previous revision of the release, when helm left
+// the release pending or failed, so that the release is deployed and unlocked before the runtime exits
+func (m *Mixin) rollbackInterrupted(step UpgradeArguments, failure error) error {
+	status, err := m.interruptedStatus(step.Name, step.Namespace)
+	if err != nil {
+		return errors.Wrapf(failure, "release %s could not be rolled back, %s", step.Name, err)
+	}
+	if (status.Info.Status != "pending-upgrade" && status.Info.Status != "failed") || status.Revision <= 1 {
+		return failure
+	}
+	revision := status.Revision - 1
+
+	timeout := defaultInterruptRollbackTimeout
+	if d, err := parseTimeout("timeout", step.Timeout); err == nil && d > 0 {
+		timeout = d
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout+timeoutGracePeriod)
+	defer cancel()
+
+	fmt.Fprintf(m.Out, "Rolling back release %s to revision %d after the upgrade was interrupted\n", step.Name, revision)
+	cmd := m.helmCommand(ctx, "rollback", step.Name, strconv.Itoa(revision), "--wait", "--timeout", timeout.String())
+	if step.Namespace != "" {
+		cmd.Args = append(cmd.Args, "--namespace", step.Namespace)
+	}
+	m.setStepEnv(cmd, step.Env)
+	err = m.runCommand(cmd)
+	if err != nil {
+		return errors.Wrapf(failure, "release %s could not be rolled back to revision %d, %s", step.Name, revision, err)
+	}
+	return errors.Wrapf(failure, "release %s was rolled back to revision %d", step.Name, revision)
+}
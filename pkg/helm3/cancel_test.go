@@ -0,0 +1,128 @@
+package helm3
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"get.porter.sh/porter/pkg/test"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWaitInterruptible(t *testing.T) {
+	t.Run("completed", func(t *testing.T) {
+		cmd := exec.Command("true")
+		require.NoError(t, cmd.Start())
+
+		interrupted, err := waitInterruptible(context.Background(), cmd)
+		require.NoError(t, err)
+		assert.False(t, interrupted)
+	})
+
+	t.Run("interrupted", func(t *testing.T) {
+		cmd := exec.Command("sleep", "10")
+		require.NoError(t, cmd.Start())
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		start := time.Now()
+		interrupted, err := waitInterruptible(ctx, cmd)
+		assert.True(t, interrupted)
+		assert.Equal(t, context.DeadlineExceeded, err)
+		assert.Less(t, time.Since(start), interruptGracePeriod, "the command should exit once interrupted")
+	})
+
+	t.Run("killed after the grace period", func(t *testing.T) {
+		defer func(period time.Duration) { interruptGracePeriod = period }(interruptGracePeriod)
+		interruptGracePeriod = 100 * time.Millisecond
+
+		// the interrupt is ignored, as by a command that does not handle it
+		cmd := exec.Command("sh", "-c", "trap '' INT; exec sleep 10")
+		require.NoError(t, cmd.Start())
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		start := time.Now()
+		interrupted, err := waitInterruptible(ctx, cmd)
+		assert.True(t, interrupted)
+		assert.Equal(t, context.Canceled, err)
+		assert.Less(t, time.Since(start), 5*time.Second, "the command should be killed after the grace period")
+	})
+}
+
+func TestUncancelledContext(t *testing.T) {
+	type key struct{}
+	ctx, cancel := context.WithCancel(context.WithValue(context.Background(), key{}, "value"))
+	cancel()
+
+	uncancelled := uncancelledContext{ctx}
+	assert.NoError(t, uncancelled.Err())
+	assert.Nil(t, uncancelled.Done())
+	assert.Equal(t, "value", uncancelled.Value(key{}))
+}
+
+func TestMixin_InterruptedError(t *testing.T) {
+	defer os.Unsetenv(test.ExpectedCommandEnv)
+	defer os.Unsetenv(test.ExpectedCommandOutputEnv)
+	os.Setenv(test.ExpectedCommandEnv, "helm3 status mysql --output json --namespace db")
+	os.Setenv(test.ExpectedCommandOutputEnv, `{"name":"mysql","namespace":"db","version":4,"info":{"status":"pending-upgrade"}}`)
+
+	h := NewTestMixin(t)
+	err := h.interruptedError("upgrade", "mysql", "db", context.Canceled)
+	require.EqualError(t, err, "the upgrade of release mysql was interrupted, the release is pending-upgrade at revision 4: context canceled")
+	assert.True(t, errors.Is(err, context.Canceled))
+
+	os.Setenv(test.ExpectedCommandEnv, "helm3 status redis --output json")
+	err = h.interruptedError("install", "mysql", "db", context.Canceled)
+	require.EqualError(t, err, "the install of release mysql was interrupted, and its status could not be read: context canceled")
+}
+
+func TestMixin_RollbackInterrupted(t *testing.T) {
+	testcases := []struct {
+		name     string
+		status   string
+		commands []string
+		wantErr  string
+	}{
+		{
+			name:   "pending upgrade",
+			status: `{"name":"mysql","namespace":"db","version":4,"info":{"status":"pending-upgrade"}}`,
+			commands: []string{
+				"helm3 status mysql --output json --namespace db",
+				"helm3 rollback mysql 3 --wait --timeout 2m0s --namespace db",
+			},
+			wantErr: "release mysql was rolled back to revision 3: upgrade interrupted",
+		},
+		{
+			name:     "deployed",
+			status:   `{"name":"mysql","namespace":"db","version":3,"info":{"status":"deployed"}}`,
+			commands: []string{"helm3 status mysql --output json --namespace db"},
+			wantErr:  "upgrade interrupted",
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			defer os.Unsetenv(test.ExpectedCommandEnv)
+			defer os.Unsetenv(test.ExpectedCommandOutputEnv)
+			os.Setenv(test.ExpectedCommandEnv, strings.Join(tc.commands, "\n"))
+			os.Setenv(test.ExpectedCommandOutputEnv, tc.status)
+
+			h := NewTestMixin(t)
+			step := UpgradeArguments{Name: "mysql", Namespace: "db", Timeout: "2m", OnInterrupt: onInterruptRollback}
+			err := h.rollbackInterrupted(step, errors.New("upgrade interrupted"))
+			require.EqualError(t, err, tc.wantErr)
+		})
+	}
+}
+
+func TestValidateOnInterrupt(t *testing.T) {
+	assert.NoError(t, validateOnInterrupt(""))
+	assert.NoError(t, validateOnInterrupt("rollback"))
+	assert.EqualError(t, validateOnInterrupt("uninstall"), "unsupported onInterrupt uninstall, expected one of none, rollback")
+}
@@ -0,0 +1,76 @@
+package helm3
+
+import (
+	"context"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// CRDArguments represent the custom resource definitions managed by a step, separately from the release,
+// since helm never upgrades the CRDs of a chart once they are installed.
+type CRDArguments struct {
+	// Chart from which the crds directory is applied
+	Chart   string `yaml:"chart,omitempty"`
+	Version string `yaml:"version,omitempty"`
+	// Manifest is applied instead of the crds directory of a chart
+	Manifest string `yaml:"manifest,omitempty"`
+	// Wait for the CRDs to be Established
+	Wait    bool   `yaml:"wait,omitempty"`
+	Timeout string `yaml:"timeout,omitempty"`
+}
+
+// crdsDir is where the chart is pulled to extract its crds directory
+func crdsDir(chart string) string {
+	return filepath.Join(os.TempDir(), "porter-helm3-crds", path.Base(chart))
+}
+
+// applyCRDs applies the custom resource definitions from the chart or manifest with kubectl
+func (m *Mixin) applyCRDs(ctx context.Context, crds CRDArguments) error {
+	manifest := crds.Manifest
+	switch {
+	case manifest != "":
+	case crds.Chart == "":
+		return errors.New("crds requires either a chart or a manifest")
+	case strings.HasPrefix(crds.Chart, ".") || strings.HasPrefix(crds.Chart, "/"):
+		// local chart directory
+		manifest = filepath.Join(crds.Chart, "crds")
+	default:
+		dir := crdsDir(crds.Chart)
+		if err := os.RemoveAll(dir); err != nil {
+			return errors.Wrapf(err, "could not clean the directory %s", dir)
+		}
+		defer os.RemoveAll(dir)
+
+		if err := m.registryLogin(ctx, crds.Chart); err != nil {
+			return err
+		}
+
+		cmd := m.helmCommand(ctx, "pull", crds.Chart, "--untar", "--untardir", dir)
+		if crds.Version != "" {
+			cmd.Args = append(cmd.Args, "--version", crds.Version)
+		}
+		if err := m.runCommand(cmd); err != nil {
+			return err
+		}
+		manifest = filepath.Join(dir, path.Base(crds.Chart), "crds")
+	}
+
+	// Server side apply avoids the size limit on the last-applied-configuration annotation of large CRDs
+	cmd := m.NewCommand(ctx, "kubectl", "apply", "--server-side", "--force-conflicts", "--recursive", "-f", manifest)
+	if err := m.runCommand(cmd); err != nil {
+		return err
+	}
+
+	if !crds.Wait {
+		return nil
+	}
+	ctx, cancel := withTimeout(ctx, crds.Timeout)
+	defer cancel()
+	cmd = m.NewCommand(ctx, "kubectl", "wait", "--for", "condition=established", "--recursive", "-f", manifest)
+	cmd.Args = appendTimeout(cmd.Args, crds.Timeout)
+	return m.runCommand(cmd)
+}
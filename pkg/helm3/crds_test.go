@@ -0,0 +1,76 @@
+package helm3
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"get.porter.sh/porter/pkg/test"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestMixin_UpgradeCRDs(t *testing.T) {
+	dir := crdsDir("jetstack/cert-manager")
+	crdsPath := filepath.Join(dir, "cert-manager", "crds")
+
+	testcases := []struct {
+		name         string
+		crds         CRDArguments
+		release      bool
+		wantCommands []string
+	}{
+		{
+			name: "crds from a chart",
+			crds: CRDArguments{Chart: "jetstack/cert-manager", Version: "v1.9.1", Wait: true, Timeout: "60s"},
+			wantCommands: []string{
+				"helm3 pull jetstack/cert-manager --untar --untardir " + dir + " --version v1.9.1",
+				"kubectl apply --server-side --force-conflicts --recursive -f " + crdsPath,
+				"kubectl wait --for condition=established --recursive -f " + crdsPath + " --timeout 60s",
+			},
+		},
+		{
+			name: "crds from a manifest",
+			crds: CRDArguments{Manifest: "./manifests/crds.yaml"},
+			wantCommands: []string{
+				"kubectl apply --server-side --force-conflicts --recursive -f ./manifests/crds.yaml",
+			},
+		},
+		{
+			name:    "crds before the release",
+			crds:    CRDArguments{Chart: "./charts/cert-manager"},
+			release: true,
+			wantCommands: []string{
+				"kubectl apply --server-side --force-conflicts --recursive -f charts/cert-manager/crds",
+				"helm3 upgrade --install cert-manager ./charts/cert-manager --atomic --create-namespace --output json",
+			},
+		},
+	}
+
+	defer os.Unsetenv(test.ExpectedCommandEnv)
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			os.Setenv(test.ExpectedCommandEnv, strings.Join(tc.wantCommands, "\n"))
+
+			step := UpgradeStep{UpgradeArguments: UpgradeArguments{
+				Step: Step{Description: "Upgrade CRDs"},
+				CRDs: &tc.crds,
+			}}
+			if tc.release {
+				step.Name = "cert-manager"
+				step.Chart = "./charts/cert-manager"
+			}
+			b, _ := yaml.Marshal(UpgradeAction{Steps: []UpgradeStep{step}})
+
+			h := NewTestMixin(t)
+			h.In = bytes.NewReader(b)
+
+			err := h.Upgrade(ctx)
+			require.NoError(t, err)
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package helm3
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+// allowUnknownFieldsEnv disables strict decoding of steps and mixin configuration when set to true,
+// so that bundles authored against a newer version of the mixin can still be executed.
+const allowUnknownFieldsEnv = "PORTER_HELM3_ALLOW_UNKNOWN_FIELDS"
+
+var unknownFieldRegex = regexp.MustCompile(`^(line \d+: )?field (\S+) not found in type \S+$`)
+
+func (m *Mixin) allowUnknownFields() bool {
+	allow, _ := strconv.ParseBool(m.Getenv(allowUnknownFieldsEnv))
+	return allow
+}
+
+// unmarshal decodes the yaml document into v, rejecting unknown fields unless they are explicitly allowed
+func (m *Mixin) unmarshal(b []byte, v interface{}) error {
+	if m.allowUnknownFields() {
+		return yaml.Unmarshal(b, v)
+	}
+	return unknownFieldsError(yaml.UnmarshalStrict(b, v))
+}
+
+// unknownFieldsError rewrites the errors returned by the strict yaml decoder into friendlier "unknown field" errors
+func unknownFieldsError(err error) error {
+	typeErr, ok := err.(*yaml.TypeError)
+	if !ok {
+		return err
+	}
+
+	errs := make([]string, 0, len(typeErr.Errors))
+	for _, e := range typeErr.Errors {
+		if match := unknownFieldRegex.FindStringSubmatch(e); match != nil {
+			e = match[1] + "unknown field " + strconv.Quote(match[2])
+		}
+		errs = append(errs, e)
+	}
+	return errors.Errorf("invalid helm3 configuration, set %s=true to ignore unknown fields:\n\t* %s",
+		allowUnknownFieldsEnv, strings.Join(errs, "\n\t* "))
+}
@@ -0,0 +1,93 @@
+package helm3
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// DependencyList reports the dependencies of a local chart, for example for license and compliance reporting
+type DependencyList struct {
+	// Chart is the path of the chart in the bundle
+	Chart string `yaml:"chart"`
+}
+
+// chartDependency is a dependency of a chart as listed by helm dependency list
+type chartDependency struct {
+	Name       string `json:"name"`
+	Version    string `json:"version"`
+	Repository string `json:"repository"`
+	Status     string `json:"status"`
+}
+
+// dependencyList prints the dependencies of a local chart as json and returns them
+func (m *Mixin) dependencyList(ctx context.Context, step ExecuteStep) (string, error) {
+	list := *step.DependencyList
+	if len(step.Arguments) > 0 || step.Plugin != "" {
+		return "", errors.New("dependencyList cannot be set together with arguments or plugin")
+	}
+	if list.Chart == "" {
+		return "", errors.New("dependencyList requires the path of a chart")
+	}
+
+	cmd := m.helmCommand(ctx, "dependency", "list", list.Chart)
+	if m.planMode() {
+		m.printPlannedCommand(cmd)
+		return "", nil
+	}
+
+	output := &bytes.Buffer{}
+	cmd.Stdout = output
+	cmd.Stderr = m.Err
+	if m.tracing() {
+		fmt.Fprintln(m.Err, formatPlannedCommand(cmd.Args))
+	}
+	err := cmd.Run()
+	if err != nil {
+		return "", errors.Wrapf(err, "could not list the dependencies of chart %s", list.Chart)
+	}
+
+	b, err := json.MarshalIndent(parseDependencyList(output.String()), "", "  ")
+	if err != nil {
+		return "", errors.Wrapf(err, "could not marshal the dependencies of chart %s", list.Chart)
+	}
+	fmt.Fprintln(m.Out, string(b))
+	return string(b), nil
+}
+
+// parseDependencyList reads the table printed by helm dependency list
+func parseDependencyList(output string) []chartDependency {
+	dependencies := []chartDependency{}
+	for _, row := range parseTable(output, 4) {
+		dependencies = append(dependencies, chartDependency{Name: row[0], Version: row[1], Repository: row[2], Status: row[3]})
+	}
+	return dependencies
+}
+
+// parseTable reads the rows of a table printed by helm, whose columns are separated by tabs and start with NAME.
+// Warnings printed around the table, such as when it has no rows, are ignored.
+func parseTable(output string, columnCount int) [][]string {
+	var rows [][]string
+	header := false
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		columns := strings.Split(scanner.Text(), "\t")
+		if len(columns) != columnCount {
+			continue
+		}
+		for i := range columns {
+			columns[i] = strings.TrimSpace(columns[i])
+		}
+		if !header {
+			header = columns[0] == "NAME"
+			continue
+		}
+		rows = append(rows, columns)
+	}
+	return rows
+}
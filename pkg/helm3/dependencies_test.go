@@ -0,0 +1,88 @@
+package helm3
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"get.porter.sh/porter/pkg/portercontext"
+	"get.porter.sh/porter/pkg/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+const dependencyListOutput = "NAME      \tVERSION\tREPOSITORY                        \tSTATUS\n" +
+	"common    \t2.x.x  \thttps://charts.bitnami.com/bitnami\tok    \n" +
+	"prometheus\t25.8.0 \toci://ghcr.io/example/charts      \tmissing\n" +
+	"\n"
+
+func TestParseDependencyList(t *testing.T) {
+	assert.Equal(t, []chartDependency{
+		{Name: "common", Version: "2.x.x", Repository: "https://charts.bitnami.com/bitnami", Status: "ok"},
+		{Name: "prometheus", Version: "25.8.0", Repository: "oci://ghcr.io/example/charts", Status: "missing"},
+	}, parseDependencyList(dependencyListOutput))
+
+	assert.Empty(t, parseDependencyList("WARNING: no dependencies at charts/mysql/charts\n"))
+}
+
+func TestMixin_ExecuteDependencyList(t *testing.T) {
+	ctx := context.Background()
+	defer os.Unsetenv(test.ExpectedCommandEnv)
+	defer os.Unsetenv(test.ExpectedCommandOutputEnv)
+	os.Setenv(test.ExpectedCommandEnv, "helm3 dependency list ./charts/mysql")
+	os.Setenv(test.ExpectedCommandOutputEnv, dependencyListOutput)
+
+	executeAction := Action{
+		Steps: []ExecuteSteps{
+			{
+				ExecuteStep: ExecuteStep{
+					Step: Step{
+						Description: "List MySQL dependencies",
+						Outputs:     []HelmOutput{{Name: "dependencies", Stdout: true}},
+					},
+					DependencyList: &DependencyList{Chart: "./charts/mysql"},
+				},
+			},
+		},
+	}
+	b, _ := yaml.Marshal(executeAction)
+
+	h := NewTestMixin(t)
+	h.In = bytes.NewReader(b)
+
+	err := h.Execute(ctx)
+	require.NoError(t, err)
+
+	dependencies, err := h.FileSystem.ReadFile(filepath.Join(portercontext.MixinOutputsDir, "dependencies"))
+	require.NoError(t, err)
+	assert.JSONEq(t, `[
+		{"name": "common", "version": "2.x.x", "repository": "https://charts.bitnami.com/bitnami", "status": "ok"},
+		{"name": "prometheus", "version": "25.8.0", "repository": "oci://ghcr.io/example/charts", "status": "missing"}
+	]`, string(dependencies))
+}
+
+func TestMixin_ExecuteDependencyListWithArguments(t *testing.T) {
+	ctx := context.Background()
+
+	executeAction := Action{
+		Steps: []ExecuteSteps{
+			{
+				ExecuteStep: ExecuteStep{
+					Step:           Step{Description: "List MySQL dependencies"},
+					Arguments:      []string{"dependency", "list"},
+					DependencyList: &DependencyList{Chart: "./charts/mysql"},
+				},
+			},
+		},
+	}
+	b, _ := yaml.Marshal(executeAction)
+
+	h := NewTestMixin(t)
+	h.In = bytes.NewReader(b)
+
+	err := h.Execute(ctx)
+	require.EqualError(t, err, "dependencyList cannot be set together with arguments or plugin")
+}
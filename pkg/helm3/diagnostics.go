@@ -0,0 +1,152 @@
+package helm3
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+// sensitiveHelmEnv are the variables printed by helm env whose value is redacted from the diagnostics
+var sensitiveHelmEnv = map[string]bool{"HELM_KUBETOKEN": true}
+
+// diagnostics describes the helm and kubectl installation of the invocation image, for support requests
+type diagnostics struct {
+	HelmVersion    string            `json:"helmVersion"`
+	HelmEnv        map[string]string `json:"helmEnv"`
+	KubectlVersion string            `json:"kubectlVersion"`
+	Plugins        []helmPlugin      `json:"plugins"`
+	Repositories   []helmRepository  `json:"repositories"`
+	// Errors are the diagnostics that could not be collected, by name
+	Errors map[string]string `json:"errors,omitempty"`
+}
+
+type helmPlugin struct {
+	Name        string `json:"name"`
+	Version     string `json:"version"`
+	Description string `json:"description"`
+}
+
+type helmRepository struct {
+	Name string `json:"name" yaml:"name"`
+	URL  string `json:"url" yaml:"url"`
+}
+
+// diagnostics prints the helm and kubectl versions, the helm environment, plugins and repositories as json
+// and returns them. A diagnostic that can't be collected is reported in the errors instead of failing the step.
+func (m *Mixin) diagnostics(ctx context.Context, step ExecuteStep) (string, error) {
+	if len(step.Arguments) > 0 || step.Plugin != "" {
+		return "", errors.New("diagnostics cannot be set together with arguments or plugin")
+	}
+
+	d := diagnostics{HelmEnv: map[string]string{}, Plugins: []helmPlugin{}, Repositories: []helmRepository{}}
+	collect := func(name string, args []string, parse func(string) error) {
+		out, err := m.captureCommand(ctx, args[0], args[1:]...)
+		if err == nil {
+			err = parse(out)
+		}
+		if err != nil {
+			if d.Errors == nil {
+				d.Errors = map[string]string{}
+			}
+			d.Errors[name] = err.Error()
+		}
+	}
+
+	helm := m.helmBinary()
+	collect("helmVersion", []string{helm, "version", "--short"}, func(out string) error {
+		d.HelmVersion = strings.TrimSpace(out)
+		return nil
+	})
+	collect("helmEnv", []string{helm, "env"}, func(out string) error {
+		d.HelmEnv = parseHelmEnv(out)
+		return nil
+	})
+	collect("kubectlVersion", []string{"kubectl", "version", "--client", "--output", "json"}, func(out string) error {
+		var version struct {
+			ClientVersion struct {
+				GitVersion string `json:"gitVersion"`
+			} `json:"clientVersion"`
+		}
+		if err := json.Unmarshal([]byte(out), &version); err != nil {
+			return errors.Wrap(err, "could not parse the kubectl version")
+		}
+		d.KubectlVersion = version.ClientVersion.GitVersion
+		return nil
+	})
+	collect("plugins", []string{helm, "plugin", "list"}, func(out string) error {
+		for _, row := range parseTable(out, 3) {
+			d.Plugins = append(d.Plugins, helmPlugin{Name: row[0], Version: row[1], Description: row[2]})
+		}
+		return nil
+	})
+	collect("repositories", []string{helm, "repo", "list", "--output", "yaml"}, func(out string) error {
+		return errors.Wrap(yaml.Unmarshal([]byte(out), &d.Repositories), "could not parse the helm repositories")
+	})
+	if m.planMode() {
+		return "", nil
+	}
+	// helm fails to list repositories when none were added
+	if msg, ok := d.Errors["repositories"]; ok && strings.Contains(msg, "no repositories to show") {
+		delete(d.Errors, "repositories")
+	}
+
+	b, err := json.MarshalIndent(d, "", "  ")
+	if err != nil {
+		return "", errors.Wrap(err, "could not marshal the diagnostics")
+	}
+	fmt.Fprintln(m.Out, string(b))
+	return string(b), nil
+}
+
+// captureCommand runs a command and returns what it printed, the error includes what it printed to stderr.
+// In plan mode the command is only printed.
+func (m *Mixin) captureCommand(ctx context.Context, name string, args ...string) (string, error) {
+	cmd := m.NewCommand(ctx, name, args...)
+	if m.planMode() {
+		m.printPlannedCommand(cmd)
+		return "", nil
+	}
+
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	if m.tracing() {
+		fmt.Fprintln(m.Err, formatPlannedCommand(cmd.Args))
+	}
+	err := cmd.Run()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", errors.Wrap(err, msg)
+		}
+		return "", err
+	}
+	return stdout.String(), nil
+}
+
+// parseHelmEnv reads the KEY="value" lines printed by helm env, redacting sensitive values
+func parseHelmEnv(output string) map[string]string {
+	env := map[string]string{}
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(strings.TrimSpace(scanner.Text()), "=")
+		if !ok {
+			continue
+		}
+		if unquoted, err := strconv.Unquote(value); err == nil {
+			value = unquoted
+		}
+		if sensitiveHelmEnv[key] && value != "" {
+			value = redacted
+		}
+		env[key] = value
+	}
+	return env
+}
@@ -0,0 +1,76 @@
+package helm3
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"get.porter.sh/porter/pkg/portercontext"
+	"get.porter.sh/porter/pkg/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestParseHelmEnv(t *testing.T) {
+	env := parseHelmEnv(`HELM_BIN="helm3"
+HELM_KUBETOKEN="eyJhbGciOiJSUzI1NiIsImtpZCI6IiJ9"
+HELM_NAMESPACE="default"
+HELM_KUBECONTEXT=""
+`)
+	assert.Equal(t, map[string]string{
+		"HELM_BIN":         "helm3",
+		"HELM_KUBETOKEN":   "*******",
+		"HELM_NAMESPACE":   "default",
+		"HELM_KUBECONTEXT": "",
+	}, env)
+}
+
+func TestMixin_ExecuteDiagnostics(t *testing.T) {
+	ctx := context.Background()
+	defer os.Unsetenv(test.ExpectedCommandEnv)
+	defer os.Unsetenv(test.ExpectedCommandOutputEnv)
+	os.Setenv(test.ExpectedCommandEnv, strings.Join([]string{
+		"helm3 version --short",
+		"helm3 env",
+		"kubectl version --client --output json",
+		"helm3 plugin list",
+		"helm3 repo list --output yaml",
+	}, "\n"))
+	// every mocked command prints the kubectl version, so the repositories can't be parsed
+	os.Setenv(test.ExpectedCommandOutputEnv, `{"clientVersion": {"gitVersion": "v1.22.1"}}`)
+
+	executeAction := Action{
+		Steps: []ExecuteSteps{
+			{
+				ExecuteStep: ExecuteStep{
+					Step: Step{
+						Description: "Collect diagnostics",
+						Outputs:     []HelmOutput{{Name: "diagnostics", Stdout: true}},
+					},
+					Diagnostics: true,
+				},
+			},
+		},
+	}
+	b, _ := yaml.Marshal(executeAction)
+
+	h := NewTestMixin(t)
+	h.In = bytes.NewReader(b)
+
+	err := h.Execute(ctx)
+	require.NoError(t, err)
+
+	output, err := h.FileSystem.ReadFile(filepath.Join(portercontext.MixinOutputsDir, "diagnostics"))
+	require.NoError(t, err)
+	var d diagnostics
+	require.NoError(t, json.Unmarshal(output, &d))
+	assert.Equal(t, "v1.22.1", d.KubectlVersion)
+	assert.Empty(t, d.Plugins)
+	assert.Contains(t, d.Errors["repositories"], "could not parse the helm repositories")
+	assert.NotContains(t, d.Errors, "kubectlVersion")
+}
@@ -0,0 +1,113 @@
+package helm3
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+var chartDigestRegex = regexp.MustCompile(`^sha256:[a-f0-9]{64}$`)
+
+// pinnedChartDir holds the charts pulled by their digest, in a directory named after the digest
+var pinnedChartDir = filepath.Join(os.TempDir(), "porter-helm3", "charts")
+
+// splitChartDigest splits an oci:// chart reference pinned to a digest, such as oci://registry/charts/mysql@sha256:...
+func splitChartDigest(chart string) (ref string, digest string, pinned bool) {
+	return strings.Cut(chart, "@")
+}
+
+// validateChartDigest checks that a chart pinned to a digest is an oci:// chart with a sha256 digest
+func validateChartDigest(chart string) error {
+	ref, digest, pinned := splitChartDigest(chart)
+	if !pinned {
+		return nil
+	}
+	if !strings.HasPrefix(ref, "oci://") {
+		return errors.Errorf("chart %s is pinned to a digest, which is only supported for oci:// charts", chart)
+	}
+	if !chartDigestRegex.MatchString(digest) {
+		return errors.Errorf("chart %s is pinned to an invalid digest %q, expected sha256: followed by 64 hexadecimal characters", ref, digest)
+	}
+	return nil
+}
+
+// pullPinnedChart pulls an oci:// chart pinned to a digest and checks that the registry served the chart of the digest.
+// It returns the path of the pulled chart, so that helm deploys the chart that was checked, and leaves other charts as is.
+// A chart vendored into the image when the bundle was built is not pulled again.
+func (m *Mixin) pullPinnedChart(ctx context.Context, chart string, version string) (string, string, error) {
+	ref, digest, pinned := splitChartDigest(chart)
+	if !pinned {
+		return chart, version, nil
+	}
+	err := validateChartDigest(chart)
+	if err != nil {
+		return "", "", err
+	}
+
+	// a chart vendored when the bundle was built is deployed from the image instead of being pulled
+	vendored, ok, err := m.readVendoredChart(chart)
+	if err != nil {
+		return "", "", err
+	}
+	if ok {
+		name, _, _ := strings.Cut(path.Base(ref), ":")
+		fmt.Fprintf(m.Out, "Using chart %s vendored with the pinned digest %s\n", ref, digest)
+		return filepath.Join(vendored.dir(), name), "", nil
+	}
+
+	dest := filepath.Join(pinnedChartDir, strings.TrimPrefix(digest, "sha256:"))
+	cmd := m.helmCommand(ctx, "pull", chart, "--untar", "--destination", dest)
+	if version != "" {
+		cmd.Args = append(cmd.Args, "--version", version)
+	}
+	// the chart is extracted in a directory named after the repository, without its tag
+	name, _, _ := strings.Cut(path.Base(ref), ":")
+	pulled := filepath.Join(dest, name)
+
+	if m.planMode() {
+		m.printPlannedCommand(cmd)
+		return pulled, "", nil
+	}
+
+	// helm does not extract a chart over a previous pull
+	err = m.FileSystem.RemoveAll(dest)
+	if err != nil {
+		return "", "", errors.Wrapf(err, "could not clean the chart directory %s", dest)
+	}
+
+	m.echoCommand(fmt.Sprintf("%s %s", cmd.Path, formatPlannedCommand(cmd.Args)))
+	stderr := &bytes.Buffer{}
+	cmd.Stderr = io.MultiWriter(m.Err, stderr)
+	out, err := cmd.Output()
+	if err != nil {
+		return "", "", errors.Wrapf(classifyHelmError(err, stderr.String()), "could not pull chart %s", chart)
+	}
+
+	pulledDigest := pulledChartDigest(out)
+	if pulledDigest != digest {
+		return "", "", errors.Errorf("chart %s was pulled with digest %q, which does not match the pinned digest %s", ref, pulledDigest, digest)
+	}
+	fmt.Fprintf(m.Out, "Pulled chart %s matching the pinned digest %s\n", ref, digest)
+	return pulled, "", nil
+}
+
+// pulledChartDigest returns the digest printed by helm pull for an oci:// chart
+func pulledChartDigest(out []byte) string {
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "Digest:") {
+			return strings.TrimSpace(strings.TrimPrefix(line, "Digest:"))
+		}
+	}
+	return ""
+}
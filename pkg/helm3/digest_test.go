@@ -0,0 +1,113 @@
+package helm3
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"get.porter.sh/porter/pkg/linter"
+	"get.porter.sh/porter/pkg/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	mysqlChartDigest = "sha256:0f8c4e6a1d3b5c7e9f0a2b4c6d8e0f1a3b5c7d9e1f2a4b6c8d0e2f4a6b8c0d2e"
+	otherChartDigest = "sha256:1a2b3c4d5e6f7a8b9c0d1e2f3a4b5c6d7e8f9a0b1c2d3e4f5a6b7c8d9e0f1a2b"
+)
+
+func TestValidateChartDigest(t *testing.T) {
+	testcases := []struct {
+		name      string
+		chart     string
+		wantError string
+	}{
+		{name: "not pinned", chart: "bitnami/mysql"},
+		{name: "pinned oci chart", chart: "oci://registry.example.com/charts/mysql@" + mysqlChartDigest},
+		{name: "pinned repository chart", chart: "bitnami/mysql@" + mysqlChartDigest,
+			wantError: "chart bitnami/mysql@" + mysqlChartDigest + " is pinned to a digest, which is only supported for oci:// charts"},
+		{name: "invalid digest", chart: "oci://registry.example.com/charts/mysql@sha256:1234",
+			wantError: `chart oci://registry.example.com/charts/mysql is pinned to an invalid digest "sha256:1234", expected sha256: followed by 64 hexadecimal characters`},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validateChartDigest(tc.chart)
+			if tc.wantError != "" {
+				require.EqualError(t, err, tc.wantError)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestMixin_InstallPinnedChart(t *testing.T) {
+	chartDir := filepath.Join(pinnedChartDir, strings.TrimPrefix(mysqlChartDigest, "sha256:"))
+	chart := "oci://registry.example.com/charts/mysql@" + mysqlChartDigest
+
+	testcases := []struct {
+		name         string
+		pulledDigest string
+		wantError    string
+	}{
+		{name: "matching digest", pulledDigest: mysqlChartDigest},
+		{name: "digest mismatch", pulledDigest: otherChartDigest,
+			wantError: `chart oci://registry.example.com/charts/mysql was pulled with digest "` + otherChartDigest + `", which does not match the pinned digest ` + mysqlChartDigest},
+	}
+
+	defer os.Unsetenv(test.ExpectedCommandEnv)
+	defer os.Unsetenv(test.ExpectedCommandOutputEnv)
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			os.Setenv(test.ExpectedCommandEnv, strings.Join([]string{
+				"helm3 pull " + chart + " --untar --destination " + chartDir,
+				"helm3 upgrade --install mysql " + filepath.Join(chartDir, "mysql") + " --atomic --create-namespace --output json",
+			}, "\n"))
+			os.Setenv(test.ExpectedCommandOutputEnv, "Pulled: registry.example.com/charts/mysql@"+mysqlChartDigest+"\nDigest: "+tc.pulledDigest+"\n")
+
+			action := InstallAction{Steps: []InstallStep{{
+				InstallArguments: InstallArguments{
+					Step:  Step{Description: "Install MySQL"},
+					Name:  "mysql",
+					Chart: chart,
+				},
+			}}}
+			b, _ := yaml.Marshal(action)
+
+			h := NewTestMixin(t)
+			h.In = bytes.NewReader(b)
+
+			err := h.Install(ctx)
+			if tc.wantError != "" {
+				require.EqualError(t, err, tc.wantError)
+				return
+			}
+			require.NoError(t, err)
+			assert.Contains(t, h.TestContext.GetOutput(), "Pulled chart oci://registry.example.com/charts/mysql matching the pinned digest")
+		})
+	}
+}
+
+func TestMixin_LintChartDigest(t *testing.T) {
+	input := `
+install:
+  - helm3:
+      description: "Install MySQL"
+      name: mysql
+      chart: oci://registry.example.com/charts/mysql@sha256:1234
+`
+	m := NewTestMixin(t)
+	m.In = strings.NewReader(input)
+
+	results, err := m.Lint(context.Background())
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, CodeInvalidChartDigest, results[0].Code)
+	assert.Equal(t, linter.LevelError, results[0].Level)
+}
@@ -0,0 +1,72 @@
+package helm3
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/Masterminds/semver"
+	"github.com/pkg/errors"
+)
+
+const (
+	dryRunNone   = "none"
+	dryRunClient = "client"
+	dryRunServer = "server"
+)
+
+// dryRunModes are the values accepted by dryRun
+var dryRunModes = []string{dryRunNone, dryRunClient, dryRunServer}
+
+// dryRunModesConstraint represents the helm client versions that accept a dry run mode and --hide-secret
+const dryRunModesConstraint = ">= v3.13.0"
+
+// isDryRun checks if a step previews its releases instead of deploying them
+func isDryRun(mode string) bool {
+	return mode != "" && mode != dryRunNone
+}
+
+// validateDryRun checks that the dry run mode of a step is supported
+func validateDryRun(mode string) error {
+	if mode == "" {
+		return nil
+	}
+	for _, supported := range dryRunModes {
+		if mode == supported {
+			return nil
+		}
+	}
+	return errors.Errorf("unsupported dryRun %s, expected one of %s", mode, strings.Join(dryRunModes, ", "))
+}
+
+// dryRunFlags returns the helm flags that render the releases of a step without applying them,
+// hiding the content of secrets when the helm client supports it
+func (m *Mixin) dryRunFlags(ctx context.Context, mode string) ([]string, error) {
+	if !isDryRun(mode) {
+		return nil, nil
+	}
+
+	if supported, err := validate(m.helmClientVersion(ctx), dryRunModesConstraint); err == nil && supported {
+		return []string{"--dry-run=" + mode, "--hide-secret"}, nil
+	}
+	if mode == dryRunServer {
+		return nil, errors.New("dryRun server requires helm 3.13 or later")
+	}
+	fmt.Fprintln(m.Out, "The helm client does not support --hide-secret, the preview of the releases includes their secrets")
+	return []string{"--dry-run"}, nil
+}
+
+// helmClientVersion returns the version of the helm client, or an empty string when it can't be determined
+func (m *Mixin) helmClientVersion(ctx context.Context) string {
+	cmd := m.helmCommand(ctx, "version", "--short")
+	cmd.Stderr = m.Err
+	out, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+	version := strings.TrimSpace(string(out))
+	if _, err := semver.NewVersion(version); err != nil {
+		return ""
+	}
+	return version
+}
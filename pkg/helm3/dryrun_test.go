@@ -0,0 +1,99 @@
+package helm3
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"get.porter.sh/porter/pkg/portercontext"
+	"get.porter.sh/porter/pkg/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestMixin_InstallDryRun(t *testing.T) {
+	ctx := context.Background()
+	defer os.Unsetenv(test.ExpectedCommandEnv)
+	defer os.Unsetenv(test.ExpectedCommandOutputEnv)
+	os.Setenv(test.ExpectedCommandEnv, "helm3 version --short\n"+
+		"helm3 upgrade --install mysql stable/mysql --namespace db --atomic --create-namespace --output json --dry-run")
+	os.Setenv(test.ExpectedCommandOutputEnv, `{"name":"mysql","namespace":"db","version":1,"info":{"status":"pending-install"},"manifest":"kind: StatefulSet\n"}`)
+
+	action := InstallAction{Steps: []InstallStep{{
+		InstallArguments: InstallArguments{
+			Step: Step{
+				Description: "Preview MySQL",
+				Outputs:     []HelmOutput{{Name: "preview", ReleaseField: "manifest"}},
+			},
+			Name:              "mysql",
+			Chart:             "stable/mysql",
+			Namespace:         "db",
+			DryRun:            "client",
+			NamespaceMetadata: &NamespaceMetadata{Labels: map[string]string{"team": "data"}},
+		},
+	}}}
+	b, _ := yaml.Marshal(action)
+
+	h := NewTestMixin(t)
+	h.In = bytes.NewReader(b)
+
+	err := h.Install(ctx)
+	require.NoError(t, err)
+	assert.Contains(t, h.TestContext.GetOutput(), "the preview of the releases includes their secrets")
+
+	val, err := h.FileSystem.ReadFile(filepath.Join(portercontext.MixinOutputsDir, "preview"))
+	require.NoError(t, err)
+	assert.Equal(t, "kind: StatefulSet\n", string(val))
+}
+
+func TestMixin_UpgradeDryRunServer(t *testing.T) {
+	testcases := []struct {
+		name    string
+		version string
+		wantErr string
+	}{
+		{name: "supported", version: "v3.14.2+g9bc7934"},
+		{name: "unsupported", version: "v3.12.3+g3a31588", wantErr: "dryRun server requires helm 3.13 or later"},
+	}
+
+	defer os.Unsetenv(test.ExpectedCommandEnv)
+	defer os.Unsetenv(test.ExpectedCommandOutputEnv)
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			os.Setenv(test.ExpectedCommandEnv, "helm3 version --short\n"+
+				"helm3 upgrade --install mysql stable/mysql --atomic --create-namespace --output json --dry-run=server --hide-secret")
+			os.Setenv(test.ExpectedCommandOutputEnv, tc.version)
+
+			action := UpgradeAction{Steps: []UpgradeStep{{
+				UpgradeArguments: UpgradeArguments{
+					Step:   Step{Description: "Preview MySQL"},
+					Name:   "mysql",
+					Chart:  "stable/mysql",
+					DryRun: "server",
+				},
+			}}}
+			b, _ := yaml.Marshal(action)
+
+			h := NewTestMixin(t)
+			h.In = bytes.NewReader(b)
+
+			err := h.Upgrade(ctx)
+			if tc.wantErr != "" {
+				require.EqualError(t, err, tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestValidateDryRun(t *testing.T) {
+	for _, mode := range []string{"", "none", "client", "server"} {
+		assert.NoError(t, validateDryRun(mode))
+	}
+	assert.EqualError(t, validateDryRun("true"), "unsupported dryRun true, expected one of none, client, server")
+}
@@ -0,0 +1,78 @@
+package helm3
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// eventsEnv enables the kubernetes events recorded for each helm operation, it is set by the events field of the mixin configuration
+	eventsEnv = "PORTER_HELM3_EVENTS"
+	// bundleNameEnv is set by porter to the name of the bundle
+	bundleNameEnv = "CNAB_BUNDLE_NAME"
+	// eventComponent is the component that reports the events of the mixin
+	eventComponent = "porter-helm3"
+)
+
+// eventsEnabled checks if a kubernetes event is recorded for each helm operation
+func (m *Mixin) eventsEnabled() bool {
+	return m.Getenv(eventsEnv) == "true"
+}
+
+// recordEvent records a helm operation on a release as a kubernetes event of the namespace of the release,
+// with the bundle, installation, action and step that ran it, so that cluster operators can audit it with kubectl.
+// A failure to record the event is printed and does not fail the step.
+func (m *Mixin) recordEvent(ctx context.Context, step string, operation string, release string, namespace string, opErr error) {
+	if !m.eventsEnabled() || m.planMode() || release == "" {
+		return
+	}
+	if namespace == "" {
+		namespace = "default"
+	}
+
+	bundle, installation, action := m.Getenv(bundleNameEnv), m.Getenv(installationNameEnv), m.Getenv(actionEnv)
+	eventType, reason := corev1.EventTypeNormal, "Helm"+strings.ToUpper(operation[:1])+operation[1:]
+	result := "succeeded"
+	if opErr != nil {
+		eventType, reason, result = corev1.EventTypeWarning, reason+"Failed", "failed: "+opErr.Error()
+	}
+	message := fmt.Sprintf("%s of release %s by step %q of the %s action of bundle %s (installation %s) %s",
+		operation, release, step, action, bundle, installation, result)
+
+	now := metav1.NewTime(time.Now())
+	event := &corev1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: eventComponent + "-",
+			Namespace:    namespace,
+			Annotations: map[string]string{
+				"porter.sh/bundle":       bundle,
+				"porter.sh/installation": installation,
+				"porter.sh/action":       action,
+				"porter.sh/step":         step,
+				"porter.sh/release":      release,
+			},
+		},
+		InvolvedObject:      corev1.ObjectReference{APIVersion: "v1", Kind: "Namespace", Name: namespace},
+		Type:                eventType,
+		Reason:              reason,
+		Message:             message,
+		Source:              corev1.EventSource{Component: eventComponent},
+		ReportingController: eventComponent,
+		FirstTimestamp:      now,
+		LastTimestamp:       now,
+		Count:               1,
+	}
+
+	client, err := m.getKubernetesClient()
+	if err == nil {
+		_, err = client.CoreV1().Events(namespace).Create(ctx, event, metav1.CreateOptions{})
+	}
+	if err != nil {
+		fmt.Fprintf(m.Err, "WARNING: could not record the %s of release %s as an event: %s\n", operation, release, err)
+	}
+}
@@ -0,0 +1,87 @@
+package helm3
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	"get.porter.sh/porter/pkg/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	testclient "k8s.io/client-go/kubernetes/fake"
+)
+
+func TestMixin_InstallRecordsEvent(t *testing.T) {
+	ctx := context.Background()
+	defer os.Unsetenv(test.ExpectedCommandEnv)
+	os.Setenv(test.ExpectedCommandEnv, "helm3 upgrade --install mysql stable/mysql --namespace db --atomic --create-namespace --output json")
+
+	action := InstallAction{Steps: []InstallStep{{
+		InstallArguments: InstallArguments{
+			Step:      Step{Description: "Install MySQL"},
+			Name:      "mysql",
+			Chart:     "stable/mysql",
+			Namespace: "db",
+		},
+	}}}
+	b, _ := yaml.Marshal(action)
+
+	client := testclient.NewSimpleClientset()
+	h := NewTestMixin(t)
+	h.In = bytes.NewReader(b)
+	h.ClientFactory = &staticKubernetesFactory{client: client}
+	h.Setenv(eventsEnv, "true")
+	h.Setenv(bundleNameEnv, "wordpress")
+	h.Setenv(installationNameEnv, "blog")
+	h.Setenv(actionEnv, "install")
+
+	err := h.Install(ctx)
+	require.NoError(t, err)
+
+	events, err := client.CoreV1().Events("db").List(ctx, metav1.ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, events.Items, 1)
+	event := events.Items[0]
+	assert.Equal(t, corev1.EventTypeNormal, event.Type)
+	assert.Equal(t, "HelmInstall", event.Reason)
+	assert.Equal(t, `install of release mysql by step "Install MySQL" of the install action of bundle wordpress (installation blog) succeeded`, event.Message)
+	assert.Equal(t, corev1.ObjectReference{APIVersion: "v1", Kind: "Namespace", Name: "db"}, event.InvolvedObject)
+	assert.Equal(t, "Install MySQL", event.Annotations["porter.sh/step"])
+}
+
+func TestMixin_RecordEvent(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("failure", func(t *testing.T) {
+		client := testclient.NewSimpleClientset()
+		h := NewTestMixin(t)
+		h.ClientFactory = &staticKubernetesFactory{client: client}
+		h.Setenv(eventsEnv, "true")
+
+		h.recordEvent(ctx, "Uninstall MySQL", "uninstall", "mysql", "", errors.New("timed out"))
+
+		events, err := client.CoreV1().Events("default").List(ctx, metav1.ListOptions{})
+		require.NoError(t, err)
+		require.Len(t, events.Items, 1)
+		assert.Equal(t, corev1.EventTypeWarning, events.Items[0].Type)
+		assert.Equal(t, "HelmUninstallFailed", events.Items[0].Reason)
+		assert.Contains(t, events.Items[0].Message, "failed: timed out")
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		client := testclient.NewSimpleClientset()
+		h := NewTestMixin(t)
+		h.ClientFactory = &staticKubernetesFactory{client: client}
+
+		h.recordEvent(ctx, "Uninstall MySQL", "uninstall", "mysql", "db", nil)
+
+		events, err := client.CoreV1().Events("db").List(ctx, metav1.ListOptions{})
+		require.NoError(t, err)
+		assert.Empty(t, events.Items)
+	})
+}
@@ -1,7 +1,10 @@
 package helm3
 
 import (
+	"bytes"
 	"context"
+	"fmt"
+	"io"
 
 	"get.porter.sh/porter/pkg/exec/builder"
 	"github.com/pkg/errors"
@@ -11,7 +14,15 @@ import (
 func (m *Mixin) loadAction(ctx context.Context) (*Action, error) {
 	var action Action
 	err := builder.LoadAction(ctx, m.RuntimeConfig, "", func(contents []byte) (interface{}, error) {
-		err := yaml.Unmarshal(contents, &action)
+		// The builder decodes the steps leniently, so check for unknown fields up front
+		err := m.unmarshal(contents, &map[string][]ExecuteSteps{})
+		if err != nil {
+			return &action, err
+		}
+		err = yaml.Unmarshal(contents, &action)
+		for i := range action.Steps {
+			action.Steps[i].command = m.helmBinary()
+		}
 		return &action, err
 	})
 	return &action, err
@@ -27,9 +38,83 @@ func (m *Mixin) Execute(ctx context.Context) error {
 	}
 	step := action.Steps[0]
 
-	_, err = builder.ExecuteSingleStepAction(ctx, m.RuntimeConfig, action)
+	err = m.setVerbosity(step.Step)
 	if err != nil {
-		return errors.Wrapf(err, "invocation of action %s failed", action)
+		return err
+	}
+
+	err = m.setGlobalFlags(step.Step)
+	if err != nil {
+		return err
+	}
+
+	err = m.validateStepHooks(step.Step)
+	if err != nil {
+		return err
+	}
+
+	err = m.checkStorageDriver()
+	if err != nil {
+		return err
+	}
+
+	removeKubeconfig, err := m.configureKubeToken()
+	if err != nil {
+		return err
+	}
+	defer removeKubeconfig()
+
+	err = step.validateOperation()
+	if err != nil {
+		return err
+	}
+
+	err = m.runStepHooks(ctx, step.Step, step.Before, hookBefore)
+	if err != nil {
+		return err
+	}
+
+	var stdout string
+	switch {
+	case step.Diagnostics:
+		stdout, err = m.diagnostics(ctx, step.ExecuteStep)
+	case step.Status != nil:
+		stdout, err = m.status(ctx, step.ExecuteStep)
+	case step.Exists != nil:
+		stdout, err = m.exists(ctx, step.ExecuteStep)
+	case step.GcHistory != nil:
+		stdout, err = m.gcHistory(ctx, step.ExecuteStep)
+	case step.GetHooks != nil:
+		stdout, err = m.getHooks(ctx, step.ExecuteStep)
+	case step.Promote != nil:
+		stdout, err = m.promote(ctx, step.ExecuteStep)
+	case step.Verify != nil:
+		stdout, err = m.verifyProvenance(ctx, step.ExecuteStep)
+	case step.DependencyList != nil:
+		stdout, err = m.dependencyList(ctx, step.ExecuteStep)
+	case step.RevisionDiff != nil:
+		stdout, err = m.revisionDiff(ctx, step.ExecuteStep)
+	default:
+		action.Steps[0].globalFlags = m.globalBuilderFlags()
+		step.globalFlags = action.Steps[0].globalFlags
+		stdout, err = m.executeCommand(ctx, action, step.ExecuteStep)
+	}
+	if err != nil {
+		return err
+	}
+
+	err = m.runStepHooks(ctx, step.Step, step.After, hookAfter)
+	if err != nil || m.planMode() {
+		return err
+	}
+
+	for _, output := range step.Outputs {
+		if output.Stdout {
+			err = m.writeOutput(output, []byte(stdout))
+			if err != nil {
+				return errors.Wrapf(err, "unable to write output '%s'", output.Name)
+			}
+		}
 	}
 
 	kubeClient, err := m.getKubernetesClient()
@@ -37,6 +122,50 @@ func (m *Mixin) Execute(ctx context.Context) error {
 		return errors.Wrap(err, "couldn't get kubernetes client")
 	}
 
-	err = m.handleOutputs(ctx, kubeClient, step.Namespace, step.Outputs)
+	err = m.handleOutputs(ctx, kubeClient, step.Namespace, step.Outputs, nil)
 	return err
 }
+
+// executeCommand runs the helm command of a custom action step and returns what it printed
+func (m *Mixin) executeCommand(ctx context.Context, action *Action, step ExecuteStep) (string, error) {
+	err := m.validateInvokeCommand(step)
+	if err != nil {
+		return "", err
+	}
+
+	err = step.validateRenderTarget()
+	if err != nil {
+		return "", err
+	}
+
+	err = step.validateSkipSchemaValidation()
+	if err != nil {
+		return "", err
+	}
+
+	// Passwords and secret values are redacted from the printed command and from errors
+	args := append([]string{step.GetCommand()}, step.GetArguments()...)
+	args = append(args, step.GetFlags().ToSlice(builder.DefaultFlagDashes)...)
+	prettyCmd := formatPlannedCommand(args)
+	if m.planMode() {
+		fmt.Fprintln(m.Out, prettyCmd)
+		return "", nil
+	}
+	m.echoCommand(prettyCmd)
+
+	// The builder streams the error output of the command to the mixin, keep a copy to classify failures
+	stderr := &bytes.Buffer{}
+	mixinErr := m.Err
+	m.Err = io.MultiWriter(mixinErr, stderr)
+	defer func() { m.Err = mixinErr }()
+	// The output is still returned for the outputs of the step when it is not printed
+	mixinOut := m.Out
+	m.Out = m.commandStdout()
+	defer func() { m.Out = mixinOut }()
+
+	stdout, err := builder.ExecuteSingleStepAction(ctx, m.RuntimeConfig, action)
+	if err != nil {
+		return "", classifyHelmError(errors.Wrapf(errors.Cause(err), "invocation of %s failed", prettyCmd), stderr.String())
+	}
+	return stdout, nil
+}
@@ -5,10 +5,13 @@ import (
 	"context"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"sort"
+	"strings"
 	"testing"
 
 	"get.porter.sh/porter/pkg/exec/builder"
+	"get.porter.sh/porter/pkg/portercontext"
 	"get.porter.sh/porter/pkg/test"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -161,3 +164,201 @@ func TestMixin_Execute(t *testing.T) {
 	err := h.Execute(ctx)
 	require.NoError(t, err)
 }
+
+func TestMixin_ExecuteClientBinary(t *testing.T) {
+	ctx := context.Background()
+
+	defer os.Unsetenv(test.ExpectedCommandEnv)
+	os.Setenv(test.ExpectedCommandEnv, "helm status mysql")
+
+	executeAction := Action{
+		Steps: []ExecuteSteps{
+			{
+				ExecuteStep: ExecuteStep{
+					Arguments: []string{
+						"status",
+						"mysql",
+					},
+				},
+			},
+		},
+	}
+
+	b, _ := yaml.Marshal(executeAction)
+
+	h := NewTestMixin(t)
+	h.Setenv(clientBinaryEnv, "helm")
+	h.In = bytes.NewReader(b)
+
+	err := h.Execute(ctx)
+	require.NoError(t, err)
+}
+
+func TestMixin_ExecuteTemplateRenderTarget(t *testing.T) {
+	ctx := context.Background()
+
+	defer os.Unsetenv(test.ExpectedCommandEnv)
+	os.Setenv(test.ExpectedCommandEnv, "helm3 template mysql stable/mysql --api-versions monitoring.coreos.com/v1 --api-versions policy/v1/PodDisruptionBudget --kube-version v1.27.3")
+
+	executeAction := Action{
+		Steps: []ExecuteSteps{
+			{
+				ExecuteStep: ExecuteStep{
+					Arguments:   []string{"template", "mysql", "stable/mysql"},
+					KubeVersion: "v1.27.3",
+					APIVersions: []string{"monitoring.coreos.com/v1", "policy/v1/PodDisruptionBudget"},
+				},
+			},
+		},
+	}
+
+	b, _ := yaml.Marshal(executeAction)
+
+	h := NewTestMixin(t)
+	h.In = bytes.NewReader(b)
+
+	err := h.Execute(ctx)
+	require.NoError(t, err)
+}
+
+func TestMixin_ExecuteLintSkipSchemaValidation(t *testing.T) {
+	ctx := context.Background()
+
+	defer os.Unsetenv(test.ExpectedCommandEnv)
+	os.Setenv(test.ExpectedCommandEnv, "helm3 lint ./charts/mysql --skip-schema-validation")
+
+	executeAction := Action{
+		Steps: []ExecuteSteps{
+			{
+				ExecuteStep: ExecuteStep{
+					Arguments:            []string{"lint", "./charts/mysql"},
+					SkipSchemaValidation: true,
+				},
+			},
+		},
+	}
+
+	b, _ := yaml.Marshal(executeAction)
+
+	h := NewTestMixin(t)
+	h.In = bytes.NewReader(b)
+
+	err := h.Execute(ctx)
+	require.NoError(t, err)
+
+	executeAction.Steps[0].Arguments = []string{"status", "mysql"}
+	b, _ = yaml.Marshal(executeAction)
+	h.In = bytes.NewReader(b)
+
+	err = h.Execute(ctx)
+	require.EqualError(t, err, "skipSchemaValidation can only be set when the step runs the install, upgrade, template or lint command")
+}
+
+func TestMixin_ExecuteValidationAfterGlobalFlag(t *testing.T) {
+	ctx := context.Background()
+
+	defer os.Unsetenv(test.ExpectedCommandEnv)
+	os.Setenv(test.ExpectedCommandEnv, strings.Join([]string{
+		"helm3 --kube-context dev lint ./charts/mysql --skip-schema-validation",
+		"helm3 --kube-context dev template mysql stable/mysql --kube-version v1.27.3",
+	}, "\n"))
+
+	testcases := []struct {
+		name      string
+		step      ExecuteStep
+		wantError string
+	}{
+		{name: "skipSchemaValidation", step: ExecuteStep{
+			Arguments:            []string{"--kube-context", "dev", "lint", "./charts/mysql"},
+			SkipSchemaValidation: true,
+		}},
+		{name: "kubeVersion", step: ExecuteStep{
+			Arguments:   []string{"--kube-context", "dev", "template", "mysql", "stable/mysql"},
+			KubeVersion: "v1.27.3",
+		}},
+		{name: "skipSchemaValidation on status", step: ExecuteStep{
+			Arguments:            []string{"--kube-context", "dev", "status", "mysql"},
+			SkipSchemaValidation: true,
+		}, wantError: "skipSchemaValidation can only be set when the step runs the install, upgrade, template or lint command"},
+		{name: "kubeVersion on status", step: ExecuteStep{
+			Arguments:   []string{"--kube-context", "dev", "status", "mysql"},
+			KubeVersion: "v1.27.3",
+		}, wantError: "kubeVersion and apiVersions can only be set when the step runs the template command"},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			b, _ := yaml.Marshal(Action{Steps: []ExecuteSteps{{ExecuteStep: tc.step}}})
+
+			h := NewTestMixin(t)
+			h.In = bytes.NewReader(b)
+
+			err := h.Execute(ctx)
+			if tc.wantError != "" {
+				require.EqualError(t, err, tc.wantError)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestMixin_ExecuteRenderTargetRequiresTemplate(t *testing.T) {
+	ctx := context.Background()
+
+	executeAction := Action{
+		Steps: []ExecuteSteps{
+			{
+				ExecuteStep: ExecuteStep{
+					Arguments:   []string{"status", "mysql"},
+					KubeVersion: "v1.27.3",
+				},
+			},
+		},
+	}
+
+	b, _ := yaml.Marshal(executeAction)
+
+	h := NewTestMixin(t)
+	h.In = bytes.NewReader(b)
+
+	err := h.Execute(ctx)
+	require.EqualError(t, err, "kubeVersion and apiVersions can only be set when the step runs the template command")
+}
+
+func TestMixin_ExecutePlugin(t *testing.T) {
+	ctx := context.Background()
+
+	defer os.Unsetenv(test.ExpectedCommandEnv)
+	defer os.Unsetenv(test.ExpectedCommandOutputEnv)
+	os.Setenv(test.ExpectedCommandEnv, "helm3 mapkubeapis mysql --dry-run --namespace db")
+	os.Setenv(test.ExpectedCommandOutputEnv, "Release 'mysql' with deprecated or removed APIs updated successfully")
+
+	executeAction := Action{
+		Steps: []ExecuteSteps{
+			{
+				ExecuteStep: ExecuteStep{
+					Step: Step{
+						Description: "Map deprecated APIs",
+						Outputs:     []HelmOutput{{Name: "mapkubeapis-report", Stdout: true}},
+					},
+					Plugin:    "mapkubeapis",
+					Arguments: []string{"mysql", "--dry-run"},
+					Flags:     builder.Flags{builder.NewFlag("namespace", "db")},
+				},
+			},
+		},
+	}
+
+	b, _ := yaml.Marshal(executeAction)
+
+	h := NewTestMixin(t)
+	h.Setenv(allowedCommandsEnv, "mapkubeapis")
+	h.In = bytes.NewReader(b)
+
+	err := h.Execute(ctx)
+	require.NoError(t, err)
+
+	report, err := h.FileSystem.ReadFile(filepath.Join(portercontext.MixinOutputsDir, "mapkubeapis-report"))
+	require.NoError(t, err)
+	assert.Equal(t, "Release 'mysql' with deprecated or removed APIs updated successfully", string(report))
+}
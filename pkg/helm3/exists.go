@@ -0,0 +1,45 @@
+package helm3
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+// ReleaseExistence checks if a release is installed, so that the following steps or actions of the bundle can
+// depend on it
+type ReleaseExistence struct {
+	Release string `yaml:"release"`
+	// Healthy only reports a release that is deployed, instead of a release with any status such as failed
+	Healthy bool `yaml:"healthy,omitempty"`
+}
+
+// exists prints true when the release exists in the namespace of the step, and false otherwise
+func (m *Mixin) exists(ctx context.Context, step ExecuteStep) (string, error) {
+	existence := *step.Exists
+	if len(step.Arguments) > 0 || step.Plugin != "" {
+		return "", errors.New("exists cannot be set together with arguments or plugin")
+	}
+	if existence.Release == "" {
+		return "", errors.New("exists requires a release")
+	}
+
+	statusFlag := "--all"
+	if existence.Healthy {
+		statusFlag = "--deployed"
+	}
+	if m.planMode() {
+		m.printPlannedCommand(m.releaseListCommand(ctx, existence.Release, step.Namespace, statusFlag))
+		return "", nil
+	}
+
+	found, err := m.releaseListed(ctx, existence.Release, step.Namespace, statusFlag)
+	if err != nil {
+		return "", err
+	}
+	result := strconv.FormatBool(found)
+	fmt.Fprintln(m.Out, result)
+	return result, nil
+}
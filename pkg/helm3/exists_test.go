@@ -0,0 +1,65 @@
+package helm3
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"get.porter.sh/porter/pkg/portercontext"
+	"get.porter.sh/porter/pkg/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestMixin_ExecuteExists(t *testing.T) {
+	testcases := []struct {
+		name     string
+		healthy  bool
+		command  string
+		output   string
+		expected string
+	}{
+		{name: "installed", command: "helm3 list --all --short --filter ^mysql$ --namespace db", output: "mysql\n", expected: "true"},
+		{name: "missing", command: "helm3 list --all --short --filter ^mysql$ --namespace db", output: "", expected: "false"},
+		{name: "healthy", healthy: true, command: "helm3 list --deployed --short --filter ^mysql$ --namespace db", output: "mysql\n", expected: "true"},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			defer os.Unsetenv(test.ExpectedCommandEnv)
+			defer os.Unsetenv(test.ExpectedCommandOutputEnv)
+			os.Setenv(test.ExpectedCommandEnv, tc.command)
+			os.Setenv(test.ExpectedCommandOutputEnv, tc.output)
+
+			executeAction := Action{
+				Steps: []ExecuteSteps{
+					{
+						ExecuteStep: ExecuteStep{
+							Step: Step{
+								Description: "MySQL exists",
+								Outputs:     []HelmOutput{{Name: "mysql-exists", Stdout: true}},
+							},
+							Namespace: "db",
+							Exists:    &ReleaseExistence{Release: "mysql", Healthy: tc.healthy},
+						},
+					},
+				},
+			}
+			b, _ := yaml.Marshal(executeAction)
+
+			h := NewTestMixin(t)
+			h.In = bytes.NewReader(b)
+
+			err := h.Execute(ctx)
+			require.NoError(t, err)
+
+			exists, err := h.FileSystem.ReadFile(filepath.Join(portercontext.MixinOutputsDir, "mysql-exists"))
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, string(exists))
+		})
+	}
+}
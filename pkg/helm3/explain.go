@@ -0,0 +1,133 @@
+package helm3
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+
+	"get.porter.sh/porter/pkg/exec/builder"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+// bundleReferenceRegex matches the parameters and credentials of the bundle referenced by the templates of a step
+var bundleReferenceRegex = regexp.MustCompile(`bundle\.(parameters|credentials)\.([a-zA-Z0-9_-]+)`)
+
+// StepMetadata reports the parameters and credentials of the bundle consumed by a helm3 step, and the outputs it
+// produces, so that porter can aggregate them for the bundle
+type StepMetadata struct {
+	Action          string   `json:"action"`
+	StepNumber      int      `json:"stepNumber"`
+	StepDescription string   `json:"stepDescription"`
+	Parameters      []string `json:"parameters"`
+	Credentials     []string `json:"credentials"`
+	Outputs         []string `json:"outputs"`
+}
+
+// stepProducts is used to decode the fields of a step that consume parameters or produce outputs
+type stepProducts struct {
+	SetFileFromParameter map[string]string `yaml:"setFileFromParameter"`
+	Jobs                 []JobWait         `yaml:"jobs"`
+	Prune                *Prune            `yaml:"prune"`
+	Outputs              []HelmOutput      `yaml:"outputs"`
+}
+
+// Explain returns the metadata of the helm3 steps of every action, sorted by action
+func (m *Mixin) Explain(ctx context.Context) ([]StepMetadata, error) {
+	var contents []byte
+	err := builder.LoadAction(ctx, m.RuntimeConfig, "", func(b []byte) (interface{}, error) {
+		contents = b
+		return nil, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	steps := []StepMetadata{}
+	err = forEachHelm3Step(contents, func(action string, index int, step helm3Step) error {
+		metadata, err := explainStep(step)
+		if err != nil {
+			return errors.Wrapf(err, "could not explain step %d of the %s action", index+1, action)
+		}
+		metadata.Action = action
+		metadata.StepNumber = index + 1
+		steps = append(steps, metadata)
+		return nil
+	})
+	return steps, err
+}
+
+// explainStep collects the parameters and credentials referenced by the templates of a step, along with the parameters
+// of setFileFromParameter, and the outputs written by the step, its jobs and its prune
+func explainStep(step helm3Step) (StepMetadata, error) {
+	raw, err := yaml.Marshal(step.raw)
+	if err != nil {
+		return StepMetadata{}, err
+	}
+	var products stepProducts
+	err = yaml.Unmarshal(raw, &products)
+	if err != nil {
+		return StepMetadata{}, err
+	}
+
+	parameters := make(map[string]bool)
+	credentials := make(map[string]bool)
+	for _, match := range bundleReferenceRegex.FindAllStringSubmatch(string(raw), -1) {
+		if match[1] == "parameters" {
+			parameters[match[2]] = true
+		} else {
+			credentials[match[2]] = true
+		}
+	}
+	for _, parameter := range products.SetFileFromParameter {
+		parameters[parameter] = true
+	}
+
+	outputs := make(map[string]bool)
+	for _, output := range products.Outputs {
+		outputs[output.Name] = true
+	}
+	for _, job := range products.Jobs {
+		if job.Output != "" {
+			outputs[job.Output] = true
+		}
+	}
+	if products.Prune != nil && products.Prune.Output != "" {
+		outputs[products.Prune.Output] = true
+	}
+
+	return StepMetadata{
+		StepDescription: step.Description,
+		Parameters:      sortedNames(parameters),
+		Credentials:     sortedNames(credentials),
+		Outputs:         sortedNames(outputs),
+	}, nil
+}
+
+// sortedNames returns the names of a set, sorted
+func sortedNames(set map[string]bool) []string {
+	names := make([]string, 0, len(set))
+	for name := range set {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// PrintExplain prints the metadata of the helm3 steps as json so that porter can aggregate it.
+func (m *Mixin) PrintExplain(ctx context.Context) error {
+	steps, err := m.Explain(ctx)
+	if err != nil {
+		return err
+	}
+
+	b, err := json.MarshalIndent(steps, "", "  ")
+	if err != nil {
+		return errors.Wrapf(err, "could not marshal the step metadata %#v", steps)
+	}
+
+	fmt.Fprintln(m.Out, string(b))
+	return nil
+}
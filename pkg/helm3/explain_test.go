@@ -0,0 +1,80 @@
+package helm3
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMixin_Explain(t *testing.T) {
+	ctx := context.Background()
+	b, err := ioutil.ReadFile("testdata/explain-input.yaml")
+	require.NoError(t, err)
+
+	m := NewTestMixin(t)
+	m.In = bytes.NewReader(b)
+
+	steps, err := m.Explain(ctx)
+	require.NoError(t, err)
+
+	assert.Equal(t, []StepMetadata{
+		{
+			Action:          "install",
+			StepNumber:      1,
+			StepDescription: "Install MySQL",
+			Parameters:      []string{"database-name", "init-script", "namespace"},
+			Credentials:     []string{"mysql-password"},
+			Outputs:         []string{"init-logs", "mysql-root-password"},
+		},
+		{
+			Action:          "status",
+			StepNumber:      1,
+			StepDescription: "MySQL status",
+			Parameters:      []string{},
+			Credentials:     []string{},
+			Outputs:         []string{"status"},
+		},
+		{
+			Action:          "uninstall",
+			StepNumber:      2,
+			StepDescription: "Uninstall MySQL",
+			Parameters:      []string{},
+			Credentials:     []string{},
+			Outputs:         []string{},
+		},
+		{
+			Action:          "upgrade",
+			StepNumber:      1,
+			StepDescription: "Upgrade MySQL",
+			Parameters:      []string{"namespace"},
+			Credentials:     []string{},
+			Outputs:         []string{"pruned"},
+		},
+	}, steps)
+}
+
+func TestMixin_PrintExplain(t *testing.T) {
+	ctx := context.Background()
+	m := NewTestMixin(t)
+	m.In = bytes.NewReader([]byte(`
+install:
+  - helm3:
+      description: "Install MySQL"
+      name: mysql
+      chart: bitnami/mysql
+`))
+
+	err := m.PrintExplain(ctx)
+	require.NoError(t, err)
+
+	var steps []map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(m.TestContext.GetOutput()), &steps))
+	require.Len(t, steps, 1)
+	assert.Equal(t, "install", steps[0]["action"])
+	assert.Equal(t, []interface{}{}, steps[0]["parameters"], "empty lists should be printed as arrays")
+}
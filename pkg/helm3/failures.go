@@ -0,0 +1,128 @@
+package helm3
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// FailureReason classifies why a helm command failed
+type FailureReason string
+
+const (
+	// ReasonAuthentication is returned when the cluster or a registry rejected the credentials
+	ReasonAuthentication FailureReason = "authentication"
+	// ReasonReleaseNotFound is returned when the release does not exist or has no deployed revision
+	ReasonReleaseNotFound FailureReason = "release-not-found"
+	// ReasonTimeout is returned when helm gave up waiting for the resources of the release
+	ReasonTimeout FailureReason = "timeout"
+	// ReasonImmutableField is returned when the upgrade changes a field that kubernetes does not allow to be updated
+	ReasonImmutableField FailureReason = "immutable-field"
+	// ReasonWebhookDenied is returned when an admission webhook denied a resource of the release
+	ReasonWebhookDenied FailureReason = "webhook-denied"
+	// ReasonOperationInProgress is returned when another install, upgrade or rollback holds the lock of the release
+	ReasonOperationInProgress FailureReason = "operation-in-progress"
+)
+
+// exitCodes are the exit codes of the mixin for each failure reason, other failures exit with 1
+var exitCodes = map[FailureReason]int{
+	ReasonAuthentication:      10,
+	ReasonReleaseNotFound:     11,
+	ReasonTimeout:             12,
+	ReasonImmutableField:      13,
+	ReasonWebhookDenied:       14,
+	ReasonOperationInProgress: 15,
+}
+
+// failurePatterns are matched in order against the lowercased error output of helm,
+// webhook denials come first because the message of the webhook may itself mention other failures
+var failurePatterns = []struct {
+	reason   FailureReason
+	patterns []string
+}{
+	{ReasonWebhookDenied, []string{"admission webhook", "denied the request"}},
+	{ReasonImmutableField, []string{"field is immutable"}},
+	{ReasonAuthentication, []string{"unauthorized", "you must be logged in to the server", "authentication required",
+		"invalid username or password"}},
+	{ReasonReleaseNotFound, []string{"release: not found", "has no deployed releases", "release not loaded"}},
+	{ReasonOperationInProgress, []string{"another operation (install/upgrade/rollback) is in progress"}},
+	{ReasonTimeout, []string{"timed out waiting for the condition", "context deadline exceeded"}},
+}
+
+// HelmError is a failure of a helm command that was classified from its error output
+type HelmError struct {
+	Reason FailureReason
+	Err    error
+}
+
+func (e *HelmError) Error() string {
+	return e.Err.Error()
+}
+
+// Cause returns the error returned by the command, for errors.Cause
+func (e *HelmError) Cause() error {
+	return e.Err
+}
+
+// Unwrap returns the error returned by the command, for errors.Is and errors.As
+func (e *HelmError) Unwrap() error {
+	return e.Err
+}
+
+// ExitCode returns the exit code of the mixin for the failure reason
+func (e *HelmError) ExitCode() int {
+	return exitCodes[e.Reason]
+}
+
+// stderrTailSize is the size of the end of the error output of a command that is kept to classify its failure
+const stderrTailSize = 64 * 1024
+
+// stderrTail keeps the end of the error output of a command, where helm prints its error, so that the large output
+// of a command run with --debug is not held in memory
+type stderrTail struct {
+	buf []byte
+}
+
+func (t *stderrTail) Write(p []byte) (int, error) {
+	n := len(p)
+	if n > stderrTailSize {
+		p = p[n-stderrTailSize:]
+	}
+	t.buf = append(t.buf, p...)
+	if len(t.buf) > stderrTailSize {
+		t.buf = append(t.buf[:0], t.buf[len(t.buf)-stderrTailSize:]...)
+	}
+	return n, nil
+}
+
+func (t *stderrTail) String() string {
+	return string(t.buf)
+}
+
+// classifyHelmError wraps the error of a helm command in a HelmError when its error output matches a known failure
+func classifyHelmError(err error, stderr string) error {
+	if err == nil {
+		return nil
+	}
+	output := strings.ToLower(stderr)
+	for _, failure := range failurePatterns {
+		for _, pattern := range failure.patterns {
+			if strings.Contains(output, pattern) {
+				return &HelmError{Reason: failure.reason, Err: err}
+			}
+		}
+	}
+	return err
+}
+
+// ExitCode returns the exit code of the mixin for an error returned by one of its commands
+func ExitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	var helmErr *HelmError
+	if errors.As(err, &helmErr) {
+		return helmErr.ExitCode()
+	}
+	return 1
+}
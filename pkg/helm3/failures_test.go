@@ -0,0 +1,74 @@
+package helm3
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"testing"
+
+	"get.porter.sh/porter/pkg/test"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestClassifyHelmError(t *testing.T) {
+	testcases := []struct {
+		stderr     string
+		wantReason FailureReason
+		wantCode   int
+	}{
+		{stderr: "Error: Kubernetes cluster unreachable: the server has asked for the client to provide credentials: Unauthorized",
+			wantReason: ReasonAuthentication, wantCode: 10},
+		{stderr: `Error: UPGRADE FAILED: "mysql" has no deployed releases`, wantReason: ReasonReleaseNotFound, wantCode: 11},
+		{stderr: "Error: INSTALLATION FAILED: timed out waiting for the condition", wantReason: ReasonTimeout, wantCode: 12},
+		{stderr: `Error: UPGRADE FAILED: cannot patch "mysql" with kind StatefulSet: StatefulSet.apps "mysql" is invalid: spec: Forbidden: updates to statefulset spec for fields other than 'replicas' are forbidden; spec.selector: Invalid value: field is immutable`,
+			wantReason: ReasonImmutableField, wantCode: 13},
+		{stderr: `Error: INSTALLATION FAILED: admission webhook "validation.gatekeeper.sh" denied the request: [required-labels] missing required label`,
+			wantReason: ReasonWebhookDenied, wantCode: 14},
+		{stderr: "Error: INSTALLATION FAILED: chart requires kubeVersion: >=1.25.0", wantCode: 1},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.stderr, func(t *testing.T) {
+			err := classifyHelmError(errors.New("exit status 1"), tc.stderr)
+			require.EqualError(t, err, "exit status 1")
+
+			var helmErr *HelmError
+			if tc.wantReason == "" {
+				assert.False(t, errors.As(err, &helmErr), "expected the error to be left unclassified")
+			} else {
+				require.True(t, errors.As(err, &helmErr), "expected the error to be classified")
+				assert.Equal(t, tc.wantReason, helmErr.Reason)
+			}
+			assert.Equal(t, tc.wantCode, ExitCode(errors.Wrap(err, "step failed")))
+		})
+	}
+}
+
+func TestMixin_InstallFailureReason(t *testing.T) {
+	ctx := context.Background()
+	defer os.Unsetenv(test.ExpectedCommandEnv)
+	defer os.Unsetenv(test.ExpectedCommandErrorEnv)
+	defer os.Unsetenv(test.ExpectedCommandExitCodeEnv)
+	os.Setenv(test.ExpectedCommandEnv, "helm3 upgrade --install mysql stable/mysql --atomic --create-namespace --output json")
+	os.Setenv(test.ExpectedCommandErrorEnv, "Error: INSTALLATION FAILED: timed out waiting for the condition")
+	os.Setenv(test.ExpectedCommandExitCodeEnv, "1")
+
+	action := InstallAction{Steps: []InstallStep{{
+		InstallArguments: InstallArguments{
+			Step:  Step{Description: "Install MySQL"},
+			Name:  "mysql",
+			Chart: "stable/mysql",
+		},
+	}}}
+	b, _ := yaml.Marshal(action)
+
+	h := NewTestMixin(t)
+	h.In = bytes.NewReader(b)
+
+	err := h.Install(ctx)
+	require.Error(t, err)
+	assert.Equal(t, 12, ExitCode(err))
+}
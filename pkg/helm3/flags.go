@@ -0,0 +1,112 @@
+package helm3
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// installManagedFlags are the helm flags set by the mixin for an install step, mapped to the field that controls them
+var installManagedFlags = map[string]string{
+	"--install":                "",
+	"--generate-name":          "randomName",
+	"-g":                       "randomName",
+	"--namespace":              "namespace",
+	"-n":                       "namespace",
+	"--version":                "version",
+	"--wait":                   "wait",
+	"--devel":                  "devel",
+	"--values":                 "values",
+	"-f":                       "values",
+	"--skip-crds":              "skipCrds",
+	"--no-hooks":               "noHooks",
+	"--repo":                   "repo",
+	"--username":               "username",
+	"--password":               "password",
+	"--timeout":                "timeout",
+	"--debug":                  "debug",
+	"--take-ownership":         "takeOwnership",
+	"--skip-schema-validation": "skipSchemaValidation",
+	"--atomic":                 "",
+	"--rollback-on-failure":    "",
+	"--create-namespace":       "",
+	"--output":                 "",
+	"-o":                       "",
+	"--set":                    "set",
+	"--set-json":               "setTyped",
+	"--dry-run":                "dryRun",
+	"--hide-secret":            "dryRun",
+}
+
+// upgradeManagedFlags are the helm flags set by the mixin for an upgrade step, mapped to the field that controls them
+var upgradeManagedFlags = map[string]string{
+	"--install":                 "installIfMissing",
+	"--namespace":               "namespace",
+	"-n":                        "namespace",
+	"--version":                 "version",
+	"--reset-values":            "resetValues",
+	"--reuse-values":            "reuseValues",
+	"--reset-then-reuse-values": "resetThenReuseValues",
+	"--wait":                    "wait",
+	"--values":                  "values",
+	"-f":                        "values",
+	"--skip-crds":               "skipCrds",
+	"--no-hooks":                "noHooks",
+	"--repo":                    "repo",
+	"--username":                "username",
+	"--password":                "password",
+	"--timeout":                 "timeout",
+	"--debug":                   "debug",
+	"--take-ownership":          "takeOwnership",
+	"--skip-schema-validation":  "skipSchemaValidation",
+	"--atomic":                  "",
+	"--rollback-on-failure":     "",
+	"--create-namespace":        "",
+	"--output":                  "",
+	"-o":                        "",
+	"--set":                     "set",
+	"--set-json":                "setTyped",
+	"--dry-run":                 "dryRun",
+	"--hide-secret":             "dryRun",
+}
+
+// uninstallManagedFlags are the helm flags set by the mixin for an uninstall step, mapped to the field that controls them
+var uninstallManagedFlags = map[string]string{
+	"--namespace": "namespace",
+	"-n":          "namespace",
+	"--no-hooks":  "noHooks",
+	"--wait":      "wait",
+	"--timeout":   "timeout",
+	"--debug":     "debug",
+}
+
+// appendExtraFlags appends the extra flags declared on a step to the command arguments,
+// rejecting any flag that is already managed by the mixin.
+func appendExtraFlags(args []string, extraFlags []string, managedFlags map[string]string) ([]string, error) {
+	for _, flag := range extraFlags {
+		if !strings.HasPrefix(flag, "-") {
+			// a value for the previous flag
+			continue
+		}
+		name := strings.SplitN(flag, "=", 2)[0]
+		field, managed := managedFlags[name]
+		if !managed {
+			continue
+		}
+		if field == "" {
+			return nil, errors.Errorf("extra flag %s conflicts with a flag that is always set by the mixin", name)
+		}
+		return nil, errors.Errorf("extra flag %s conflicts with a flag managed by the mixin, use the %s field instead", name, field)
+	}
+	return append(args, extraFlags...), nil
+}
+
+// hasFlag checks if a flag is set in the command arguments, with or without a value
+func hasFlag(args []string, flag string) bool {
+	for _, arg := range args {
+		if arg == flag || strings.HasPrefix(arg, flag+"=") {
+			return true
+		}
+	}
+	return false
+}
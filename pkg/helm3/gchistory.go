@@ -0,0 +1,200 @@
+package helm3
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// deployedStatus is the status of the revision of a release that is deployed, it is never deleted
+const deployedStatus = "deployed"
+
+// HistoryGC deletes the old revisions of releases, for clusters where they were installed without a history limit
+// and accumulated thousands of secrets
+type HistoryGC struct {
+	Releases []string `yaml:"releases"`
+	// Keep is the number of most recent revisions kept for each release
+	Keep int `yaml:"keep,omitempty"`
+	// MaxAge also deletes the revisions older than a duration, such as 720h
+	MaxAge string `yaml:"maxAge,omitempty"`
+}
+
+// releaseRevision is a revision of a release stored by helm
+type releaseRevision struct {
+	Release   string `json:"release"`
+	Namespace string `json:"namespace"`
+	Revision  int    `json:"revision"`
+	// object is the secret or configmap storing the revision
+	object  string
+	status  string
+	created time.Time
+}
+
+// validate checks that the revisions to delete are defined
+func (gc HistoryGC) validate() error {
+	if len(gc.Releases) == 0 {
+		return errors.New("gcHistory requires the releases to trim")
+	}
+	if gc.Keep < 0 {
+		return errors.Errorf("invalid gcHistory keep %d", gc.Keep)
+	}
+	if gc.Keep == 0 && gc.MaxAge == "" {
+		return errors.New("gcHistory requires keep or maxAge")
+	}
+	if gc.MaxAge != "" {
+		maxAge, err := time.ParseDuration(gc.MaxAge)
+		if err != nil || maxAge <= 0 {
+			return errors.Errorf("invalid gcHistory maxAge %q, expected a duration such as 720h", gc.MaxAge)
+		}
+	}
+	return nil
+}
+
+// gcHistory deletes the revisions of the releases beyond the retention of the step, in the namespace of the step,
+// and prints the deleted revisions as json. The last revision and the deployed revision of a release are always kept.
+func (m *Mixin) gcHistory(ctx context.Context, step ExecuteStep) (string, error) {
+	gc := *step.GcHistory
+	if len(step.Arguments) > 0 || step.Plugin != "" {
+		return "", errors.New("gcHistory cannot be set together with arguments or plugin")
+	}
+	err := gc.validate()
+	if err != nil {
+		return "", err
+	}
+	maxAge, _ := time.ParseDuration(gc.MaxAge)
+
+	namespace := step.Namespace
+	if namespace == "" {
+		namespace = "default"
+	}
+	if m.planMode() {
+		for _, release := range gc.Releases {
+			fmt.Fprintf(m.Out, "# history of release %s in namespace %s: keep %d revisions, maxAge %s\n",
+				release, namespace, gc.Keep, gc.MaxAge)
+		}
+		return "", nil
+	}
+
+	deleted := []releaseRevision{}
+	for _, release := range gc.Releases {
+		revisions, err := m.releaseRevisions(ctx, release, namespace)
+		if err != nil {
+			return "", err
+		}
+		for _, r := range expiredRevisions(revisions, gc.Keep, maxAge, time.Now()) {
+			err = m.deleteRevision(ctx, r)
+			if err != nil {
+				return "", errors.Wrapf(err, "could not delete revision %d of release %s", r.Revision, r.Release)
+			}
+			deleted = append(deleted, r)
+		}
+	}
+
+	b, err := json.Marshal(deleted)
+	if err != nil {
+		return "", errors.Wrap(err, "could not format the deleted revisions")
+	}
+	fmt.Fprintln(m.Out, string(b))
+	return string(b), nil
+}
+
+// historyStorage returns the kind of objects storing the releases, from the helm storage driver
+func (m *Mixin) historyStorage() (string, error) {
+	switch driver := m.Getenv(helmDriverEnv); driver {
+	case "", "secret", "secrets":
+		return "secret", nil
+	case "configmap", "configmaps":
+		return "configmap", nil
+	default:
+		return "", errors.Errorf("gcHistory supports the secret and configmap storage drivers, got %s", driver)
+	}
+}
+
+// releaseRevisions lists the revisions of a release from the objects labelled by helm
+func (m *Mixin) releaseRevisions(ctx context.Context, release string, namespace string) ([]releaseRevision, error) {
+	storage, err := m.historyStorage()
+	if err != nil {
+		return nil, err
+	}
+	client, err := m.getKubernetesClient()
+	if err != nil {
+		return nil, errors.Wrap(err, "couldn't get kubernetes client")
+	}
+
+	var objects []metav1.ObjectMeta
+	opts := metav1.ListOptions{LabelSelector: "owner=helm,name=" + release}
+	if storage == "configmap" {
+		list, err := client.CoreV1().ConfigMaps(namespace).List(ctx, opts)
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not list the revisions of release %s", release)
+		}
+		for _, cm := range list.Items {
+			objects = append(objects, cm.ObjectMeta)
+		}
+	} else {
+		list, err := client.CoreV1().Secrets(namespace).List(ctx, opts)
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not list the revisions of release %s", release)
+		}
+		for _, secret := range list.Items {
+			objects = append(objects, secret.ObjectMeta)
+		}
+	}
+
+	revisions := make([]releaseRevision, 0, len(objects))
+	for _, o := range objects {
+		revision, err := strconv.Atoi(o.Labels["version"])
+		if err != nil {
+			continue
+		}
+		revisions = append(revisions, releaseRevision{
+			Release:   release,
+			Namespace: namespace,
+			Revision:  revision,
+			object:    o.Name,
+			status:    o.Labels["status"],
+			created:   o.CreationTimestamp.Time,
+		})
+	}
+	return revisions, nil
+}
+
+// deleteRevision deletes the object storing a revision of a release
+func (m *Mixin) deleteRevision(ctx context.Context, r releaseRevision) error {
+	storage, err := m.historyStorage()
+	if err != nil {
+		return err
+	}
+	client, err := m.getKubernetesClient()
+	if err != nil {
+		return errors.Wrap(err, "couldn't get kubernetes client")
+	}
+	if storage == "configmap" {
+		return client.CoreV1().ConfigMaps(r.Namespace).Delete(ctx, r.object, metav1.DeleteOptions{})
+	}
+	return client.CoreV1().Secrets(r.Namespace).Delete(ctx, r.object, metav1.DeleteOptions{})
+}
+
+// expiredRevisions returns the revisions beyond the keep most recent ones, or older than maxAge when it is set,
+// from the oldest. The last revision and the deployed revision are never expired.
+func expiredRevisions(revisions []releaseRevision, keep int, maxAge time.Duration, now time.Time) []releaseRevision {
+	sort.Slice(revisions, func(i, j int) bool { return revisions[i].Revision > revisions[j].Revision })
+
+	var expired []releaseRevision
+	for i, r := range revisions {
+		if i == 0 || r.status == deployedStatus {
+			continue
+		}
+		if (keep > 0 && i >= keep) || (maxAge > 0 && now.Sub(r.created) > maxAge) {
+			expired = append(expired, r)
+		}
+	}
+	sort.Slice(expired, func(i, j int) bool { return expired[i].Revision < expired[j].Revision })
+	return expired
+}
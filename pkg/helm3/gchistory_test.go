@@ -0,0 +1,97 @@
+package helm3
+
+import (
+	"bytes"
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	testclient "k8s.io/client-go/kubernetes/fake"
+)
+
+// releaseSecret returns the secret storing a revision of a release, created days ago
+func releaseSecret(release string, revision int, status string, days int) runtime.Object {
+	return &corev1.Secret{ObjectMeta: metav1.ObjectMeta{
+		Name:              "sh.helm.release.v1." + release + ".v" + strconv.Itoa(revision),
+		Namespace:         "db",
+		Labels:            map[string]string{"owner": "helm", "name": release, "version": strconv.Itoa(revision), "status": status},
+		CreationTimestamp: metav1.NewTime(time.Now().Add(-time.Duration(days) * 24 * time.Hour)),
+	}}
+}
+
+func TestMixin_ExecuteGcHistory(t *testing.T) {
+	ctx := context.Background()
+	client := testclient.NewSimpleClientset(
+		releaseSecret("mysql", 1, "superseded", 40),
+		releaseSecret("mysql", 2, "superseded", 35),
+		releaseSecret("mysql", 3, "deployed", 20),
+		releaseSecret("mysql", 4, "failed", 10),
+		releaseSecret("mysql", 5, "failed", 1),
+		releaseSecret("redis", 1, "deployed", 40),
+	)
+
+	action := Action{Steps: []ExecuteSteps{{ExecuteStep: ExecuteStep{
+		Step:      Step{Description: "Trim history"},
+		Namespace: "db",
+		GcHistory: &HistoryGC{Releases: []string{"mysql", "redis"}, Keep: 3, MaxAge: "720h"},
+	}}}}
+	b, _ := yaml.Marshal(action)
+
+	h := NewTestMixin(t)
+	h.In = bytes.NewReader(b)
+	h.ClientFactory = &staticKubernetesFactory{client: client}
+
+	err := h.Execute(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, `[{"release":"mysql","namespace":"db","revision":1},{"release":"mysql","namespace":"db","revision":2}]`+"\n",
+		h.TestContext.GetOutput())
+
+	secrets, err := client.CoreV1().Secrets("db").List(ctx, metav1.ListOptions{})
+	require.NoError(t, err)
+	var names []string
+	for _, s := range secrets.Items {
+		names = append(names, s.Name)
+	}
+	assert.ElementsMatch(t, []string{"sh.helm.release.v1.mysql.v3", "sh.helm.release.v1.mysql.v4",
+		"sh.helm.release.v1.mysql.v5", "sh.helm.release.v1.redis.v1"}, names)
+}
+
+func TestExpiredRevisions(t *testing.T) {
+	now := time.Now()
+	revisions := func() []releaseRevision {
+		return []releaseRevision{
+			{Revision: 1, status: "superseded", created: now.Add(-48 * time.Hour)},
+			{Revision: 2, status: "deployed", created: now.Add(-36 * time.Hour)},
+			{Revision: 3, status: "failed", created: now.Add(-24 * time.Hour)},
+			{Revision: 4, status: "failed", created: now.Add(-12 * time.Hour)},
+		}
+	}
+	revisionNumbers := func(revisions []releaseRevision) []int {
+		var numbers []int
+		for _, r := range revisions {
+			numbers = append(numbers, r.Revision)
+		}
+		return numbers
+	}
+
+	assert.Equal(t, []int{1, 3}, revisionNumbers(expiredRevisions(revisions(), 1, 0, now)), "keep the last and deployed revisions")
+	assert.Equal(t, []int{1}, revisionNumbers(expiredRevisions(revisions(), 2, 0, now)))
+	assert.Equal(t, []int{1}, revisionNumbers(expiredRevisions(revisions(), 0, 30*time.Hour, now)))
+	assert.Equal(t, []int{1, 3}, revisionNumbers(expiredRevisions(revisions(), 0, 18*time.Hour, now)))
+	assert.Equal(t, []int{1, 3}, revisionNumbers(expiredRevisions(revisions(), 3, 18*time.Hour, now)))
+}
+
+func TestHistoryGC_Validate(t *testing.T) {
+	require.EqualError(t, HistoryGC{Keep: 3}.validate(), "gcHistory requires the releases to trim")
+	require.EqualError(t, HistoryGC{Releases: []string{"mysql"}}.validate(), "gcHistory requires keep or maxAge")
+	require.EqualError(t, HistoryGC{Releases: []string{"mysql"}, MaxAge: "30d"}.validate(),
+		`invalid gcHistory maxAge "30d", expected a duration such as 720h`)
+	require.NoError(t, HistoryGC{Releases: []string{"mysql"}, Keep: 10}.validate())
+}
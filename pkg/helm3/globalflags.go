@@ -0,0 +1,113 @@
+package helm3
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"get.porter.sh/porter/pkg/exec/builder"
+	"github.com/pkg/errors"
+)
+
+// globalFlagsEnv records the globalFlags of the mixin configuration in the invocation image, as comma separated flags
+const globalFlagsEnv = "PORTER_HELM3_GLOBAL_FLAGS"
+
+var globalFlagRegex = regexp.MustCompile(`^--[a-z0-9][a-z0-9-]*$`)
+
+// globalFlag is a flag added to every helm command, without a value when value is empty
+type globalFlag struct {
+	name  string
+	value string
+}
+
+func (f globalFlag) String() string {
+	if f.value == "" {
+		return f.name
+	}
+	return fmt.Sprintf("%s=%s", f.name, f.value)
+}
+
+// validateGlobalFlags checks that the globalFlags of the mixin configuration are flags that can be recorded
+// in the invocation image
+func validateGlobalFlags(flags map[string]string) error {
+	for name, value := range flags {
+		if !globalFlagRegex.MatchString(name) {
+			return errors.Errorf("supplied globalFlags %q is not a flag such as --burst-limit", name)
+		}
+		if strings.ContainsAny(value, ", \t\n") {
+			return errors.Errorf("supplied globalFlags %s value %q cannot contain commas or spaces", name, value)
+		}
+	}
+	return nil
+}
+
+// sortedGlobalFlags returns the global flags sorted by name
+func sortedGlobalFlags(flags map[string]string) []globalFlag {
+	sorted := make([]globalFlag, 0, len(flags))
+	for name, value := range flags {
+		sorted = append(sorted, globalFlag{name, value})
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].name < sorted[j].name })
+	return sorted
+}
+
+// encodeGlobalFlags returns the value of globalFlagsEnv for the globalFlags of the mixin configuration
+func encodeGlobalFlags(flags map[string]string) string {
+	encoded := make([]string, 0, len(flags))
+	for _, flag := range sortedGlobalFlags(flags) {
+		encoded = append(encoded, flag.String())
+	}
+	return strings.Join(encoded, ",")
+}
+
+// setGlobalFlags resolves the global flags of the step that runs, from the globalFlags of the mixin configuration
+// and the repository paths overridden at runtime, which are in turn overridden by the globalFlags of the step,
+// where a null value removes a flag
+func (m *Mixin) setGlobalFlags(step Step) error {
+	flags := make(map[string]string)
+	if encoded := m.Getenv(globalFlagsEnv); encoded != "" {
+		for _, flag := range strings.Split(encoded, ",") {
+			name, value, _ := strings.Cut(flag, "=")
+			flags[name] = value
+		}
+	}
+	for name, value := range m.repositoryPathFlags() {
+		flags[name] = value
+	}
+	for name, value := range step.GlobalFlags {
+		if !globalFlagRegex.MatchString(name) {
+			return errors.Errorf("globalFlags %q of the step is not a flag such as --burst-limit", name)
+		}
+		if value == nil {
+			delete(flags, name)
+			continue
+		}
+		flags[name] = *value
+	}
+	m.globalFlags = sortedGlobalFlags(flags)
+	return nil
+}
+
+// globalFlagArgs returns the global flags of the step that runs as helm arguments
+func (m *Mixin) globalFlagArgs() []string {
+	args := make([]string, 0, len(m.globalFlags))
+	for _, flag := range m.globalFlags {
+		args = append(args, flag.String())
+	}
+	return args
+}
+
+// globalBuilderFlags returns the global flags of the step that runs as flags of a custom action step
+func (m *Mixin) globalBuilderFlags() builder.Flags {
+	flags := make(builder.Flags, 0, len(m.globalFlags))
+	for _, flag := range m.globalFlags {
+		name := strings.TrimPrefix(flag.name, "--")
+		if flag.value == "" {
+			flags = append(flags, builder.NewFlag(name))
+		} else {
+			flags = append(flags, builder.NewFlag(name, flag.value))
+		}
+	}
+	return flags
+}
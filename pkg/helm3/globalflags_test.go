@@ -0,0 +1,84 @@
+package helm3
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"testing"
+
+	"get.porter.sh/porter/pkg/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestValidateGlobalFlags(t *testing.T) {
+	require.NoError(t, validateGlobalFlags(map[string]string{"--burst-limit": "200", "--kube-insecure-skip-tls-verify": ""}))
+	require.EqualError(t, validateGlobalFlags(map[string]string{"burst-limit": "200"}),
+		`supplied globalFlags "burst-limit" is not a flag such as --burst-limit`)
+	require.EqualError(t, validateGlobalFlags(map[string]string{"--kube-context": "a,b"}),
+		`supplied globalFlags --kube-context value "a,b" cannot contain commas or spaces`)
+	assert.Equal(t, "--burst-limit=200,--kube-insecure-skip-tls-verify",
+		encodeGlobalFlags(map[string]string{"--kube-insecure-skip-tls-verify": "", "--burst-limit": "200"}))
+}
+
+func TestMixin_InstallGlobalFlags(t *testing.T) {
+	burstLimit := "300"
+	testcases := []struct {
+		name        string
+		globalFlags map[string]*string
+		wantCommand string
+	}{
+		{name: "mixin flags",
+			wantCommand: "helm3 --burst-limit=200 --kube-insecure-skip-tls-verify upgrade --install mysql stable/mysql --atomic --create-namespace --output json"},
+		{name: "flag overridden by the step", globalFlags: map[string]*string{"--burst-limit": &burstLimit},
+			wantCommand: "helm3 --burst-limit=300 --kube-insecure-skip-tls-verify upgrade --install mysql stable/mysql --atomic --create-namespace --output json"},
+		{name: "flag removed by the step", globalFlags: map[string]*string{"--kube-insecure-skip-tls-verify": nil},
+			wantCommand: "helm3 --burst-limit=200 upgrade --install mysql stable/mysql --atomic --create-namespace --output json"},
+	}
+
+	defer os.Unsetenv(test.ExpectedCommandEnv)
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			os.Setenv(test.ExpectedCommandEnv, tc.wantCommand)
+
+			action := InstallAction{Steps: []InstallStep{{
+				InstallArguments: InstallArguments{
+					Step:  Step{Description: "Install MySQL", GlobalFlags: tc.globalFlags},
+					Name:  "mysql",
+					Chart: "stable/mysql",
+				},
+			}}}
+			b, _ := yaml.Marshal(action)
+
+			h := NewTestMixin(t)
+			h.Setenv(globalFlagsEnv, "--burst-limit=200,--kube-insecure-skip-tls-verify")
+			h.In = bytes.NewReader(b)
+
+			err := h.Install(ctx)
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestMixin_ExecuteGlobalFlags(t *testing.T) {
+	ctx := context.Background()
+	defer os.Unsetenv(test.ExpectedCommandEnv)
+	os.Setenv(test.ExpectedCommandEnv, "helm3 history mysql --burst-limit 200 --kube-insecure-skip-tls-verify")
+
+	action := Action{Steps: []ExecuteSteps{{
+		ExecuteStep: ExecuteStep{
+			Step:      Step{Description: "MySQL history"},
+			Arguments: []string{"history", "mysql"},
+		},
+	}}}
+	b, _ := yaml.Marshal(action)
+
+	h := NewTestMixin(t)
+	h.Setenv(globalFlagsEnv, "--burst-limit=200,--kube-insecure-skip-tls-verify")
+	h.In = bytes.NewReader(b)
+
+	err := h.Execute(ctx)
+	require.NoError(t, err)
+}
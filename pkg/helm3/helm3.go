@@ -2,7 +2,10 @@ package helm3
 
 import (
 	"bufio"
+	"fmt"
+	"io"
 	"io/ioutil"
+	"os/exec"
 	"strings"
 
 	"get.porter.sh/porter/pkg/runtime"
@@ -16,6 +19,17 @@ import (
 const defaultClientVersion string = "v3.8.2"
 const defaultClientPlatfrom string = "linux"
 const defaultClientArchitecture string = "amd64"
+const defaultClientBinary string = "helm3"
+const defaultKubectlVersion string = "v1.22.1"
+
+// clientBinaryEnv holds the name of the helm binary installed in the invocation image, when it is not the default
+const clientBinaryEnv string = "PORTER_HELM3_CLIENT_BINARY"
+
+// helmDriverEnv selects the storage driver helm uses to persist releases
+const helmDriverEnv string = "HELM_DRIVER"
+
+// helmDriverSQLConnectionStringEnv holds the connection string used by the sql storage driver
+const helmDriverSQLConnectionStringEnv string = "HELM_DRIVER_SQL_CONNECTION_STRING"
 
 // Helm is the logic behind the helm mixin
 type Mixin struct {
@@ -24,6 +38,21 @@ type Mixin struct {
 	HelmClientVersion      string
 	HelmClientPlatfrom     string
 	HelmClientArchitecture string
+	// PlanMode prints the helm commands instead of executing them
+	PlanMode bool
+	// Executor and ResourceReader replace the backends selected when the bundle was built, when they are set
+	Executor       Executor
+	ResourceReader ResourceReader
+
+	// verbosity of the step that runs, see setVerbosity
+	verbosity string
+	// suppressOutput hides the output of the commands of the step that runs
+	suppressOutput bool
+	// globalFlags are added to every helm command of the step that runs, see setGlobalFlags
+	globalFlags []globalFlag
+	// spooled are the files of the spool directory opened by the step that runs, shared with the copies of the mixin
+	// that run the releases of a parallel step, see removeSpool
+	spooled *spooledFiles
 }
 
 // New helm mixin client, initialized with useful defaults.
@@ -34,7 +63,16 @@ func New() *Mixin {
 		HelmClientVersion:      defaultClientVersion,
 		HelmClientPlatfrom:     defaultClientPlatfrom,
 		HelmClientArchitecture: defaultClientArchitecture,
+		spooled:                &spooledFiles{},
+	}
+}
+
+// helmBinary returns the name of the helm binary installed in the invocation image
+func (m *Mixin) helmBinary() string {
+	if binary := m.Getenv(clientBinaryEnv); binary != "" {
+		return binary
 	}
+	return defaultClientBinary
 }
 
 func (m *Mixin) getPayloadData() ([]byte, error) {
@@ -80,6 +118,61 @@ func (m *Mixin) ValidatePayload(b []byte) error {
 	return nil
 }
 
+// checkStorageDriver ensures the configured helm storage driver can be used before running helm
+func (m *Mixin) checkStorageDriver() error {
+	if m.Getenv(helmDriverEnv) == "sql" && m.Getenv(helmDriverSQLConnectionStringEnv) == "" {
+		return errors.Errorf("the sql storage driver requires a credential that sets the %s environment variable",
+			helmDriverSQLConnectionStringEnv)
+	}
+	return nil
+}
+
+// readOutput runs a command and calls read with its output as it is printed, instead of holding it in memory.
+// The rest of the output is drained so that the command does not block on a full pipe when read stops early.
+func readOutput(cmd *exec.Cmd, read func(r io.Reader) error) error {
+	output, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	readErr := read(output)
+	io.Copy(io.Discard, output)
+	if err := cmd.Wait(); err != nil {
+		return err
+	}
+	return readErr
+}
+
+// runCommand prints and executes the command, streaming its output to the mixin.
+// In plan mode the command is only printed.
+func (m *Mixin) runCommand(cmd *exec.Cmd) error {
+	if m.planMode() {
+		m.printPlannedCommand(cmd)
+		return nil
+	}
+
+	// only the last lines of a suppressed output are kept, to be printed when the command fails
+	output := newOutputTail(quietOutputLines)
+	stderr := &stderrTail{}
+	cmd.Stdout = io.MultiWriter(m.commandStdout(), output)
+	cmd.Stderr = io.MultiWriter(m.Err, stderr)
+
+	prettyCmd := fmt.Sprintf("%s %s", cmd.Path, formatPlannedCommand(cmd.Args))
+	m.echoCommand(prettyCmd)
+
+	err := cmd.Start()
+	if err != nil {
+		return fmt.Errorf("could not execute command, %s: %s", prettyCmd, err)
+	}
+	err = cmd.Wait()
+	if err != nil && m.outputSuppressed() {
+		fmt.Fprint(m.Out, output.String())
+	}
+	return classifyHelmError(err, stderr.String())
+}
+
 func (m *Mixin) getKubernetesClient() (k8s.Interface, error) {
 	return m.ClientFactory.GetClient()
 }
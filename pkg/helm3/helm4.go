@@ -0,0 +1,48 @@
+package helm3
+
+import "strings"
+
+// helm4ClientVersionConstraint represents the Helm 4 client versions, including alphas and release candidates,
+// that can be installed when experimentalHelm4 is set
+const helm4ClientVersionConstraint string = ">= v4.0.0-0, < v5.0.0-0"
+
+// clientMajorVersionEnv records the major version of the helm client installed in the invocation image,
+// when it is not helm 3
+const clientMajorVersionEnv = "PORTER_HELM3_CLIENT_MAJOR_VERSION"
+
+// helm4Flags are the flags set by the mixin that were renamed in helm 4, mapped to their helm 4 name
+var helm4Flags = map[string]string{
+	"--atomic": "--rollback-on-failure",
+	"--force":  "--force-replace",
+}
+
+// helm4 checks if the helm client installed in the invocation image is a helm 4 release
+func (m *Mixin) helm4() bool {
+	return m.Getenv(clientMajorVersionEnv) == "4"
+}
+
+// helmFlag returns the name of a flag set by the mixin for the installed helm client
+func (m *Mixin) helmFlag(flag string) string {
+	if renamed, ok := helm4Flags[flag]; ok && m.helm4() {
+		return renamed
+	}
+	return flag
+}
+
+// helmFlags renames the flags of a step that were renamed in helm 4, so that the extraFlags of a bundle work with
+// both helm 3 and helm 4 clients
+func (m *Mixin) helmFlags(flags []string) []string {
+	if !m.helm4() {
+		return flags
+	}
+	renamed := make([]string, 0, len(flags))
+	for _, flag := range flags {
+		name, value, hasValue := strings.Cut(flag, "=")
+		name = m.helmFlag(name)
+		if hasValue {
+			name += "=" + value
+		}
+		renamed = append(renamed, name)
+	}
+	return renamed
+}
@@ -0,0 +1,20 @@
+package helm3
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMixin_HelmFlags(t *testing.T) {
+	extraFlags := []string{"--force", "--history-max=5", "--atomic=true"}
+
+	m := NewTestMixin(t)
+	assert.Equal(t, "--atomic", m.helmFlag("--atomic"))
+	assert.Equal(t, extraFlags, m.helmFlags(extraFlags))
+
+	m.Setenv(clientMajorVersionEnv, "4")
+	assert.Equal(t, "--rollback-on-failure", m.helmFlag("--atomic"))
+	assert.Equal(t, "--wait", m.helmFlag("--wait"))
+	assert.Equal(t, []string{"--force-replace", "--history-max=5", "--rollback-on-failure=true"}, m.helmFlags(extraFlags))
+}
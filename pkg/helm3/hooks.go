@@ -0,0 +1,181 @@
+package helm3
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+// ReleaseHooks reports the hooks registered by the chart of a release, such as the jobs run before an upgrade
+type ReleaseHooks struct {
+	Release string `yaml:"release"`
+	// Revision of the release, the last revision by default
+	Revision int `yaml:"revision,omitempty"`
+}
+
+// releaseHook is a hook of a release printed by the getHooks step
+type releaseHook struct {
+	Kind   string `json:"kind"`
+	Name   string `json:"name"`
+	Source string `json:"source,omitempty"`
+	// Events are the events that run the hook, for example pre-install or post-upgrade
+	Events         []string `json:"events"`
+	Weight         int      `json:"weight"`
+	DeletePolicies []string `json:"deletePolicies,omitempty"`
+}
+
+// getHooks prints the hooks of a release as json and returns them
+func (m *Mixin) getHooks(ctx context.Context, step ExecuteStep) (string, error) {
+	hooks := *step.GetHooks
+	if len(step.Arguments) > 0 || step.Plugin != "" {
+		return "", errors.New("getHooks cannot be set together with arguments or plugin")
+	}
+	if hooks.Release == "" {
+		return "", errors.New("getHooks requires a release")
+	}
+
+	cmd := m.helmCommand(ctx, "get", "hooks", hooks.Release)
+	if step.Namespace != "" {
+		cmd.Args = append(cmd.Args, "--namespace", step.Namespace)
+	}
+	if hooks.Revision > 0 {
+		cmd.Args = append(cmd.Args, "--revision", strconv.Itoa(hooks.Revision))
+	}
+	if m.planMode() {
+		m.printPlannedCommand(cmd)
+		return "", nil
+	}
+	m.setStepEnv(cmd, step.Env)
+	m.echoCommand(formatPlannedCommand(cmd.Args))
+
+	stderr := &bytes.Buffer{}
+	cmd.Stderr = io.MultiWriter(m.Err, stderr)
+	out, err := cmd.Output()
+	if err != nil {
+		return "", errors.Wrapf(classifyHelmError(err, stderr.String()), "could not get the hooks of release %s", hooks.Release)
+	}
+
+	report, err := parseReleaseHooks(string(out))
+	if err != nil {
+		return "", errors.Wrapf(err, "could not parse the hooks of release %s", hooks.Release)
+	}
+	b, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return "", errors.Wrapf(err, "could not print the hooks of release %s", hooks.Release)
+	}
+	fmt.Fprintln(m.Out, string(b))
+	return string(b), nil
+}
+
+// parseReleaseHooks reads the hook manifests printed by helm get hooks, each preceded by the template it comes from
+func parseReleaseHooks(output string) ([]releaseHook, error) {
+	hooks := []releaseHook{}
+	for _, doc := range splitManifests(output) {
+		var resource struct {
+			Kind     string `yaml:"kind"`
+			Metadata struct {
+				Name        string            `yaml:"name"`
+				Annotations map[string]string `yaml:"annotations"`
+			} `yaml:"metadata"`
+		}
+		if err := yaml.Unmarshal([]byte(doc.content), &resource); err != nil {
+			return nil, err
+		}
+		if resource.Kind == "" {
+			continue
+		}
+
+		annotations := resource.Metadata.Annotations
+		hook := releaseHook{
+			Kind:           resource.Kind,
+			Name:           resource.Metadata.Name,
+			Source:         doc.source,
+			Events:         splitAnnotation(annotations["helm.sh/hook"]),
+			DeletePolicies: splitAnnotation(annotations["helm.sh/hook-delete-policy"]),
+		}
+		if weight := annotations["helm.sh/hook-weight"]; weight != "" {
+			hook.Weight, _ = strconv.Atoi(strings.TrimSpace(weight))
+		}
+		hooks = append(hooks, hook)
+	}
+	return hooks, nil
+}
+
+// manifestDocument is a yaml document of a manifest printed by helm, with the template it was rendered from
+type manifestDocument struct {
+	source  string
+	content string
+}
+
+// splitManifests splits a manifest printed by helm into its yaml documents
+func splitManifests(manifest string) []manifestDocument {
+	var docs []manifestDocument
+	readManifestDocuments(strings.NewReader(manifest), func(doc manifestDocument) error {
+		docs = append(docs, doc)
+		return nil
+	})
+	return docs
+}
+
+// readManifestDocuments calls read with each yaml document of a manifest printed by helm, so that only one document
+// of a large manifest is held in memory at a time
+func readManifestDocuments(manifest io.Reader, read func(doc manifestDocument) error) error {
+	var doc manifestDocument
+	var content strings.Builder
+	flush := func() error {
+		doc.content = content.String()
+		var err error
+		if strings.TrimSpace(doc.content) != "" {
+			err = read(doc)
+		}
+		doc = manifestDocument{}
+		content.Reset()
+		return err
+	}
+
+	// lines are read without a maximum length, values such as embedded certificates or dashboards can be long
+	r := bufio.NewReader(manifest)
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return err
+		}
+		if line == "" && err == io.EOF {
+			break
+		}
+		line = strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+		if strings.HasPrefix(line, "---") {
+			if err := flush(); err != nil {
+				return err
+			}
+		} else {
+			if strings.HasPrefix(line, "# Source: ") && doc.source == "" {
+				doc.source = strings.TrimPrefix(line, "# Source: ")
+			}
+			content.WriteString(line + "\n")
+		}
+		if err == io.EOF {
+			break
+		}
+	}
+	return flush()
+}
+
+// splitAnnotation splits the comma separated values of a helm annotation
+func splitAnnotation(value string) []string {
+	var values []string
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
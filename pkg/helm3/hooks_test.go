@@ -0,0 +1,109 @@
+package helm3
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"get.porter.sh/porter/pkg/portercontext"
+	"get.porter.sh/porter/pkg/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+const getHooksOutput = `---
+# Source: mysql/templates/migrate-job.yaml
+apiVersion: batch/v1
+kind: Job
+metadata:
+  name: mysql-migrate
+  annotations:
+    "helm.sh/hook": pre-install,pre-upgrade
+    "helm.sh/hook-weight": "-5"
+    "helm.sh/hook-delete-policy": before-hook-creation,hook-succeeded
+spec:
+  template:
+    spec:
+      restartPolicy: Never
+---
+# Source: mysql/templates/tests/test-connection.yaml
+apiVersion: v1
+kind: Pod
+metadata:
+  name: mysql-test-connection
+  annotations:
+    "helm.sh/hook": test
+`
+
+func TestParseReleaseHooks(t *testing.T) {
+	hooks, err := parseReleaseHooks(getHooksOutput)
+	require.NoError(t, err)
+	assert.Equal(t, []releaseHook{
+		{
+			Kind:           "Job",
+			Name:           "mysql-migrate",
+			Source:         "mysql/templates/migrate-job.yaml",
+			Events:         []string{"pre-install", "pre-upgrade"},
+			Weight:         -5,
+			DeletePolicies: []string{"before-hook-creation", "hook-succeeded"},
+		},
+		{
+			Kind:   "Pod",
+			Name:   "mysql-test-connection",
+			Source: "mysql/templates/tests/test-connection.yaml",
+			Events: []string{"test"},
+		},
+	}, hooks)
+
+	hooks, err = parseReleaseHooks("")
+	require.NoError(t, err)
+	assert.Empty(t, hooks)
+}
+
+func TestMixin_ExecuteGetHooks(t *testing.T) {
+	ctx := context.Background()
+	defer os.Unsetenv(test.ExpectedCommandEnv)
+	defer os.Unsetenv(test.ExpectedCommandOutputEnv)
+	os.Setenv(test.ExpectedCommandEnv, "helm3 get hooks mysql --namespace db --revision 3")
+	os.Setenv(test.ExpectedCommandOutputEnv, getHooksOutput)
+
+	executeAction := Action{
+		Steps: []ExecuteSteps{
+			{
+				ExecuteStep: ExecuteStep{
+					Step: Step{
+						Description: "MySQL hooks",
+						Outputs:     []HelmOutput{{Name: "hooks", Stdout: true}},
+					},
+					Namespace: "db",
+					GetHooks:  &ReleaseHooks{Release: "mysql", Revision: 3},
+				},
+			},
+		},
+	}
+	b, _ := yaml.Marshal(executeAction)
+
+	h := NewTestMixin(t)
+	h.In = bytes.NewReader(b)
+
+	err := h.Execute(ctx)
+	require.NoError(t, err)
+
+	hooks, err := h.FileSystem.ReadFile(filepath.Join(portercontext.MixinOutputsDir, "hooks"))
+	require.NoError(t, err)
+	assert.JSONEq(t, `[
+		{"kind": "Job", "name": "mysql-migrate", "source": "mysql/templates/migrate-job.yaml",
+			"events": ["pre-install", "pre-upgrade"], "weight": -5, "deletePolicies": ["before-hook-creation", "hook-succeeded"]},
+		{"kind": "Pod", "name": "mysql-test-connection", "source": "mysql/templates/tests/test-connection.yaml",
+			"events": ["test"], "weight": 0}
+	]`, string(hooks))
+}
+
+func TestMixin_ExecuteGetHooksWithoutRelease(t *testing.T) {
+	h := NewTestMixin(t)
+	_, err := h.getHooks(context.Background(), ExecuteStep{GetHooks: &ReleaseHooks{}})
+	require.EqualError(t, err, "getHooks requires a release")
+}
@@ -0,0 +1,104 @@
+package helm3
+
+import (
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+// containerFields are the fields of a pod spec that list containers with an image
+var containerFields = map[string]bool{"containers": true, "initContainers": true, "ephemeralContainers": true}
+
+// manifestImages lists the distinct container images of a rendered manifest, sorted by name.
+// Pod specs are found at any depth, so that the images of deployments, jobs, cronjobs and custom resources
+// that embed a pod template are all reported.
+func manifestImages(manifest io.Reader) ([]string, error) {
+	seen := make(map[string]bool)
+	var images []string
+
+	decoder := yaml.NewDecoder(manifest)
+	for {
+		var doc interface{}
+		err := decoder.Decode(&doc)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "could not parse the rendered manifest")
+		}
+		collectImages(doc, func(image string) {
+			if !seen[image] {
+				seen[image] = true
+				images = append(images, image)
+			}
+		})
+	}
+
+	sort.Strings(images)
+	return images, nil
+}
+
+// collectImages walks a decoded manifest and calls add with the image of each container
+func collectImages(node interface{}, add func(image string)) {
+	switch n := node.(type) {
+	case map[interface{}]interface{}:
+		for key, value := range n {
+			if name, ok := key.(string); ok && containerFields[name] {
+				containers, _ := value.([]interface{})
+				for _, c := range containers {
+					container, _ := c.(map[interface{}]interface{})
+					if image, ok := container["image"].(string); ok && image != "" {
+						add(image)
+					}
+				}
+			}
+			collectImages(value, add)
+		}
+	case []interface{}:
+		for _, item := range n {
+			collectImages(item, add)
+		}
+	}
+}
+
+// defaultImageRegistryValues are the values set to the registry of an image registry override when no value is given,
+// the global registry convention of the bitnami charts and of many charts derived from them
+var defaultImageRegistryValues = []string{"global.imageRegistry"}
+
+// ImageRegistryOverride deploys the images of a chart from a mirror registry, for example in a disconnected cluster
+type ImageRegistryOverride struct {
+	// Registry is the host, and optional path prefix, of the mirror registry
+	Registry string `yaml:"registry"`
+	// Values are the paths of the chart values set to the registry, global.imageRegistry by default
+	Values []string `yaml:"values,omitempty"`
+}
+
+// validate checks that the registry is a host and not a URL
+func (o ImageRegistryOverride) validate() error {
+	if o.Registry == "" {
+		return errors.New("imageRegistryOverride requires a registry")
+	}
+	if strings.Contains(o.Registry, "://") {
+		return errors.Errorf("imageRegistryOverride registry %q must be a registry host without a scheme, for example mirror.example.com", o.Registry)
+	}
+	return nil
+}
+
+// apply returns the values to set on a release with the registry, values set by the step are kept
+func (o ImageRegistryOverride) apply(set map[string]string) map[string]string {
+	paths := o.Values
+	if len(paths) == 0 {
+		paths = defaultImageRegistryValues
+	}
+	applied := make(map[string]string, len(set)+len(paths))
+	for _, path := range paths {
+		applied[path] = strings.TrimSuffix(o.Registry, "/")
+	}
+	for k, v := range set {
+		applied[k] = v
+	}
+	return applied
+}
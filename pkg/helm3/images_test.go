@@ -0,0 +1,57 @@
+package helm3
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"testing"
+
+	"get.porter.sh/porter/pkg/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestImageRegistryOverride(t *testing.T) {
+	t.Run("default values", func(t *testing.T) {
+		override := ImageRegistryOverride{Registry: "mirror.example.com/"}
+		require.NoError(t, override.validate())
+		assert.Equal(t, map[string]string{"global.imageRegistry": "mirror.example.com", "replicaCount": "2"},
+			override.apply(map[string]string{"replicaCount": "2"}))
+	})
+
+	t.Run("value paths", func(t *testing.T) {
+		override := ImageRegistryOverride{Registry: "mirror.example.com/dockerhub", Values: []string{"image.registry", "metrics.image.registry"}}
+		assert.Equal(t, map[string]string{"image.registry": "registry.internal", "metrics.image.registry": "mirror.example.com/dockerhub"},
+			override.apply(map[string]string{"image.registry": "registry.internal"}), "values set by the step should be kept")
+	})
+
+	t.Run("invalid registry", func(t *testing.T) {
+		assert.EqualError(t, ImageRegistryOverride{}.validate(), "imageRegistryOverride requires a registry")
+		assert.EqualError(t, ImageRegistryOverride{Registry: "https://mirror.example.com"}.validate(),
+			`imageRegistryOverride registry "https://mirror.example.com" must be a registry host without a scheme, for example mirror.example.com`)
+	})
+}
+
+func TestMixin_InstallImageRegistryOverride(t *testing.T) {
+	ctx := context.Background()
+	defer os.Unsetenv(test.ExpectedCommandEnv)
+	os.Setenv(test.ExpectedCommandEnv, "helm3 upgrade --install mysql bitnami/mysql --atomic --create-namespace --output json --set auth.database=app --set global.imageRegistry=mirror.example.com")
+
+	action := InstallAction{Steps: []InstallStep{{
+		InstallArguments: InstallArguments{
+			Step:                  Step{Description: "Install MySQL"},
+			Name:                  "mysql",
+			Chart:                 "bitnami/mysql",
+			Set:                   map[string]string{"auth.database": "app"},
+			ImageRegistryOverride: &ImageRegistryOverride{Registry: "mirror.example.com"},
+		},
+	}}}
+	b, _ := yaml.Marshal(action)
+
+	h := NewTestMixin(t)
+	h.In = bytes.NewReader(b)
+
+	err := h.Install(ctx)
+	require.NoError(t, err)
+}
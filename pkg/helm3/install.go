@@ -5,10 +5,8 @@ import (
 	"fmt"
 	"os/exec"
 	"sort"
-	"strings"
 
 	"github.com/pkg/errors"
-	"gopkg.in/yaml.v2"
 )
 
 type InstallAction struct {
@@ -37,6 +35,203 @@ type InstallArguments struct {
 	Wait      bool              `yaml:"wait"`
 	Timeout   string            `yaml:"timeout"`
 	Debug     bool              `yaml:"debug"`
+
+	// Subcharts enables or disables the dependencies of an umbrella chart and sets values scoped to them
+	Subcharts map[string]Subchart `yaml:"subcharts,omitempty"`
+
+	// EscapeSet escapes the dots of label and annotation keys and the commas of values in set, it is enabled by default
+	EscapeSet *bool `yaml:"escapeSet,omitempty"`
+
+	// SetTyped sets chart values that keep their yaml type, such as numbers and booleans, with --set-json
+	SetTyped map[string]interface{} `yaml:"setTyped,omitempty"`
+
+	// SetFileFromParameter sets chart values to the content of bundle file parameters, mapping each value to a parameter name
+	SetFileFromParameter map[string]string `yaml:"setFileFromParameter,omitempty"`
+
+	// SetFromOutput sets chart values to the outputs of the previous steps of the action, mapping each value to an output name
+	SetFromOutput map[string]string `yaml:"setFromOutput,omitempty"`
+
+	// NamespaceMetadata labels and annotates the namespace of each release before helm runs
+	NamespaceMetadata *NamespaceMetadata `yaml:"namespaceMetadata,omitempty"`
+
+	// TakeOwnership adopts existing resources that were not created by helm, it requires helm 3.17 or later
+	TakeOwnership bool `yaml:"takeOwnership,omitempty"`
+
+	// SkipSchemaValidation ignores the values.schema.json of the chart, it requires helm 3.16 or later
+	SkipSchemaValidation bool `yaml:"skipSchemaValidation,omitempty"`
+
+	// CheckPermissions checks that the bundle credentials can manage the resources of each release before it is installed
+	CheckPermissions bool `yaml:"checkPermissions,omitempty"`
+
+	// Policy evaluates the rendered manifest of each release against policies of the bundle before it is installed
+	Policy *PolicyCheck `yaml:"policy,omitempty"`
+
+	// LockTimeout retries helm while another operation holds the lock of a release, for at most the duration
+	LockTimeout string `yaml:"lockTimeout,omitempty"`
+
+	// ExtraFlags are passed as-is to helm, after the flags managed by the mixin
+	ExtraFlags []string `yaml:"extraFlags,omitempty"`
+
+	// Namespaces installs every release of the step once in each namespace, instead of the namespace of the step
+	Namespaces NamespaceList `yaml:"namespaces,omitempty"`
+
+	// GenerateName derives the release name from the installation name, instead of the name of the release
+	GenerateName string `yaml:"generateName,omitempty"`
+
+	// RandomName lets helm generate the name of a release that has no name, with --generate-name
+	RandomName bool `yaml:"randomName,omitempty"`
+
+	// Releases are installed in order instead of the single release defined by Name and Chart
+	Releases []Release `yaml:"releases,omitempty"`
+
+	// Parallel installs the releases of the step at the same time, for releases that don't depend on each other
+	Parallel bool `yaml:"parallel,omitempty"`
+	// MaxParallel is the number of releases installed at the same time by a parallel step, 4 by default
+	MaxParallel int `yaml:"maxParallel,omitempty"`
+
+	// CRDs are applied before the release, or on their own when the step has no release
+	CRDs *CRDArguments `yaml:"crds,omitempty"`
+
+	// Manifests of the bundle are applied with kubectl before or after the releases of the step
+	Manifests []Manifest `yaml:"manifests,omitempty"`
+
+	// ReleasesFile records each release deployed by the step in a file kept with the state of the bundle,
+	// so that an uninstall step tears them down in reverse order
+	ReleasesFile string `yaml:"releasesFile,omitempty"`
+
+	// Jobs deployed by the releases of the step are waited for once the releases are deployed, and their logs written to outputs
+	Jobs []JobWait `yaml:"jobs,omitempty"`
+
+	// SmokeTest probes the application over HTTP through a port-forward once the releases of the step are deployed
+	SmokeTest *SmokeTest `yaml:"smokeTest,omitempty"`
+
+	// Repositories required by the step, added when the bundle is built
+	Repositories map[string]Repository `yaml:"repositories,omitempty"`
+
+	// Verify the cosign signature of OCI charts before they are installed
+	Verify *SignatureVerification `yaml:"verify,omitempty"`
+
+	// SkipIfExists leaves a release untouched when it is already installed
+	SkipIfExists bool `yaml:"skipIfExists,omitempty"`
+	// FailIfExists fails the step when a release is already installed
+	FailIfExists bool `yaml:"failIfExists,omitempty"`
+
+	// ReplaceFailed uninstalls a release left in the failed status by a previous install before installing it again
+	ReplaceFailed bool `yaml:"replaceFailed,omitempty"`
+	// KeepHistory keeps the release history when a failed release is uninstalled
+	KeepHistory bool `yaml:"keepHistory,omitempty"`
+
+	// PorterValues sets the porter.action, porter.installation and porter.revision values on every release
+	PorterValues bool `yaml:"porterValues,omitempty"`
+
+	// ImageRegistryOverride sets the registry of the images of every release to a mirror registry
+	ImageRegistryOverride *ImageRegistryOverride `yaml:"imageRegistryOverride,omitempty"`
+
+	// DryRun renders each release with helm instead of installing it, client, server or none
+	DryRun string `yaml:"dryRun,omitempty"`
+
+	// dryRunFlags are the helm flags of the dry run, resolved when the step runs
+	dryRunFlags []string
+}
+
+// validateExclusiveFields checks the fields of an install step that cannot be set together, which lint reports too
+func (s InstallArguments) validateExclusiveFields() error {
+	if s.SkipIfExists && s.FailIfExists {
+		return errors.New("skipIfExists and failIfExists cannot both be set")
+	}
+	if s.GenerateName != "" && (s.Name != "" || len(s.Releases) > 0) {
+		return errors.New("generateName cannot be set together with name or releases")
+	}
+	if s.RandomName {
+		return s.validateRandomName()
+	}
+	return nil
+}
+
+// releases returns the arguments for each release installed by the step, in order
+func (s InstallArguments) releases() []InstallArguments {
+	releases := []InstallArguments{s}
+	if len(s.Releases) == 0 && s.CRDs != nil && s.Name == "" && s.Chart == "" {
+		// the step only manages custom resource definitions
+		return nil
+	}
+	if len(s.Releases) > 0 {
+		defaults := releaseDefaults{Namespace: s.Namespace, Version: s.Version, Set: s.Set, Values: s.Values}
+		releases = make([]InstallArguments, 0, len(s.Releases))
+		for _, r := range s.Releases {
+			r = r.apply(defaults)
+			args := s
+			args.Releases = nil
+			args.Name, args.Chart, args.Version, args.Namespace, args.Set, args.Values = r.Name, r.Chart, r.Version, r.Namespace, r.Set, r.Values
+			releases = append(releases, args)
+		}
+	}
+
+	// Every release is installed once per namespace, in the order of the namespaces
+	namespaces := []string{""}
+	if len(s.Namespaces) > 0 {
+		namespaces = s.Namespaces
+	}
+	expanded := make([]InstallArguments, 0, len(namespaces)*len(releases))
+	for _, namespace := range namespaces {
+		for _, args := range releases {
+			args.Namespaces = nil
+			if namespace != "" {
+				args.Namespace = namespace
+			}
+			args.Name = namespacedReleaseName(args.Name, args.Namespace)
+			expanded = append(expanded, args)
+		}
+	}
+	return expanded
+}
+
+// escapeSet returns whether the keys and values of set are escaped, which is the default
+func (s InstallArguments) escapeSet() bool {
+	return s.EscapeSet == nil || *s.EscapeSet
+}
+
+// repositoryCharts returns the charts of the releases of the step that are pulled from the repositories of the
+// invocation image, instead of the repo of the step
+func (s InstallArguments) repositoryCharts() []string {
+	if s.Repo != "" {
+		return nil
+	}
+	var charts []string
+	for _, release := range s.releases() {
+		charts = append(charts, release.Chart)
+	}
+	return charts
+}
+
+// releaseStep returns the fields of the step used by the release pipeline
+func (s InstallArguments) releaseStep() releaseStep {
+	return releaseStep{
+		Step:              s.Step,
+		Namespace:         s.Namespace,
+		DryRun:            s.DryRun,
+		CRDs:              s.CRDs,
+		Manifests:         s.Manifests,
+		Jobs:              s.Jobs,
+		SmokeTest:         s.SmokeTest,
+		Repositories:      s.Repositories,
+		RepositoryCharts:  s.repositoryCharts(),
+		NamespaceMetadata: s.NamespaceMetadata,
+		Verify:            s.Verify,
+		CheckPermissions:  s.CheckPermissions,
+		Policy:            s.Policy,
+		ReleasesFile:      s.ReleasesFile,
+	}
+}
+
+// timeouts returns the timeout fields of the step
+func (s InstallArguments) timeouts() []timeoutField {
+	fields := []timeoutField{{"timeout", s.Timeout}, {"lockTimeout", s.LockTimeout}}
+	if s.CRDs != nil {
+		fields = append(fields, timeoutField{"crds.timeout", s.CRDs.Timeout})
+	}
+	fields = append(fields, jobTimeouts(s.Jobs)...)
+	return append(fields, outputTimeouts(s.Outputs)...)
 }
 
 func (m *Mixin) Install(ctx context.Context) error {
@@ -46,13 +241,8 @@ func (m *Mixin) Install(ctx context.Context) error {
 		return err
 	}
 
-	kubeClient, err := m.getKubernetesClient()
-	if err != nil {
-		return errors.Wrap(err, "couldn't get kubernetes client")
-	}
-
 	var action InstallAction
-	err = yaml.Unmarshal(payload, &action)
+	err = m.unmarshal(payload, &action)
 	if err != nil {
 		return err
 	}
@@ -61,9 +251,224 @@ func (m *Mixin) Install(ctx context.Context) error {
 	}
 	step := action.Steps[0]
 
-	cmd := m.NewCommand(ctx, "helm3")
+	pipeline := m.newReleasePipeline(step.Step)
+	// the release results and rendered manifests of the step are spooled until its outputs are written
+	defer pipeline.close()
+
+	err = pipeline.start()
+	if err != nil {
+		return err
+	}
+
+	err = step.validateExclusiveFields()
+	if err != nil {
+		return err
+	}
+
+	if step.GenerateName != "" {
+		step.Name, err = m.resolveGeneratedName(step.GenerateName, step.Name, len(step.Releases))
+		if err != nil {
+			return err
+		}
+	}
+
+	err = m.templateNamespaces(&step.Namespace, step.Namespaces, step.Releases)
+	if err != nil {
+		return err
+	}
+
+	err = validateNamespaces(step.Namespaces, step.Namespace, step.Releases)
+	if err != nil {
+		return err
+	}
+
+	err = step.releaseStep().validate()
+	if err != nil {
+		return err
+	}
+
+	err = validateParallel(step.Parallel, step.MaxParallel)
+	if err != nil {
+		return err
+	}
 
-	cmd.Args = append(cmd.Args, "upgrade", "--install", step.Name, step.Chart)
+	err = validateTimeouts(step.timeouts()...)
+	if err != nil {
+		return err
+	}
+
+	err = m.validateNamespaceScoped(step.CRDs, step.NamespaceMetadata, step.Namespace, step.Namespaces, step.Releases, nil)
+	if err != nil {
+		return err
+	}
+
+	for _, release := range step.releases() {
+		err = validateChartDigest(release.Chart)
+		if err != nil {
+			return err
+		}
+	}
+
+	if step.ImageRegistryOverride != nil {
+		err = step.ImageRegistryOverride.validate()
+		if err != nil {
+			return err
+		}
+		step.Set = step.ImageRegistryOverride.apply(step.Set)
+	}
+
+	if step.PorterValues {
+		step.Set = m.applyPorterValues(step.Set)
+	}
+
+	err = pipeline.prepare(ctx, step.releaseStep())
+	if err != nil {
+		return err
+	}
+	step.dryRunFlags = pipeline.dryRunFlags
+	dryRun := pipeline.dryRun
+
+	var parallel []InstallArguments
+	for _, release := range step.releases() {
+		err = pipeline.prepareNamespace(ctx, release.Namespace)
+		if err != nil {
+			return err
+		}
+
+		if step.ReplaceFailed && !m.planMode() && !dryRun {
+			err = m.replaceFailedRelease(ctx, release)
+			if err != nil {
+				return err
+			}
+		}
+
+		if (step.SkipIfExists || step.FailIfExists) && !m.planMode() {
+			exists, err := m.releaseExists(ctx, release.Name, release.Namespace)
+			if err != nil {
+				return err
+			}
+			if exists && step.FailIfExists {
+				return errors.Errorf("release %s already exists", release.Name)
+			}
+			if exists {
+				fmt.Fprintf(m.Out, "Release %s already exists, skipping its installation\n", release.Name)
+				continue
+			}
+		}
+
+		err = pipeline.readyChart(ctx, release.Name, release.Namespace, &release.Chart, &release.Version, func(ctx context.Context) (*exec.Cmd, error) {
+			return m.installCommand(ctx, release)
+		})
+		if err != nil {
+			return err
+		}
+
+		if step.Parallel {
+			// the releases are installed together once all of them are ready
+			parallel = append(parallel, release)
+			continue
+		}
+
+		result, err := m.installRelease(ctx, step.InstallArguments, release, dryRun)
+		if err != nil {
+			return err
+		}
+		if result != nil {
+			err = pipeline.record(*result)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if len(parallel) > 0 {
+		labels := make([]string, len(parallel))
+		for i, release := range parallel {
+			labels[i] = release.Name
+			if len(step.Namespaces) > 0 {
+				labels[i] = release.Namespace + "/" + release.Name
+			}
+		}
+		parallelResults, installErr := m.runParallel(labels, step.MaxParallel, func(m *Mixin, i int) (*releaseResult, error) {
+			return m.installRelease(ctx, step.InstallArguments, parallel[i], dryRun)
+		})
+		// the installed releases are recorded even when another release failed, so that they can be uninstalled
+		for _, result := range parallelResults {
+			if result == nil {
+				continue
+			}
+			err = pipeline.record(*result)
+			if err != nil {
+				return err
+			}
+		}
+		if installErr != nil {
+			return installErr
+		}
+	}
+
+	return pipeline.finish(ctx)
+}
+
+// installRelease installs a single release of an install step while holding its lock, and records its event
+func (m *Mixin) installRelease(ctx context.Context, step InstallArguments, release InstallArguments, dryRun bool) (*releaseResult, error) {
+	var result *releaseResult
+	err := m.retryOperationInProgress(ctx, release.Name, step.LockTimeout, func() error {
+		return m.withReleaseLock(release.Name, release.Namespace, step.Description, func() error {
+			var err error
+			result, err = m.install(ctx, release)
+			return err
+		})
+	})
+	if !dryRun {
+		name := release.Name
+		if result != nil {
+			name = result.Name
+		}
+		m.recordEvent(ctx, step.Description, "install", name, release.Namespace, err)
+	}
+	return result, err
+}
+
+// install runs helm for a single release of an install step
+func (m *Mixin) install(ctx context.Context, step InstallArguments) (*releaseResult, error) {
+	ctx, cancel := withTimeout(ctx, step.Timeout)
+	defer cancel()
+
+	// helm is interrupted instead of killed when the step is cancelled, so that it can release the lock of the release
+	cmd, err := m.installCommand(uncancelledContext{ctx}, step)
+	if err != nil {
+		return nil, err
+	}
+
+	if m.planMode() {
+		m.printPlannedCommand(cmd)
+		return nil, nil
+	}
+
+	if step.Wait && step.Name != "" && !isDryRun(step.DryRun) {
+		stop := m.watchProgress(ctx, step.Name, step.Namespace)
+		defer stop()
+	}
+
+	result, err := m.runReleaseCommand(ctx, cmd)
+	if err != nil && ctx.Err() != nil {
+		return nil, m.interruptedError("install", step.Name, step.Namespace, err)
+	}
+	return result, err
+}
+
+// installCommand builds the helm command that installs a single release of an install step
+func (m *Mixin) installCommand(ctx context.Context, step InstallArguments) (*exec.Cmd, error) {
+	var err error
+	cmd := m.helmCommand(ctx)
+
+	if step.RandomName {
+		// upgrade --install requires a release name, so a release with a generated name is always installed
+		cmd.Args = append(cmd.Args, "install", "--generate-name", step.Chart)
+	} else {
+		cmd.Args = append(cmd.Args, "upgrade", "--install", step.Name, step.Chart)
+	}
 
 	if step.Namespace != "" {
 		cmd.Args = append(cmd.Args, "--namespace", step.Namespace)
@@ -82,7 +487,7 @@ func (m *Mixin) Install(ctx context.Context) error {
 	}
 
 	for _, v := range step.Values {
-		cmd.Args = append(cmd.Args, "--values", v)
+		cmd.Args = append(cmd.Args, "--values", m.valuesFile(v))
 	}
 
 	if step.SkipCrds {
@@ -97,39 +502,67 @@ func (m *Mixin) Install(ctx context.Context) error {
 		cmd.Args = append(cmd.Args, "--repo", step.Repo, "--username", step.Username, "--password", step.Password)
 	}
 
-	if step.Timeout != "" {
-		cmd.Args = append(cmd.Args, "--timeout", step.Timeout)
-	}
-	if step.Debug {
+	cmd.Args = appendTimeout(cmd.Args, step.Timeout)
+	if step.Debug || m.verbose() {
 		cmd.Args = append(cmd.Args, "--debug")
 	}
+	if step.TakeOwnership {
+		cmd.Args = append(cmd.Args, "--take-ownership")
+	}
+	if step.SkipSchemaValidation {
+		cmd.Args = append(cmd.Args, "--skip-schema-validation")
+	}
 	// This will ensure the installation process deletes the installation on failure.
-	cmd.Args = append(cmd.Args, "--atomic")
+	cmd.Args = append(cmd.Args, m.helmFlag("--atomic"))
 	// This will ensure the creation of the release namespace if not present.
-	cmd.Args = append(cmd.Args, "--create-namespace")
+	cmd.Args = m.appendNamespaceFlags(cmd.Args)
+	// Print the release as json so that its result can be used by the step outputs
+	cmd.Args = append(cmd.Args, "--output", "json")
 	// Set values
-	cmd.Args = HandleSettingChartValuesForInstall(step, cmd)
-
-	cmd.Stdout = m.Out
-	cmd.Stderr = m.Err
-
-	// format the command with all arguments
-	prettyCmd := fmt.Sprintf("%s %s", cmd.Path, strings.Join(cmd.Args, " "))
-	fmt.Fprintln(m.Out, prettyCmd)
-
-	// Here where really the command get executed
-	err = cmd.Start()
-	// Exit on error
+	step.Set = subchartValues(step.Subcharts, step.Set)
+	cmd.Args = HandleSettingChartValuesForInstall(InstallStep{step}, cmd)
+	setTypedArgs, err := setTypedArgs(step.SetTyped)
+	if err != nil {
+		return nil, err
+	}
+	cmd.Args = append(cmd.Args, setTypedArgs...)
+	setFileArgs, err := m.setFileArgs(step.SetFileFromParameter)
+	if err != nil {
+		return nil, err
+	}
+	cmd.Args = append(cmd.Args, setFileArgs...)
+	setFromOutputArgs, err := m.setFromOutputArgs(step.SetFromOutput)
 	if err != nil {
-		return fmt.Errorf("could not execute command, %s: %s", prettyCmd, err)
+		return nil, err
 	}
-	err = cmd.Wait()
-	// Exit on error
+	cmd.Args = append(cmd.Args, setFromOutputArgs...)
+	cmd.Args = append(cmd.Args, step.dryRunFlags...)
+	cmd.Args, err = appendExtraFlags(cmd.Args, m.helmFlags(step.ExtraFlags), installManagedFlags)
+	if err != nil {
+		return nil, err
+	}
+
+	m.setStepEnv(cmd, step.Env)
+	return cmd, nil
+}
+
+// replaceFailedRelease uninstalls the release when a previous install left it in the failed status
+func (m *Mixin) replaceFailedRelease(ctx context.Context, step InstallArguments) error {
+	failed, err := m.releaseFailed(ctx, step.Name, step.Namespace)
 	if err != nil {
 		return err
 	}
-	err = m.handleOutputs(ctx, kubeClient, step.Namespace, step.Outputs)
-	return err
+	if !failed {
+		return nil
+	}
+
+	fmt.Fprintf(m.Out, "Release %s is in the failed status, uninstalling it before retrying the installation\n", step.Name)
+	uninstall := UninstallArguments{Namespace: step.Namespace, Debug: step.Debug}
+	uninstall.Env = step.Env
+	if step.KeepHistory {
+		uninstall.ExtraFlags = []string{"--keep-history"}
+	}
+	return m.delete(ctx, step.Name, uninstall)
 }
 
 // Prepare set arguments
@@ -143,7 +576,11 @@ func HandleSettingChartValuesForInstall(step InstallStep, cmd *exec.Cmd) []strin
 	sort.Strings(setKeys)
 
 	for _, k := range setKeys {
-		cmd.Args = append(cmd.Args, "--set", fmt.Sprintf("%s=%s", k, step.Set[k]))
+		key, value := k, step.Set[k]
+		if step.escapeSet() {
+			key, value = escapeSetKey(key), escapeSetValue(value)
+		}
+		cmd.Args = append(cmd.Args, "--set", fmt.Sprintf("%s=%s", key, value))
 	}
 	return cmd.Args
 }
@@ -0,0 +1,86 @@
+package helm3
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// allowedCommandsEnv lists the helm commands that custom actions may run in addition to the default ones
+const allowedCommandsEnv = "PORTER_HELM3_ALLOWED_COMMANDS"
+
+// defaultAllowedCommands are the helm commands, their aliases, and the plugins supported by the mixin, that custom
+// actions may run. helm plugin is left out, since it installs and runs arbitrary code at runtime.
+var defaultAllowedCommands = []string{
+	"completion", "create", "del", "delete", "dep", "dependencies", "dependency", "diff", "env", "fetch", "get",
+	"hist", "history", "inspect", "install", "lint", "list", "ls", "package", "pull", "push", "registry",
+	"repo", "rollback", "search", "secrets", "show", "status", "template", "test", "un", "uninstall", "upgrade",
+	"verify", "version",
+}
+
+// helmBoolGlobalFlags are the global flags of helm that don't take a value
+var helmBoolGlobalFlags = map[string]bool{"--debug": true, "--kube-insecure-skip-tls-verify": true}
+
+// helmCommandName returns the helm command of the arguments of a step, after the global flags that may come first,
+// such as --kube-context dev
+func helmCommandName(args []string) (string, bool) {
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if !strings.HasPrefix(arg, "-") {
+			return arg, true
+		}
+		if !strings.Contains(arg, "=") && !helmBoolGlobalFlags[arg] {
+			// the value of the flag
+			i++
+		}
+	}
+	return "", false
+}
+
+var commandNameRegex = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+
+// isValidCommandName checks that an additional allowed command looks like a helm command or plugin name
+func isValidCommandName(name string) bool {
+	return commandNameRegex.MatchString(name)
+}
+
+// allowedCommands returns the helm commands that custom actions may run
+func (m *Mixin) allowedCommands() map[string]bool {
+	allowed := make(map[string]bool, len(defaultAllowedCommands))
+	for _, command := range defaultAllowedCommands {
+		allowed[command] = true
+	}
+	for _, command := range strings.Split(m.Getenv(allowedCommandsEnv), ",") {
+		if command = strings.TrimSpace(command); command != "" {
+			allowed[command] = true
+		}
+	}
+	return allowed
+}
+
+// validateInvokeCommand checks that a custom action step starts with a helm command that is allowed
+func (m *Mixin) validateInvokeCommand(step ExecuteStep) error {
+	command, ok := helmCommandName(step.GetArguments())
+	if !ok {
+		return errors.New("the arguments of the step must start with a helm command")
+	}
+	return m.validateHelmCommand(command)
+}
+
+// validateHelmCommand checks that a helm command run by a step, outside of the actions of the mixin, is allowed
+func (m *Mixin) validateHelmCommand(command string) error {
+
+	allowed := m.allowedCommands()
+	if !allowed[command] {
+		names := make([]string, 0, len(allowed))
+		for name := range allowed {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return errors.Errorf("helm command %q is not allowed, add it to allowedCommands in the mixin configuration or use one of: %s",
+			command, strings.Join(names, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package helm3
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"testing"
+
+	"get.porter.sh/porter/pkg/exec/builder"
+	"get.porter.sh/porter/pkg/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestMixin_ValidateInvokeCommand(t *testing.T) {
+	testcases := []struct {
+		name      string
+		arguments []string
+		allowed   string
+		wantError string
+	}{
+		{name: "helm command", arguments: []string{"status", "mysql"}},
+		{name: "supported plugin", arguments: []string{"diff", "upgrade", "mysql", "stable/mysql"}},
+		{name: "allowed plugin", arguments: []string{"unittest", "./charts/mysql"}, allowed: "cm-push, unittest"},
+		{name: "no arguments", wantError: "the arguments of the step must start with a helm command"},
+		{name: "alias", arguments: []string{"ls", "--all"}},
+		{name: "create", arguments: []string{"create", "./charts/mysql"}},
+		{name: "global flag first", arguments: []string{"--kube-context", "dev", "status", "mysql"}},
+		{name: "global flags with values", arguments: []string{"--debug", "--kube-context=dev", "list"}},
+		{name: "helm plugin", arguments: []string{"plugin", "install", "https://github.com/example/helm-plugin"},
+			wantError: `helm command "plugin" is not allowed, add it to allowedCommands in the mixin configuration or use one of: ` +
+				"completion, create, del, delete, dep, dependencies, dependency, diff, env, fetch, get, hist, history, inspect, " +
+				"install, lint, list, ls, package, pull, push, registry, repo, rollback, search, secrets, show, status, " +
+				"template, test, un, uninstall, upgrade, verify, version"},
+		{name: "allowed helm plugin", arguments: []string{"plugin", "list"}, allowed: "plugin"},
+		{name: "only global flags", arguments: []string{"--kube-context", "dev"},
+			wantError: "the arguments of the step must start with a helm command"},
+		{name: "unknown command after a global flag", arguments: []string{"--kube-context", "dev", "unittest"},
+			wantError: `helm command "unittest" is not allowed, add it to allowedCommands in the mixin configuration or use one of: ` +
+				"completion, create, del, delete, dep, dependencies, dependency, diff, env, fetch, get, hist, history, inspect, " +
+				"install, lint, list, ls, package, pull, push, registry, repo, rollback, search, secrets, show, status, " +
+				"template, test, un, uninstall, upgrade, verify, version"},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			h := NewTestMixin(t)
+			h.Setenv(allowedCommandsEnv, tc.allowed)
+
+			err := h.validateInvokeCommand(ExecuteStep{Arguments: tc.arguments})
+			if tc.wantError != "" {
+				require.EqualError(t, err, tc.wantError)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestMixin_ExecuteRedactsFailedCommand(t *testing.T) {
+	ctx := context.Background()
+
+	defer os.Unsetenv(test.ExpectedCommandEnv)
+	os.Setenv(test.ExpectedCommandEnv, "helm3 registry login localhost:5000 -p otherpass -u myuser")
+
+	executeAction := Action{
+		Steps: []ExecuteSteps{
+			{
+				ExecuteStep: ExecuteStep{
+					Arguments: []string{"registry", "login", "localhost:5000"},
+					Flags: builder.Flags{
+						builder.NewFlag("u", "myuser"),
+						builder.NewFlag("p", "mypass"),
+					},
+				},
+			},
+		},
+	}
+
+	b, _ := yaml.Marshal(executeAction)
+
+	h := NewTestMixin(t)
+	h.In = bytes.NewReader(b)
+
+	err := h.Execute(ctx)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invocation of helm3 registry login localhost:5000 -p '*******' -u myuser failed")
+	assert.NotContains(t, err.Error(), "mypass")
+	assert.Contains(t, h.TestContext.GetOutput(), "helm3 registry login localhost:5000 -p '*******' -u myuser\n")
+}
@@ -0,0 +1,219 @@
+package helm3
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// defaultJobTimeout is how long a job is waited for without a timeout
+const defaultJobTimeout = "5m"
+
+// JobWait waits for a job deployed by the releases of a step to complete, such as a schema migration,
+// and writes its logs to an output
+type JobWait struct {
+	// Name of the job, or Selector of the labels of the jobs
+	Name     string `yaml:"name,omitempty"`
+	Selector string `yaml:"selector,omitempty"`
+	// Namespace of the job, the namespace of the step by default
+	Namespace string `yaml:"namespace,omitempty"`
+	// Timeout of the wait, 5m by default
+	Timeout string `yaml:"timeout,omitempty"`
+	// Output is the name of the output where the logs of the job are written
+	Output string `yaml:"output,omitempty"`
+}
+
+// id returns the name of the job, or the selector of the jobs, for messages
+func (j JobWait) id() string {
+	if j.Name != "" {
+		return j.Name
+	}
+	return j.Selector
+}
+
+// resource returns the jobs waited for, in the form understood by kubectl
+func (j JobWait) resource() []string {
+	if j.Name != "" {
+		return []string{"job/" + j.Name}
+	}
+	return []string{"job", "--selector", j.Selector}
+}
+
+// pods returns the pods of the jobs for kubectl logs, the pods of jobs selected by labels are expected to have the same labels
+func (j JobWait) pods() []string {
+	if j.Name != "" {
+		return []string{"job/" + j.Name}
+	}
+	return []string{"--selector", j.Selector, "--prefix"}
+}
+
+// validateJobs checks that every job of a step can be waited for
+func validateJobs(jobs []JobWait) error {
+	for _, job := range jobs {
+		if (job.Name == "") == (job.Selector == "") {
+			return errors.New("jobs requires either the name or the selector of each job")
+		}
+	}
+	return nil
+}
+
+// jobTimeouts returns the timeout field of each job
+func jobTimeouts(jobs []JobWait) []timeoutField {
+	fields := make([]timeoutField, 0, len(jobs))
+	for _, job := range jobs {
+		fields = append(fields, timeoutField{"timeout of job " + job.id(), job.Timeout})
+	}
+	return fields
+}
+
+// waitForJobs waits for each job of a step to complete, in order, and writes its logs to its output.
+// The logs of a job that doesn't complete are printed, to troubleshoot it.
+func (m *Mixin) waitForJobs(ctx context.Context, jobs []JobWait, namespace string) error {
+	for _, job := range jobs {
+		if job.Namespace == "" {
+			job.Namespace = namespace
+		}
+		if job.Timeout == "" {
+			job.Timeout = defaultJobTimeout
+		}
+
+		if m.planMode() {
+			m.printPlannedCommand(m.jobStatusCommand(ctx, job))
+			continue
+		}
+		err := m.waitForJob(ctx, job)
+		if err != nil {
+			if logs, logsErr := m.jobLogs(ctx, job); logsErr == nil {
+				fmt.Fprint(m.Err, logs)
+			}
+			return errors.Wrapf(err, "job %s did not complete", job.id())
+		}
+
+		if job.Output == "" {
+			continue
+		}
+		logs, err := m.jobLogs(ctx, job)
+		if err != nil {
+			return err
+		}
+		err = m.writeOutput(HelmOutput{Name: job.Output}, []byte(logs))
+		if err != nil {
+			return errors.Wrapf(err, "unable to write output '%s'", job.Output)
+		}
+	}
+	return nil
+}
+
+// jobPollInterval is the time between two reads of the status of the jobs that are waited for
+var jobPollInterval = 5 * time.Second
+
+// jobStatusCommand returns the command that prints the jobs waited for as json
+func (m *Mixin) jobStatusCommand(ctx context.Context, job JobWait) *exec.Cmd {
+	cmd := m.NewCommand(ctx, "kubectl", "get")
+	cmd.Args = append(cmd.Args, job.resource()...)
+	cmd.Args = append(cmd.Args, "--output", "json")
+	if job.Namespace != "" {
+		cmd.Args = append(cmd.Args, "--namespace", job.Namespace)
+	}
+	return cmd
+}
+
+// jobCondition is a condition of the status of a job, Complete or Failed once the job is finished
+type jobCondition struct {
+	Type   string `json:"type"`
+	Status string `json:"status"`
+}
+
+// jobStatus is a job printed by kubectl, or a list of the jobs selected by labels
+type jobStatus struct {
+	Kind     string `json:"kind"`
+	Metadata struct {
+		Name string `json:"name"`
+	} `json:"metadata"`
+	Status struct {
+		Conditions []jobCondition `json:"conditions"`
+	} `json:"status"`
+	Items []jobStatus `json:"items"`
+}
+
+// finished returns whether the job has the Complete or the Failed condition
+func (s jobStatus) finished(condition string) bool {
+	for _, c := range s.Status.Conditions {
+		if c.Type == condition && c.Status == "True" {
+			return true
+		}
+	}
+	return false
+}
+
+// waitForJob polls the status of the jobs until they all complete, and fails as soon as one of them fails, rather
+// than when the timeout expires as kubectl wait --for condition=complete does
+func (m *Mixin) waitForJob(ctx context.Context, job JobWait) error {
+	timeout, err := parseTimeout("timeout of job "+job.id(), job.Timeout)
+	if err != nil {
+		return err
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		cmd := m.jobStatusCommand(ctx, job)
+		cmd.Stderr = m.Err
+		out, err := cmd.Output()
+		if err != nil {
+			return errors.Wrapf(err, "could not get the status of %s", formatPlannedCommand(cmd.Args))
+		}
+		var status jobStatus
+		if err := json.Unmarshal(out, &status); err != nil {
+			return errors.Wrap(err, "could not parse the status of the job")
+		}
+		jobs := []jobStatus{status}
+		if status.Kind == "List" {
+			jobs = status.Items
+		}
+
+		complete := len(jobs) > 0
+		for _, j := range jobs {
+			if j.finished("Failed") {
+				return errors.Errorf("job %s failed", j.Metadata.Name)
+			}
+			complete = complete && j.finished("Complete")
+		}
+		if complete {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return errors.Errorf("timed out after %s", timeout)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(jobPollInterval):
+		}
+	}
+}
+
+// jobLogs returns the logs of every container of a job
+func (m *Mixin) jobLogs(ctx context.Context, job JobWait) (string, error) {
+	cmd := m.NewCommand(ctx, "kubectl", "logs")
+	cmd.Args = append(cmd.Args, job.pods()...)
+	cmd.Args = append(cmd.Args, "--all-containers", "--tail", "-1")
+	if job.Namespace != "" {
+		cmd.Args = append(cmd.Args, "--namespace", job.Namespace)
+	}
+
+	stdout := &bytes.Buffer{}
+	cmd.Stdout = stdout
+	cmd.Stderr = m.Err
+	m.echoCommand(formatPlannedCommand(cmd.Args))
+	err := cmd.Run()
+	if err != nil {
+		return "", errors.Wrapf(err, "could not get the logs of job %s", job.id())
+	}
+	return stdout.String(), nil
+}
@@ -0,0 +1,84 @@
+package helm3
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"get.porter.sh/porter/pkg/portercontext"
+	"get.porter.sh/porter/pkg/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMixin_WaitForJobs(t *testing.T) {
+	ctx := context.Background()
+	defer os.Unsetenv(test.ExpectedCommandEnv)
+	defer os.Unsetenv(test.ExpectedCommandOutputEnv)
+	os.Setenv(test.ExpectedCommandEnv, strings.Join([]string{
+		"kubectl get job/mysql-migrate --output json --namespace db",
+		"kubectl logs job/mysql-migrate --all-containers --tail -1 --namespace db",
+		"kubectl get job --selector app=seed --output json --namespace seed",
+	}, "\n"))
+	// every mocked command prints the same output, the completed job is also read as its logs
+	completed := `{"kind":"Job","metadata":{"name":"mysql-migrate"},"status":{"conditions":[{"type":"Complete","status":"True"}]}}`
+	os.Setenv(test.ExpectedCommandOutputEnv, completed)
+
+	jobs := []JobWait{
+		{Name: "mysql-migrate", Timeout: "10m", Output: "migrations"},
+		{Selector: "app=seed", Namespace: "seed"},
+	}
+
+	h := NewTestMixin(t)
+	err := h.waitForJobs(ctx, jobs, "db")
+	require.NoError(t, err)
+
+	logs, err := h.FileSystem.ReadFile(filepath.Join(portercontext.MixinOutputsDir, "migrations"))
+	require.NoError(t, err)
+	assert.Equal(t, completed, string(logs))
+}
+
+func TestMixin_WaitForJobsFailure(t *testing.T) {
+	ctx := context.Background()
+	defer os.Unsetenv(test.ExpectedCommandEnv)
+	defer os.Unsetenv(test.ExpectedCommandExitCodeEnv)
+	os.Setenv(test.ExpectedCommandEnv, strings.Join([]string{
+		"kubectl get job/mysql-migrate --output json --namespace db",
+		"kubectl logs job/mysql-migrate --all-containers --tail -1 --namespace db",
+	}, "\n"))
+	os.Setenv(test.ExpectedCommandExitCodeEnv, "1")
+
+	h := NewTestMixin(t)
+	err := h.waitForJobs(ctx, []JobWait{{Name: "mysql-migrate", Output: "migrations"}}, "db")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "job mysql-migrate did not complete")
+}
+
+func TestMixin_WaitForJobsFailed(t *testing.T) {
+	ctx := context.Background()
+	defer os.Unsetenv(test.ExpectedCommandEnv)
+	defer os.Unsetenv(test.ExpectedCommandOutputEnv)
+	os.Setenv(test.ExpectedCommandEnv, strings.Join([]string{
+		"kubectl get job --selector app=seed --output json --namespace db",
+		"kubectl logs --selector app=seed --prefix --all-containers --tail -1 --namespace db",
+	}, "\n"))
+	os.Setenv(test.ExpectedCommandOutputEnv, `{"kind":"List","items":[`+
+		`{"kind":"Job","metadata":{"name":"seed-users"},"status":{"conditions":[{"type":"Complete","status":"True"}]}},`+
+		`{"kind":"Job","metadata":{"name":"seed-orders"},"status":{"conditions":[{"type":"Failed","status":"True"}]}}]}`)
+
+	h := NewTestMixin(t)
+	start := time.Now()
+	err := h.waitForJobs(ctx, []JobWait{{Selector: "app=seed", Timeout: "10m"}}, "db")
+	require.EqualError(t, err, "job app=seed did not complete: job seed-orders failed")
+	assert.Less(t, time.Since(start), time.Minute, "a failed job should fail the step without waiting for the timeout")
+}
+
+func TestValidateJobs(t *testing.T) {
+	require.NoError(t, validateJobs([]JobWait{{Name: "migrate"}, {Selector: "app=seed"}}))
+	require.EqualError(t, validateJobs([]JobWait{{}}), "jobs requires either the name or the selector of each job")
+	require.EqualError(t, validateJobs([]JobWait{{Name: "migrate", Selector: "app=seed"}}),
+		"jobs requires either the name or the selector of each job")
+}
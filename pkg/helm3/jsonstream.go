@@ -0,0 +1,258 @@
+package helm3
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+
+	"github.com/pkg/errors"
+)
+
+// jsonFields decode the values of the fields of a json object by name
+type jsonFields map[string]func(dec *json.Decoder) error
+
+// decodeJSONValue returns a field decoder that decodes its value into v
+func decodeJSONValue(v interface{}) func(dec *json.Decoder) error {
+	return func(dec *json.Decoder) error {
+		return dec.Decode(v)
+	}
+}
+
+// decodeJSONObject reads the json object printed first by a command field by field, so that it is never held in
+// memory at once, in case a wrapper of the helm binary adds its own output. The values of the fields with a decoder
+// are decoded by it, the string values of the fields with a writer, such as the manifest of a release, are unescaped
+// to it as they are read, and the other values are skipped.
+func decodeJSONObject(r io.Reader, fields jsonFields, streams map[string]io.Writer) error {
+	for {
+		dec := json.NewDecoder(r)
+		if err := expectJSONDelim(dec, '{'); err != nil {
+			return err
+		}
+
+		streamed := false
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				return err
+			}
+			name, _ := key.(string)
+			if w, ok := streams[name]; ok {
+				// the decoder would read the whole string, so the rest of the object is read as an object of its own
+				rest := bufio.NewReader(io.MultiReader(dec.Buffered(), r))
+				if err := copyJSONString(rest, w); err != nil {
+					return errors.Wrapf(err, "could not read %s", name)
+				}
+				next, err := nextJSONByte(rest)
+				if err != nil {
+					return err
+				}
+				if next == '}' {
+					return nil
+				}
+				if next != ',' {
+					return errors.Errorf("invalid character %q after %s", next, name)
+				}
+				r = io.MultiReader(strings.NewReader("{"), rest)
+				streamed = true
+				break
+			}
+			if decode, ok := fields[name]; ok {
+				err = decode(dec)
+			} else {
+				err = skipJSONValue(dec)
+			}
+			if err != nil {
+				return errors.Wrapf(err, "could not read %s", name)
+			}
+		}
+		if !streamed {
+			return expectJSONDelim(dec, '}')
+		}
+	}
+}
+
+// decodeJSONFields reads the next value of the decoder as an object, decoding the values of the fields with a
+// decoder and skipping the others. A null value is left undecoded.
+func decodeJSONFields(dec *json.Decoder, fields jsonFields) error {
+	t, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if t == nil {
+		return nil
+	}
+	if t != json.Delim('{') {
+		return errors.Errorf("expected a json object, got %v", t)
+	}
+	for dec.More() {
+		key, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		name, _ := key.(string)
+		if decode, ok := fields[name]; ok {
+			err = decode(dec)
+		} else {
+			err = skipJSONValue(dec)
+		}
+		if err != nil {
+			return errors.Wrapf(err, "could not read %s", name)
+		}
+	}
+	return expectJSONDelim(dec, '}')
+}
+
+// decodeJSONArray reads the next value of the decoder as an array, calling decode for each of its elements so that
+// only one of them is held in memory at once. A null value has no elements.
+func decodeJSONArray(dec *json.Decoder, decode func(dec *json.Decoder) error) error {
+	t, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if t == nil {
+		return nil
+	}
+	if t != json.Delim('[') {
+		return errors.Errorf("expected a json array, got %v", t)
+	}
+	for dec.More() {
+		if err := decode(dec); err != nil {
+			return err
+		}
+	}
+	return expectJSONDelim(dec, ']')
+}
+
+// expectJSONDelim reads the next token of the decoder, which must be the delimiter
+func expectJSONDelim(dec *json.Decoder, delim json.Delim) error {
+	t, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if t != delim {
+		return errors.Errorf("expected %s in json, got %v", delim, t)
+	}
+	return nil
+}
+
+// skipJSONValue reads the next value of the decoder token by token, counting the nested objects and arrays, without
+// decoding it
+func skipJSONValue(dec *json.Decoder) error {
+	depth := 0
+	for {
+		t, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		switch t {
+		case json.Delim('{'), json.Delim('['):
+			depth++
+		case json.Delim('}'), json.Delim(']'):
+			depth--
+		}
+		if depth == 0 {
+			return nil
+		}
+	}
+}
+
+// nextJSONByte returns the next byte of a json document that is not a space
+func nextJSONByte(r *bufio.Reader) (byte, error) {
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		switch b {
+		case ' ', '\t', '\n', '\r':
+			continue
+		}
+		return b, nil
+	}
+}
+
+// copyJSONString writes the json string value that follows a key of an object to w, unescaped as it is read
+func copyJSONString(r *bufio.Reader, w io.Writer) error {
+	for _, want := range []byte{':', '"'} {
+		b, err := nextJSONByte(r)
+		if err != nil {
+			return err
+		}
+		if b != want {
+			return errors.Errorf("invalid character %q, expected a string", b)
+		}
+	}
+
+	bw := bufio.NewWriter(w)
+	for {
+		b, err := r.ReadByte()
+		if err == io.EOF {
+			return io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return err
+		}
+		switch b {
+		case '"':
+			return bw.Flush()
+		case '\\':
+			err = copyJSONEscape(r, bw)
+		default:
+			err = bw.WriteByte(b)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// copyJSONEscape writes the character of an escape sequence of a json string, after its backslash
+func copyJSONEscape(r *bufio.Reader, w *bufio.Writer) error {
+	b, err := r.ReadByte()
+	if err != nil {
+		return err
+	}
+	switch b {
+	case '"', '\\', '/':
+		return w.WriteByte(b)
+	case 'b':
+		return w.WriteByte('\b')
+	case 'f':
+		return w.WriteByte('\f')
+	case 'n':
+		return w.WriteByte('\n')
+	case 'r':
+		return w.WriteByte('\r')
+	case 't':
+		return w.WriteByte('\t')
+	case 'u':
+		hex := make([]byte, 4)
+		if _, err := io.ReadFull(r, hex); err != nil {
+			return err
+		}
+		c, err := strconv.ParseUint(string(hex), 16, 16)
+		if err != nil {
+			return errors.Errorf("invalid escape \\u%s in json string", hex)
+		}
+		char := rune(c)
+		if utf16.IsSurrogate(char) {
+			// a character outside of the basic plane is escaped as a surrogate pair
+			char = unicode.ReplacementChar
+			if next, err := r.Peek(6); err == nil && next[0] == '\\' && next[1] == 'u' {
+				if low, err := strconv.ParseUint(string(next[2:]), 16, 16); err == nil {
+					if pair := utf16.DecodeRune(rune(c), rune(low)); pair != unicode.ReplacementChar {
+						char = pair
+						r.Discard(6)
+					}
+				}
+			}
+		}
+		_, err = w.WriteRune(char)
+		return err
+	}
+	return errors.Errorf("invalid escape \\%c in json string", b)
+}
@@ -0,0 +1,90 @@
+package helm3
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodeJSONObject(t *testing.T) {
+	manifest := "kind: ConfigMap\ndata:\n  quote: \"a\\b\"\n  tab: \"\t\"\n  emoji: \U0001F600 é\n"
+	encoded, err := json.Marshal(manifest)
+	require.NoError(t, err)
+
+	testcases := []struct {
+		name   string
+		output string
+	}{
+		{"manifest in the middle", `{"name":"mysql","manifest":` + string(encoded) + `,"version":3,"info":{"status":"deployed"}}`},
+		{"manifest last", `{"name":"mysql","version":3,"info":{"status":"deployed"},"manifest":` + string(encoded) + `}`},
+		{"escaped characters", `{"name":"mysql","version":3,"info":{"status":"deployed"},"manifest":"kind: ConfigMap\ndata:\n  quote: \"a\\b\"\n  tab: \"\t\"\n  emoji: \ud83d\ude00 \u00e9\n"}`},
+		{"output of a wrapper", "{\n  \"name\": \"mysql\",\n  \"manifest\": " + string(encoded) + " ,\n  \"version\": 3, \"info\": {\"status\": \"deployed\"}\n}\nwrapper: done\n"},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			var name string
+			var version int
+			var info struct {
+				Status string `json:"status"`
+			}
+			streamed := &bytes.Buffer{}
+			err := decodeJSONObject(strings.NewReader(tc.output), jsonFields{
+				"name":    decodeJSONValue(&name),
+				"version": decodeJSONValue(&version),
+				"info":    decodeJSONValue(&info),
+			}, map[string]io.Writer{"manifest": streamed})
+			require.NoError(t, err)
+
+			assert.Equal(t, "mysql", name)
+			assert.Equal(t, 3, version)
+			assert.Equal(t, "deployed", info.Status)
+			assert.Equal(t, manifest, streamed.String())
+		})
+	}
+}
+
+func TestDecodeJSONObject_Invalid(t *testing.T) {
+	testcases := []struct {
+		name    string
+		output  string
+		wantErr string
+	}{
+		{"not an object", `["mysql"]`, "expected { in json"},
+		{"manifest not a string", `{"manifest":3}`, "expected a string"},
+		{"unterminated manifest", `{"manifest":"kind: ConfigMap`, "could not read manifest"},
+		{"invalid escape", `{"manifest":"\x"}`, "invalid escape"},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := decodeJSONObject(strings.NewReader(tc.output), nil, map[string]io.Writer{"manifest": io.Discard})
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tc.wantErr)
+		})
+	}
+}
+
+func TestDecodeJSONArray(t *testing.T) {
+	var names []string
+	err := decodeJSONFields(json.NewDecoder(strings.NewReader(`{"files":[{"name":"a"},{"name":"b"}],"other":null}`)), jsonFields{
+		"files": func(dec *json.Decoder) error {
+			return decodeJSONArray(dec, func(dec *json.Decoder) error {
+				var f struct {
+					Name string `json:"name"`
+				}
+				err := dec.Decode(&f)
+				names = append(names, f.Name)
+				return err
+			})
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, names)
+
+	err = decodeJSONArray(json.NewDecoder(strings.NewReader(`null`)), nil)
+	require.NoError(t, err)
+}
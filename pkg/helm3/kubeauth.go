@@ -0,0 +1,84 @@
+package helm3
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultAWSIAMAuthenticatorVersion = "v0.6.14"
+	defaultKubeloginVersion           = "v0.1.1"
+)
+
+// supportedKubeAuthPlugins are the kubeconfig exec auth plugins that can be installed with kubeAuthPlugins
+var supportedKubeAuthPlugins = []string{"aws-iam-authenticator", "kubelogin", "gke-gcloud-auth-plugin"}
+
+func isSupportedKubeAuthPlugin(plugin string) bool {
+	for _, supported := range supportedKubeAuthPlugins {
+		if plugin == supported {
+			return true
+		}
+	}
+	return false
+}
+
+// kubeAuthPluginArchitectures are the architectures of the helm client for which aws-iam-authenticator and kubelogin
+// are released
+var kubeAuthPluginArchitectures = []string{"amd64", "arm64"}
+
+// validateKubeAuthPlatform checks that the plugins downloaded from their releases are released for the architecture of
+// the helm client, gke-gcloud-auth-plugin is installed from the packages of the image instead
+func (m *Mixin) validateKubeAuthPlatform(plugins []string) error {
+	for _, plugin := range plugins {
+		if plugin == "gke-gcloud-auth-plugin" {
+			continue
+		}
+		supported := false
+		for _, arch := range kubeAuthPluginArchitectures {
+			supported = supported || m.HelmClientArchitecture == arch
+		}
+		if !supported {
+			return errors.Errorf("supplied kubeAuthPlugins %q is not available for the %s architecture, supported architectures are: %s",
+				plugin, m.HelmClientArchitecture, strings.Join(kubeAuthPluginArchitectures, ", "))
+		}
+	}
+	return nil
+}
+
+// buildKubeAuthPlugins writes the Dockerfile lines that install the exec auth plugins used by the kubeconfigs of
+// EKS, AKS and GKE clusters. The plugins downloaded from their releases are verified against the sha256 checksums
+// published with the release of their version and architecture.
+func (m *Mixin) buildKubeAuthPlugins(plugins []string) {
+	for _, plugin := range plugins {
+		switch plugin {
+		case "aws-iam-authenticator":
+			version := strings.TrimPrefix(defaultAWSIAMAuthenticatorVersion, "v")
+			release := "https://github.com/kubernetes-sigs/aws-iam-authenticator/releases/download/" + defaultAWSIAMAuthenticatorVersion
+			binary := fmt.Sprintf("aws-iam-authenticator_%s_%s_%s", version, m.HelmClientPlatfrom, m.HelmClientArchitecture)
+			fmt.Fprintf(m.Out, "RUN curl -fsSLO %s/%s &&\\\n", release, binary)
+			fmt.Fprintf(m.Out, "    curl -fsSL %s/authenticator_%s_checksums.txt | grep ' %s$' | sha256sum -c - &&\\\n",
+				release, version, binary)
+			fmt.Fprintf(m.Out, "    install -m 0755 %s /usr/local/bin/aws-iam-authenticator &&\\\n", binary)
+			fmt.Fprintf(m.Out, "    rm %s\n", binary)
+		case "kubelogin":
+			archive := fmt.Sprintf("kubelogin-%s-%s.zip", m.HelmClientPlatfrom, m.HelmClientArchitecture)
+			url := fmt.Sprintf("https://github.com/Azure/kubelogin/releases/download/%s/%s", defaultKubeloginVersion, archive)
+			fmt.Fprintln(m.Out, "RUN "+aptInstall("unzip")+" &&\\")
+			fmt.Fprintf(m.Out, "    curl -fsSLO %s &&\\\n", url)
+			fmt.Fprintf(m.Out, "    echo \"$(curl -fsSL %s.sha256 | cut -d ' ' -f1)  %s\" | sha256sum -c - &&\\\n", url, archive)
+			fmt.Fprintf(m.Out, "    unzip -j %s bin/%s_%s/kubelogin -d /usr/local/bin &&\\\n",
+				archive, m.HelmClientPlatfrom, m.HelmClientArchitecture)
+			fmt.Fprintf(m.Out, "    rm %s\n", archive)
+		case "gke-gcloud-auth-plugin":
+			// The plugin is only distributed with the Google Cloud CLI packages
+			fmt.Fprintln(m.Out, "RUN "+aptInstall("gnupg")+" &&\\")
+			fmt.Fprintln(m.Out, "    curl -fsSL https://packages.cloud.google.com/apt/doc/apt-key.gpg | gpg --dearmor -o /usr/share/keyrings/cloud.google.gpg &&\\")
+			fmt.Fprintln(m.Out, `    echo "deb [signed-by=/usr/share/keyrings/cloud.google.gpg] https://packages.cloud.google.com/apt cloud-sdk main" > /etc/apt/sources.list.d/google-cloud-sdk.list &&\`)
+			fmt.Fprintln(m.Out, "    "+aptInstall("google-cloud-cli-gke-gcloud-auth-plugin"))
+			// kubectl versions before 1.26 only run the plugin when asked to
+			fmt.Fprintln(m.Out, "ENV USE_GKE_GCLOUD_AUTH_PLUGIN=True")
+		}
+	}
+}
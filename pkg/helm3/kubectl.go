@@ -0,0 +1,58 @@
+package helm3
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// stableKubectlVersion selects the latest kubectl release when used as the kubectlVersion
+const stableKubectlVersion = "stable"
+
+// kubectlVersionEnv records the kubectl version installed in the invocation image
+const kubectlVersionEnv = "PORTER_HELM3_KUBECTL_VERSION"
+
+// kubectlStableURL is the stable release channel of kubectl, a variable so that tests can replace it
+var kubectlStableURL = "https://storage.googleapis.com/kubernetes-release/release/stable.txt"
+
+// resolveKubectlVersion returns the kubectl version to install, resolving the stable release channel
+func resolveKubectlVersion(ctx context.Context, version string) (string, error) {
+	if version == "" {
+		return defaultKubectlVersion, nil
+	}
+	if version != stableKubectlVersion {
+		if !strings.HasPrefix(version, "v") {
+			return "", errors.Errorf("supplied kubectlVersion %q must be %s or a version starting with v", version, stableKubectlVersion)
+		}
+		return version, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, kubectlStableURL, nil)
+	if err != nil {
+		return "", errors.Wrap(err, "could not resolve the stable kubectl version")
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", errors.Wrap(err, "could not resolve the stable kubectl version")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", errors.Errorf("could not resolve the stable kubectl version, %s returned %s", kubectlStableURL, resp.Status)
+	}
+
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", errors.Wrap(err, "could not resolve the stable kubectl version")
+	}
+	resolved := strings.TrimSpace(string(b))
+	if !strings.HasPrefix(resolved, "v") {
+		return "", errors.Errorf("could not resolve the stable kubectl version, unexpected version %q", resolved)
+	}
+	return resolved, nil
+}
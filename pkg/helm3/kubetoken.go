@@ -0,0 +1,108 @@
+package helm3
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/MChorfa/porter-helm3/pkg/kubernetes"
+	"github.com/pkg/errors"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+const (
+	// kubeAPIServerEnv is the address of the kubernetes API server, it enables the token authentication of the bundle
+	kubeAPIServerEnv = "PORTER_HELM3_KUBE_APISERVER"
+	// kubeTokenEnv is the bearer token used to authenticate to the API server
+	kubeTokenEnv = "PORTER_HELM3_KUBE_TOKEN"
+	// kubeTokenFileEnv is the path of a file holding the bearer token, such as a projected service account token,
+	// which is read again whenever it is rotated
+	kubeTokenFileEnv = "PORTER_HELM3_KUBE_TOKEN_FILE"
+	// kubeCADataEnv is the PEM certificate authority of the API server, optionally base64 encoded
+	kubeCADataEnv = "PORTER_HELM3_KUBE_CA_DATA"
+	// kubeCAFileEnv is the path of the certificate authority of the API server
+	kubeCAFileEnv = "PORTER_HELM3_KUBE_CA_FILE"
+	// kubeconfigEnv is the path of the kubeconfig read by helm and kubectl
+	kubeconfigEnv = "KUBECONFIG"
+)
+
+// tokenKubeconfigPath is where the kubeconfig assembled from the token authentication of the bundle is written
+var tokenKubeconfigPath = filepath.Join(os.TempDir(), "porter-helm3", "kubeconfig")
+
+// configureKubeToken writes a kubeconfig from the API server, token and certificate authority in the environment,
+// usually bundle credentials, and uses it for helm, kubectl and the kubernetes client, so that a bundle running with
+// workload identity doesn't need a kubeconfig credential. Nothing changes when the API server is not set.
+// The returned function removes the kubeconfig once the step completes, so that the token is not left behind.
+func (m *Mixin) configureKubeToken() (func(), error) {
+	server := m.Getenv(kubeAPIServerEnv)
+	if server == "" {
+		return func() {}, nil
+	}
+
+	token, tokenFile := m.Getenv(kubeTokenEnv), m.Getenv(kubeTokenFileEnv)
+	if (token == "") == (tokenFile == "") {
+		return nil, errors.Errorf("%s requires either %s or %s", kubeAPIServerEnv, kubeTokenEnv, kubeTokenFileEnv)
+	}
+	caData, caFile := m.Getenv(kubeCADataEnv), m.Getenv(kubeCAFileEnv)
+	if caData != "" && caFile != "" {
+		return nil, errors.Errorf("only one of %s and %s can be set", kubeCADataEnv, kubeCAFileEnv)
+	}
+
+	cluster := clientcmdapi.NewCluster()
+	cluster.Server = server
+	cluster.CertificateAuthority = caFile
+	if caData != "" {
+		ca, err := decodeCertificateAuthority(caData)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid %s", kubeCADataEnv)
+		}
+		cluster.CertificateAuthorityData = ca
+	}
+	user := clientcmdapi.NewAuthInfo()
+	user.Token = token
+	user.TokenFile = tokenFile
+	kubeContext := clientcmdapi.NewContext()
+	kubeContext.Cluster = "porter"
+	kubeContext.AuthInfo = "porter"
+
+	config := clientcmdapi.NewConfig()
+	config.Clusters["porter"] = cluster
+	config.AuthInfos["porter"] = user
+	config.Contexts["porter"] = kubeContext
+	config.CurrentContext = "porter"
+	b, err := clientcmd.Write(*config)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not write the kubeconfig of the token authentication")
+	}
+
+	err = m.FileSystem.MkdirAll(filepath.Dir(tokenKubeconfigPath), 0700)
+	if err == nil {
+		err = m.FileSystem.WriteFile(tokenKubeconfigPath, b, 0600)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "could not write the kubeconfig of the token authentication")
+	}
+	m.Setenv(kubeconfigEnv, tokenKubeconfigPath)
+	m.ClientFactory = kubernetes.NewForKubeconfig(tokenKubeconfigPath)
+	return func() {
+		if err := m.FileSystem.Remove(tokenKubeconfigPath); err != nil {
+			fmt.Fprintf(m.Err, "WARNING: could not remove the kubeconfig %s: %s\n", tokenKubeconfigPath, err)
+		}
+	}, nil
+}
+
+// decodeCertificateAuthority returns a PEM certificate authority, decoding it when it is base64 encoded
+// as in the certificate-authority-data of a kubeconfig
+func decodeCertificateAuthority(ca string) ([]byte, error) {
+	if strings.Contains(ca, "-----BEGIN") {
+		return []byte(ca), nil
+	}
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(ca))
+	if err != nil || !strings.Contains(string(decoded), "-----BEGIN") {
+		return nil, errors.New("expected a PEM certificate, optionally base64 encoded")
+	}
+	return decoded, nil
+}
@@ -0,0 +1,90 @@
+package helm3
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+const testCA = "-----BEGIN CERTIFICATE-----\nMIIBtest\n-----END CERTIFICATE-----\n"
+
+func TestMixin_ConfigureKubeToken(t *testing.T) {
+	testcases := []struct {
+		name      string
+		env       map[string]string
+		wantToken string
+		wantFile  string
+		wantCA    string
+		wantError string
+	}{
+		{
+			name:      "token with base64 certificate authority",
+			env:       map[string]string{kubeTokenEnv: "secret", kubeCADataEnv: base64.StdEncoding.EncodeToString([]byte(testCA))},
+			wantToken: "secret",
+			wantCA:    testCA,
+		},
+		{
+			name:     "projected token with PEM certificate authority",
+			env:      map[string]string{kubeTokenFileEnv: "/var/run/secrets/tokens/porter", kubeCADataEnv: testCA},
+			wantFile: "/var/run/secrets/tokens/porter",
+			wantCA:   testCA,
+		},
+		{
+			name:      "missing token",
+			env:       map[string]string{},
+			wantError: "PORTER_HELM3_KUBE_APISERVER requires either PORTER_HELM3_KUBE_TOKEN or PORTER_HELM3_KUBE_TOKEN_FILE",
+		},
+		{
+			name:      "invalid certificate authority",
+			env:       map[string]string{kubeTokenEnv: "secret", kubeCADataEnv: "not a certificate"},
+			wantError: "invalid PORTER_HELM3_KUBE_CA_DATA: expected a PEM certificate, optionally base64 encoded",
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			h := NewTestMixin(t)
+			h.Setenv(kubeAPIServerEnv, "https://10.0.0.1:443")
+			for k, v := range tc.env {
+				h.Setenv(k, v)
+			}
+
+			remove, err := h.configureKubeToken()
+			if tc.wantError != "" {
+				require.EqualError(t, err, tc.wantError)
+				return
+			}
+			require.NoError(t, err)
+			defer func() {
+				remove()
+				exists, err := h.FileSystem.Exists(tokenKubeconfigPath)
+				require.NoError(t, err)
+				assert.False(t, exists, "the kubeconfig should be removed once the step completes")
+			}()
+			assert.Equal(t, tokenKubeconfigPath, h.Getenv(kubeconfigEnv))
+
+			b, err := h.FileSystem.ReadFile(tokenKubeconfigPath)
+			require.NoError(t, err)
+			config, err := clientcmd.Load(b)
+			require.NoError(t, err)
+			require.Equal(t, "porter", config.CurrentContext)
+			assert.Equal(t, "https://10.0.0.1:443", config.Clusters["porter"].Server)
+			assert.Equal(t, tc.wantCA, string(config.Clusters["porter"].CertificateAuthorityData))
+			assert.Equal(t, tc.wantToken, config.AuthInfos["porter"].Token)
+			assert.Equal(t, tc.wantFile, config.AuthInfos["porter"].TokenFile)
+		})
+	}
+}
+
+func TestMixin_ConfigureKubeTokenDisabled(t *testing.T) {
+	h := NewTestMixin(t)
+	h.Setenv(kubeTokenEnv, "secret")
+
+	remove, err := h.configureKubeToken()
+	require.NoError(t, err)
+	remove()
+	assert.Empty(t, h.Getenv(kubeconfigEnv))
+}
@@ -0,0 +1,315 @@
+package helm3
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"get.porter.sh/porter/pkg/exec/builder"
+	"get.porter.sh/porter/pkg/linter"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+const (
+	// CodeMissingField is reported when a step is missing a field required by its action
+	CodeMissingField linter.Code = "helm3-100"
+	// CodeMutuallyExclusiveFields is reported when a step sets fields that cannot be used together
+	CodeMutuallyExclusiveFields linter.Code = "helm3-101"
+	// CodeUnknownRepository is reported when a chart references a repository that is not configured on the mixin
+	CodeUnknownRepository linter.Code = "helm3-102"
+	// CodeUnreferencedRepository is reported when a configured repository is not used by any chart
+	CodeUnreferencedRepository linter.Code = "helm3-103"
+	// CodeInvalidDuration is reported when a timeout cannot be parsed as a duration
+	CodeInvalidDuration linter.Code = "helm3-104"
+	// CodeIgnoredField is reported when a field is set but will not be used
+	CodeIgnoredField linter.Code = "helm3-105"
+	// CodeInvalidNamespaces is reported when the namespaces of a step can't be used to deploy its releases
+	CodeInvalidNamespaces linter.Code = "helm3-106"
+	// CodeInvalidChartDigest is reported when a chart is pinned to a digest that can't be pulled
+	CodeInvalidChartDigest linter.Code = "helm3-107"
+)
+
+// lintInput represents stdin passed to the mixin for the lint command.
+type lintInput struct {
+	Config    MixinConfig     `yaml:"config"`
+	Install   []InstallStep   `yaml:"install"`
+	Upgrade   []UpgradeStep   `yaml:"upgrade"`
+	Uninstall []UninstallStep `yaml:"uninstall"`
+}
+
+// stepLinter collects the lint results for a single step
+type stepLinter struct {
+	location linter.Location
+	// line is the line of the step in the steps passed to the mixin, added to the messages since the location of
+	// porter has no line
+	line    int
+	results linter.Results
+}
+
+func (l *stepLinter) add(level linter.Level, code linter.Code, title string, format string, args ...interface{}) {
+	message := fmt.Sprintf(format, args...)
+	if l.line > 0 {
+		message = fmt.Sprintf("%s (line %d)", message, l.line)
+	}
+	l.results = append(l.results, linter.Result{
+		Level:    level,
+		Location: l.location,
+		Code:     code,
+		Title:    title,
+		Message:  message,
+	})
+}
+
+func (l *stepLinter) required(field string, value string) {
+	if value == "" {
+		l.add(linter.LevelError, CodeMissingField, "Missing required field",
+			"The %s field is required for the %s action", field, l.location.Action)
+	}
+}
+
+func (l *stepLinter) timeouts(fields []timeoutField) {
+	for _, field := range fields {
+		if _, err := parseTimeout(field.name, field.value); err != nil {
+			l.add(linter.LevelError, CodeInvalidDuration, "Invalid duration",
+				"The %s %q is not a valid duration, for example 90s or 5m30s", field.name, field.value)
+		}
+	}
+}
+
+func (l *stepLinter) chartDigest(chart string) {
+	if err := validateChartDigest(chart); err != nil {
+		l.add(linter.LevelError, CodeInvalidChartDigest, "Invalid chart digest", "%s", err)
+	}
+}
+
+// repo returns the repo of a step, reporting it as ignored when it is set without both credentials
+func (l *stepLinter) repo(repo, username, password string) string {
+	if repo != "" && (username == "" || password == "") {
+		l.add(linter.LevelWarning, CodeIgnoredField, "Ignored field",
+			"The repo field is only used when both username and password are set")
+		return ""
+	}
+	return repo
+}
+
+// exclusive reports the fields of a step that cannot be set together, with the error returned when the step runs
+func (l *stepLinter) exclusive(err error) {
+	if err != nil {
+		l.add(linter.LevelError, CodeMutuallyExclusiveFields, "Mutually exclusive fields", "%s", err)
+	}
+}
+
+func (l *stepLinter) namespaces(namespaces NamespaceList, namespace string, releases []Release) {
+	if err := validateNamespaces(namespaces, namespace, releases); err != nil {
+		l.add(linter.LevelError, CodeInvalidNamespaces, "Invalid namespaces", "%s", err)
+	}
+}
+
+// Lint checks the helm3 steps in the bundle for common problems.
+func (m *Mixin) Lint(ctx context.Context) (linter.Results, error) {
+	var input lintInput
+	var actions map[string]interface{}
+	var lines map[string][]int
+	err := builder.LoadAction(ctx, m.RuntimeConfig, "", func(contents []byte) (interface{}, error) {
+		err := yaml.Unmarshal(contents, &input)
+		if err != nil {
+			return &input, err
+		}
+		err = yaml.Unmarshal(contents, &actions)
+		if err != nil {
+			return &input, err
+		}
+		lines, err = stepLines(contents)
+		return &input, err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var results linter.Results
+	referencedRepos := make(map[string]bool)
+
+	for i, step := range input.Install {
+		l := newStepLinter("install", i, step.Description, lines)
+		l.timeouts(step.timeouts())
+		l.namespaces(step.Namespaces, step.Namespace, step.Releases)
+		repo := l.repo(step.Repo, step.Username, step.Password)
+		for _, release := range step.releases() {
+			if step.GenerateName == "" && !step.RandomName {
+				l.required("name", release.Name)
+			}
+			l.required("chart", release.Chart)
+			l.chartDigest(release.Chart)
+			lintChartRepository(l, knownRepositories(input.Config, step.Repositories), release.Chart, repo, referencedRepos)
+		}
+		l.exclusive(step.validateExclusiveFields())
+		if step.KeepHistory && !step.ReplaceFailed {
+			l.add(linter.LevelWarning, CodeIgnoredField, "Ignored field",
+				"The keepHistory field is only used when replaceFailed is set")
+		}
+		results = append(results, l.results...)
+	}
+
+	for i, step := range input.Upgrade {
+		l := newStepLinter("upgrade", i, step.Description, lines)
+		l.timeouts(step.timeouts())
+		l.namespaces(step.Namespaces, step.Namespace, step.Releases)
+		repo := l.repo(step.Repo, step.Username, step.Password)
+		for _, release := range step.releases() {
+			if step.GenerateName == "" && step.ReleaseNameFile == "" {
+				l.required("name", release.Name)
+			}
+			l.required("chart", release.Chart)
+			l.chartDigest(release.Chart)
+			lintChartRepository(l, knownRepositories(input.Config, step.Repositories), release.Chart, repo, referencedRepos)
+		}
+		l.exclusive(step.validateExclusiveFields())
+		results = append(results, l.results...)
+	}
+
+	for i, step := range input.Uninstall {
+		l := newStepLinter("uninstall", i, step.Description, lines)
+		if len(step.Releases) == 0 && step.GenerateName == "" && step.ReleaseNameFile == "" && step.ReleasesFile == "" {
+			l.add(linter.LevelError, CodeMissingField, "Missing required field",
+				"The releases field is required for the uninstall action")
+		}
+		l.timeouts(step.timeouts())
+		l.namespaces(step.Namespaces, step.Namespace, nil)
+		results = append(results, l.results...)
+	}
+
+	actionNames := make([]string, 0, len(actions))
+	for name := range actions {
+		switch name {
+		case "config", "install", "upgrade", "uninstall":
+			continue
+		}
+		actionNames = append(actionNames, name)
+	}
+	sort.Strings(actionNames)
+	for _, name := range actionNames {
+		// Custom actions are decoded separately since they don't have a fixed key
+		var steps []ExecuteSteps
+		b, err := yaml.Marshal(actions[name])
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not marshal the %s action", name)
+		}
+		err = yaml.Unmarshal(b, &steps)
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not unmarshal the %s action", name)
+		}
+		for i, step := range steps {
+			l := newStepLinter(name, i, step.Description, lines)
+			l.required("description", step.Description)
+			results = append(results, l.results...)
+		}
+	}
+
+	repoNames := make([]string, 0, len(input.Config.Repositories))
+	for name := range input.Config.Repositories {
+		repoNames = append(repoNames, name)
+	}
+	sort.Strings(repoNames)
+	for _, name := range repoNames {
+		if !referencedRepos[name] {
+			results = append(results, linter.Result{
+				Level:    linter.LevelWarning,
+				Location: linter.Location{Mixin: "helm3"},
+				Code:     CodeUnreferencedRepository,
+				Title:    "Unreferenced repository",
+				Message:  fmt.Sprintf("The repository %q is configured but no chart uses it", name),
+			})
+		}
+	}
+
+	return results, nil
+}
+
+func newStepLinter(action string, index int, description string, lines map[string][]int) *stepLinter {
+	l := &stepLinter{
+		location: linter.Location{
+			Action:          action,
+			Mixin:           "helm3",
+			StepNumber:      index + 1,
+			StepDescription: description,
+		},
+	}
+	if index < len(lines[action]) {
+		l.line = lines[action][index]
+	}
+	return l
+}
+
+// stepLines returns the line of each step of the actions passed to the mixin, by action and step index
+func stepLines(contents []byte) (map[string][]int, error) {
+	var doc yamlv3.Node
+	err := yamlv3.Unmarshal(contents, &doc)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not read the lines of the steps")
+	}
+	lines := make(map[string][]int)
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yamlv3.MappingNode {
+		return lines, nil
+	}
+	actions := doc.Content[0].Content
+	for i := 0; i+1 < len(actions); i += 2 {
+		name, steps := actions[i].Value, actions[i+1]
+		if name == "config" || steps.Kind != yamlv3.SequenceNode {
+			continue
+		}
+		for _, step := range steps.Content {
+			lines[name] = append(lines[name], step.Line)
+		}
+	}
+	return lines, nil
+}
+
+// knownRepositories returns the repositories configured on the mixin along with the ones required by a step
+func knownRepositories(config MixinConfig, required map[string]Repository) map[string]Repository {
+	repos := make(map[string]Repository, len(config.Repositories)+len(required))
+	for name, repo := range config.Repositories {
+		repos[name] = repo
+	}
+	for name, repo := range required {
+		repos[name] = repo
+	}
+	return repos
+}
+
+// lintChartRepository checks that a chart in the form REPO/CHART references a configured repository
+func lintChartRepository(l *stepLinter, repositories map[string]Repository, chart string, repo string, referenced map[string]bool) {
+	if repo != "" || strings.Contains(chart, "://") || strings.HasPrefix(chart, ".") || strings.HasPrefix(chart, "/") {
+		return
+	}
+	parts := strings.SplitN(chart, "/", 2)
+	if len(parts) != 2 {
+		return
+	}
+
+	repoName := parts[0]
+	referenced[repoName] = true
+	if _, ok := repositories[repoName]; !ok {
+		l.add(linter.LevelWarning, CodeUnknownRepository, "Unknown repository",
+			"The chart %q references the repository %q, which is not configured on the mixin or the step", chart, repoName)
+	}
+}
+
+// PrintLintResults prints the lint results as json so that porter can report them.
+func (m *Mixin) PrintLintResults(ctx context.Context) error {
+	results, err := m.Lint(ctx)
+	if err != nil {
+		return err
+	}
+
+	b, err := json.MarshalIndent(results, "", "  ")
+	if err != nil {
+		return errors.Wrapf(err, "could not marshal lint results %#v", results)
+	}
+
+	fmt.Fprintln(m.Out, string(b))
+	return nil
+}
@@ -0,0 +1,124 @@
+package helm3
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"get.porter.sh/porter/pkg/linter"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMixin_Lint(t *testing.T) {
+	ctx := context.Background()
+	b, err := ioutil.ReadFile("testdata/lint-input.yaml")
+	require.NoError(t, err)
+
+	m := NewTestMixin(t)
+	m.In = bytes.NewReader(b)
+
+	results, err := m.Lint(ctx)
+	require.NoError(t, err)
+
+	install := linter.Location{Action: "install", Mixin: "helm3", StepNumber: 1, StepDescription: "Install MySQL"}
+	upgrade := linter.Location{Action: "upgrade", Mixin: "helm3", StepNumber: 1, StepDescription: "Upgrade MySQL"}
+	gotCodes := make([]linter.Code, 0, len(results))
+	for _, r := range results {
+		gotCodes = append(gotCodes, r.Code)
+	}
+	assert.Equal(t, []linter.Code{
+		CodeInvalidDuration,
+		CodeIgnoredField,
+		CodeUnknownRepository,
+		CodeMissingField,
+		CodeMutuallyExclusiveFields,
+		CodeInvalidNamespaces,
+		CodeUnreferencedRepository,
+	}, gotCodes)
+
+	assert.Equal(t, install, results[0].Location)
+	assert.Equal(t, linter.LevelError, results[0].Level)
+	assert.Equal(t, install, results[2].Location)
+	assert.Equal(t, linter.LevelWarning, results[2].Level)
+	assert.Contains(t, results[2].Message, `"bitnami"`)
+	assert.Equal(t, upgrade, results[3].Location)
+	assert.Contains(t, results[3].Message, "name field is required")
+	assert.Equal(t, upgrade, results[4].Location)
+	assert.Equal(t, "namespaces cannot be set together with namespace (line 21)", results[5].Message)
+	assert.True(t, strings.HasSuffix(results[0].Message, "(line 8)"), results[0].Message)
+	assert.True(t, strings.HasSuffix(results[3].Message, "(line 15)"), results[3].Message)
+	assert.Contains(t, results[6].Message, `"jetstack"`)
+}
+
+func TestMixin_LintExclusiveFields(t *testing.T) {
+	testcases := []struct {
+		name    string
+		action  string
+		fields  string
+		message string
+	}{
+		{"skipIfExists and failIfExists", "install", "name: mysql\n      skipIfExists: true\n      failIfExists: true",
+			"skipIfExists and failIfExists cannot both be set"},
+		{"generateName and name", "install", "name: mysql\n      generateName: mysql-",
+			"generateName cannot be set together with name or releases"},
+		{"randomName and failIfExists", "install", "randomName: true\n      failIfExists: true",
+			"randomName cannot be set together with skipIfExists, failIfExists or replaceFailed"},
+		{"values strategies", "upgrade", "name: mysql\n      resetValues: true\n      reuseValues: true",
+			"only one of resetValues, reuseValues and resetThenReuseValues can be set, got resetValues, reuseValues"},
+		{"releaseNameFile and name", "upgrade", "name: mysql\n      releaseNameFile: release-name",
+			"releaseNameFile cannot be set together with name, generateName or releases"},
+		{"canary and blueGreen", "upgrade", "name: mysql\n      canary:\n        weight: 10\n      blueGreen:\n        router: mysql-router",
+			"blueGreen cannot be set together with releases, namespaces or canary"},
+		{"namespaces and blueGreen", "upgrade", "name: mysql\n      namespaces: [tenant-a]\n      blueGreen:\n        router: mysql-router",
+			"blueGreen cannot be set together with releases, namespaces or canary"},
+		{"dryRun and canary", "upgrade", "name: mysql\n      dryRun: client\n      canary:\n        weight: 10",
+			"dryRun cannot be set together with canary"},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			input := fmt.Sprintf("%s:\n  - helm3:\n      description: Deploy MySQL\n      chart: oci://ghcr.io/example/charts/mysql\n      %s\n",
+				tc.action, tc.fields)
+
+			m := NewTestMixin(t)
+			m.In = strings.NewReader(input)
+
+			results, err := m.Lint(context.Background())
+			require.NoError(t, err)
+			require.Len(t, results, 1)
+			assert.Equal(t, CodeMutuallyExclusiveFields, results[0].Code)
+			assert.Equal(t, linter.LevelError, results[0].Level)
+			assert.Equal(t, tc.message+" (line 2)", results[0].Message)
+		})
+	}
+}
+
+func TestMixin_LintUpgradeRepo(t *testing.T) {
+	input := "upgrade:\n  - helm3:\n      description: Upgrade MySQL\n      name: mysql\n      chart: mysql\n      repo: https://charts.example.com\n      username: admin\n"
+
+	m := NewTestMixin(t)
+	m.In = strings.NewReader(input)
+
+	results, err := m.Lint(context.Background())
+	require.NoError(t, err)
+	require.NotEmpty(t, results)
+	assert.Equal(t, CodeIgnoredField, results[0].Code)
+	assert.Equal(t, linter.LevelWarning, results[0].Level)
+	assert.Equal(t, "The repo field is only used when both username and password are set (line 2)", results[0].Message)
+}
+
+func TestMixin_PrintLintResults(t *testing.T) {
+	ctx := context.Background()
+	b, err := ioutil.ReadFile("testdata/execute-input.yaml")
+	require.NoError(t, err)
+
+	m := NewTestMixin(t)
+	m.In = bytes.NewReader(b)
+
+	err = m.PrintLintResults(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "null\n", m.TestContext.GetOutput())
+}
@@ -0,0 +1,140 @@
+package helm3
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// operationRetryInterval is the first wait before a release locked by another operation is retried,
+// it doubles after each attempt up to maxOperationRetryInterval
+var operationRetryInterval = 5 * time.Second
+
+const maxOperationRetryInterval = time.Minute
+
+// releaseLockDir holds a lock file for each release operated by a step, it is shared by the steps of an action
+var releaseLockDir = filepath.Join(os.TempDir(), "porter-helm3", "releases")
+
+// releaseLockHolder is written to the lock file of a release to report which step holds the lock
+type releaseLockHolder struct {
+	PID         int    `json:"pid"`
+	Description string `json:"description"`
+}
+
+// isOperationInProgress checks if helm failed because another operation holds the lock of the release
+func isOperationInProgress(err error) bool {
+	var helmErr *HelmError
+	return errors.As(err, &helmErr) && helmErr.Reason == ReasonOperationInProgress
+}
+
+// retryOperationInProgress runs the helm command of a release again while another install, upgrade or rollback
+// holds the lock of the release, for example after an interrupted run, until the lock timeout is reached
+func (m *Mixin) retryOperationInProgress(ctx context.Context, release string, lockTimeout string, run func() error) error {
+	err := run()
+	if lockTimeout == "" || !isOperationInProgress(err) {
+		return err
+	}
+
+	timeout, parseErr := parseTimeout("lockTimeout", lockTimeout)
+	if parseErr != nil {
+		return parseErr
+	}
+
+	deadline := time.Now().Add(timeout)
+	interval := operationRetryInterval
+	for isOperationInProgress(err) {
+		if time.Now().Add(interval).After(deadline) {
+			return errors.Wrapf(err, "release %s is still locked by another operation after %s", release, timeout)
+		}
+		fmt.Fprintf(m.Out, "Another operation is in progress on release %s, retrying in %s\n", release, interval)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(interval):
+		}
+
+		interval *= 2
+		if interval > maxOperationRetryInterval {
+			interval = maxOperationRetryInterval
+		}
+		err = run()
+	}
+	return err
+}
+
+// withReleaseLock runs the helm command of a step on a release while holding the lock of the release, so that two steps
+// of an action never operate the same release at the same time. A release locked by another step fails with the
+// operation-in-progress reason, and is retried like a release locked by helm when the step sets a lockTimeout.
+func (m *Mixin) withReleaseLock(release string, namespace string, description string, run func() error) error {
+	if m.planMode() || release == "" {
+		return run()
+	}
+
+	err := m.FileSystem.MkdirAll(releaseLockDir, 0700)
+	if err != nil {
+		return errors.Wrapf(err, "could not create the release lock directory %s", releaseLockDir)
+	}
+	path := filepath.Join(releaseLockDir, fmt.Sprintf("%s_%s.lock", namespace, release))
+
+	locked, err := m.createReleaseLock(path, description)
+	if err == nil && !locked {
+		holder, alive := m.readReleaseLock(path)
+		if alive {
+			return &HelmError{Reason: ReasonOperationInProgress, Err: errors.Errorf(
+				"release %s is locked by the step %q (pid %d) of the same action, steps that share a release cannot run at the same time",
+				release, holder.Description, holder.PID)}
+		}
+		// the step that held the lock exited without releasing it
+		err = m.FileSystem.Remove(path)
+		if err == nil {
+			locked, err = m.createReleaseLock(path, description)
+		}
+		if err == nil && !locked {
+			err = errors.New("the lock was taken by another step")
+		}
+	}
+	if err != nil {
+		return errors.Wrapf(err, "could not lock release %s", release)
+	}
+	defer m.FileSystem.Remove(path)
+
+	return run()
+}
+
+// createReleaseLock creates the lock file of a release, it returns false when the file already exists
+func (m *Mixin) createReleaseLock(path string, description string) (bool, error) {
+	f, err := m.FileSystem.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if os.IsExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	return true, json.NewEncoder(f).Encode(releaseLockHolder{PID: os.Getpid(), Description: description})
+}
+
+// readReleaseLock returns the step that holds the lock of a release, and whether its process is still running
+func (m *Mixin) readReleaseLock(path string) (releaseLockHolder, bool) {
+	var holder releaseLockHolder
+	b, err := m.FileSystem.ReadFile(path)
+	if err != nil || json.Unmarshal(b, &holder) != nil || holder.PID <= 0 {
+		// the lock is being written, or was just released
+		return holder, true
+	}
+	if holder.PID == os.Getpid() {
+		return holder, true
+	}
+	p, err := os.FindProcess(holder.PID)
+	if err != nil {
+		return holder, false
+	}
+	return holder, p.Signal(syscall.Signal(0)) == nil
+}
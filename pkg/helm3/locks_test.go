@@ -0,0 +1,144 @@
+package helm3
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"get.porter.sh/porter/pkg/test"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+const operationInProgressError = "Error: UPGRADE FAILED: another operation (install/upgrade/rollback) is in progress"
+
+func TestMixin_RetryOperationInProgress(t *testing.T) {
+	defer func(interval time.Duration) { operationRetryInterval = interval }(operationRetryInterval)
+	operationRetryInterval = time.Millisecond
+
+	t.Run("lock released", func(t *testing.T) {
+		h := NewTestMixin(t)
+		attempts := 0
+		err := h.retryOperationInProgress(context.Background(), "mysql", "1m", func() error {
+			attempts++
+			if attempts < 3 {
+				return classifyHelmError(errors.New("exit status 1"), operationInProgressError)
+			}
+			return nil
+		})
+		require.NoError(t, err)
+		assert.Equal(t, 3, attempts)
+		assert.Contains(t, h.TestContext.GetOutput(), "Another operation is in progress on release mysql, retrying in 1ms")
+	})
+
+	t.Run("other failures are not retried", func(t *testing.T) {
+		h := NewTestMixin(t)
+		attempts := 0
+		err := h.retryOperationInProgress(context.Background(), "mysql", "1m", func() error {
+			attempts++
+			return errors.New("exit status 1")
+		})
+		require.EqualError(t, err, "exit status 1")
+		assert.Equal(t, 1, attempts)
+	})
+
+	t.Run("no lock timeout", func(t *testing.T) {
+		h := NewTestMixin(t)
+		attempts := 0
+		err := h.retryOperationInProgress(context.Background(), "mysql", "", func() error {
+			attempts++
+			return classifyHelmError(errors.New("exit status 1"), operationInProgressError)
+		})
+		require.True(t, isOperationInProgress(err))
+		assert.Equal(t, 1, attempts)
+	})
+}
+
+func TestMixin_UpgradeLockTimeout(t *testing.T) {
+	defer func(interval time.Duration) { operationRetryInterval = interval }(operationRetryInterval)
+	operationRetryInterval = time.Millisecond
+
+	ctx := context.Background()
+	defer os.Unsetenv(test.ExpectedCommandEnv)
+	defer os.Unsetenv(test.ExpectedCommandErrorEnv)
+	defer os.Unsetenv(test.ExpectedCommandExitCodeEnv)
+	os.Setenv(test.ExpectedCommandEnv, "helm3 upgrade --install mysql stable/mysql --atomic --create-namespace --output json")
+	os.Setenv(test.ExpectedCommandErrorEnv, operationInProgressError)
+	os.Setenv(test.ExpectedCommandExitCodeEnv, "1")
+
+	action := UpgradeAction{Steps: []UpgradeStep{{
+		UpgradeArguments: UpgradeArguments{
+			Step:        Step{Description: "Upgrade MySQL"},
+			Name:        "mysql",
+			Chart:       "stable/mysql",
+			LockTimeout: "50ms",
+		},
+	}}}
+	b, _ := yaml.Marshal(action)
+
+	h := NewTestMixin(t)
+	h.In = bytes.NewReader(b)
+
+	err := h.Upgrade(ctx)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "release mysql is still locked by another operation after 50ms")
+	assert.Equal(t, 15, ExitCode(err))
+	assert.Contains(t, h.TestContext.GetOutput(), "Another operation is in progress on release mysql, retrying in 1ms")
+}
+
+func TestMixin_WithReleaseLock(t *testing.T) {
+	lockFile := filepath.Join(releaseLockDir, "db_mysql.lock")
+	writeLock := func(t *testing.T, h *TestMixin, pid int) {
+		b, _ := json.Marshal(releaseLockHolder{PID: pid, Description: "Upgrade MySQL"})
+		require.NoError(t, h.FileSystem.MkdirAll(releaseLockDir, 0700))
+		require.NoError(t, h.FileSystem.WriteFile(lockFile, b, 0600))
+	}
+
+	t.Run("lock released after the step", func(t *testing.T) {
+		h := NewTestMixin(t)
+		err := h.withReleaseLock("mysql", "db", "Install MySQL", func() error {
+			exists, _ := h.FileSystem.Exists(lockFile)
+			assert.True(t, exists, "the lock should be held while the step runs")
+			return nil
+		})
+		require.NoError(t, err)
+		exists, _ := h.FileSystem.Exists(lockFile)
+		assert.False(t, exists, "the lock should be released once the step is done")
+	})
+
+	t.Run("locked by a running step", func(t *testing.T) {
+		h := NewTestMixin(t)
+		writeLock(t, h, os.Getpid())
+
+		err := h.withReleaseLock("mysql", "db", "Test MySQL", func() error {
+			t.Fatal("the step should not run while the release is locked")
+			return nil
+		})
+		require.True(t, isOperationInProgress(err))
+		assert.Contains(t, err.Error(), `release mysql is locked by the step "Upgrade MySQL"`)
+		assert.Equal(t, 15, ExitCode(err))
+	})
+
+	t.Run("locked by an exited step", func(t *testing.T) {
+		exited := exec.Command("true")
+		require.NoError(t, exited.Run())
+
+		h := NewTestMixin(t)
+		writeLock(t, h, exited.Process.Pid)
+
+		ran := false
+		err := h.withReleaseLock("mysql", "db", "Test MySQL", func() error {
+			ran = true
+			return nil
+		})
+		require.NoError(t, err)
+		assert.True(t, ran)
+	})
+}
@@ -0,0 +1,73 @@
+package helm3
+
+import (
+	"context"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// manifestBefore applies a manifest before the releases of the step are deployed, which is the default
+	manifestBefore = "before"
+	// manifestAfter applies a manifest once every release of the step is deployed
+	manifestAfter = "after"
+)
+
+var manifestPhases = []string{manifestBefore, manifestAfter}
+
+// Manifest is a file or directory of kubernetes manifests of the bundle, applied with kubectl before or after
+// the releases of a step, for prerequisites such as namespaces, quotas or image pull secrets
+type Manifest struct {
+	// Path of a yaml file, or of a directory of yaml files, in the bundle
+	Path string `yaml:"path"`
+	// Namespace of the resources that don't set one, the namespace of the step by default
+	Namespace string `yaml:"namespace,omitempty"`
+	// When the manifest is applied: before or after the releases, before by default
+	When string `yaml:"when,omitempty"`
+	// ServerSide applies the manifest with server-side apply
+	ServerSide bool `yaml:"serverSide,omitempty"`
+}
+
+// validateManifests checks that every manifest of a step can be applied
+func validateManifests(manifests []Manifest) error {
+	for _, manifest := range manifests {
+		if manifest.Path == "" {
+			return errors.New("manifests requires the path of each manifest")
+		}
+		if manifest.When != "" && manifest.When != manifestBefore && manifest.When != manifestAfter {
+			return errors.Errorf("unsupported when %q of manifest %s, expected one of %s",
+				manifest.When, manifest.Path, strings.Join(manifestPhases, ", "))
+		}
+	}
+	return nil
+}
+
+// applyManifests applies the manifests of a step for a phase, in order, in the namespace of the step by default
+func (m *Mixin) applyManifests(ctx context.Context, manifests []Manifest, when string, namespace string) error {
+	for _, manifest := range manifests {
+		phase := manifest.When
+		if phase == "" {
+			phase = manifestBefore
+		}
+		if phase != when {
+			continue
+		}
+
+		cmd := m.NewCommand(ctx, "kubectl", "apply")
+		if manifest.ServerSide {
+			cmd.Args = append(cmd.Args, "--server-side", "--force-conflicts")
+		}
+		cmd.Args = append(cmd.Args, "--recursive", "-f", manifest.Path)
+		if ns := manifest.Namespace; ns != "" {
+			cmd.Args = append(cmd.Args, "--namespace", ns)
+		} else if namespace != "" {
+			cmd.Args = append(cmd.Args, "--namespace", namespace)
+		}
+		err := m.runCommand(cmd)
+		if err != nil {
+			return errors.Wrapf(err, "could not apply manifest %s", manifest.Path)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package helm3
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"get.porter.sh/porter/pkg/test"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestMixin_InstallManifests(t *testing.T) {
+	ctx := context.Background()
+	defer os.Unsetenv(test.ExpectedCommandEnv)
+	os.Setenv(test.ExpectedCommandEnv, strings.Join([]string{
+		"kubectl apply --recursive -f manifests/quota.yaml --namespace db",
+		"kubectl apply --server-side --force-conflicts --recursive -f manifests/pull-secrets --namespace registry",
+		"helm3 upgrade --install mysql stable/mysql --namespace db --atomic --create-namespace --output json",
+		"kubectl apply --recursive -f manifests/network-policy.yaml --namespace db",
+	}, "\n"))
+
+	action := InstallAction{Steps: []InstallStep{{
+		InstallArguments: InstallArguments{
+			Step:      Step{Description: "Install MySQL"},
+			Name:      "mysql",
+			Chart:     "stable/mysql",
+			Namespace: "db",
+			Manifests: []Manifest{
+				{Path: "manifests/quota.yaml"},
+				{Path: "manifests/network-policy.yaml", When: manifestAfter},
+				{Path: "manifests/pull-secrets", Namespace: "registry", ServerSide: true},
+			},
+		},
+	}}}
+	b, _ := yaml.Marshal(action)
+
+	h := NewTestMixin(t)
+	h.In = bytes.NewReader(b)
+
+	err := h.Install(ctx)
+	require.NoError(t, err)
+}
+
+func TestValidateManifests(t *testing.T) {
+	require.NoError(t, validateManifests([]Manifest{{Path: "quota.yaml"}, {Path: "policy.yaml", When: manifestAfter}}))
+	require.EqualError(t, validateManifests([]Manifest{{When: manifestBefore}}), "manifests requires the path of each manifest")
+	require.EqualError(t, validateManifests([]Manifest{{Path: "quota.yaml", When: "during"}}),
+		`unsupported when "during" of manifest quota.yaml, expected one of before, after`)
+}
@@ -0,0 +1,38 @@
+package helm3
+
+import (
+	"strings"
+)
+
+const (
+	// actionEnv is set by porter to the name of the action being run
+	actionEnv = "CNAB_ACTION"
+	// revisionEnv is set by porter to the revision of the installation
+	revisionEnv = "CNAB_REVISION"
+)
+
+// porterValues returns the porter.action, porter.installation and porter.revision values of a release,
+// so that charts can label their resources with the installation that manages them
+func (m *Mixin) porterValues() map[string]string {
+	values := make(map[string]string, 3)
+	for key, env := range map[string]string{
+		"porter.action":       actionEnv,
+		"porter.installation": installationNameEnv,
+		"porter.revision":     revisionEnv,
+	} {
+		if value := m.Getenv(env); value != "" {
+			// commas separate the values of --set
+			values[key] = strings.ReplaceAll(value, ",", `\,`)
+		}
+	}
+	return values
+}
+
+// applyPorterValues returns the values to set on a release with the porter values, values set by the step are kept
+func (m *Mixin) applyPorterValues(set map[string]string) map[string]string {
+	applied := m.porterValues()
+	for k, v := range set {
+		applied[k] = v
+	}
+	return applied
+}
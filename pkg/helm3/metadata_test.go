@@ -0,0 +1,50 @@
+package helm3
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"testing"
+
+	"get.porter.sh/porter/pkg/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestMixin_PorterValues(t *testing.T) {
+	h := NewTestMixin(t)
+	h.Setenv(actionEnv, "upgrade")
+	h.Setenv(installationNameEnv, "shop,eu")
+
+	assert.Equal(t, map[string]string{"porter.action": "upgrade", "porter.installation": `shop\,eu`}, h.porterValues(),
+		"unset values should be skipped and commas escaped")
+	assert.Equal(t, map[string]string{"porter.action": "custom", "porter.installation": `shop\,eu`, "replicaCount": "2"},
+		h.applyPorterValues(map[string]string{"porter.action": "custom", "replicaCount": "2"}), "values set by the step should be kept")
+}
+
+func TestMixin_UpgradePorterValues(t *testing.T) {
+	ctx := context.Background()
+	defer os.Unsetenv(test.ExpectedCommandEnv)
+	os.Setenv(test.ExpectedCommandEnv, "helm3 upgrade --install mysql bitnami/mysql --atomic --create-namespace --output json "+
+		"--set porter.action=upgrade --set porter.installation=shop --set porter.revision=01H4KQ8ZJ4Y5V0X8M3N6P9R2T7")
+
+	action := UpgradeAction{Steps: []UpgradeStep{{
+		UpgradeArguments: UpgradeArguments{
+			Step:         Step{Description: "Upgrade MySQL"},
+			Name:         "mysql",
+			Chart:        "bitnami/mysql",
+			PorterValues: true,
+		},
+	}}}
+	b, _ := yaml.Marshal(action)
+
+	h := NewTestMixin(t)
+	h.Setenv(actionEnv, "upgrade")
+	h.Setenv(installationNameEnv, "shop")
+	h.Setenv(revisionEnv, "01H4KQ8ZJ4Y5V0X8M3N6P9R2T7")
+	h.In = bytes.NewReader(b)
+
+	err := h.Upgrade(ctx)
+	require.NoError(t, err)
+}
@@ -0,0 +1,281 @@
+package helm3
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// namespacePlaceholder is replaced by the namespace of the release in release names, for example mysql-{{ namespace }}
+var namespacePlaceholder = regexp.MustCompile(`{{\s*namespace\s*}}`)
+
+// installationPlaceholder matches a field of the installation templated in a namespace, for example
+// tenant-{{ installation.name }} or {{ installation.labels.team }}
+var installationPlaceholder = regexp.MustCompile(`{{\s*installation\.([^\s}]+)\s*}}`)
+
+// namespaceScopedEnv runs the mixin without cluster-scoped calls, it is set by the namespaceScoped field of the mixin configuration
+const namespaceScopedEnv = "PORTER_HELM3_NAMESPACE_SCOPED"
+
+// installationLabelsEnv holds the labels of the installation as comma separated key=value pairs,
+// porter does not pass them to the bundle so they are mapped from a parameter
+const installationLabelsEnv = "PORTER_HELM3_INSTALLATION_LABELS"
+
+// templateNamespace replaces the installation placeholders of a namespace and validates the resulting name
+func (m *Mixin) templateNamespace(namespace string) (string, error) {
+	if !installationPlaceholder.MatchString(namespace) {
+		return namespace, nil
+	}
+
+	var err error
+	templated := installationPlaceholder.ReplaceAllStringFunc(namespace, func(placeholder string) string {
+		field := installationPlaceholder.FindStringSubmatch(placeholder)[1]
+		value, fieldErr := m.installationField(field)
+		if fieldErr != nil && err == nil {
+			err = errors.Wrapf(fieldErr, "could not template namespace %s", namespace)
+		}
+		return value
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if errs := validation.IsDNS1123Label(templated); len(errs) > 0 {
+		return "", errors.Errorf("namespace %q templated from %s is invalid: %s", templated, namespace, strings.Join(errs, ", "))
+	}
+	return templated, nil
+}
+
+// installationField returns the value of a field of the installation, either its name or one of its labels
+func (m *Mixin) installationField(field string) (string, error) {
+	if field == "name" {
+		name := m.Getenv(installationNameEnv)
+		if name == "" {
+			return "", errors.Errorf("the installation name is not set in %s", installationNameEnv)
+		}
+		return name, nil
+	}
+
+	key := strings.TrimPrefix(field, "labels.")
+	if key == field || key == "" {
+		return "", errors.Errorf("unknown installation field %s, expected name or labels.KEY", field)
+	}
+	for _, label := range strings.Split(m.Getenv(installationLabelsEnv), ",") {
+		k, v, _ := strings.Cut(label, "=")
+		if strings.TrimSpace(k) == key {
+			return strings.TrimSpace(v), nil
+		}
+	}
+	return "", errors.Errorf("the installation label %s is not set in %s", key, installationLabelsEnv)
+}
+
+// templateNamespaces templates the namespace of a step, the namespaces it deploys to and the namespaces
+// of its releases
+func (m *Mixin) templateNamespaces(namespace *string, namespaces NamespaceList, releases []Release) error {
+	var err error
+	*namespace, err = m.templateNamespace(*namespace)
+	if err != nil {
+		return err
+	}
+	for i := range namespaces {
+		namespaces[i], err = m.templateNamespace(namespaces[i])
+		if err != nil {
+			return err
+		}
+	}
+	for i := range releases {
+		releases[i].Namespace, err = m.templateNamespace(releases[i].Namespace)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// NamespaceList is a list of namespaces, declared as a yaml list or as a string with a json array
+// or comma separated namespaces, for example when it is templated from a bundle parameter
+type NamespaceList []string
+
+// UnmarshalYAML accepts a list of namespaces or a string holding the list
+func (l *NamespaceList) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var list []string
+	if err := unmarshal(&list); err == nil {
+		*l = list
+		return nil
+	}
+
+	var s string
+	if err := unmarshal(&s); err != nil {
+		return errors.New("namespaces must be a list of namespaces or a string with the namespaces")
+	}
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, "[") {
+		if err := json.Unmarshal([]byte(s), &list); err != nil {
+			return errors.Wrapf(err, "invalid list of namespaces %s", s)
+		}
+	} else {
+		for _, namespace := range strings.Split(s, ",") {
+			if namespace = strings.TrimSpace(namespace); namespace != "" {
+				list = append(list, namespace)
+			}
+		}
+	}
+	*l = list
+	return nil
+}
+
+// namespacedReleaseName replaces the namespace placeholder in a release name with the namespace of the release
+func namespacedReleaseName(name string, namespace string) string {
+	return namespacePlaceholder.ReplaceAllLiteralString(name, namespace)
+}
+
+// validateNamespaces checks that the namespaces of a step can be used to deploy its releases
+func validateNamespaces(namespaces NamespaceList, namespace string, releases []Release) error {
+	if len(namespaces) == 0 {
+		return nil
+	}
+	if namespace != "" {
+		return errors.New("namespaces cannot be set together with namespace")
+	}
+	for _, r := range releases {
+		if r.Namespace != "" {
+			return errors.Errorf("namespaces cannot be set together with the namespace of release %s", r.Name)
+		}
+	}
+	seen := make(map[string]bool, len(namespaces))
+	for _, ns := range namespaces {
+		// templated namespaces are validated once they are templated at runtime
+		if errs := validation.IsDNS1123Label(ns); len(errs) > 0 && !installationPlaceholder.MatchString(ns) {
+			return errors.Errorf("invalid namespace %q: %s", ns, strings.Join(errs, ", "))
+		}
+		if seen[ns] {
+			return errors.Errorf("namespace %s is listed more than once", ns)
+		}
+		seen[ns] = true
+	}
+	return nil
+}
+
+// namespaceScoped checks if the mixin runs with credentials limited to namespaces, so that it must not create
+// namespaces or custom resource definitions, or read any other cluster-scoped resource
+func (m *Mixin) namespaceScoped() bool {
+	return m.Getenv(namespaceScopedEnv) == "true"
+}
+
+// validateNamespaceScoped checks that a step doesn't manage cluster-scoped resources when the mixin is namespace scoped.
+// Every release must have a namespace, since the mixin reads the workloads and events of a release without a namespace
+// across the cluster.
+func (m *Mixin) validateNamespaceScoped(crds *CRDArguments, metadata *NamespaceMetadata, namespace string, namespaces NamespaceList, releases []Release, prune *Prune) error {
+	if !m.namespaceScoped() {
+		return nil
+	}
+	if crds != nil {
+		return errors.New("crds cannot be applied by a namespace scoped mixin, custom resource definitions are cluster-scoped")
+	}
+	if metadata != nil {
+		return errors.New("namespaceMetadata cannot be set by a namespace scoped mixin, namespaces are cluster-scoped")
+	}
+	if namespace == "" && len(namespaces) == 0 {
+		if len(releases) == 0 {
+			return errors.New("a namespace scoped mixin requires the namespace of the step")
+		}
+		for _, r := range releases {
+			if r.Namespace == "" {
+				return errors.Errorf("a namespace scoped mixin requires the namespace of the step or of release %s", r.Name)
+			}
+		}
+	}
+	if prune != nil {
+		for _, kind := range prune.Kinds {
+			k, _, _ := strings.Cut(kind, "/")
+			if isClusterScopedKind(k) {
+				return errors.Errorf("prune kind %s cannot be pruned by a namespace scoped mixin, %s is cluster-scoped", kind, k)
+			}
+		}
+	}
+	return nil
+}
+
+// appendNamespaceFlags ensures that helm creates the namespace of a release, unless the mixin is namespace scoped,
+// in which case the namespace must already exist and the custom resource definitions of the chart are skipped
+func (m *Mixin) appendNamespaceFlags(args []string) []string {
+	if !m.namespaceScoped() {
+		return append(args, "--create-namespace")
+	}
+	if !hasFlag(args, "--skip-crds") {
+		args = append(args, "--skip-crds")
+	}
+	return args
+}
+
+// NamespaceMetadata represents the labels and annotations set on the release namespace before helm runs
+type NamespaceMetadata struct {
+	Labels      map[string]string `yaml:"labels,omitempty"`
+	Annotations map[string]string `yaml:"annotations,omitempty"`
+}
+
+// prepareNamespace creates the namespace with the labels and annotations,
+// or adds them to the namespace when it already exists.
+func (m *Mixin) prepareNamespace(ctx context.Context, namespace string, metadata NamespaceMetadata) error {
+	if namespace == "" {
+		return errors.New("namespaceMetadata requires the namespace of the release to be set")
+	}
+
+	if m.planMode() {
+		fmt.Fprintf(m.Out, "# namespace %s labels: %s annotations: %s\n",
+			namespace, formatMetadata(metadata.Labels), formatMetadata(metadata.Annotations))
+		return nil
+	}
+
+	client, err := m.getKubernetesClient()
+	if err != nil {
+		return errors.Wrap(err, "couldn't get kubernetes client")
+	}
+
+	ns, err := client.CoreV1().Namespaces().Get(ctx, namespace, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		ns = &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+			Name:        namespace,
+			Labels:      metadata.Labels,
+			Annotations: metadata.Annotations,
+		}}
+		_, err = client.CoreV1().Namespaces().Create(ctx, ns, metav1.CreateOptions{})
+		return errors.Wrapf(err, "could not create namespace %s", namespace)
+	}
+	if err != nil {
+		return errors.Wrapf(err, "could not get namespace %s", namespace)
+	}
+
+	if ns.Labels == nil {
+		ns.Labels = make(map[string]string, len(metadata.Labels))
+	}
+	for k, v := range metadata.Labels {
+		ns.Labels[k] = v
+	}
+	if ns.Annotations == nil {
+		ns.Annotations = make(map[string]string, len(metadata.Annotations))
+	}
+	for k, v := range metadata.Annotations {
+		ns.Annotations[k] = v
+	}
+	_, err = client.CoreV1().Namespaces().Update(ctx, ns, metav1.UpdateOptions{})
+	return errors.Wrapf(err, "could not update namespace %s", namespace)
+}
+
+// formatMetadata formats labels or annotations as sorted key=value pairs
+func formatMetadata(metadata map[string]string) string {
+	pairs := make([]string, 0, len(metadata))
+	for k, v := range metadata {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
@@ -0,0 +1,349 @@
+package helm3
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"testing"
+
+	"get.porter.sh/porter/pkg/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	testclient "k8s.io/client-go/kubernetes/fake"
+)
+
+type staticKubernetesFactory struct {
+	client kubernetes.Interface
+}
+
+func (f *staticKubernetesFactory) GetClient() (kubernetes.Interface, error) {
+	return f.client, nil
+}
+
+func TestMixin_InstallNamespaceMetadata(t *testing.T) {
+	metadata := &NamespaceMetadata{
+		Labels:      map[string]string{"pod-security.kubernetes.io/enforce": "restricted"},
+		Annotations: map[string]string{"owner": "platform"},
+	}
+
+	testcases := []struct {
+		name     string
+		existing *corev1.Namespace
+		want     metav1.ObjectMeta
+	}{
+		{
+			name: "new namespace",
+			want: metav1.ObjectMeta{
+				Name:        "mysql",
+				Labels:      map[string]string{"pod-security.kubernetes.io/enforce": "restricted"},
+				Annotations: map[string]string{"owner": "platform"},
+			},
+		},
+		{
+			name: "existing namespace",
+			existing: &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+				Name:   "mysql",
+				Labels: map[string]string{"istio-injection": "enabled"},
+			}},
+			want: metav1.ObjectMeta{
+				Name:        "mysql",
+				Labels:      map[string]string{"istio-injection": "enabled", "pod-security.kubernetes.io/enforce": "restricted"},
+				Annotations: map[string]string{"owner": "platform"},
+			},
+		},
+	}
+
+	defer os.Unsetenv(test.ExpectedCommandEnv)
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			os.Setenv(test.ExpectedCommandEnv, "helm3 upgrade --install mysql stable/mysql --namespace mysql --atomic --create-namespace --output json")
+
+			action := InstallAction{Steps: []InstallStep{{
+				InstallArguments: InstallArguments{
+					Step:              Step{Description: "Install MySQL"},
+					Name:              "mysql",
+					Chart:             "stable/mysql",
+					Namespace:         "mysql",
+					NamespaceMetadata: metadata,
+				},
+			}}}
+			b, _ := yaml.Marshal(action)
+
+			client := testclient.NewSimpleClientset()
+			if tc.existing != nil {
+				client = testclient.NewSimpleClientset(tc.existing)
+			}
+			h := NewTestMixin(t)
+			h.ClientFactory = &staticKubernetesFactory{client: client}
+			h.In = bytes.NewReader(b)
+
+			err := h.Install(ctx)
+			require.NoError(t, err)
+
+			ns, err := client.CoreV1().Namespaces().Get(ctx, "mysql", metav1.GetOptions{})
+			require.NoError(t, err)
+			assert.Equal(t, tc.want.Labels, ns.Labels)
+			assert.Equal(t, tc.want.Annotations, ns.Annotations)
+		})
+	}
+}
+
+func TestMixin_InstallNamespaceMetadataWithoutNamespace(t *testing.T) {
+	ctx := context.Background()
+
+	action := InstallAction{Steps: []InstallStep{{
+		InstallArguments: InstallArguments{
+			Step:              Step{Description: "Install MySQL"},
+			Name:              "mysql",
+			Chart:             "stable/mysql",
+			NamespaceMetadata: &NamespaceMetadata{Labels: map[string]string{"istio-injection": "enabled"}},
+		},
+	}}}
+	b, _ := yaml.Marshal(action)
+
+	h := NewTestMixin(t)
+	h.In = bytes.NewReader(b)
+
+	err := h.Install(ctx)
+	require.EqualError(t, err, "namespaceMetadata requires the namespace of the release to be set")
+}
+
+func TestNamespaceList_UnmarshalYAML(t *testing.T) {
+	testcases := []struct {
+		name  string
+		input string
+	}{
+		{name: "list", input: "namespaces: [tenant-a, tenant-b]"},
+		{name: "json", input: `namespaces: '["tenant-a", "tenant-b"]'`},
+		{name: "comma separated", input: "namespaces: tenant-a, tenant-b"},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			var step struct {
+				Namespaces NamespaceList `yaml:"namespaces"`
+			}
+			err := yaml.Unmarshal([]byte(tc.input), &step)
+			require.NoError(t, err)
+			assert.Equal(t, NamespaceList{"tenant-a", "tenant-b"}, step.Namespaces)
+		})
+	}
+}
+
+func TestValidateNamespaces(t *testing.T) {
+	testcases := []struct {
+		name       string
+		namespaces NamespaceList
+		namespace  string
+		releases   []Release
+		wantErr    string
+	}{
+		{name: "valid", namespaces: NamespaceList{"tenant-a", "tenant-b"}},
+		{name: "with namespace", namespaces: NamespaceList{"tenant-a"}, namespace: "db",
+			wantErr: "namespaces cannot be set together with namespace"},
+		{name: "with release namespace", namespaces: NamespaceList{"tenant-a"},
+			releases: []Release{{Name: "mysql", Namespace: "db"}},
+			wantErr:  "namespaces cannot be set together with the namespace of release mysql"},
+		{name: "invalid", namespaces: NamespaceList{"Tenant_A"}, wantErr: `invalid namespace "Tenant_A"`},
+		{name: "duplicate", namespaces: NamespaceList{"tenant-a", "tenant-a"}, wantErr: "namespace tenant-a is listed more than once"},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validateNamespaces(tc.namespaces, tc.namespace, tc.releases)
+			if tc.wantErr == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tc.wantErr)
+		})
+	}
+}
+
+func TestMixin_InstallNamespaces(t *testing.T) {
+	ctx := context.Background()
+	defer os.Unsetenv(test.ExpectedCommandEnv)
+	os.Setenv(test.ExpectedCommandEnv, "helm3 upgrade --install mysql-tenant-a stable/mysql --namespace tenant-a --atomic --create-namespace --output json\n"+
+		"helm3 upgrade --install mysql-tenant-b stable/mysql --namespace tenant-b --atomic --create-namespace --output json")
+
+	action := InstallAction{Steps: []InstallStep{{
+		InstallArguments: InstallArguments{
+			Step:       Step{Description: "Install MySQL"},
+			Name:       "mysql-{{ namespace }}",
+			Chart:      "stable/mysql",
+			Namespaces: NamespaceList{"tenant-a", "tenant-b"},
+		},
+	}}}
+	b, _ := yaml.Marshal(action)
+
+	h := NewTestMixin(t)
+	h.In = bytes.NewReader(b)
+
+	err := h.Install(ctx)
+	require.NoError(t, err)
+}
+
+func TestMixin_UninstallNamespaces(t *testing.T) {
+	ctx := context.Background()
+	defer os.Unsetenv(test.ExpectedCommandEnv)
+	os.Setenv(test.ExpectedCommandEnv, "helm3 uninstall mysql-tenant-a --namespace tenant-a\n"+
+		"helm3 uninstall mysql-tenant-b --namespace tenant-b")
+
+	action := UninstallAction{Steps: []UninstallStep{{
+		UninstallArguments: UninstallArguments{
+			Step:       Step{Description: "Uninstall MySQL"},
+			Releases:   []string{"mysql-{{ namespace }}"},
+			Namespaces: NamespaceList{"tenant-a", "tenant-b"},
+		},
+	}}}
+	b, _ := yaml.Marshal(action)
+
+	h := NewTestMixin(t)
+	h.In = bytes.NewReader(b)
+
+	err := h.Uninstall(ctx)
+	require.NoError(t, err)
+}
+
+func TestMixin_TemplateNamespace(t *testing.T) {
+	testcases := []struct {
+		namespace string
+		want      string
+		wantErr   string
+	}{
+		{namespace: "mysql", want: "mysql"},
+		{namespace: "tenant-{{ installation.name }}", want: "tenant-shop"},
+		{namespace: "{{installation.labels.team}}-{{ installation.name }}", want: "payments-shop"},
+		{namespace: "{{ installation.labels.env }}", wantErr: "the installation label env is not set in PORTER_HELM3_INSTALLATION_LABELS"},
+		{namespace: "{{ installation.namespace }}", wantErr: "unknown installation field namespace"},
+		{namespace: "{{ installation.labels.owner }}", wantErr: `namespace "Platform_Team" templated from {{ installation.labels.owner }} is invalid`},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.namespace, func(t *testing.T) {
+			h := NewTestMixin(t)
+			h.Setenv(installationNameEnv, "shop")
+			h.Setenv(installationLabelsEnv, "team=payments, owner=Platform_Team")
+
+			got, err := h.templateNamespace(tc.namespace)
+			if tc.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestMixin_InstallTemplatedNamespace(t *testing.T) {
+	ctx := context.Background()
+	defer os.Unsetenv(test.ExpectedCommandEnv)
+	os.Setenv(test.ExpectedCommandEnv, "helm3 upgrade --install mysql stable/mysql --namespace tenant-shop --atomic --create-namespace --output json")
+
+	action := InstallAction{Steps: []InstallStep{{
+		InstallArguments: InstallArguments{
+			Step:      Step{Description: "Install MySQL"},
+			Name:      "mysql",
+			Chart:     "stable/mysql",
+			Namespace: "tenant-{{ installation.name }}",
+		},
+	}}}
+	b, _ := yaml.Marshal(action)
+
+	h := NewTestMixin(t)
+	h.Setenv(installationNameEnv, "shop")
+	h.In = bytes.NewReader(b)
+
+	err := h.Install(ctx)
+	require.NoError(t, err)
+}
+
+func TestMixin_InstallNamespaceScoped(t *testing.T) {
+	ctx := context.Background()
+	defer os.Unsetenv(test.ExpectedCommandEnv)
+	os.Setenv(test.ExpectedCommandEnv, "helm3 upgrade --install mysql stable/mysql --namespace mysql --atomic --skip-crds --output json")
+
+	action := InstallAction{Steps: []InstallStep{{
+		InstallArguments: InstallArguments{
+			Step:      Step{Description: "Install MySQL"},
+			Name:      "mysql",
+			Chart:     "stable/mysql",
+			Namespace: "mysql",
+		},
+	}}}
+	b, _ := yaml.Marshal(action)
+
+	h := NewTestMixin(t)
+	h.In = bytes.NewReader(b)
+	h.Setenv(namespaceScopedEnv, "true")
+
+	err := h.Install(ctx)
+	require.NoError(t, err)
+}
+
+func TestMixin_UpgradeNamespaceScoped(t *testing.T) {
+	testcases := []struct {
+		name             string
+		args             UpgradeArguments
+		withoutNamespace bool
+		wantErr          string
+	}{
+		{
+			name:             "without namespace",
+			withoutNamespace: true,
+			wantErr:          "a namespace scoped mixin requires the namespace of the step",
+		},
+		{
+			name:             "release without namespace",
+			args:             UpgradeArguments{Releases: []Release{{Name: "mysql", Chart: "stable/mysql", Namespace: "db"}, {Name: "redis", Chart: "bitnami/redis"}}},
+			withoutNamespace: true,
+			wantErr:          "a namespace scoped mixin requires the namespace of the step or of release redis",
+		},
+		{
+			name:    "cluster-scoped prune kind",
+			args:    UpgradeArguments{Prune: &Prune{Kinds: []string{"ConfigMap", "clusterrole/mysql-metrics"}}},
+			wantErr: "prune kind clusterrole/mysql-metrics cannot be pruned by a namespace scoped mixin, clusterrole is cluster-scoped",
+		},
+		{
+			name:    "crds",
+			args:    UpgradeArguments{CRDs: &CRDArguments{Chart: "stable/mysql"}},
+			wantErr: "crds cannot be applied by a namespace scoped mixin, custom resource definitions are cluster-scoped",
+		},
+		{
+			name:    "namespace metadata",
+			args:    UpgradeArguments{NamespaceMetadata: &NamespaceMetadata{Labels: map[string]string{"istio-injection": "enabled"}}},
+			wantErr: "namespaceMetadata cannot be set by a namespace scoped mixin, namespaces are cluster-scoped",
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			tc.args.Step = Step{Description: "Upgrade MySQL"}
+			if len(tc.args.Releases) == 0 {
+				tc.args.Name = "mysql"
+				tc.args.Chart = "stable/mysql"
+			}
+			if !tc.withoutNamespace {
+				tc.args.Namespace = "mysql"
+			}
+			action := UpgradeAction{Steps: []UpgradeStep{{UpgradeArguments: tc.args}}}
+			b, _ := yaml.Marshal(action)
+
+			h := NewTestMixin(t)
+			h.In = bytes.NewReader(b)
+			h.Setenv(namespaceScopedEnv, "true")
+
+			err := h.Upgrade(ctx)
+			require.EqualError(t, err, tc.wantErr)
+		})
+	}
+}
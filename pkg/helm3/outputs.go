@@ -1,22 +1,30 @@
 package helm3
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"sort"
 	"strings"
+	"time"
 
+	"get.porter.sh/porter/pkg/portercontext"
 	"github.com/pkg/errors"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/jsonpath"
 )
 
 func (m *Mixin) getSecret(ctx context.Context, client kubernetes.Interface, namespace, name, key string) ([]byte, error) {
 	if namespace == "" {
 		namespace = "default"
 	}
-	if m.DebugMode {
+	if m.tracing() {
 		fmt.Fprintf(os.Stderr, "Retrieving secret %s/%s and using key %s as an output\n", namespace, name, key)
 	}
 
@@ -31,27 +39,147 @@ func (m *Mixin) getSecret(ctx context.Context, client kubernetes.Interface, name
 	return val, nil
 }
 
+// getOutput reads an output from a resource of the cluster with the resource backend of the mixin
 func (m *Mixin) getOutput(ctx context.Context, resourceType, resourceName, namespace, jsonPath string) ([]byte, error) {
-	args := []string{"get", resourceType, resourceName}
-	args = append(args, fmt.Sprintf("-o=jsonpath=%s", jsonPath))
+	return m.resourceReader().ReadField(ctx, resourceType, resourceName, namespace, jsonPath)
+}
+
+// getStatusOutput evaluates a jsonpath expression against the release record printed by helm status
+func (m *Mixin) getStatusOutput(ctx context.Context, release, namespace, path string) ([]byte, error) {
+	if !strings.HasPrefix(path, "{") {
+		path = "{" + path + "}"
+	}
+	parser := jsonpath.New(release)
+	err := parser.Parse(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid helmStatusPath %s", path)
+	}
+
+	cmd := m.helmCommand(ctx, "status", release, "--output", "json")
 	if namespace != "" {
-		args = append(args, fmt.Sprintf("--namespace=%s", namespace))
+		cmd.Args = append(cmd.Args, "--namespace", namespace)
 	}
-	cmd := m.NewCommand(ctx, "kubectl", args...)
 	cmd.Stderr = m.Err
 	out, err := cmd.Output()
 	if err != nil {
-		prettyCmd := fmt.Sprintf("%s%s", cmd.Dir, strings.Join(cmd.Args, " "))
-		return nil, errors.Wrap(err, fmt.Sprintf("couldn't run command %s", prettyCmd))
+		prettyCmd := fmt.Sprintf("%s %s", cmd.Path, formatPlannedCommand(cmd.Args))
+		return nil, errors.Wrapf(err, "couldn't run command %s", prettyCmd)
+	}
+
+	var status interface{}
+	err = json.Unmarshal(out, &status)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not parse the status of release %s", release)
+	}
+	result := &bytes.Buffer{}
+	err = parser.Execute(result, status)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not evaluate helmStatusPath %s against release %s", path, release)
+	}
+	return result.Bytes(), nil
+}
+
+// outputPollInterval is the time between two reads of a resource output that is waited for
+var outputPollInterval = 5 * time.Second
+
+// waitForOutput reads a resource output until the resource exists and the jsonpath expression returns a value,
+// for example the status of a custom resource that is populated by its controller after the release is deployed
+func (m *Mixin) waitForOutput(ctx context.Context, output HelmOutput) ([]byte, error) {
+	timeout, err := parseTimeout("waitTimeout of output "+output.Name, output.WaitTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		val, err := m.getOutput(ctx, output.ResourceType, output.ResourceName, output.Namespace, output.JSONPath)
+		if err == nil && len(strings.TrimSpace(string(val))) > 0 {
+			return val, nil
+		}
+		if time.Now().After(deadline) {
+			if err == nil {
+				err = errors.Errorf("%s is empty", output.JSONPath)
+			}
+			return nil, errors.Wrapf(err, "timed out after %s waiting for output %s", timeout, output.Name)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(outputPollInterval):
+		}
 	}
-	return out, nil
 }
 
-func (m *Mixin) handleOutputs(ctx context.Context, client kubernetes.Interface, namespace string, outputs []HelmOutput) error {
+// writeOutput writes the value of an output for porter, and to the path of the output when it is set
+// so that the following steps of the action can read it
+func (m *Mixin) writeOutput(output HelmOutput, val []byte) error {
+	err := m.Context.WriteMixinOutputToFile(output.Name, val)
+	if err != nil || output.Path == "" {
+		return err
+	}
+
+	err = m.FileSystem.MkdirAll(filepath.Dir(output.Path), 0700)
+	if err != nil {
+		return errors.Wrapf(err, "could not create the directory of %s", output.Path)
+	}
+	return errors.Wrapf(m.FileSystem.WriteFile(output.Path, val, 0600), "could not write output %s to %s", output.Name, output.Path)
+}
+
+// writeOutputFrom writes an output for porter like writeOutput, copying its value from a reader so that a large value,
+// such as the manifest of a release, is not held in memory
+func (m *Mixin) writeOutputFrom(output HelmOutput, r io.Reader) error {
+	err := m.FileSystem.MkdirAll(portercontext.MixinOutputsDir, 0700)
+	if err != nil {
+		return errors.Wrapf(err, "could not create the directory of %s", portercontext.MixinOutputsDir)
+	}
+	f, err := m.FileSystem.OpenFile(filepath.Join(portercontext.MixinOutputsDir, output.Name), os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := io.Copy(f, r); err != nil {
+		return err
+	}
+	if output.Path == "" {
+		return nil
+	}
+
+	err = m.FileSystem.MkdirAll(filepath.Dir(output.Path), 0700)
+	if err != nil {
+		return errors.Wrapf(err, "could not create the directory of %s", output.Path)
+	}
+	copied, err := m.FileSystem.OpenFile(output.Path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return errors.Wrapf(err, "could not write output %s to %s", output.Name, output.Path)
+	}
+	defer copied.Close()
+	if err := rewind(f); err != nil {
+		return err
+	}
+	_, err = io.Copy(copied, f)
+	return errors.Wrapf(err, "could not write output %s to %s", output.Name, output.Path)
+}
+
+func (m *Mixin) handleOutputs(ctx context.Context, client kubernetes.Interface, namespace string, outputs []HelmOutput, results []releaseResult) error {
 	var outputError error
 	//Now get the outputs
 	for _, output := range outputs {
 
+		if output.Secret != "" && output.TLS != nil {
+			secretNamespace := namespace
+			if output.Namespace != "" {
+				secretNamespace = output.Namespace
+			}
+
+			val, err := m.getTLSOutput(ctx, client, secretNamespace, output)
+			if err != nil {
+				return err
+			}
+
+			outputError = m.writeOutput(output, val)
+		}
+
 		if output.Secret != "" && output.Key != "" {
 			// Override namespace if output.Namespace is set
 			if output.Namespace != "" {
@@ -64,22 +192,82 @@ func (m *Mixin) handleOutputs(ctx context.Context, client kubernetes.Interface,
 				return err
 			}
 
-			outputError = m.Context.WriteMixinOutputToFile(output.Name, val)
+			outputError = m.writeOutput(output, val)
 		}
 
 		if output.ResourceType != "" && output.ResourceName != "" && output.JSONPath != "" {
-			bytes, err := m.getOutput(ctx,
-				output.ResourceType,
-				output.ResourceName,
-				output.Namespace,
-				output.JSONPath,
-			)
+			var bytes []byte
+			var err error
+			if output.WaitTimeout != "" {
+				bytes, err = m.waitForOutput(ctx, output)
+			} else {
+				bytes, err = m.getOutput(ctx,
+					output.ResourceType,
+					output.ResourceName,
+					output.Namespace,
+					output.JSONPath,
+				)
+			}
+			if err != nil {
+				return err
+			}
+
+			outputError = m.writeOutput(output, bytes)
+
+		}
+
+		if output.Address {
+			val, err := m.getAddressOutput(ctx, output)
 			if err != nil {
 				return err
 			}
 
-			outputError = m.Context.WriteMixinOutputToFile(output.Name, bytes)
+			outputError = m.writeOutput(output, val)
+		}
+
+		if output.HelmStatusPath != "" {
+			release, releaseNamespace := output.Release, namespace
+			if output.Namespace != "" {
+				releaseNamespace = output.Namespace
+			}
+			if release == "" {
+				result, err := findReleaseResult(output, results)
+				if err != nil {
+					return err
+				}
+				release, releaseNamespace = result.Name, result.Namespace
+			}
+
+			val, err := m.getStatusOutput(ctx, release, releaseNamespace, output.HelmStatusPath)
+			if err != nil {
+				return err
+			}
 
+			outputError = m.writeOutput(output, val)
+		}
+
+		if output.ReleaseField == "manifest" {
+			result, err := findReleaseResult(output, results)
+			if err != nil {
+				return err
+			}
+			manifest, err := result.manifestReader()
+			if err != nil {
+				return err
+			}
+
+			outputError = m.writeOutputFrom(output, manifest)
+		} else if output.ReleaseField != "" {
+			result, err := findReleaseResult(output, results)
+			if err != nil {
+				return err
+			}
+			val, err := result.field(output.ReleaseField)
+			if err != nil {
+				return err
+			}
+
+			outputError = m.writeOutput(output, []byte(val))
 		}
 
 		if outputError != nil {
@@ -88,3 +276,32 @@ func (m *Mixin) handleOutputs(ctx context.Context, client kubernetes.Interface,
 	}
 	return nil
 }
+
+// setFromOutputArgs returns the --set-file arguments that pass the outputs written by the previous steps of the action
+// to the chart values. The value is read by helm from the output file, so that secrets such as generated passwords
+// are not printed with the command.
+func (m *Mixin) setFromOutputArgs(setFromOutput map[string]string) ([]string, error) {
+	keys := make([]string, 0, len(setFromOutput))
+	for k := range setFromOutput {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	args := make([]string, 0, len(keys)*2)
+	for _, k := range keys {
+		name := setFromOutput[k]
+		path := filepath.Join(portercontext.MixinOutputsDir, name)
+		// the outputs of the previous steps are not written in plan mode
+		if !m.planMode() {
+			exists, err := m.FileSystem.Exists(path)
+			if err != nil {
+				return nil, errors.Wrapf(err, "could not read output %s used for the value %s", name, k)
+			}
+			if !exists {
+				return nil, errors.Errorf("output %s used for the value %s was not written by a previous step of the action", name, k)
+			}
+		}
+		args = append(args, "--set-file", fmt.Sprintf("%s=%s", k, path))
+	}
+	return args, nil
+}
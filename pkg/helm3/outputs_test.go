@@ -0,0 +1,218 @@
+package helm3
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"get.porter.sh/porter/pkg/portercontext"
+	"get.porter.sh/porter/pkg/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	testclient "k8s.io/client-go/kubernetes/fake"
+)
+
+func TestMixin_UpgradeHelmStatusOutputs(t *testing.T) {
+	ctx := context.Background()
+	defer os.Unsetenv(test.ExpectedCommandEnv)
+	defer os.Unsetenv(test.ExpectedCommandOutputEnv)
+	os.Setenv(test.ExpectedCommandEnv, strings.Join([]string{
+		"helm3 upgrade --install mysql stable/mysql --namespace db --atomic --create-namespace --output json",
+		"helm3 status mysql --output json --namespace db",
+		"helm3 status redis --output json --namespace cache",
+	}, "\n"))
+	os.Setenv(test.ExpectedCommandOutputEnv, `{"name":"mysql","namespace":"db","version":3,"info":{"status":"deployed","last_deployed":"2024-01-02T03:04:05Z"}}`)
+
+	action := UpgradeAction{Steps: []UpgradeStep{{
+		UpgradeArguments: UpgradeArguments{
+			Step: Step{
+				Description: "Upgrade MySQL",
+				Outputs: []HelmOutput{
+					{Name: "mysql-last-deployed", HelmStatusPath: ".info.last_deployed"},
+					{Name: "redis-status", Release: "redis", Namespace: "cache", HelmStatusPath: "{.info.status}"},
+				},
+			},
+			Namespace: "db",
+			Name:      "mysql",
+			Chart:     "stable/mysql",
+		},
+	}}}
+	b, _ := yaml.Marshal(action)
+
+	h := NewTestMixin(t)
+	h.In = bytes.NewReader(b)
+
+	err := h.Upgrade(ctx)
+	require.NoError(t, err)
+
+	lastDeployed, err := h.FileSystem.ReadFile(filepath.Join(portercontext.MixinOutputsDir, "mysql-last-deployed"))
+	require.NoError(t, err)
+	assert.Equal(t, "2024-01-02T03:04:05Z", string(lastDeployed))
+	status, err := h.FileSystem.ReadFile(filepath.Join(portercontext.MixinOutputsDir, "redis-status"))
+	require.NoError(t, err)
+	assert.Equal(t, "deployed", string(status))
+}
+
+func TestMixin_WaitForOutput(t *testing.T) {
+	defer func(interval time.Duration) { outputPollInterval = interval }(outputPollInterval)
+	outputPollInterval = 10 * time.Millisecond
+
+	output := HelmOutput{
+		Name:         "certificate-expiry",
+		ResourceType: "certificate.cert-manager.io",
+		ResourceName: "mysql-tls",
+		Namespace:    "db",
+		JSONPath:     "{.status.notAfter}",
+		WaitTimeout:  "50ms",
+	}
+
+	defer os.Unsetenv(test.ExpectedCommandEnv)
+	defer os.Unsetenv(test.ExpectedCommandOutputEnv)
+	os.Setenv(test.ExpectedCommandEnv, "kubectl get certificate.cert-manager.io mysql-tls -o=jsonpath={.status.notAfter} --namespace=db")
+
+	t.Run("populated", func(t *testing.T) {
+		os.Setenv(test.ExpectedCommandOutputEnv, "2025-01-01T00:00:00Z")
+
+		h := NewTestMixin(t)
+		val, err := h.waitForOutput(context.Background(), output)
+		require.NoError(t, err)
+		assert.Equal(t, "2025-01-01T00:00:00Z", string(val))
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		os.Setenv(test.ExpectedCommandOutputEnv, "")
+
+		h := NewTestMixin(t)
+		_, err := h.waitForOutput(context.Background(), output)
+		require.EqualError(t, err, "timed out after 50ms waiting for output certificate-expiry: {.status.notAfter} is empty")
+	})
+
+	t.Run("invalid timeout", func(t *testing.T) {
+		h := NewTestMixin(t)
+		invalid := output
+		invalid.WaitTimeout = "5"
+		_, err := h.waitForOutput(context.Background(), invalid)
+		require.EqualError(t, err, "invalid waitTimeout of output certificate-expiry \"5\", expected a positive duration such as 90s or 5m30s")
+	})
+}
+
+func TestMixin_WriteOutputPath(t *testing.T) {
+	h := NewTestMixin(t)
+
+	err := h.writeOutput(HelmOutput{Name: "mysql-revision", Path: "/cnab/app/outputs/mysql/revision"}, []byte("3"))
+	require.NoError(t, err)
+
+	val, err := h.FileSystem.ReadFile(filepath.Join(portercontext.MixinOutputsDir, "mysql-revision"))
+	require.NoError(t, err)
+	assert.Equal(t, "3", string(val))
+	val, err = h.FileSystem.ReadFile("/cnab/app/outputs/mysql/revision")
+	require.NoError(t, err)
+	assert.Equal(t, "3", string(val))
+}
+
+func TestMixin_GetOutputWithoutKubectl(t *testing.T) {
+	ctx := context.Background()
+	client := testclient.NewSimpleClientset(
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "mysql", Namespace: "db"},
+			Data:       map[string][]byte{"mysql-password": []byte("secret")},
+		},
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "mysql", Namespace: "default"},
+			Data:       map[string]string{"port": "3306"},
+		},
+	)
+
+	h := NewTestMixin(t)
+	h.Setenv(resourceBackendEnv, clientResourceBackend)
+	h.ClientFactory = &staticKubernetesFactory{client: client}
+
+	val, err := h.getOutput(ctx, "secret", "mysql", "db", "{.data.mysql-password}")
+	require.NoError(t, err)
+	assert.Equal(t, "c2VjcmV0", string(val), "secret data should be base64 encoded like with kubectl")
+
+	val, err = h.getOutput(ctx, "configmaps", "mysql", "", "{.data.port}")
+	require.NoError(t, err)
+	assert.Equal(t, "3306", string(val))
+
+	_, err = h.getOutput(ctx, "service", "mysql", "db", "{.spec.clusterIP}")
+	require.EqualError(t, err, "output resourceType service requires kubectl, only secret and configmap outputs are supported without it")
+
+	_, err = h.getOutput(ctx, "secret", "postgres", "db", "{.data.password}")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "couldn't get secret db/postgres")
+}
+
+type staticResourceReader map[string]string
+
+func (r staticResourceReader) ReadField(ctx context.Context, resourceType, resourceName, namespace, jsonPath string) ([]byte, error) {
+	return []byte(r[resourceType+"/"+resourceName+jsonPath]), nil
+}
+
+func TestMixin_ResourceReader(t *testing.T) {
+	h := NewTestMixin(t)
+	assert.IsType(t, kubectlReader{}, h.resourceReader())
+
+	h.Setenv(resourceBackendEnv, clientResourceBackend)
+	assert.IsType(t, clientReader{}, h.resourceReader())
+
+	h.ResourceReader = staticResourceReader{"service/mysql{.spec.clusterIP}": "10.0.0.12"}
+	val, err := h.getOutput(context.Background(), "service", "mysql", "db", "{.spec.clusterIP}")
+	require.NoError(t, err)
+	assert.Equal(t, "10.0.0.12", string(val))
+}
+
+func TestMixin_UpgradeSetFromOutput(t *testing.T) {
+	testcases := []struct {
+		name          string
+		setFromOutput map[string]string
+		wantError     string
+	}{
+		{
+			name:          "output of a previous step",
+			setFromOutput: map[string]string{"auth.rootPassword": "mysql-root-password"},
+		},
+		{
+			name:          "missing output",
+			setFromOutput: map[string]string{"auth.password": "mysql-password"},
+			wantError:     "output mysql-password used for the value auth.password was not written by a previous step of the action",
+		},
+	}
+
+	passwordFile := filepath.Join(portercontext.MixinOutputsDir, "mysql-root-password")
+	defer os.Unsetenv(test.ExpectedCommandEnv)
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			os.Setenv(test.ExpectedCommandEnv, "helm3 upgrade --install mysql stable/mysql --atomic --create-namespace --output json --set-file auth.rootPassword="+passwordFile)
+
+			action := UpgradeAction{Steps: []UpgradeStep{{
+				UpgradeArguments: UpgradeArguments{
+					Step:          Step{Description: "Upgrade MySQL"},
+					Name:          "mysql",
+					Chart:         "stable/mysql",
+					SetFromOutput: tc.setFromOutput,
+				},
+			}}}
+			b, _ := yaml.Marshal(action)
+
+			h := NewTestMixin(t)
+			require.NoError(t, h.Context.WriteMixinOutputToFile("mysql-root-password", []byte("s3cr3t")))
+			h.In = bytes.NewReader(b)
+
+			err := h.Upgrade(ctx)
+			if tc.wantError != "" {
+				require.EqualError(t, err, tc.wantError)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
@@ -0,0 +1,140 @@
+package helm3
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// defaultMaxParallel is the number of releases of a parallel step that are deployed at the same time by default
+const defaultMaxParallel = 4
+
+// validateParallel checks the parallelism of a step
+func validateParallel(parallel bool, maxParallel int) error {
+	if maxParallel < 0 {
+		return errors.Errorf("invalid maxParallel %d", maxParallel)
+	}
+	if maxParallel > 0 && !parallel {
+		return errors.New("maxParallel requires parallel")
+	}
+	return nil
+}
+
+// runParallel calls run for each release, with at most maxParallel releases at the same time, and returns their results
+// in the order of the releases. The output of each release is prefixed with its label, and the failures of every release
+// are returned together once all of them are done.
+func (m *Mixin) runParallel(labels []string, maxParallel int, run func(m *Mixin, i int) (*releaseResult, error)) ([]*releaseResult, error) {
+	if maxParallel == 0 {
+		maxParallel = defaultMaxParallel
+	}
+	if m.planMode() {
+		// the planned commands are printed in the order of the releases
+		maxParallel = 1
+	}
+
+	results := make([]*releaseResult, len(labels))
+	errs := make([]error, len(labels))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, maxParallel)
+	for i, label := range labels {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(i int, label string) {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			prefix := fmt.Sprintf("[%s] ", label)
+			out := &prefixWriter{w: m.Out, prefix: prefix, mu: &mu}
+			errOut := &prefixWriter{w: m.Err, prefix: prefix, mu: &mu}
+			results[i], errs[i] = run(m.withOutput(out, errOut), i)
+			out.flush()
+			errOut.flush()
+		}(i, label)
+	}
+	wg.Wait()
+
+	var failures []string
+	var first error
+	for i, err := range errs {
+		if err == nil {
+			continue
+		}
+		if first == nil {
+			first = err
+		}
+		failures = append(failures, fmt.Sprintf("%s: %s", labels[i], err))
+	}
+	if first == nil {
+		return results, nil
+	}
+	return results, &parallelError{failures: failures, first: first}
+}
+
+// withOutput returns a copy of the mixin that writes to other outputs, for a release deployed in parallel
+func (m *Mixin) withOutput(out io.Writer, errOut io.Writer) *Mixin {
+	c := *m.Context
+	c.Out, c.Err = out, errOut
+	attributed := *m
+	attributed.Context = &c
+	return &attributed
+}
+
+// parallelError reports the releases of a parallel step that failed. It unwraps to the first failure,
+// which selects the exit code of the mixin.
+type parallelError struct {
+	failures []string
+	first    error
+}
+
+func (e *parallelError) Error() string {
+	if len(e.failures) == 1 {
+		return e.failures[0]
+	}
+	return fmt.Sprintf("%d releases failed:\n%s", len(e.failures), strings.Join(e.failures, "\n"))
+}
+
+func (e *parallelError) Unwrap() error {
+	return e.first
+}
+
+// prefixWriter writes complete lines to a shared writer with a prefix, so that the output of releases deployed in
+// parallel is not interleaved within a line
+type prefixWriter struct {
+	w      io.Writer
+	prefix string
+	mu     *sync.Mutex
+	buf    bytes.Buffer
+}
+
+func (p *prefixWriter) Write(b []byte) (int, error) {
+	p.buf.Write(b)
+	for {
+		i := bytes.IndexByte(p.buf.Bytes(), '\n')
+		if i < 0 {
+			return len(b), nil
+		}
+		line := p.buf.Next(i + 1)
+		p.mu.Lock()
+		_, err := fmt.Fprintf(p.w, "%s%s", p.prefix, line)
+		p.mu.Unlock()
+		if err != nil {
+			return len(b), err
+		}
+	}
+}
+
+// flush writes the last line, when it does not end with a newline
+func (p *prefixWriter) flush() {
+	if p.buf.Len() == 0 {
+		return
+	}
+	p.mu.Lock()
+	fmt.Fprintf(p.w, "%s%s\n", p.prefix, p.buf.String())
+	p.mu.Unlock()
+	p.buf.Reset()
+}
@@ -0,0 +1,105 @@
+package helm3
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"get.porter.sh/porter/pkg/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestMixin_InstallParallel(t *testing.T) {
+	ctx := context.Background()
+	defer os.Unsetenv(test.ExpectedCommandEnv)
+	os.Setenv(test.ExpectedCommandEnv, strings.Join([]string{
+		"helm3 upgrade --install mysql bitnami/mysql --namespace db --atomic --create-namespace --output json",
+		"helm3 upgrade --install redis bitnami/redis --namespace db --atomic --create-namespace --output json",
+		"helm3 upgrade --install kafka bitnami/kafka --namespace db --atomic --create-namespace --output json",
+	}, "\n"))
+
+	action := InstallAction{Steps: []InstallStep{{
+		InstallArguments: InstallArguments{
+			Step:        Step{Description: "Install the data services"},
+			Namespace:   "db",
+			Parallel:    true,
+			MaxParallel: 2,
+			Releases: []Release{
+				{Name: "mysql", Chart: "bitnami/mysql"},
+				{Name: "redis", Chart: "bitnami/redis"},
+				{Name: "kafka", Chart: "bitnami/kafka"},
+			},
+		},
+	}}}
+	b, _ := yaml.Marshal(action)
+
+	h := NewTestMixin(t)
+	h.In = bytes.NewReader(b)
+
+	err := h.Install(ctx)
+	require.NoError(t, err)
+	output := h.TestContext.GetOutput()
+	assert.Contains(t, output, "[mysql] ")
+	assert.Contains(t, output, "[redis] ")
+	assert.Contains(t, output, "[kafka] ")
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		assert.Regexp(t, `^\[(mysql|redis|kafka)\] `, line, "every line should be attributed to its release")
+	}
+}
+
+func TestMixin_InstallParallelFailures(t *testing.T) {
+	ctx := context.Background()
+	defer os.Unsetenv(test.ExpectedCommandEnv)
+	os.Setenv(test.ExpectedCommandEnv, "helm3 upgrade --install mysql bitnami/mysql --namespace db --atomic --create-namespace --output json")
+
+	action := InstallAction{Steps: []InstallStep{{
+		InstallArguments: InstallArguments{
+			Step:      Step{Description: "Install the data services"},
+			Namespace: "db",
+			Parallel:  true,
+			Releases: []Release{
+				{Name: "mysql", Chart: "bitnami/mysql"},
+				{Name: "redis", Chart: "bitnami/redis"},
+				{Name: "kafka", Chart: "bitnami/kafka"},
+			},
+		},
+	}}}
+	b, _ := yaml.Marshal(action)
+
+	h := NewTestMixin(t)
+	h.In = bytes.NewReader(b)
+
+	err := h.Install(ctx)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "2 releases failed:\n")
+	assert.Contains(t, err.Error(), "\nredis: ")
+	assert.Contains(t, err.Error(), "\nkafka: ")
+	assert.NotContains(t, err.Error(), "mysql: ")
+}
+
+func TestValidateParallel(t *testing.T) {
+	assert.NoError(t, validateParallel(false, 0))
+	assert.NoError(t, validateParallel(true, 0))
+	assert.NoError(t, validateParallel(true, 8))
+	assert.EqualError(t, validateParallel(true, -1), "invalid maxParallel -1")
+	assert.EqualError(t, validateParallel(false, 2), "maxParallel requires parallel")
+}
+
+func TestPrefixWriter(t *testing.T) {
+	var out bytes.Buffer
+	var mu sync.Mutex
+	w := &prefixWriter{w: &out, prefix: "[mysql] ", mu: &mu}
+
+	w.Write([]byte("Release mysql is "))
+	assert.Empty(t, out.String(), "incomplete lines should be buffered")
+	w.Write([]byte("deployed\nwaiting"))
+	w.Write([]byte(" for pods\n"))
+	w.Write([]byte("done"))
+	w.flush()
+	assert.Equal(t, "[mysql] Release mysql is deployed\n[mysql] waiting for pods\n[mysql] done\n", out.String())
+}
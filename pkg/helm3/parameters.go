@@ -0,0 +1,58 @@
+package helm3
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// bundleFile is the path of the bundle definition in the invocation image
+const bundleFile = "/cnab/bundle.json"
+
+// bundleParameters represents the parameters of the bundle definition, with the path where each one is mounted
+type bundleParameters struct {
+	Parameters map[string]struct {
+		Destination struct {
+			Path string `json:"path"`
+		} `json:"destination"`
+	} `json:"parameters"`
+}
+
+// setFileArgs returns the --set-file arguments that pass the file parameters of the bundle to the chart values
+func (m *Mixin) setFileArgs(setFileFromParameter map[string]string) ([]string, error) {
+	if len(setFileFromParameter) == 0 {
+		return nil, nil
+	}
+
+	b, err := m.FileSystem.ReadFile(bundleFile)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not read the bundle definition %s", bundleFile)
+	}
+	var bundle bundleParameters
+	err = json.Unmarshal(b, &bundle)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not parse the bundle definition %s", bundleFile)
+	}
+
+	keys := make([]string, 0, len(setFileFromParameter))
+	for k := range setFileFromParameter {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	args := make([]string, 0, len(keys)*2)
+	for _, k := range keys {
+		name := setFileFromParameter[k]
+		param, ok := bundle.Parameters[name]
+		if !ok {
+			return nil, errors.Errorf("parameter %s used for the value %s is not defined by the bundle", name, k)
+		}
+		if param.Destination.Path == "" {
+			return nil, errors.Errorf("parameter %s used for the value %s is not a file parameter", name, k)
+		}
+		args = append(args, "--set-file", fmt.Sprintf("%s=%s", k, param.Destination.Path))
+	}
+	return args, nil
+}
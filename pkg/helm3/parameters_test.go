@@ -0,0 +1,64 @@
+package helm3
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"testing"
+
+	"get.porter.sh/porter/pkg/test"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestMixin_InstallSetFileFromParameter(t *testing.T) {
+	testcases := []struct {
+		name                 string
+		setFileFromParameter map[string]string
+		wantError            string
+	}{
+		{
+			name:                 "file parameter",
+			setFileFromParameter: map[string]string{"license.key": "license"},
+		},
+		{
+			name:                 "undefined parameter",
+			setFileFromParameter: map[string]string{"tls.crt": "certificate"},
+			wantError:            "parameter certificate used for the value tls.crt is not defined by the bundle",
+		},
+		{
+			name:                 "not a file parameter",
+			setFileFromParameter: map[string]string{"namespace": "namespace"},
+			wantError:            "parameter namespace used for the value namespace is not a file parameter",
+		},
+	}
+
+	defer os.Unsetenv(test.ExpectedCommandEnv)
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			os.Setenv(test.ExpectedCommandEnv, "helm3 upgrade --install mysql stable/mysql --atomic --create-namespace --output json --set-file license.key=/cnab/app/license.txt")
+
+			action := InstallAction{Steps: []InstallStep{{
+				InstallArguments: InstallArguments{
+					Step:                 Step{Description: "Install MySQL"},
+					Name:                 "mysql",
+					Chart:                "stable/mysql",
+					SetFileFromParameter: tc.setFileFromParameter,
+				},
+			}}}
+			b, _ := yaml.Marshal(action)
+
+			h := NewTestMixin(t)
+			h.TestContext.AddTestFile("testdata/bundle.json", bundleFile)
+			h.In = bytes.NewReader(b)
+
+			err := h.Install(ctx)
+			if tc.wantError != "" {
+				require.EqualError(t, err, tc.wantError)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
@@ -0,0 +1,250 @@
+package helm3
+
+import (
+	"context"
+	"os/exec"
+
+	"github.com/pkg/errors"
+)
+
+// releaseStep holds the fields of an install or upgrade step that are used by the stages run around its releases
+type releaseStep struct {
+	Step              Step
+	Namespace         string
+	DryRun            string
+	CRDs              *CRDArguments
+	Manifests         []Manifest
+	Jobs              []JobWait
+	SmokeTest         *SmokeTest
+	Repositories      map[string]Repository
+	RepositoryCharts  []string
+	NamespaceMetadata *NamespaceMetadata
+	Verify            *SignatureVerification
+	CheckPermissions  bool
+	Policy            *PolicyCheck
+	ReleasesFile      string
+}
+
+// releasePipeline runs the stages shared by the install and upgrade steps: it prepares the step, readies the chart of
+// each release and checks it with the helm command built by the step, records the deployed releases, and runs the
+// stages that follow the releases before writing the outputs of the step
+type releasePipeline struct {
+	m    *Mixin
+	step releaseStep
+
+	// dryRun is set when the releases are rendered instead of deployed, once the step is prepared
+	dryRun bool
+	// dryRunFlags are the helm flags of the dry run, resolved when the step is prepared
+	dryRunFlags []string
+
+	results  []releaseResult
+	prepared map[string]bool
+	cleanup  []func()
+}
+
+func (m *Mixin) newReleasePipeline(step Step) *releasePipeline {
+	return &releasePipeline{m: m, step: releaseStep{Step: step}, prepared: make(map[string]bool)}
+}
+
+// start configures the mixin for the step, before its fields are validated
+func (p *releasePipeline) start() error {
+	err := p.m.setVerbosity(p.step.Step)
+	if err != nil {
+		return err
+	}
+
+	err = p.m.setGlobalFlags(p.step.Step)
+	if err != nil {
+		return err
+	}
+
+	err = p.m.validateStepHooks(p.step.Step)
+	if err != nil {
+		return err
+	}
+
+	err = p.m.checkStorageDriver()
+	if err != nil {
+		return err
+	}
+
+	removeKubeconfig, err := p.m.configureKubeToken()
+	if err != nil {
+		return err
+	}
+	p.cleanup = append(p.cleanup, removeKubeconfig)
+	return nil
+}
+
+// close removes what the step added to the invocation image, in reverse order, along with the spooled release results
+func (p *releasePipeline) close() {
+	for i := len(p.cleanup) - 1; i >= 0; i-- {
+		p.cleanup[i]()
+	}
+	p.m.removeSpool()
+}
+
+// validate checks the fields of the step used by the release pipeline
+func (s releaseStep) validate() error {
+	err := validateDryRun(s.DryRun)
+	if err != nil {
+		return err
+	}
+
+	err = validateManifests(s.Manifests)
+	if err != nil {
+		return err
+	}
+
+	err = validateJobs(s.Jobs)
+	if err != nil {
+		return err
+	}
+
+	if s.SmokeTest != nil {
+		return s.SmokeTest.validate()
+	}
+	return nil
+}
+
+// prepare adds the repositories of the validated step and runs the stages that precede its releases
+func (p *releasePipeline) prepare(ctx context.Context, step releaseStep) error {
+	p.step = step
+	p.dryRun = isDryRun(step.DryRun)
+
+	err := p.m.importGPGKey(ctx)
+	if err != nil {
+		return err
+	}
+
+	removeRepositories, err := p.m.addRuntimeRepositories(ctx, p.step.Repositories)
+	if err != nil {
+		return err
+	}
+	p.cleanup = append(p.cleanup, removeRepositories)
+
+	err = p.m.verifyRepositories(ctx, p.step.Repositories)
+	if err != nil {
+		return err
+	}
+
+	err = p.m.updateRepositories(ctx, p.step.RepositoryCharts, p.step.Repositories)
+	if err != nil {
+		return err
+	}
+
+	p.dryRunFlags, err = p.m.dryRunFlags(ctx, p.step.DryRun)
+	if err != nil {
+		return err
+	}
+
+	if p.dryRun {
+		return nil
+	}
+
+	err = p.m.runStepHooks(ctx, p.step.Step, p.step.Step.Before, hookBefore)
+	if err != nil {
+		return err
+	}
+
+	if p.step.CRDs != nil {
+		err = p.m.applyCRDs(ctx, *p.step.CRDs)
+		if err != nil {
+			return err
+		}
+	}
+
+	return p.m.applyManifests(ctx, p.step.Manifests, manifestBefore, p.step.Namespace)
+}
+
+// prepareNamespace labels and annotates the namespace of a release once per step
+func (p *releasePipeline) prepareNamespace(ctx context.Context, namespace string) error {
+	if p.step.NamespaceMetadata == nil || p.prepared[namespace] || p.dryRun {
+		return nil
+	}
+	err := p.m.prepareNamespace(ctx, namespace, *p.step.NamespaceMetadata)
+	if err != nil {
+		return err
+	}
+	p.prepared[namespace] = true
+	return nil
+}
+
+// readyChart logs in to the registry of the chart of a release, verifies its signature and resolves a chart pinned
+// to a digest, updating the chart and version of the release, then runs the preflight checks of the step against
+// the helm command built for the release
+func (p *releasePipeline) readyChart(ctx context.Context, name string, namespace string, chart *string, version *string, command func(ctx context.Context) (*exec.Cmd, error)) error {
+	err := p.m.registryLogin(ctx, *chart)
+	if err != nil {
+		return err
+	}
+
+	if p.step.Verify != nil {
+		err = p.m.verifyChart(ctx, *p.step.Verify, *chart, *version)
+		if err != nil {
+			return err
+		}
+	}
+
+	*chart, *version, err = p.m.pullPinnedChart(ctx, *chart, *version)
+	if err != nil {
+		return err
+	}
+
+	if (!p.step.CheckPermissions && p.step.Policy == nil) || p.m.planMode() {
+		return nil
+	}
+	cmd, err := command(ctx)
+	if err != nil {
+		return err
+	}
+	return p.m.preflight(ctx, name, namespace, cmd, p.step.CheckPermissions, p.step.Policy)
+}
+
+// record keeps the result of a deployed release for the outputs of the step, and records the release in the
+// releases file of the step
+func (p *releasePipeline) record(result releaseResult) error {
+	p.results = append(p.results, result)
+	if p.step.ReleasesFile == "" || p.dryRun {
+		return nil
+	}
+	return p.m.recordRelease(p.step.ReleasesFile, installedRelease{Name: result.Name, Namespace: result.Namespace})
+}
+
+// finish runs the stages that follow the releases of the step and writes its outputs
+func (p *releasePipeline) finish(ctx context.Context) error {
+	if !p.dryRun {
+		err := p.m.applyManifests(ctx, p.step.Manifests, manifestAfter, p.step.Namespace)
+		if err != nil {
+			return err
+		}
+
+		err = p.m.waitForJobs(ctx, p.step.Jobs, p.step.Namespace)
+		if err != nil {
+			return err
+		}
+
+		if p.step.SmokeTest != nil {
+			err = p.m.runSmokeTest(ctx, *p.step.SmokeTest, p.step.Namespace)
+			if err != nil {
+				return err
+			}
+		}
+
+		err = p.m.runStepHooks(ctx, p.step.Step, p.step.Step.After, hookAfter)
+		if err != nil {
+			return err
+		}
+	}
+
+	if p.m.planMode() {
+		return nil
+	}
+
+	kubeClient, err := p.m.getKubernetesClient()
+	if err != nil {
+		return errors.Wrap(err, "couldn't get kubernetes client")
+	}
+
+	return p.m.handleOutputs(ctx, kubeClient, p.step.Namespace, p.step.Step.Outputs, p.results)
+}
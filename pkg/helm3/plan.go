@@ -0,0 +1,88 @@
+package helm3
+
+import (
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// planEnv enables plan mode when set to true, the helm commands are printed instead of being executed
+const planEnv = "PORTER_HELM3_PLAN"
+
+// redacted replaces sensitive values in planned commands
+const redacted = "*******"
+
+// sensitiveFlags are flags whose value is always redacted from planned commands
+var sensitiveFlags = map[string]bool{
+	"--password":   true,
+	"-p":           true,
+	"--kube-token": true,
+}
+
+// setFlags are flags that take a key=value pair, whose value is redacted when the key looks sensitive
+var setFlags = map[string]bool{
+	"--set":        true,
+	"--set-string": true,
+	"--set-json":   true,
+}
+
+var sensitiveKeyRegex = regexp.MustCompile(`(?i)(password|passwd|secret|token|credential|private)`)
+
+func (m *Mixin) planMode() bool {
+	if m.PlanMode {
+		return true
+	}
+	plan, _ := strconv.ParseBool(m.Getenv(planEnv))
+	return plan
+}
+
+// printPlannedCommand prints the fully resolved command, without executing it
+func (m *Mixin) printPlannedCommand(cmd *exec.Cmd) {
+	fmt.Fprintln(m.Out, formatPlannedCommand(cmd.Args))
+}
+
+// formatPlannedCommand formats the arguments of a command for review, quoting them as needed
+// and redacting passwords and set values that look like secrets. Every command printed or quoted in an error
+// is formatted with it, so that its secrets are not logged.
+func formatPlannedCommand(args []string) string {
+	formatted := make([]string, 0, len(args))
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		name, value, hasValue := strings.Cut(arg, "=")
+		if strings.HasPrefix(arg, "-") && hasValue {
+			// --flag=value
+			formatted = append(formatted, name+"="+quoteArg(redactFlagValue(name, value)))
+			continue
+		}
+
+		formatted = append(formatted, quoteArg(arg))
+		if (sensitiveFlags[arg] || setFlags[arg]) && i+1 < len(args) {
+			i++
+			formatted = append(formatted, quoteArg(redactFlagValue(arg, args[i])))
+		}
+	}
+	return strings.Join(formatted, " ")
+}
+
+func redactFlagValue(flag string, value string) string {
+	if sensitiveFlags[flag] {
+		return redacted
+	}
+	if setFlags[flag] {
+		key, _, _ := strings.Cut(value, "=")
+		if sensitiveKeyRegex.MatchString(key) {
+			return key + "=" + redacted
+		}
+	}
+	return value
+}
+
+// quoteArg single quotes an argument when it would be split or interpreted by a shell
+func quoteArg(arg string) string {
+	if arg != "" && !strings.ContainsAny(arg, " \t\n'\"$`\\|&;<>()*?[]{}#~") {
+		return arg
+	}
+	return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+}
@@ -0,0 +1,82 @@
+package helm3
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"get.porter.sh/porter/pkg/exec/builder"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestFormatPlannedCommand(t *testing.T) {
+	testcases := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{"plain", []string{"helm3", "status", "mysql"}, "helm3 status mysql"},
+		{"password", []string{"helm3", "upgrade", "--password", "hunter2", "--username", "admin"},
+			"helm3 upgrade --password '*******' --username admin"},
+		{"short password", []string{"helm3", "registry", "login", "-p", "hunter2"}, "helm3 registry login -p '*******'"},
+		{"password with equals", []string{"helm3", "pull", "--password=hunter2"}, "helm3 pull --password='*******'"},
+		{"sensitive set", []string{"helm3", "install", "--set", "auth.rootPassword=hunter2", "--set", "replicas=2"},
+			"helm3 install --set 'auth.rootPassword=*******' --set replicas=2"},
+		{"quoting", []string{"helm3", "install", "--description", "my release"}, "helm3 install --description 'my release'"},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, formatPlannedCommand(tc.args))
+		})
+	}
+}
+
+func TestMixin_InstallPlanMode(t *testing.T) {
+	ctx := context.Background()
+	action := InstallAction{Steps: []InstallStep{{
+		InstallArguments: InstallArguments{
+			Step:         Step{Description: "Install MySQL"},
+			Name:         "mysql",
+			Chart:        "stable/mysql",
+			Repo:         "https://charts.example.com",
+			Username:     "admin",
+			Password:     "hunter2",
+			SkipIfExists: true,
+			Set:          map[string]string{"auth.rootPassword": "hunter2"},
+		},
+	}}}
+	b, _ := yaml.Marshal(action)
+
+	h := NewTestMixin(t)
+	h.Setenv(planEnv, "true")
+	h.In = bytes.NewReader(b)
+
+	err := h.Install(ctx)
+	require.NoError(t, err)
+	assert.Contains(t, h.TestContext.GetOutput(),
+		"helm3 upgrade --install mysql stable/mysql --repo https://charts.example.com --username admin --password '*******' --atomic --create-namespace --output json --set 'auth.rootPassword=*******'\n")
+	assert.NotContains(t, h.TestContext.GetOutput(), "hunter2")
+}
+
+func TestMixin_ExecutePlanMode(t *testing.T) {
+	ctx := context.Background()
+	action := Action{Steps: []ExecuteSteps{{
+		ExecuteStep: ExecuteStep{
+			Step:      Step{Description: "Login"},
+			Arguments: []string{"registry", "login", "localhost:5000"},
+			Flags:     builder.Flags{builder.NewFlag("u", "myuser"), builder.NewFlag("p", "mypass")},
+		},
+	}}}
+	b, _ := yaml.Marshal(action)
+
+	h := NewTestMixin(t)
+	h.PlanMode = true
+	h.In = bytes.NewReader(b)
+
+	err := h.Execute(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "helm3 registry login localhost:5000 -p '*******' -u myuser\n", h.TestContext.GetOutput())
+}
@@ -0,0 +1,68 @@
+package helm3
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Plugin represents a helm plugin installed in the invocation image, that steps can run with plugin
+type Plugin struct {
+	URL     string `yaml:"url"`
+	Version string `yaml:"version,omitempty"`
+}
+
+// validatePlugins checks that the configured plugins can be installed and run by name
+func validatePlugins(plugins map[string]Plugin) error {
+	for name, plugin := range plugins {
+		if !isValidCommandName(name) {
+			return errors.Errorf("supplied plugin name %q is not a valid helm command name", name)
+		}
+		if plugin.URL == "" {
+			return errors.Errorf("supplied plugin %q must set the url it is installed from", name)
+		}
+	}
+	return nil
+}
+
+// pluginNames returns the names of the configured plugins, sorted
+func pluginNames(plugins map[string]Plugin) []string {
+	names := make([]string, 0, len(plugins))
+	for name := range plugins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// buildPlugins writes the Dockerfile lines that install the helm plugins
+func (m *Mixin) buildPlugins(plugins map[string]Plugin, helmBinary string) {
+	// Plugins are installed for the user the container will execute as
+	fmt.Fprintln(m.Out, "USER ${BUNDLE_USER}")
+	for _, name := range pluginNames(plugins) {
+		plugin := plugins[name]
+		install := []string{helmBinary, "plugin", "install", plugin.URL}
+		if plugin.Version != "" {
+			install = append(install, "--version", plugin.Version)
+		}
+		fmt.Fprintf(m.Out, "RUN %s\n", strings.Join(install, " "))
+	}
+	fmt.Fprintln(m.Out, "USER root")
+}
+
+// mapKubeAPIsPlugin updates the manifests stored in helm releases that use deprecated or removed kubernetes apis
+const mapKubeAPIsPlugin = "mapkubeapis"
+
+// mapDeprecatedAPIs runs the mapkubeapis plugin against a release, so that it can be upgraded
+// after the cluster removed api versions used by its last manifest
+func (m *Mixin) mapDeprecatedAPIs(ctx context.Context, step UpgradeArguments) error {
+	cmd := m.helmCommand(ctx, mapKubeAPIsPlugin, step.Name)
+	if step.Namespace != "" {
+		cmd.Args = append(cmd.Args, "--namespace", step.Namespace)
+	}
+	m.setStepEnv(cmd, step.Env)
+	return m.runCommand(cmd)
+}
@@ -0,0 +1,81 @@
+package helm3
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const defaultConftestVersion = "v0.45.0"
+
+// defaultPolicyPath is the directory of the bundle where conftest looks for policies by default
+const defaultPolicyPath = "policy"
+
+// Conftest represents the conftest version installed in the invocation image
+type Conftest struct {
+	Version string `yaml:"version,omitempty"`
+}
+
+// PolicyCheck represents the evaluation of the rendered manifest of a release against rego policies with conftest
+type PolicyCheck struct {
+	// Path is the directory of the bundle with the policies, it defaults to policy
+	Path string `yaml:"path,omitempty"`
+	// Namespaces are the rego packages of the policies that are evaluated, instead of the main package
+	Namespaces []string `yaml:"namespaces,omitempty"`
+}
+
+// conftestPlatforms and conftestArchitectures map the helm client platform to the names used by the conftest releases
+var (
+	conftestPlatforms     = map[string]string{"linux": "Linux", "darwin": "Darwin"}
+	conftestArchitectures = map[string]string{"amd64": "x86_64", "arm64": "arm64"}
+)
+
+// validateConftestPlatform checks that conftest is released for the platform and architecture of the helm client
+func (m *Mixin) validateConftestPlatform() error {
+	if _, ok := conftestPlatforms[m.HelmClientPlatfrom]; !ok {
+		return errors.Errorf("conftest is not available for the %s platform", m.HelmClientPlatfrom)
+	}
+	if _, ok := conftestArchitectures[m.HelmClientArchitecture]; !ok {
+		return errors.Errorf("conftest is not available for the %s architecture", m.HelmClientArchitecture)
+	}
+	return nil
+}
+
+// buildConftest writes the Dockerfile lines that install conftest
+func (m *Mixin) buildConftest(conftest Conftest) {
+	if conftest.Version == "" {
+		conftest.Version = defaultConftestVersion
+	}
+	archive := fmt.Sprintf("conftest_%s_%s_%s.tar.gz", strings.TrimPrefix(conftest.Version, "v"),
+		conftestPlatforms[m.HelmClientPlatfrom], conftestArchitectures[m.HelmClientArchitecture])
+	fmt.Fprintf(m.Out, "RUN curl -fsSL https://github.com/open-policy-agent/conftest/releases/download/%s/%s |\\\n",
+		conftest.Version, archive)
+	fmt.Fprintln(m.Out, "    tar -xz -C /usr/local/bin conftest")
+}
+
+// checkPolicy evaluates the rendered manifest of a release against the policies with conftest,
+// failing the step when a policy is violated
+func (m *Mixin) checkPolicy(ctx context.Context, release string, manifest io.Reader, policy PolicyCheck) error {
+	fmt.Fprintf(m.Out, "Checking release %s against the policies\n", release)
+
+	path := policy.Path
+	if path == "" {
+		path = defaultPolicyPath
+	}
+	cmd := m.NewCommand(ctx, "conftest", "test", "--policy", path)
+	for _, namespace := range policy.Namespaces {
+		cmd.Args = append(cmd.Args, "--namespace", namespace)
+	}
+	// the manifest is read from stdin
+	cmd.Args = append(cmd.Args, "-")
+	cmd.Stdin = manifest
+
+	err := m.runCommand(cmd)
+	if err != nil {
+		return errors.Wrapf(err, "release %s violates the policies in %s", release, path)
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package helm3
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+
+	"get.porter.sh/porter/pkg/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestMixin_InstallPolicy(t *testing.T) {
+	ctx := context.Background()
+	defer os.Unsetenv(test.ExpectedCommandEnv)
+	defer os.Unsetenv(test.ExpectedCommandOutputEnv)
+	os.Setenv(test.ExpectedCommandEnv, strings.Join([]string{
+		"helm3 upgrade --install mysql stable/mysql --atomic --create-namespace --output json --dry-run",
+		"conftest test --policy policy/kubernetes --namespace kubernetes.security -",
+		"helm3 upgrade --install mysql stable/mysql --atomic --create-namespace --output json",
+	}, "\n"))
+	rendered, err := json.Marshal(map[string]string{"manifest": "apiVersion: apps/v1\nkind: StatefulSet\nmetadata:\n  name: mysql\n"})
+	require.NoError(t, err)
+	os.Setenv(test.ExpectedCommandOutputEnv, string(rendered))
+
+	action := InstallAction{Steps: []InstallStep{{
+		InstallArguments: InstallArguments{
+			Step:  Step{Description: "Install MySQL"},
+			Name:  "mysql",
+			Chart: "stable/mysql",
+			Policy: &PolicyCheck{
+				Path:       "policy/kubernetes",
+				Namespaces: []string{"kubernetes.security"},
+			},
+		},
+	}}}
+	b, _ := yaml.Marshal(action)
+
+	h := NewTestMixin(t)
+	h.In = bytes.NewReader(b)
+
+	err = h.Install(ctx)
+	require.NoError(t, err)
+	assert.Contains(t, h.TestContext.GetOutput(), "Checking release mysql against the policies")
+}
+
+func TestMixin_CheckPolicyViolated(t *testing.T) {
+	ctx := context.Background()
+	defer os.Unsetenv(test.ExpectedCommandEnv)
+	defer os.Unsetenv(test.ExpectedCommandExitCodeEnv)
+	os.Setenv(test.ExpectedCommandEnv, "conftest test --policy policy -")
+	os.Setenv(test.ExpectedCommandExitCodeEnv, "1")
+
+	h := NewTestMixin(t)
+	err := h.checkPolicy(ctx, "mysql", strings.NewReader("kind: StatefulSet\n"), PolicyCheck{})
+	require.EqualError(t, err, "release mysql violates the policies in policy: exit status 1")
+}
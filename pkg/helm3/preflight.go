@@ -0,0 +1,232 @@
+package helm3
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+// preflightVerbs are the verbs helm needs on the resources of a release to install or upgrade it
+var preflightVerbs = []string{"get", "create", "patch"}
+
+// manifestResource identifies a kind of resource rendered by a chart, in the namespace where it is deployed
+type manifestResource struct {
+	Kind      string
+	Group     string
+	Namespace string
+}
+
+// resourceType returns the resource type understood by kubectl, for example deployment.apps
+func (r manifestResource) resourceType() string {
+	kind := strings.ToLower(r.Kind)
+	if r.Group == "" {
+		return kind
+	}
+	return kind + "." + r.Group
+}
+
+// clusterScopedKinds are the kinds of the kubernetes api that are not namespaced
+var clusterScopedKinds = map[string]bool{
+	"APIService":                       true,
+	"CertificateSigningRequest":        true,
+	"ClusterRole":                      true,
+	"ClusterRoleBinding":               true,
+	"ComponentStatus":                  true,
+	"CSIDriver":                        true,
+	"CSINode":                          true,
+	"CustomResourceDefinition":         true,
+	"FlowSchema":                       true,
+	"IngressClass":                     true,
+	"MutatingWebhookConfiguration":     true,
+	"Namespace":                        true,
+	"Node":                             true,
+	"PersistentVolume":                 true,
+	"PodSecurityPolicy":                true,
+	"PriorityClass":                    true,
+	"PriorityLevelConfiguration":       true,
+	"RuntimeClass":                     true,
+	"StorageClass":                     true,
+	"ValidatingAdmissionPolicy":        true,
+	"ValidatingAdmissionPolicyBinding": true,
+	"ValidatingWebhookConfiguration":   true,
+	"VolumeAttachment":                 true,
+}
+
+// isClusterScopedKind checks if a kind, in any case, is one of the cluster-scoped kinds of the kubernetes api
+func isClusterScopedKind(kind string) bool {
+	for k := range clusterScopedKinds {
+		if strings.EqualFold(k, kind) {
+			return true
+		}
+	}
+	return false
+}
+
+// manifestResources lists the distinct kinds of resources of a rendered manifest and their namespaces.
+// Resources without a namespace are deployed to the namespace of the release, unless their kind is cluster-scoped.
+func manifestResources(manifest io.Reader, namespace string) ([]manifestResource, error) {
+	seen := make(map[manifestResource]bool)
+	var resources []manifestResource
+
+	decoder := yaml.NewDecoder(manifest)
+	for {
+		var doc struct {
+			APIVersion string `yaml:"apiVersion"`
+			Kind       string `yaml:"kind"`
+			Metadata   struct {
+				Namespace string `yaml:"namespace"`
+			} `yaml:"metadata"`
+		}
+		err := decoder.Decode(&doc)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "could not parse the rendered manifest")
+		}
+		if doc.Kind == "" {
+			continue
+		}
+
+		r := manifestResource{Kind: doc.Kind, Namespace: doc.Metadata.Namespace}
+		if group, _, ok := strings.Cut(doc.APIVersion, "/"); ok {
+			r.Group = group
+		}
+		if clusterScopedKinds[r.Kind] {
+			r.Namespace = ""
+		} else if r.Namespace == "" {
+			r.Namespace = namespace
+		}
+		if !seen[r] {
+			seen[r] = true
+			resources = append(resources, r)
+		}
+	}
+
+	sort.Slice(resources, func(i, j int) bool {
+		if resources[i].resourceType() != resources[j].resourceType() {
+			return resources[i].resourceType() < resources[j].resourceType()
+		}
+		return resources[i].Namespace < resources[j].Namespace
+	})
+	return resources, nil
+}
+
+// preflight renders a release with a dry run of its helm command, and runs the checks enabled on the step
+// against the rendered manifest, before anything is deployed
+func (m *Mixin) preflight(ctx context.Context, release string, namespace string, cmd *exec.Cmd, checkPermissions bool, policy *PolicyCheck) error {
+	manifest, err := m.renderManifest(release, cmd)
+	if err != nil {
+		return err
+	}
+	if policy != nil {
+		err = m.checkPolicy(ctx, release, manifest, *policy)
+		if err == nil {
+			err = rewind(manifest)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if checkPermissions {
+		return m.checkPermissions(ctx, release, namespace, manifest)
+	}
+	return nil
+}
+
+// renderManifest returns the manifest of a release, printed by a dry run of its install or upgrade command, from a
+// spooled file so that it is not held in memory
+func (m *Mixin) renderManifest(release string, cmd *exec.Cmd) (spooledFile, error) {
+	stderr := &stderrTail{}
+	if !hasFlag(cmd.Args, "--dry-run") {
+		cmd.Args = append(cmd.Args, "--dry-run")
+	}
+	cmd.Stderr = io.MultiWriter(m.Err, stderr)
+	out, err := m.spoolFile("render-*.json")
+	if err != nil {
+		return nil, err
+	}
+	defer out.Close()
+	cmd.Stdout = out
+	err = cmd.Run()
+	if err != nil {
+		return nil, errors.Wrapf(classifyHelmError(err, stderr.String()), "could not render release %s", release)
+	}
+	if err := rewind(out); err != nil {
+		return nil, errors.Wrapf(err, "could not read the manifest of release %s", release)
+	}
+
+	manifest, err := m.spoolFile("render-*.yaml")
+	if err != nil {
+		return nil, err
+	}
+	err = decodeJSONObject(out, nil, map[string]io.Writer{"manifest": manifest})
+	if err == nil {
+		err = rewind(manifest)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not read the manifest of release %s", release)
+	}
+	return manifest, nil
+}
+
+// checkPermissions checks with kubectl auth can-i that the credentials of the bundle can manage
+// every kind of resource of the rendered release
+func (m *Mixin) checkPermissions(ctx context.Context, release string, namespace string, manifest io.Reader) error {
+	fmt.Fprintf(m.Out, "Checking the permissions required by release %s\n", release)
+
+	resources, err := manifestResources(manifest, namespace)
+	if err != nil {
+		return err
+	}
+
+	var missing []string
+	for _, r := range resources {
+		for _, verb := range preflightVerbs {
+			allowed, err := m.canI(ctx, verb, r)
+			if err != nil {
+				return err
+			}
+			if !allowed {
+				permission := fmt.Sprintf("%s %s", verb, r.resourceType())
+				if r.Namespace != "" {
+					permission += " in namespace " + r.Namespace
+				}
+				missing = append(missing, permission)
+			}
+		}
+	}
+	if len(missing) > 0 {
+		return errors.Errorf("missing permissions to deploy release %s:\n\t* %s", release, strings.Join(missing, "\n\t* "))
+	}
+	return nil
+}
+
+// canI asks the cluster if the credentials of the bundle can perform the verb on a kind of resource
+func (m *Mixin) canI(ctx context.Context, verb string, r manifestResource) (bool, error) {
+	cmd := m.NewCommand(ctx, "kubectl", "auth", "can-i", verb, r.resourceType())
+	if r.Namespace != "" {
+		cmd.Args = append(cmd.Args, "--namespace", r.Namespace)
+	}
+	out, err := cmd.Output()
+	// the answer is on the last line, after any warning about the resource
+	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+	answer := strings.TrimSpace(lines[len(lines)-1])
+	if answer == "yes" {
+		return true, nil
+	}
+	// kubectl prints no and exits with 1 when the action is not allowed
+	if answer == "no" {
+		return false, nil
+	}
+	if err == nil {
+		err = errors.Errorf("unexpected answer %q", answer)
+	}
+	return false, errors.Wrapf(err, "could not check if %s %s is allowed", verb, r.resourceType())
+}
@@ -0,0 +1,100 @@
+package helm3
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+
+	"get.porter.sh/porter/pkg/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+const preflightManifest = `---
+# Source: mysql/templates/service.yaml
+apiVersion: v1
+kind: Service
+metadata:
+  name: mysql
+---
+apiVersion: apps/v1
+kind: StatefulSet
+metadata:
+  name: mysql
+  namespace: db
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRole
+metadata:
+  name: mysql-metrics
+`
+
+func TestManifestResources(t *testing.T) {
+	resources, err := manifestResources(strings.NewReader(preflightManifest), "db")
+	require.NoError(t, err)
+	assert.Equal(t, []manifestResource{
+		{Kind: "ClusterRole", Group: "rbac.authorization.k8s.io"},
+		{Kind: "Service", Namespace: "db"},
+		{Kind: "StatefulSet", Group: "apps", Namespace: "db"},
+	}, resources)
+	assert.Equal(t, "clusterrole.rbac.authorization.k8s.io", resources[0].resourceType())
+	assert.Equal(t, "service", resources[1].resourceType())
+}
+
+// setPreflightOutput prints the dry run of the release, followed by the answer of kubectl auth can-i,
+// since every mocked command prints the same output
+func setPreflightOutput(t *testing.T, answer string) {
+	b, err := json.Marshal(map[string]string{"manifest": "apiVersion: apps/v1\nkind: StatefulSet\nmetadata:\n  name: mysql\n"})
+	require.NoError(t, err)
+	os.Setenv(test.ExpectedCommandOutputEnv, string(b)+"\n"+answer)
+}
+
+func TestMixin_InstallCheckPermissions(t *testing.T) {
+	ctx := context.Background()
+	defer os.Unsetenv(test.ExpectedCommandEnv)
+	defer os.Unsetenv(test.ExpectedCommandOutputEnv)
+	os.Setenv(test.ExpectedCommandEnv, strings.Join([]string{
+		"helm3 upgrade --install mysql stable/mysql --namespace db --atomic --create-namespace --output json --dry-run",
+		"kubectl auth can-i get statefulset.apps --namespace db",
+		"kubectl auth can-i create statefulset.apps --namespace db",
+		"kubectl auth can-i patch statefulset.apps --namespace db",
+		"helm3 upgrade --install mysql stable/mysql --namespace db --atomic --create-namespace --output json",
+	}, "\n"))
+
+	action := InstallAction{Steps: []InstallStep{{
+		InstallArguments: InstallArguments{
+			Step:             Step{Description: "Install MySQL"},
+			Name:             "mysql",
+			Chart:            "stable/mysql",
+			Namespace:        "db",
+			CheckPermissions: true,
+		},
+	}}}
+	b, _ := yaml.Marshal(action)
+
+	t.Run("allowed", func(t *testing.T) {
+		setPreflightOutput(t, "yes")
+		h := NewTestMixin(t)
+		h.In = bytes.NewReader(b)
+
+		err := h.Install(ctx)
+		require.NoError(t, err)
+		assert.Contains(t, h.TestContext.GetOutput(), "Checking the permissions required by release mysql")
+	})
+
+	t.Run("denied", func(t *testing.T) {
+		setPreflightOutput(t, "no")
+		h := NewTestMixin(t)
+		h.In = bytes.NewReader(b)
+
+		err := h.Install(ctx)
+		require.EqualError(t, err, "missing permissions to deploy release mysql:\n"+
+			"\t* get statefulset.apps in namespace db\n"+
+			"\t* create statefulset.apps in namespace db\n"+
+			"\t* patch statefulset.apps in namespace db")
+	})
+}
@@ -0,0 +1,174 @@
+package helm3
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8s "k8s.io/client-go/kubernetes"
+)
+
+// progressInterval is the time between two reports of the workloads that a release waits for
+var progressInterval = 30 * time.Second
+
+const (
+	// releaseNameAnnotation is set by helm on the resources of a release
+	releaseNameAnnotation = "meta.helm.sh/release-name"
+	// managedByHelmSelector selects the resources created by helm
+	managedByHelmSelector = "app.kubernetes.io/managed-by=Helm"
+	// maxProgressPods is the number of pods reported for each workload that is not ready
+	maxProgressPods = 3
+)
+
+// waitingWorkload is a workload of a release that is not ready yet
+type waitingWorkload struct {
+	name     string
+	ready    int32
+	desired  int32
+	selector *metav1.LabelSelector
+}
+
+// watchProgress periodically prints the workloads of a release that are not ready, and their pods that are not
+// running, while helm waits for the release, until the returned function is called
+func (m *Mixin) watchProgress(ctx context.Context, release string, namespace string) func() {
+	client, err := m.getKubernetesClient()
+	if err != nil || m.quiet() {
+		return func() {}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(progressInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			summary, err := progressSummary(ctx, client, release, namespace)
+			if err != nil {
+				if m.tracing() {
+					fmt.Fprintf(m.Err, "could not read the progress of release %s: %s\n", release, err)
+				}
+				continue
+			}
+			if len(summary) > 0 && ctx.Err() == nil {
+				fmt.Fprintf(m.Out, "Waiting for release %s:\n\t* %s\n", release, strings.Join(summary, "\n\t* "))
+			}
+		}
+	}()
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+// progressSummary lists the deployments, statefulsets and daemonsets of a release that are not ready,
+// followed by their pods that are not ready and the reason why
+func progressSummary(ctx context.Context, client k8s.Interface, release string, namespace string) ([]string, error) {
+	workloads, err := waitingWorkloads(ctx, client, release, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	var summary []string
+	for _, w := range workloads {
+		summary = append(summary, fmt.Sprintf("%s %d/%d ready", w.name, w.ready, w.desired))
+		selector, err := metav1.LabelSelectorAsSelector(w.selector)
+		if err != nil || selector.Empty() {
+			continue
+		}
+		pods, err := client.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{LabelSelector: selector.String()})
+		if err != nil {
+			return nil, err
+		}
+		reported := 0
+		for _, pod := range pods.Items {
+			reason, ready := podReadiness(pod)
+			if ready {
+				continue
+			}
+			if reported == maxProgressPods {
+				break
+			}
+			summary = append(summary, fmt.Sprintf("pod/%s %s", pod.Name, reason))
+			reported++
+		}
+	}
+	return summary, nil
+}
+
+// waitingWorkloads returns the workloads of a release that have fewer ready replicas than desired
+func waitingWorkloads(ctx context.Context, client k8s.Interface, release string, namespace string) ([]waitingWorkload, error) {
+	opts := metav1.ListOptions{LabelSelector: managedByHelmSelector}
+	ofRelease := func(meta metav1.ObjectMeta) bool {
+		return meta.Annotations[releaseNameAnnotation] == release
+	}
+
+	var workloads []waitingWorkload
+	deployments, err := client.AppsV1().Deployments(namespace).List(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range deployments.Items {
+		if desired := replicas(d.Spec.Replicas); ofRelease(d.ObjectMeta) && d.Status.ReadyReplicas < desired {
+			workloads = append(workloads, waitingWorkload{"deployment/" + d.Name, d.Status.ReadyReplicas, desired, d.Spec.Selector})
+		}
+	}
+
+	statefulSets, err := client.AppsV1().StatefulSets(namespace).List(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range statefulSets.Items {
+		if desired := replicas(s.Spec.Replicas); ofRelease(s.ObjectMeta) && s.Status.ReadyReplicas < desired {
+			workloads = append(workloads, waitingWorkload{"statefulset/" + s.Name, s.Status.ReadyReplicas, desired, s.Spec.Selector})
+		}
+	}
+
+	daemonSets, err := client.AppsV1().DaemonSets(namespace).List(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	for _, ds := range daemonSets.Items {
+		if ofRelease(ds.ObjectMeta) && ds.Status.NumberReady < ds.Status.DesiredNumberScheduled {
+			workloads = append(workloads, waitingWorkload{"daemonset/" + ds.Name, ds.Status.NumberReady, ds.Status.DesiredNumberScheduled, ds.Spec.Selector})
+		}
+	}
+	return workloads, nil
+}
+
+// replicas returns the desired replicas of a workload, which default to 1
+func replicas(r *int32) int32 {
+	if r == nil {
+		return 1
+	}
+	return *r
+}
+
+// podReadiness returns why a pod is not ready, from its waiting containers or its scheduling,
+// and whether it is ready
+func podReadiness(pod corev1.Pod) (string, bool) {
+	for _, c := range pod.Status.Conditions {
+		if c.Type == corev1.PodReady && c.Status == corev1.ConditionTrue {
+			return "", true
+		}
+	}
+	for _, c := range append(pod.Status.InitContainerStatuses, pod.Status.ContainerStatuses...) {
+		if c.State.Waiting != nil && c.State.Waiting.Reason != "" {
+			return fmt.Sprintf("%s: %s", pod.Status.Phase, c.State.Waiting.Reason), false
+		}
+	}
+	for _, c := range pod.Status.Conditions {
+		if c.Type == corev1.PodScheduled && c.Status == corev1.ConditionFalse && c.Message != "" {
+			return fmt.Sprintf("%s: %s", pod.Status.Phase, c.Message), false
+		}
+	}
+	return string(pod.Status.Phase), false
+}
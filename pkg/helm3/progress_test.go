@@ -0,0 +1,98 @@
+package helm3
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	testclient "k8s.io/client-go/kubernetes/fake"
+)
+
+func releaseObjectMeta(name string, release string) metav1.ObjectMeta {
+	return metav1.ObjectMeta{
+		Name:        name,
+		Namespace:   "mysql",
+		Labels:      map[string]string{"app.kubernetes.io/managed-by": "Helm"},
+		Annotations: map[string]string{releaseNameAnnotation: release},
+	}
+}
+
+func progressClient() *testclient.Clientset {
+	three := int32(3)
+	selector := &metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}}
+	return testclient.NewSimpleClientset(
+		&appsv1.Deployment{
+			ObjectMeta: releaseObjectMeta("web", "porter-ci-mysql"),
+			Spec:       appsv1.DeploymentSpec{Replicas: &three, Selector: selector},
+			Status:     appsv1.DeploymentStatus{ReadyReplicas: 1},
+		},
+		&appsv1.Deployment{
+			ObjectMeta: releaseObjectMeta("other", "another-release"),
+			Spec:       appsv1.DeploymentSpec{Replicas: &three},
+		},
+		&appsv1.StatefulSet{
+			ObjectMeta: releaseObjectMeta("db", "porter-ci-mysql"),
+			Status:     appsv1.StatefulSetStatus{ReadyReplicas: 1},
+		},
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "web-1", Namespace: "mysql", Labels: map[string]string{"app": "web"}},
+			Status: corev1.PodStatus{
+				Phase:      corev1.PodRunning,
+				Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}},
+			},
+		},
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "web-2", Namespace: "mysql", Labels: map[string]string{"app": "web"}},
+			Status: corev1.PodStatus{
+				Phase: corev1.PodPending,
+				ContainerStatuses: []corev1.ContainerStatus{{
+					State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "ImagePullBackOff"}},
+				}},
+			},
+		},
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "web-3", Namespace: "mysql", Labels: map[string]string{"app": "web"}},
+			Status: corev1.PodStatus{
+				Phase: corev1.PodPending,
+				Conditions: []corev1.PodCondition{{
+					Type: corev1.PodScheduled, Status: corev1.ConditionFalse, Message: "0/3 nodes are available: 3 Insufficient cpu.",
+				}},
+			},
+		},
+	)
+}
+
+func TestProgressSummary(t *testing.T) {
+	summary, err := progressSummary(context.Background(), progressClient(), "porter-ci-mysql", "mysql")
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"deployment/web 1/3 ready",
+		"pod/web-2 Pending: ImagePullBackOff",
+		"pod/web-3 Pending: 0/3 nodes are available: 3 Insufficient cpu.",
+	}, summary, "the statefulset has the default of one replica and is ready")
+}
+
+func TestMixin_WatchProgress(t *testing.T) {
+	defer func(interval time.Duration) { progressInterval = interval }(progressInterval)
+	progressInterval = 10 * time.Millisecond
+
+	m := NewTestMixin(t)
+	m.ClientFactory = &staticKubernetesFactory{client: progressClient()}
+
+	stop := m.watchProgress(context.Background(), "porter-ci-mysql", "mysql")
+	time.Sleep(50 * time.Millisecond)
+	stop()
+
+	output := m.TestContext.GetOutput()
+	assert.Contains(t, output, "Waiting for release porter-ci-mysql:\n\t* deployment/web 1/3 ready\n\t* pod/web-2 Pending: ImagePullBackOff\n")
+	assert.NotContains(t, output, "deployment/other")
+
+	// nothing is printed once the release is no longer watched
+	time.Sleep(30 * time.Millisecond)
+	assert.Equal(t, output, m.TestContext.GetOutput())
+}
@@ -0,0 +1,162 @@
+package helm3
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+// keepResourcePolicy is the resource policy of resources that helm never deletes
+const keepResourcePolicy = "keep"
+
+// manifestObject identifies a resource of a release manifest
+type manifestObject struct {
+	Kind      string
+	Namespace string
+	Name      string
+}
+
+func (o manifestObject) String() string {
+	if o.Namespace == "" {
+		return o.Kind + "/" + o.Name
+	}
+	return fmt.Sprintf("%s %s/%s", o.Kind, o.Namespace, o.Name)
+}
+
+// matches checks if the resource is protected by a KIND or KIND/NAME entry of protect, the kind is case insensitive
+func (o manifestObject) matches(protected string) bool {
+	kind, name, hasName := strings.Cut(protected, "/")
+	if !strings.EqualFold(kind, o.Kind) {
+		return false
+	}
+	return !hasName || name == o.Name
+}
+
+// validateProtect checks that every protected resource is a KIND or KIND/NAME
+func validateProtect(protect []string) error {
+	for _, protected := range protect {
+		kind, name, hasName := strings.Cut(protected, "/")
+		if kind == "" || (hasName && (name == "" || strings.Contains(name, "/"))) {
+			return errors.Errorf("invalid protected resource %q, expected KIND or KIND/NAME such as PersistentVolumeClaim or Secret/mysql-credentials", protected)
+		}
+	}
+	return nil
+}
+
+// manifestObjects returns the resources of a manifest, and whether helm keeps each one when it is removed from the chart.
+// Resources without a namespace are deployed to the namespace of the release, except the cluster-scoped ones.
+func manifestObjects(manifest io.Reader, namespace string) (map[manifestObject]bool, error) {
+	objects := make(map[manifestObject]bool)
+	err := readManifestDocuments(manifest, func(doc manifestDocument) error {
+		var resource struct {
+			Kind     string `yaml:"kind"`
+			Metadata struct {
+				Name        string            `yaml:"name"`
+				Namespace   string            `yaml:"namespace"`
+				Annotations map[string]string `yaml:"annotations"`
+			} `yaml:"metadata"`
+		}
+		if err := yaml.Unmarshal([]byte(doc.content), &resource); err != nil {
+			return errors.Wrap(err, "could not parse the manifest")
+		}
+		if resource.Kind == "" {
+			return nil
+		}
+		o := manifestObject{Kind: resource.Kind, Namespace: resource.Metadata.Namespace, Name: resource.Metadata.Name}
+		if isClusterScopedKind(o.Kind) {
+			o.Namespace = ""
+		} else if o.Namespace == "" {
+			o.Namespace = namespace
+		}
+		objects[o] = resource.Metadata.Annotations["helm.sh/resource-policy"] == keepResourcePolicy
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return objects, nil
+}
+
+// deployedObjects returns the resources of the current revision of a release, reading its manifest as it is
+// printed by helm
+func (m *Mixin) deployedObjects(ctx context.Context, release string, namespace string) (map[manifestObject]bool, error) {
+	var objects map[manifestObject]bool
+	err := m.readManifest(ctx, release, namespace, 0, func(manifest io.Reader) error {
+		var err error
+		objects, err = manifestObjects(manifest, namespace)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if objects == nil {
+		// the manifest is not read in plan mode
+		objects = make(map[manifestObject]bool)
+	}
+	return objects, nil
+}
+
+// checkProtectedResources renders the upgrade of a release with a server dry run, and fails when it would delete a
+// protected resource of the deployed release, or replace it because the upgrade is forced
+func (m *Mixin) checkProtectedResources(ctx context.Context, release UpgradeArguments, protect []string) error {
+	exists, err := m.releaseExists(ctx, release.Name, release.Namespace)
+	if err != nil || !exists {
+		return err
+	}
+
+	deployed, err := m.deployedObjects(ctx, release.Name, release.Namespace)
+	if err != nil {
+		return err
+	}
+
+	cmd, err := m.upgradeCommand(ctx, release)
+	if err != nil {
+		return err
+	}
+	if !hasFlag(cmd.Args, "--dry-run") {
+		cmd.Args = append(cmd.Args, "--dry-run=server")
+	}
+	forced := hasFlag(cmd.Args, "--force") || hasFlag(cmd.Args, "--force-replace")
+	manifest, err := m.renderManifest(release.Name, cmd)
+	if err != nil {
+		return err
+	}
+	upgraded, err := manifestObjects(manifest, release.Namespace)
+	if err != nil {
+		return errors.Wrapf(err, "could not read the resources of the upgrade of release %s", release.Name)
+	}
+
+	violations := protectedViolations(deployed, upgraded, protect, forced)
+	if len(violations) == 0 {
+		return nil
+	}
+	return errors.Errorf("the upgrade of release %s was aborted to protect its resources:\n\t* %s",
+		release.Name, strings.Join(violations, "\n\t* "))
+}
+
+// protectedViolations compares the resources of a deployed release with the resources of its upgrade, and returns
+// the protected resources that the upgrade would delete or, when it is forced, replace
+func protectedViolations(deployed map[manifestObject]bool, upgraded map[manifestObject]bool, protect []string, forced bool) []string {
+	var violations []string
+	for o, kept := range deployed {
+		protected := false
+		for _, p := range protect {
+			protected = protected || o.matches(p)
+		}
+		if !protected {
+			continue
+		}
+		if _, ok := upgraded[o]; !ok && !kept {
+			violations = append(violations, o.String()+" would be deleted")
+		} else if ok && forced {
+			violations = append(violations, o.String()+" would be replaced by the forced upgrade")
+		}
+	}
+	sort.Strings(violations)
+	return violations
+}
@@ -0,0 +1,87 @@
+package helm3
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const deployedManifest = `---
+# Source: mysql/templates/secrets.yaml
+apiVersion: v1
+kind: Secret
+metadata:
+  name: mysql-credentials
+---
+# Source: mysql/templates/pvc.yaml
+apiVersion: v1
+kind: PersistentVolumeClaim
+metadata:
+  name: mysql-data
+---
+# Source: mysql/templates/backup-pvc.yaml
+apiVersion: v1
+kind: PersistentVolumeClaim
+metadata:
+  name: mysql-backup
+  namespace: backups
+  annotations:
+    helm.sh/resource-policy: keep
+---
+# Source: mysql/templates/configmap.yaml
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: mysql-config
+---
+# Source: mysql/templates/clusterrole.yaml
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRole
+metadata:
+  name: mysql-metrics
+`
+
+const upgradedManifest = `---
+# Source: mysql/templates/secrets.yaml
+apiVersion: v1
+kind: Secret
+metadata:
+  name: mysql-credentials
+`
+
+func TestManifestObjects(t *testing.T) {
+	objects, err := manifestObjects(strings.NewReader(deployedManifest), "db")
+	require.NoError(t, err)
+	assert.Equal(t, map[manifestObject]bool{
+		{Kind: "Secret", Namespace: "db", Name: "mysql-credentials"}:                false,
+		{Kind: "PersistentVolumeClaim", Namespace: "db", Name: "mysql-data"}:        false,
+		{Kind: "PersistentVolumeClaim", Namespace: "backups", Name: "mysql-backup"}: true,
+		{Kind: "ConfigMap", Namespace: "db", Name: "mysql-config"}:                  false,
+		{Kind: "ClusterRole", Name: "mysql-metrics"}:                                false,
+	}, objects)
+}
+
+func TestProtectedViolations(t *testing.T) {
+	deployed, err := manifestObjects(strings.NewReader(deployedManifest), "db")
+	require.NoError(t, err)
+	upgraded, err := manifestObjects(strings.NewReader(upgradedManifest), "db")
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"PersistentVolumeClaim db/mysql-data would be deleted"},
+		protectedViolations(deployed, upgraded, []string{"persistentvolumeclaim", "Secret/mysql-credentials"}, false))
+	assert.Equal(t, []string{
+		"PersistentVolumeClaim db/mysql-data would be deleted",
+		"Secret db/mysql-credentials would be replaced by the forced upgrade",
+	}, protectedViolations(deployed, upgraded, []string{"PersistentVolumeClaim", "Secret/mysql-credentials"}, true))
+	assert.Empty(t, protectedViolations(deployed, upgraded, []string{"Secret/other"}, true))
+}
+
+func TestValidateProtect(t *testing.T) {
+	require.NoError(t, validateProtect([]string{"PersistentVolumeClaim", "Secret/mysql-credentials"}))
+	require.EqualError(t, validateProtect([]string{"Secret/"}),
+		`invalid protected resource "Secret/", expected KIND or KIND/NAME such as PersistentVolumeClaim or Secret/mysql-credentials`)
+	require.Error(t, validateProtect([]string{"/mysql"}))
+	require.Error(t, validateProtect([]string{"Secret/db/mysql"}))
+}
@@ -0,0 +1,38 @@
+package helm3
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+)
+
+// ProvenanceVerification checks the provenance file of a packaged chart, so that verifying an artifact can be
+// a gated custom action of its own
+type ProvenanceVerification struct {
+	// Chart is the path of the packaged chart, its provenance file is expected next to it with the .prov extension
+	Chart string `yaml:"chart"`
+	// Keyring is the path of the public keyring used to verify the signature, helm uses its default keyring when empty
+	Keyring string `yaml:"keyring,omitempty"`
+}
+
+// verifyProvenance runs helm verify against a packaged chart, failing when the chart can't be verified
+func (m *Mixin) verifyProvenance(ctx context.Context, step ExecuteStep) (string, error) {
+	verify := *step.Verify
+	if len(step.Arguments) > 0 || step.Plugin != "" {
+		return "", errors.New("verify cannot be set together with arguments or plugin")
+	}
+	if verify.Chart == "" {
+		return "", errors.New("verify requires the path of a packaged chart")
+	}
+
+	cmd := m.helmCommand(ctx, "verify", verify.Chart)
+	if verify.Keyring != "" {
+		cmd.Args = append(cmd.Args, "--keyring", verify.Keyring)
+	}
+
+	err := m.runCommand(cmd)
+	if err != nil {
+		return "", errors.Wrapf(err, "provenance verification of chart %s failed", verify.Chart)
+	}
+	return "", nil
+}
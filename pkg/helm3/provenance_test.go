@@ -0,0 +1,85 @@
+package helm3
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"testing"
+
+	"get.porter.sh/porter/pkg/test"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestMixin_ExecuteVerify(t *testing.T) {
+	ctx := context.Background()
+	defer os.Unsetenv(test.ExpectedCommandEnv)
+	os.Setenv(test.ExpectedCommandEnv, "helm3 verify ./charts/mysql-9.4.1.tgz --keyring /cnab/app/keys/pubring.gpg")
+
+	executeAction := Action{
+		Steps: []ExecuteSteps{
+			{
+				ExecuteStep: ExecuteStep{
+					Step:   Step{Description: "Verify MySQL"},
+					Verify: &ProvenanceVerification{Chart: "./charts/mysql-9.4.1.tgz", Keyring: "/cnab/app/keys/pubring.gpg"},
+				},
+			},
+		},
+	}
+	b, _ := yaml.Marshal(executeAction)
+
+	h := NewTestMixin(t)
+	h.In = bytes.NewReader(b)
+
+	err := h.Execute(ctx)
+	require.NoError(t, err)
+}
+
+func TestMixin_ExecuteVerifyFailed(t *testing.T) {
+	ctx := context.Background()
+	defer os.Unsetenv(test.ExpectedCommandEnv)
+	defer os.Unsetenv(test.ExpectedCommandExitCodeEnv)
+	os.Setenv(test.ExpectedCommandEnv, "helm3 verify ./charts/mysql-9.4.1.tgz")
+	os.Setenv(test.ExpectedCommandExitCodeEnv, "1")
+
+	executeAction := Action{
+		Steps: []ExecuteSteps{
+			{
+				ExecuteStep: ExecuteStep{
+					Step:   Step{Description: "Verify MySQL"},
+					Verify: &ProvenanceVerification{Chart: "./charts/mysql-9.4.1.tgz"},
+				},
+			},
+		},
+	}
+	b, _ := yaml.Marshal(executeAction)
+
+	h := NewTestMixin(t)
+	h.In = bytes.NewReader(b)
+
+	err := h.Execute(ctx)
+	require.EqualError(t, err, "provenance verification of chart ./charts/mysql-9.4.1.tgz failed: exit status 1")
+}
+
+func TestMixin_ExecuteMultipleOperations(t *testing.T) {
+	ctx := context.Background()
+
+	executeAction := Action{
+		Steps: []ExecuteSteps{
+			{
+				ExecuteStep: ExecuteStep{
+					Step:           Step{Description: "Verify MySQL"},
+					DependencyList: &DependencyList{Chart: "./charts/mysql"},
+					Verify:         &ProvenanceVerification{Chart: "./charts/mysql-9.4.1.tgz"},
+				},
+			},
+		},
+	}
+	b, _ := yaml.Marshal(executeAction)
+
+	h := NewTestMixin(t)
+	h.In = bytes.NewReader(b)
+
+	err := h.Execute(ctx)
+	require.EqualError(t, err, "only one of revisionDiff, dependencyList, verify, promote, status, getHooks, exists, gcHistory and diagnostics can be set, got dependencyList, verify")
+}
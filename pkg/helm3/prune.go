@@ -0,0 +1,194 @@
+package helm3
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Prune deletes the resources of a release that its chart no longer renders after an upgrade. Helm deletes them
+// itself, except the resources with the keep resource policy and the custom resource definitions of the crds
+// directory of the chart, which are only deleted when their kind is allowed.
+type Prune struct {
+	// Kinds are the resources that can be pruned, KIND or KIND/NAME such as CustomResourceDefinition
+	Kinds []string `yaml:"kinds"`
+	// Output is the name of the output where the pruned resources are written as json
+	Output string `yaml:"output,omitempty"`
+}
+
+// prunedResource is a resource deleted by a prune, as reported in its output
+type prunedResource struct {
+	Release   string `json:"release"`
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+}
+
+// validate checks that the resources that can be pruned are explicitly allowed
+func (p Prune) validate() error {
+	if len(p.Kinds) == 0 {
+		return errors.New("prune requires the kinds of the resources that can be pruned")
+	}
+	for _, kind := range p.Kinds {
+		k, name, hasName := strings.Cut(kind, "/")
+		if k == "" || (hasName && (name == "" || strings.Contains(name, "/"))) {
+			return errors.Errorf("invalid prune kind %q, expected KIND or KIND/NAME such as CustomResourceDefinition", kind)
+		}
+	}
+	return nil
+}
+
+// releaseObjects returns the resources of the deployed release, before it is upgraded, or nil when it doesn't exist
+func (m *Mixin) releaseObjects(ctx context.Context, release UpgradeArguments) (map[manifestObject]bool, error) {
+	exists, err := m.releaseExists(ctx, release.Name, release.Namespace)
+	if err != nil || !exists {
+		return nil, err
+	}
+	return m.prunableObjects(ctx, release.Name, release.Namespace)
+}
+
+// prunableObjects returns the resources of the current revision of a release, with the custom resource definitions
+// of its chart that helm leaves out of the manifest
+func (m *Mixin) prunableObjects(ctx context.Context, release string, namespace string) (map[manifestObject]bool, error) {
+	objects, err := m.deployedObjects(ctx, release, namespace)
+	if err != nil {
+		return nil, err
+	}
+	crds, err := m.chartCRDs(ctx, release, namespace)
+	if err != nil {
+		return nil, err
+	}
+	for o := range crds {
+		objects[o] = true
+	}
+	return objects, nil
+}
+
+// chartCRDs returns the custom resource definitions of the crds directory of the chart stored with a release,
+// which helm installs without adding them to the manifest of the release, and never deletes
+func (m *Mixin) chartCRDs(ctx context.Context, release string, namespace string) (map[manifestObject]bool, error) {
+	cmd := m.helmCommand(ctx, "status", release, "--output", "json")
+	if namespace != "" {
+		cmd.Args = append(cmd.Args, "--namespace", namespace)
+	}
+	cmd.Stderr = m.Err
+
+	// the files of the chart are decoded one at a time, and the manifest of the release is skipped as it is read
+	crds := make(map[manifestObject]bool)
+	readFile := func(dec *json.Decoder) error {
+		var f struct {
+			Name string `json:"name"`
+			Data []byte `json:"data"`
+		}
+		if err := dec.Decode(&f); err != nil {
+			return err
+		}
+		// the same files as the crds helm installs, see CRDObjects of the helm chart package
+		if !strings.HasPrefix(f.Name, "crds/") || !hasManifestExtension(f.Name) {
+			return nil
+		}
+		objects, err := manifestObjects(bytes.NewReader(f.Data), "")
+		if err != nil {
+			return errors.Wrapf(err, "could not read %s of the chart of release %s", f.Name, release)
+		}
+		for o := range objects {
+			crds[o] = true
+		}
+		return nil
+	}
+	err := readOutput(cmd, func(r io.Reader) error {
+		return decodeJSONObject(r, jsonFields{
+			"chart": func(dec *json.Decoder) error {
+				return decodeJSONFields(dec, jsonFields{
+					"files": func(dec *json.Decoder) error { return decodeJSONArray(dec, readFile) },
+				})
+			},
+		}, map[string]io.Writer{"manifest": io.Discard})
+	})
+	if err != nil {
+		prettyCmd := fmt.Sprintf("%s %s", cmd.Path, formatPlannedCommand(cmd.Args))
+		return nil, errors.Wrapf(err, "could not read the status of release %s, %s", release, prettyCmd)
+	}
+	return crds, nil
+}
+
+// hasManifestExtension checks if a file of a chart is a yaml or json manifest
+func hasManifestExtension(name string) bool {
+	switch path.Ext(name) {
+	case ".yaml", ".yml", ".json":
+		return true
+	}
+	return false
+}
+
+// pruneOrphans deletes the resources of the previous revision of an upgraded release that it no longer renders,
+// and that helm kept, when they are allowed by the prune and not protected
+func (m *Mixin) pruneOrphans(ctx context.Context, release UpgradeArguments, previous map[manifestObject]bool, prune Prune, protect []string) ([]prunedResource, error) {
+	if len(previous) == 0 {
+		return nil, nil
+	}
+	current, err := m.prunableObjects(ctx, release.Name, release.Namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	var pruned []prunedResource
+	for _, o := range orphanedObjects(previous, current, prune.Kinds, protect) {
+		cmd := m.NewCommand(ctx, "kubectl", "delete", o.Kind, o.Name, "--ignore-not-found")
+		if o.Namespace != "" {
+			cmd.Args = append(cmd.Args, "--namespace", o.Namespace)
+		}
+		err = m.runCommand(cmd)
+		if err != nil {
+			return pruned, errors.Wrapf(err, "could not prune %s of release %s", o, release.Name)
+		}
+		fmt.Fprintf(m.Out, "Pruned %s of release %s\n", o, release.Name)
+		pruned = append(pruned, prunedResource{Release: release.Name, Kind: o.Kind, Namespace: o.Namespace, Name: o.Name})
+	}
+	return pruned, nil
+}
+
+// orphanedObjects returns the resources kept by helm that the upgrade of a release no longer renders,
+// which match one of the kinds and none of the protected resources, sorted
+func orphanedObjects(previous map[manifestObject]bool, current map[manifestObject]bool, kinds []string, protect []string) []manifestObject {
+	matches := func(o manifestObject, refs []string) bool {
+		for _, ref := range refs {
+			if o.matches(ref) {
+				return true
+			}
+		}
+		return false
+	}
+
+	var orphaned []manifestObject
+	for o, kept := range previous {
+		if _, ok := current[o]; ok || !kept {
+			continue
+		}
+		if matches(o, kinds) && !matches(o, protect) {
+			orphaned = append(orphaned, o)
+		}
+	}
+	sort.Slice(orphaned, func(i, j int) bool { return orphaned[i].String() < orphaned[j].String() })
+	return orphaned
+}
+
+// writePrunedOutput writes the resources pruned by a step to the output of its prune
+func (m *Mixin) writePrunedOutput(prune Prune, pruned []prunedResource) error {
+	if pruned == nil {
+		pruned = []prunedResource{}
+	}
+	b, err := json.Marshal(pruned)
+	if err != nil {
+		return errors.Wrap(err, "could not format the pruned resources")
+	}
+	err = m.writeOutput(HelmOutput{Name: prune.Output}, b)
+	return errors.Wrapf(err, "unable to write output '%s'", prune.Output)
+}
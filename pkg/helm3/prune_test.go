@@ -0,0 +1,70 @@
+package helm3
+
+import (
+	"context"
+	"encoding/base64"
+	"os"
+	"strings"
+	"testing"
+
+	"get.porter.sh/porter/pkg/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOrphanedObjects(t *testing.T) {
+	crd := manifestObject{Kind: "CustomResourceDefinition", Namespace: "db", Name: "backups.example.com"}
+	pvc := manifestObject{Kind: "PersistentVolumeClaim", Namespace: "db", Name: "data"}
+	configMap := manifestObject{Kind: "ConfigMap", Namespace: "db", Name: "settings"}
+	service := manifestObject{Kind: "Service", Namespace: "db", Name: "mysql"}
+
+	previous := map[manifestObject]bool{crd: true, pvc: true, configMap: false, service: false}
+	current := map[manifestObject]bool{service: false}
+
+	assert.Equal(t, []manifestObject{crd, pvc},
+		orphanedObjects(previous, current, []string{"customresourcedefinition", "PersistentVolumeClaim"}, nil),
+		"resources deleted by helm are not pruned")
+	assert.Equal(t, []manifestObject{crd},
+		orphanedObjects(previous, current, []string{"CustomResourceDefinition", "PersistentVolumeClaim"}, []string{"PersistentVolumeClaim/data"}),
+		"protected resources are not pruned")
+	assert.Empty(t, orphanedObjects(previous, current, []string{"CustomResourceDefinition/restores.example.com"}, nil))
+}
+
+func TestPrune_Validate(t *testing.T) {
+	require.NoError(t, Prune{Kinds: []string{"CustomResourceDefinition", "PersistentVolumeClaim/data"}}.validate())
+	require.EqualError(t, Prune{}.validate(), "prune requires the kinds of the resources that can be pruned")
+	require.EqualError(t, Prune{Kinds: []string{"PersistentVolumeClaim/"}}.validate(),
+		`invalid prune kind "PersistentVolumeClaim/", expected KIND or KIND/NAME such as CustomResourceDefinition`)
+}
+
+func TestMixin_PruneOrphans(t *testing.T) {
+	ctx := context.Background()
+	defer os.Unsetenv(test.ExpectedCommandEnv)
+	defer os.Unsetenv(test.ExpectedCommandOutputEnv)
+	os.Setenv(test.ExpectedCommandEnv, strings.Join([]string{
+		"helm3 get manifest mysql --namespace db",
+		"helm3 status mysql --output json --namespace db",
+		"kubectl delete CustomResourceDefinition backups.example.com --ignore-not-found",
+	}, "\n"))
+	// helm prints the same output for both commands: a manifest without resources, and the status of a release
+	// whose chart has the restores.example.com custom resource definition in its crds directory
+	restores := base64.StdEncoding.EncodeToString([]byte(`apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: restores.example.com
+`))
+	os.Setenv(test.ExpectedCommandOutputEnv, `{"chart":{"files":[{"name":"crds/restores.yaml","data":"`+restores+`"},{"name":"README.md","data":"IyBNeVNRTA=="}]}}`)
+
+	previous := map[manifestObject]bool{
+		{Kind: "CustomResourceDefinition", Name: "backups.example.com"}:  true,
+		{Kind: "CustomResourceDefinition", Name: "restores.example.com"}: true,
+		{Kind: "Service", Namespace: "db", Name: "mysql"}:                false,
+	}
+	release := UpgradeArguments{Name: "mysql", Namespace: "db"}
+
+	h := NewTestMixin(t)
+	pruned, err := h.pruneOrphans(ctx, release, previous, Prune{Kinds: []string{"CustomResourceDefinition"}}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []prunedResource{{Release: "mysql", Kind: "CustomResourceDefinition", Name: "backups.example.com"}}, pruned)
+	assert.Contains(t, h.TestContext.GetOutput(), "Pruned CustomResourceDefinition/backups.example.com of release mysql\n")
+}
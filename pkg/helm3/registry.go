@@ -0,0 +1,129 @@
+package helm3
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// helmRegistryConfigEnv is the path of the file where helm stores registry logins
+const helmRegistryConfigEnv = "HELM_REGISTRY_CONFIG"
+
+// registryAuthEnv lists the registry auth helpers enabled when the invocation image was built
+const registryAuthEnv = "PORTER_HELM3_REGISTRY_AUTH"
+
+// registryAuthHelper exchanges cloud credentials for the username and password of a registry
+type registryAuthHelper struct {
+	// hosts matches the registry hosts served by the helper
+	hosts *regexp.Regexp
+	// credentials returns the username and password used to log into the registry
+	credentials func(m *Mixin, ctx context.Context, host string) (string, string, error)
+}
+
+// ecrHosts matches ECR registry hosts, capturing their region
+var ecrHosts = regexp.MustCompile(`^\d{12}\.dkr\.ecr(-fips)?\.([a-z0-9-]+)\.amazonaws\.com(\.cn)?$`)
+
+// registryAuthHelpers are the registry auth helpers that can be enabled with registryAuth
+var registryAuthHelpers = map[string]registryAuthHelper{
+	"ecr": {
+		hosts:       ecrHosts,
+		credentials: ecrCredentials,
+	},
+	"acr": {
+		hosts:       regexp.MustCompile(`^[a-z0-9]+\.azurecr\.(io|cn|us)$`),
+		credentials: acrCredentials,
+	},
+	"gar": {
+		hosts:       regexp.MustCompile(`^[a-z0-9-]+-docker\.pkg\.dev$`),
+		credentials: garCredentials,
+	},
+}
+
+// supportedRegistryAuthHelpers are the names of the registry auth helpers, in the order they are documented
+var supportedRegistryAuthHelpers = []string{"ecr", "acr", "gar"}
+
+// buildRegistryAuth writes the Dockerfile lines that enable the registry auth helpers
+func (m *Mixin) buildRegistryAuth(helpers []string) {
+	fmt.Fprintf(m.Out, "ENV %s=%s\n", registryAuthEnv, strings.Join(helpers, ","))
+	for _, helper := range helpers {
+		if helper == "ecr" {
+			// ECR tokens are exchanged with the AWS CLI
+			fmt.Fprintln(m.Out, "RUN "+aptInstall("awscli"))
+		}
+	}
+}
+
+// registryLogin logs into the registry hosting an oci:// chart when an enabled auth helper serves it
+func (m *Mixin) registryLogin(ctx context.Context, chart string) error {
+	if !strings.HasPrefix(chart, "oci://") {
+		return nil
+	}
+	host := strings.SplitN(strings.TrimPrefix(chart, "oci://"), "/", 2)[0]
+
+	for _, name := range strings.Split(m.Getenv(registryAuthEnv), ",") {
+		helper, ok := registryAuthHelpers[strings.TrimSpace(name)]
+		if !ok || !helper.hosts.MatchString(host) {
+			continue
+		}
+
+		cmd := m.helmCommand(ctx, "registry", "login", host)
+		if m.planMode() {
+			// don't exchange credentials when only printing the commands
+			cmd.Args = append(cmd.Args, "--username", "USERNAME", "--password-stdin")
+			return m.runCommand(cmd)
+		}
+
+		username, password, err := helper.credentials(m, ctx, host)
+		if err != nil {
+			return errors.Wrapf(err, "could not get the %s credentials for registry %s", name, host)
+		}
+		cmd.Args = append(cmd.Args, "--username", username, "--password-stdin")
+		cmd.Stdin = strings.NewReader(password)
+		return m.runCommand(cmd)
+	}
+	return nil
+}
+
+// ecrCredentials exchanges the AWS credentials in the environment for an ECR token
+func ecrCredentials(m *Mixin, ctx context.Context, host string) (string, string, error) {
+	region := ecrHosts.FindStringSubmatch(host)[2]
+	cmd := m.NewCommand(ctx, "aws", "ecr", "get-login-password", "--region", region)
+
+	output := &bytes.Buffer{}
+	cmd.Stdout = output
+	cmd.Stderr = m.Err
+	if m.tracing() {
+		fmt.Fprintf(m.Err, "%s %s\n", cmd.Path, formatPlannedCommand(cmd.Args))
+	}
+	if err := cmd.Run(); err != nil {
+		return "", "", err
+	}
+	return "AWS", strings.TrimSpace(output.String()), nil
+}
+
+// acrCredentials uses the Azure service principal in the environment, which ACR accepts as registry credentials
+func acrCredentials(m *Mixin, ctx context.Context, host string) (string, string, error) {
+	clientID := m.Getenv("AZURE_CLIENT_ID")
+	clientSecret := m.Getenv("AZURE_CLIENT_SECRET")
+	if clientID == "" || clientSecret == "" {
+		return "", "", errors.New("AZURE_CLIENT_ID and AZURE_CLIENT_SECRET must be set")
+	}
+	return clientID, clientSecret, nil
+}
+
+// garCredentials uses the Google service account key in the environment, which GAR accepts as a json key
+func garCredentials(m *Mixin, ctx context.Context, host string) (string, string, error) {
+	keyFile := m.Getenv("GOOGLE_APPLICATION_CREDENTIALS")
+	if keyFile == "" {
+		return "", "", errors.New("GOOGLE_APPLICATION_CREDENTIALS must be set")
+	}
+	key, err := m.FileSystem.ReadFile(keyFile)
+	if err != nil {
+		return "", "", errors.Wrapf(err, "could not read the service account key %s", keyFile)
+	}
+	return "_json_key", string(key), nil
+}
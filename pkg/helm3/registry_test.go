@@ -0,0 +1,102 @@
+package helm3
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"get.porter.sh/porter/pkg/test"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestMixin_InstallRegistryAuth(t *testing.T) {
+	testcases := []struct {
+		name         string
+		registryAuth string
+		chart        string
+		env          map[string]string
+		wantCommands []string
+		wantError    string
+	}{
+		{
+			name:         "ecr",
+			registryAuth: "ecr",
+			chart:        "oci://123456789012.dkr.ecr.eu-west-1.amazonaws.com/charts/mysql",
+			wantCommands: []string{
+				"aws ecr get-login-password --region eu-west-1",
+				"helm3 registry login 123456789012.dkr.ecr.eu-west-1.amazonaws.com --username AWS --password-stdin",
+				"helm3 upgrade --install mysql oci://123456789012.dkr.ecr.eu-west-1.amazonaws.com/charts/mysql --version 0.10.2 --atomic --create-namespace --output json",
+			},
+		},
+		{
+			name:         "acr",
+			registryAuth: "ecr,acr",
+			chart:        "oci://example.azurecr.io/charts/mysql",
+			env:          map[string]string{"AZURE_CLIENT_ID": "client-id", "AZURE_CLIENT_SECRET": "client-secret"},
+			wantCommands: []string{
+				"helm3 registry login example.azurecr.io --username client-id --password-stdin",
+				"helm3 upgrade --install mysql oci://example.azurecr.io/charts/mysql --version 0.10.2 --atomic --create-namespace --output json",
+			},
+		},
+		{
+			name:         "gar",
+			registryAuth: "gar",
+			chart:        "oci://europe-west1-docker.pkg.dev/example/charts/mysql",
+			env:          map[string]string{"GOOGLE_APPLICATION_CREDENTIALS": "/cnab/app/key.json"},
+			wantCommands: []string{
+				"helm3 registry login europe-west1-docker.pkg.dev --username _json_key --password-stdin",
+				"helm3 upgrade --install mysql oci://europe-west1-docker.pkg.dev/example/charts/mysql --version 0.10.2 --atomic --create-namespace --output json",
+			},
+		},
+		{
+			name:         "helper not enabled",
+			registryAuth: "ecr",
+			chart:        "oci://example.azurecr.io/charts/mysql",
+			wantCommands: []string{
+				"helm3 upgrade --install mysql oci://example.azurecr.io/charts/mysql --version 0.10.2 --atomic --create-namespace --output json",
+			},
+		},
+		{
+			name:         "missing credentials",
+			registryAuth: "acr",
+			chart:        "oci://example.azurecr.io/charts/mysql",
+			wantError:    "could not get the acr credentials for registry example.azurecr.io: AZURE_CLIENT_ID and AZURE_CLIENT_SECRET must be set",
+		},
+	}
+
+	defer os.Unsetenv(test.ExpectedCommandEnv)
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			os.Setenv(test.ExpectedCommandEnv, strings.Join(tc.wantCommands, "\n"))
+
+			action := InstallAction{Steps: []InstallStep{{
+				InstallArguments: InstallArguments{
+					Step:    Step{Description: "Install MySQL"},
+					Name:    "mysql",
+					Chart:   tc.chart,
+					Version: "0.10.2",
+				},
+			}}}
+			b, _ := yaml.Marshal(action)
+
+			h := NewTestMixin(t)
+			h.TestContext.AddTestFile("testdata/install-input.yaml", "/cnab/app/key.json")
+			h.Setenv(registryAuthEnv, tc.registryAuth)
+			for k, v := range tc.env {
+				h.Setenv(k, v)
+			}
+			h.In = bytes.NewReader(b)
+
+			err := h.Install(ctx)
+			if tc.wantError != "" {
+				require.EqualError(t, err, tc.wantError)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
@@ -0,0 +1,176 @@
+package helm3
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Release represents a single release of a step that installs or upgrades multiple releases.
+// Fields that are not set on the release default to the values set on the step.
+type Release struct {
+	Name      string            `yaml:"name"`
+	Chart     string            `yaml:"chart"`
+	Version   string            `yaml:"version,omitempty"`
+	Namespace string            `yaml:"namespace,omitempty"`
+	Set       map[string]string `yaml:"set,omitempty"`
+	Values    []string          `yaml:"values,omitempty"`
+}
+
+// releaseDefaults holds the fields of a step that are shared by all of its releases
+type releaseDefaults struct {
+	Namespace string
+	Version   string
+	Set       map[string]string
+	Values    []string
+}
+
+// apply returns the release with any unset fields defaulted from the step.
+// Values files of the step are passed before the values files of the release,
+// and set values of the release take precedence over the set values of the step.
+func (r Release) apply(defaults releaseDefaults) Release {
+	if r.Namespace == "" {
+		r.Namespace = defaults.Namespace
+	}
+	if r.Version == "" {
+		r.Version = defaults.Version
+	}
+
+	set := make(map[string]string, len(defaults.Set)+len(r.Set))
+	for k, v := range defaults.Set {
+		set[k] = v
+	}
+	for k, v := range r.Set {
+		set[k] = v
+	}
+	r.Set = set
+
+	values := make([]string, 0, len(defaults.Values)+len(r.Values))
+	values = append(values, defaults.Values...)
+	r.Values = append(values, r.Values...)
+
+	return r
+}
+
+// releaseExists checks if a release with the given name is already installed in the namespace, whatever its status
+func (m *Mixin) releaseExists(ctx context.Context, name string, namespace string) (bool, error) {
+	return m.releaseListed(ctx, name, namespace, "--all")
+}
+
+// releaseFailed checks if a release with the given name is installed in the namespace with the failed status
+func (m *Mixin) releaseFailed(ctx context.Context, name string, namespace string) (bool, error) {
+	return m.releaseListed(ctx, name, namespace, "--failed")
+}
+
+// releaseListCommand returns the helm list command that prints the name of a release when it has the release status of the flag
+func (m *Mixin) releaseListCommand(ctx context.Context, name string, namespace string, statusFlag string) *exec.Cmd {
+	cmd := m.helmCommand(ctx, "list", statusFlag, "--short", "--filter", fmt.Sprintf("^%s$", regexp.QuoteMeta(name)))
+	if namespace != "" {
+		cmd.Args = append(cmd.Args, "--namespace", namespace)
+	}
+	return cmd
+}
+
+// releaseListed checks if helm lists a release with the given name when filtering on a release status flag
+func (m *Mixin) releaseListed(ctx context.Context, name string, namespace string, statusFlag string) (bool, error) {
+	cmd := m.releaseListCommand(ctx, name, namespace, statusFlag)
+	output := &bytes.Buffer{}
+	cmd.Stdout = output
+	cmd.Stderr = m.Err
+
+	prettyCmd := fmt.Sprintf("%s %s", cmd.Path, formatPlannedCommand(cmd.Args))
+	if m.tracing() {
+		fmt.Fprintln(m.Err, prettyCmd)
+	}
+
+	err := cmd.Run()
+	if err != nil {
+		return false, errors.Wrapf(err, "could not check if release %s exists, %s", name, prettyCmd)
+	}
+
+	for _, line := range strings.Split(output.String(), "\n") {
+		if strings.TrimSpace(line) == name {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+const (
+	// installationNameEnv is set by porter to the name of the installation
+	installationNameEnv = "CNAB_INSTALLATION_NAME"
+	// maxReleaseNameLength is the longest release name accepted by helm
+	maxReleaseNameLength = 53
+)
+
+var invalidReleaseNameChars = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// generateReleaseName derives a release name from the installation name and the generateName of a step.
+// Names longer than helm accepts are truncated and suffixed with a hash of the full name, so that the same
+// installation always gets the same release name.
+func (m *Mixin) generateReleaseName(generateName string) (string, error) {
+	installation := m.Getenv(installationNameEnv)
+	if installation == "" {
+		return "", errors.Errorf("generateName requires the installation name from %s", installationNameEnv)
+	}
+
+	name := strings.ToLower(fmt.Sprintf("%s-%s", installation, generateName))
+	name = strings.Trim(invalidReleaseNameChars.ReplaceAllString(name, "-"), "-")
+	if len(name) <= maxReleaseNameLength {
+		return name, nil
+	}
+
+	hash := fmt.Sprintf("%x", sha256.Sum256([]byte(name)))[:8]
+	prefix := strings.TrimRight(name[:maxReleaseNameLength-len(hash)-1], "-")
+	return fmt.Sprintf("%s-%s", prefix, hash), nil
+}
+
+// resolveGeneratedName returns the release name generated for a step that sets generateName
+func (m *Mixin) resolveGeneratedName(generateName string, name string, releases int) (string, error) {
+	if name != "" || releases > 0 {
+		return "", errors.New("generateName cannot be set together with name or releases")
+	}
+
+	name, err := m.generateReleaseName(generateName)
+	if err != nil {
+		return "", err
+	}
+	fmt.Fprintf(m.Out, "Using the generated release name %s\n", name)
+	return name, nil
+}
+
+// validateRandomName checks that helm can generate the name of the release of an install step, which is only known
+// once the release is installed
+func (s InstallArguments) validateRandomName() error {
+	if s.Name != "" || s.GenerateName != "" || len(s.Releases) > 0 || len(s.Namespaces) > 0 {
+		return errors.New("randomName cannot be set together with name, generateName, releases or namespaces")
+	}
+	if s.SkipIfExists || s.FailIfExists || s.ReplaceFailed {
+		return errors.New("randomName cannot be set together with skipIfExists, failIfExists or replaceFailed")
+	}
+	return nil
+}
+
+// readReleaseName reads the name of a release from a file, written by an output of the step that installed it
+// and persisted with the state of the bundle. It returns false when the file does not exist.
+func (m *Mixin) readReleaseName(file string) (string, bool, error) {
+	exists, err := m.FileSystem.Exists(file)
+	if err != nil || !exists {
+		return "", false, errors.Wrapf(err, "could not read the release name from %s", file)
+	}
+	b, err := m.FileSystem.ReadFile(file)
+	if err != nil {
+		return "", false, errors.Wrapf(err, "could not read the release name from %s", file)
+	}
+	name := strings.TrimSpace(string(b))
+	if name == "" {
+		return "", false, errors.Errorf("the release name file %s is empty", file)
+	}
+	return name, true, nil
+}
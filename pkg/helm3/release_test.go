@@ -0,0 +1,218 @@
+package helm3
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"testing"
+
+	"get.porter.sh/porter/pkg/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestMixin_GenerateReleaseName(t *testing.T) {
+	testcases := []struct {
+		name         string
+		installation string
+		generateName string
+		wantName     string
+		wantError    string
+	}{
+		{name: "short name", installation: "shop", generateName: "mysql", wantName: "shop-mysql"},
+		{name: "sanitized name", installation: "My_Shop.prod", generateName: "mysql", wantName: "my-shop-prod-mysql"},
+		{name: "truncated name", installation: "a-very-long-installation-name-for-the-online-shop", generateName: "mysql",
+			wantName: "a-very-long-installation-name-for-the-online-1afec7db"},
+		{name: "missing installation", generateName: "mysql",
+			wantError: "generateName requires the installation name from CNAB_INSTALLATION_NAME"},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			h := NewTestMixin(t)
+			h.Setenv(installationNameEnv, tc.installation)
+
+			name, err := h.generateReleaseName(tc.generateName)
+			if tc.wantError != "" {
+				require.EqualError(t, err, tc.wantError)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantName, name)
+			assert.LessOrEqual(t, len(name), maxReleaseNameLength)
+		})
+	}
+}
+
+func TestMixin_InstallGenerateName(t *testing.T) {
+	ctx := context.Background()
+	defer os.Unsetenv(test.ExpectedCommandEnv)
+	os.Setenv(test.ExpectedCommandEnv, "helm3 upgrade --install shop-mysql stable/mysql --atomic --create-namespace --output json")
+
+	action := InstallAction{Steps: []InstallStep{{
+		InstallArguments: InstallArguments{
+			Step:         Step{Description: "Install MySQL"},
+			GenerateName: "mysql",
+			Chart:        "stable/mysql",
+		},
+	}}}
+	b, _ := yaml.Marshal(action)
+
+	h := NewTestMixin(t)
+	h.Setenv(installationNameEnv, "shop")
+	h.In = bytes.NewReader(b)
+
+	err := h.Install(ctx)
+	require.NoError(t, err)
+	assert.Contains(t, h.TestContext.GetOutput(), "Using the generated release name shop-mysql")
+}
+
+func TestMixin_UpgradeGenerateNameWithName(t *testing.T) {
+	ctx := context.Background()
+	action := UpgradeAction{Steps: []UpgradeStep{{
+		UpgradeArguments: UpgradeArguments{
+			Step:         Step{Description: "Upgrade MySQL"},
+			Name:         "mysql",
+			GenerateName: "mysql",
+			Chart:        "stable/mysql",
+		},
+	}}}
+	b, _ := yaml.Marshal(action)
+
+	h := NewTestMixin(t)
+	h.Setenv(installationNameEnv, "shop")
+	h.In = bytes.NewReader(b)
+
+	err := h.Upgrade(ctx)
+	require.EqualError(t, err, "generateName cannot be set together with name or releases")
+}
+
+func TestMixin_UninstallGenerateName(t *testing.T) {
+	ctx := context.Background()
+	defer os.Unsetenv(test.ExpectedCommandEnv)
+	os.Setenv(test.ExpectedCommandEnv, "helm3 uninstall shop-mysql")
+
+	action := UninstallAction{Steps: []UninstallStep{{
+		UninstallArguments: UninstallArguments{
+			Step:         Step{Description: "Uninstall MySQL"},
+			GenerateName: "mysql",
+		},
+	}}}
+	b, _ := yaml.Marshal(action)
+
+	h := NewTestMixin(t)
+	h.Setenv(installationNameEnv, "shop")
+	h.In = bytes.NewReader(b)
+
+	err := h.Uninstall(ctx)
+	require.NoError(t, err)
+}
+
+func TestMixin_InstallRandomName(t *testing.T) {
+	ctx := context.Background()
+	defer os.Unsetenv(test.ExpectedCommandEnv)
+	defer os.Unsetenv(test.ExpectedCommandOutputEnv)
+	os.Setenv(test.ExpectedCommandEnv, "helm3 install --generate-name stable/mysql --atomic --create-namespace --output json")
+	os.Setenv(test.ExpectedCommandOutputEnv, `{"name":"mysql-1700000000","namespace":"default","version":1,"info":{"status":"deployed"}}`)
+
+	action := InstallAction{Steps: []InstallStep{{
+		InstallArguments: InstallArguments{
+			Step: Step{
+				Description: "Install MySQL",
+				Outputs:     []HelmOutput{{Name: "release", ReleaseField: "name", Path: "state/mysql-release"}},
+			},
+			RandomName: true,
+			Chart:      "stable/mysql",
+		},
+	}}}
+	b, _ := yaml.Marshal(action)
+
+	h := NewTestMixin(t)
+	h.In = bytes.NewReader(b)
+
+	err := h.Install(ctx)
+	require.NoError(t, err)
+
+	name, err := h.FileSystem.ReadFile("state/mysql-release")
+	require.NoError(t, err)
+	assert.Equal(t, "mysql-1700000000", string(name))
+}
+
+func TestMixin_InstallRandomNameWithName(t *testing.T) {
+	ctx := context.Background()
+	action := InstallAction{Steps: []InstallStep{{
+		InstallArguments: InstallArguments{
+			Step:       Step{Description: "Install MySQL"},
+			Name:       "mysql",
+			RandomName: true,
+			Chart:      "stable/mysql",
+		},
+	}}}
+	b, _ := yaml.Marshal(action)
+
+	h := NewTestMixin(t)
+	h.In = bytes.NewReader(b)
+
+	err := h.Install(ctx)
+	require.EqualError(t, err, "randomName cannot be set together with name, generateName, releases or namespaces")
+}
+
+func TestMixin_UpgradeReleaseNameFile(t *testing.T) {
+	ctx := context.Background()
+	defer os.Unsetenv(test.ExpectedCommandEnv)
+	os.Setenv(test.ExpectedCommandEnv, "helm3 upgrade --install mysql-1700000000 stable/mysql --atomic --create-namespace --output json")
+
+	action := UpgradeAction{Steps: []UpgradeStep{{
+		UpgradeArguments: UpgradeArguments{
+			Step:            Step{Description: "Upgrade MySQL"},
+			ReleaseNameFile: "mysql-release",
+			Chart:           "stable/mysql",
+		},
+	}}}
+	b, _ := yaml.Marshal(action)
+
+	h := NewTestMixin(t)
+	h.In = bytes.NewReader(b)
+	require.NoError(t, h.FileSystem.WriteFile("mysql-release", []byte("mysql-1700000000\n"), 0600))
+
+	err := h.Upgrade(ctx)
+	require.NoError(t, err)
+}
+
+func TestMixin_UninstallReleaseNameFile(t *testing.T) {
+	testcases := []struct {
+		name       string
+		release    string
+		wantOutput string
+	}{
+		{name: "saved release", release: "mysql-1700000000"},
+		{name: "missing file", wantOutput: "The release name file mysql-release does not exist, the release was not installed"},
+	}
+
+	defer os.Unsetenv(test.ExpectedCommandEnv)
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			os.Setenv(test.ExpectedCommandEnv, "helm3 uninstall mysql-1700000000")
+
+			action := UninstallAction{Steps: []UninstallStep{{
+				UninstallArguments: UninstallArguments{
+					Step:            Step{Description: "Uninstall MySQL"},
+					ReleaseNameFile: "mysql-release",
+				},
+			}}}
+			b, _ := yaml.Marshal(action)
+
+			h := NewTestMixin(t)
+			h.In = bytes.NewReader(b)
+			if tc.release != "" {
+				require.NoError(t, h.FileSystem.WriteFile("mysql-release", []byte(tc.release), 0600))
+			}
+
+			err := h.Uninstall(ctx)
+			require.NoError(t, err)
+			assert.Contains(t, h.TestContext.GetOutput(), tc.wantOutput)
+		})
+	}
+}
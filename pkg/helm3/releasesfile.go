@@ -0,0 +1,76 @@
+package helm3
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// installedRelease is a release recorded in a releases file, in the order it was deployed
+type installedRelease struct {
+	Name      string
+	Namespace string
+}
+
+// String returns the line of the release in a releases file, NAMESPACE/NAME or NAME without a namespace
+func (r installedRelease) String() string {
+	if r.Namespace == "" {
+		return r.Name
+	}
+	return r.Namespace + "/" + r.Name
+}
+
+// readReleasesFile returns the releases recorded in a releases file in the order they were deployed,
+// and whether the file exists
+func (m *Mixin) readReleasesFile(file string) ([]installedRelease, bool, error) {
+	exists, err := m.FileSystem.Exists(file)
+	if err != nil || !exists {
+		return nil, false, errors.Wrapf(err, "could not read the releases from %s", file)
+	}
+	b, err := m.FileSystem.ReadFile(file)
+	if err != nil {
+		return nil, false, errors.Wrapf(err, "could not read the releases from %s", file)
+	}
+
+	var releases []installedRelease
+	for _, line := range strings.Split(string(b), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if namespace, name, ok := strings.Cut(line, "/"); ok {
+			releases = append(releases, installedRelease{Name: name, Namespace: namespace})
+		} else {
+			releases = append(releases, installedRelease{Name: line})
+		}
+	}
+	return releases, true, nil
+}
+
+// writeReleasesFile replaces the releases recorded in a releases file
+func (m *Mixin) writeReleasesFile(file string, releases []installedRelease) error {
+	lines := make([]string, 0, len(releases))
+	for _, r := range releases {
+		lines = append(lines, r.String()+"\n")
+	}
+	err := m.FileSystem.MkdirAll(filepath.Dir(file), 0700)
+	if err == nil {
+		err = m.FileSystem.WriteFile(file, []byte(strings.Join(lines, "")), 0600)
+	}
+	return errors.Wrapf(err, "could not write the releases to %s", file)
+}
+
+// recordRelease appends a deployed release to a releases file, a release that is already recorded keeps its position
+func (m *Mixin) recordRelease(file string, release installedRelease) error {
+	releases, _, err := m.readReleasesFile(file)
+	if err != nil {
+		return err
+	}
+	for _, r := range releases {
+		if r == release {
+			return nil
+		}
+	}
+	return m.writeReleasesFile(file, append(releases, release))
+}
@@ -0,0 +1,145 @@
+package helm3
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"get.porter.sh/porter/pkg/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestMixin_RecordRelease(t *testing.T) {
+	h := NewTestMixin(t)
+
+	require.NoError(t, h.recordRelease("state/releases", installedRelease{Name: "mysql", Namespace: "db"}))
+	require.NoError(t, h.recordRelease("state/releases", installedRelease{Name: "redis"}))
+	require.NoError(t, h.recordRelease("state/releases", installedRelease{Name: "mysql", Namespace: "db"}))
+
+	b, err := h.FileSystem.ReadFile("state/releases")
+	require.NoError(t, err)
+	assert.Equal(t, "db/mysql\nredis\n", string(b))
+
+	releases, exists, err := h.readReleasesFile("state/releases")
+	require.NoError(t, err)
+	assert.True(t, exists)
+	assert.Equal(t, []installedRelease{{Name: "mysql", Namespace: "db"}, {Name: "redis"}}, releases)
+}
+
+func TestMixin_InstallReleasesFile(t *testing.T) {
+	ctx := context.Background()
+	defer os.Unsetenv(test.ExpectedCommandEnv)
+	defer os.Unsetenv(test.ExpectedCommandOutputEnv)
+	os.Setenv(test.ExpectedCommandEnv, strings.Join([]string{
+		"helm3 upgrade --install mysql bitnami/mysql --namespace db --atomic --create-namespace --output json",
+		"helm3 upgrade --install wordpress bitnami/wordpress --namespace db --atomic --create-namespace --output json",
+	}, "\n"))
+	os.Setenv(test.ExpectedCommandOutputEnv, `{"name": "mysql", "namespace": "db", "version": 1}`)
+
+	action := InstallAction{Steps: []InstallStep{{
+		InstallArguments: InstallArguments{
+			Step:         Step{Description: "Install WordPress"},
+			Namespace:    "db",
+			ReleasesFile: "releases",
+			Releases: []Release{
+				{Name: "mysql", Chart: "bitnami/mysql"},
+				{Name: "wordpress", Chart: "bitnami/wordpress"},
+			},
+		},
+	}}}
+	b, _ := yaml.Marshal(action)
+
+	h := NewTestMixin(t)
+	h.In = bytes.NewReader(b)
+
+	err := h.Install(ctx)
+	require.NoError(t, err)
+
+	// the mocked helm prints the same release for both commands
+	releases, err := h.FileSystem.ReadFile("releases")
+	require.NoError(t, err)
+	assert.Equal(t, "db/mysql\n", string(releases))
+}
+
+func TestMixin_UninstallReleasesFile(t *testing.T) {
+	ctx := context.Background()
+	defer os.Unsetenv(test.ExpectedCommandEnv)
+	os.Setenv(test.ExpectedCommandEnv, strings.Join([]string{
+		"helm3 uninstall wordpress --namespace web",
+		"helm3 uninstall redis --namespace db",
+		"helm3 uninstall mysql --namespace db",
+	}, "\n"))
+
+	action := UninstallAction{Steps: []UninstallStep{{
+		UninstallArguments: UninstallArguments{
+			Step:         Step{Description: "Uninstall WordPress"},
+			Namespace:    "db",
+			ReleasesFile: "releases",
+		},
+	}}}
+	b, _ := yaml.Marshal(action)
+
+	h := NewTestMixin(t)
+	h.In = bytes.NewReader(b)
+	require.NoError(t, h.FileSystem.WriteFile("releases", []byte("db/mysql\nredis\nweb/wordpress\n"), 0600))
+
+	err := h.Uninstall(ctx)
+	require.NoError(t, err)
+
+	exists, err := h.FileSystem.Exists("releases")
+	require.NoError(t, err)
+	assert.False(t, exists, "the releases file should be removed once every release is uninstalled")
+}
+
+func TestMixin_UninstallReleasesFileFailure(t *testing.T) {
+	ctx := context.Background()
+	defer os.Unsetenv(test.ExpectedCommandEnv)
+	defer os.Unsetenv(test.ExpectedCommandExitCodeEnv)
+	os.Setenv(test.ExpectedCommandEnv, strings.Join([]string{
+		"helm3 uninstall redis --namespace db",
+		"helm3 uninstall mysql --namespace db",
+	}, "\n"))
+	os.Setenv(test.ExpectedCommandExitCodeEnv, "1")
+
+	action := UninstallAction{Steps: []UninstallStep{{
+		UninstallArguments: UninstallArguments{
+			Step:         Step{Description: "Uninstall WordPress"},
+			Namespace:    "db",
+			ReleasesFile: "releases",
+		},
+	}}}
+	b, _ := yaml.Marshal(action)
+
+	h := NewTestMixin(t)
+	h.In = bytes.NewReader(b)
+	require.NoError(t, h.FileSystem.WriteFile("releases", []byte("db/mysql\ndb/redis\n"), 0600))
+
+	err := h.Uninstall(ctx)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "2 errors occurred")
+
+	releases, err := h.FileSystem.ReadFile("releases")
+	require.NoError(t, err)
+	assert.Equal(t, "db/mysql\ndb/redis\n", string(releases))
+}
+
+func TestMixin_UninstallMissingReleasesFile(t *testing.T) {
+	action := UninstallAction{Steps: []UninstallStep{{
+		UninstallArguments: UninstallArguments{
+			Step:         Step{Description: "Uninstall WordPress"},
+			ReleasesFile: "releases",
+		},
+	}}}
+	b, _ := yaml.Marshal(action)
+
+	h := NewTestMixin(t)
+	h.In = bytes.NewReader(b)
+
+	err := h.Uninstall(context.Background())
+	require.NoError(t, err)
+	assert.Contains(t, h.TestContext.GetOutput(), "The releases file releases does not exist")
+}
@@ -0,0 +1,474 @@
+package helm3
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+// repoCredentialsSecret is the id of the build secret holding the credentials of private repositories
+const repoCredentialsSecret = "helm-repo-creds"
+
+const (
+	// repositoryConfigEnv overrides the path of the repository definitions used by helm at runtime, it is set by the
+	// repositoryConfig field of the mixin configuration, or by the bundle from a parameter or credential
+	repositoryConfigEnv = "PORTER_HELM3_REPOSITORY_CONFIG"
+	// repositoryCacheEnv overrides the path of the repository cache used by helm at runtime, it is set by the
+	// repositoryCache field of the mixin configuration, or by the bundle from a parameter or credential
+	repositoryCacheEnv = "PORTER_HELM3_REPOSITORY_CACHE"
+)
+
+var nonAlphanumericRegex = regexp.MustCompile(`[^A-Z0-9]`)
+
+// repoCredentialsVar returns the prefix of the variables holding the credentials of a repository in the build secret,
+// for example HELM_REPO_MY_CHARTS for the my-charts repository.
+func repoCredentialsVar(name string) string {
+	return "HELM_REPO_" + nonAlphanumericRegex.ReplaceAllString(strings.ToUpper(name), "_")
+}
+
+// buildPrivateRepositories writes a single RUN line that adds the private repositories and updates every repository
+// with the helm-repo-creds build secret mounted, then removes the credentials that helm saved, so that they never
+// end up in an image layer or in the build logs.
+func (m *Mixin) buildPrivateRepositories(helmBinary string, names []string, repositories map[string]Repository, updateCommand string) {
+	commands := []string{fmt.Sprintf(". /run/secrets/%s", repoCredentialsSecret)}
+	for _, name := range names {
+		url := repositories[name].URL
+		if url == "" {
+			if m.DebugMode {
+				fmt.Fprintln(m.Err, "DEBUG: addition of repository failed: repository url must be supplied")
+			}
+			continue
+		}
+		prefix := repoCredentialsVar(name)
+		commands = append(commands, fmt.Sprintf(`printf '%%s' "$%s_PASSWORD" | %s repo add %s %s --username "$%s_USERNAME" --password-stdin`,
+			prefix, helmBinary, name, url, prefix))
+	}
+	if updateCommand != "" {
+		commands = append(commands, updateCommand)
+	}
+	commands = append(commands,
+		fmt.Sprintf(`sed -i -e 's/^  password: .*/  password: ""/' -e 's/^  username: .*/  username: ""/' "$(%s env HELM_REPOSITORY_CONFIG)"`, helmBinary),
+	)
+	fmt.Fprintf(m.Out, "RUN --mount=type=secret,id=%s,mode=0444 %s\n", repoCredentialsSecret, strings.Join(commands, " &&\\\n    "))
+}
+
+// validateRepositoryPaths checks that the repository paths of the mixin configuration can be recorded in the invocation image
+func validateRepositoryPaths(config string, cache string) error {
+	if strings.ContainsAny(config, " \t\n") {
+		return errors.Errorf("supplied repositoryConfig %q cannot contain spaces", config)
+	}
+	if strings.ContainsAny(cache, " \t\n") {
+		return errors.Errorf("supplied repositoryCache %q cannot contain spaces", cache)
+	}
+	return nil
+}
+
+// repositoryPathFlags returns the helm flags that override the repository definitions and cache at runtime,
+// the repositories added to the invocation image at build time are used by default
+func (m *Mixin) repositoryPathFlags() map[string]string {
+	flags := make(map[string]string)
+	if config := m.Getenv(repositoryConfigEnv); config != "" {
+		flags["--repository-config"] = config
+	}
+	if cache := m.Getenv(repositoryCacheEnv); cache != "" {
+		flags["--repository-cache"] = cache
+	}
+	return flags
+}
+
+// repoUpdateConstraint is the helm client versions that can update a subset of the repositories
+const repoUpdateConstraint = ">= 3.7.0"
+
+// repoUpdateCommand returns the command that updates the repositories added to the invocation image,
+// or an empty string when updates are skipped. It fails when a repository can't be updated with failOnError.
+func (m *Mixin) repoUpdateCommand(helmBinary string, names []string, skip bool, failOnError bool) (string, error) {
+	if skip {
+		return "", nil
+	}
+	selective, err := validate(m.HelmClientVersion, repoUpdateConstraint)
+	if err != nil {
+		return "", err
+	}
+	command := fmt.Sprintf("%s repo update", helmBinary)
+	if selective && len(names) > 0 {
+		command = fmt.Sprintf("%s %s", command, strings.Join(names, " "))
+	}
+	if failOnError {
+		command = fmt.Sprintf("%s %s", command, failOnRepoUpdateFailFlag)
+	}
+	return command, nil
+}
+
+// helm3Step is used to decode a helm3 step of an action, with its charts and repositories
+type helm3Step struct {
+	Description  string                 `yaml:"description"`
+	Chart        string                 `yaml:"chart"`
+	Version      string                 `yaml:"version"`
+	Repo         string                 `yaml:"repo"`
+	Releases     []stepRelease          `yaml:"releases"`
+	Repositories map[string]Repository  `yaml:"repositories"`
+	Verify       *SignatureVerification `yaml:"verify"`
+
+	// raw is the undecoded step, for the fields that are not decoded such as templates
+	raw interface{}
+}
+
+// stepRelease is a release of a helm3 step, which uninstall steps reference by name only
+type stepRelease struct {
+	Chart   string `yaml:"chart"`
+	Version string `yaml:"version"`
+}
+
+// UnmarshalYAML decodes a release, or the name of a release of an uninstall step which has no chart
+func (r *stepRelease) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var name string
+	if unmarshal(&name) == nil {
+		return nil
+	}
+	type release stepRelease
+	return unmarshal((*release)(r))
+}
+
+// helm3Steps is used to decode the helm3 steps of an action, ignoring the steps of other mixins
+type helm3Steps []struct {
+	Step *helm3Step `yaml:"helm3"`
+}
+
+// forEachHelm3Step decodes the helm3 steps of every action, sorted by action, and calls fn with each of them
+func forEachHelm3Step(contents []byte, fn func(action string, index int, step helm3Step) error) error {
+	var actions map[string]interface{}
+	err := yaml.Unmarshal(contents, &actions)
+	if err != nil {
+		return errors.Wrap(err, "could not unmarshal the actions")
+	}
+
+	actionNames := make([]string, 0, len(actions))
+	for name := range actions {
+		if name != "config" {
+			actionNames = append(actionNames, name)
+		}
+	}
+	sort.Strings(actionNames)
+	for _, name := range actionNames {
+		b, err := yaml.Marshal(actions[name])
+		if err != nil {
+			return errors.Wrapf(err, "could not marshal the %s action", name)
+		}
+		var steps helm3Steps
+		err = yaml.Unmarshal(b, &steps)
+		if err != nil {
+			return errors.Wrapf(err, "could not unmarshal the %s action", name)
+		}
+		var rawSteps []struct {
+			Step interface{} `yaml:"helm3"`
+		}
+		err = yaml.Unmarshal(b, &rawSteps)
+		if err != nil {
+			return errors.Wrapf(err, "could not unmarshal the %s action", name)
+		}
+
+		for i, step := range steps {
+			if step.Step == nil {
+				continue
+			}
+			step.Step.raw = rawSteps[i].Step
+			err = fn(name, i, *step.Step)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// buildRepositories returns the repositories configured on the mixin, along with the repositories
+// declared by the helm3 steps of every action.
+func buildRepositories(contents []byte, config MixinConfig) (map[string]Repository, error) {
+	repos := make(map[string]Repository, len(config.Repositories))
+	for name, repo := range config.Repositories {
+		if repo.Runtime {
+			return nil, errors.Errorf("repository %q of the mixin configuration cannot be added at runtime, declare it on the steps that use it", name)
+		}
+		repos[name] = repo
+	}
+
+	// runtime repositories are removed once their step completes, they can't replace a repository of the image
+	runtime := make(map[string]string)
+	err := forEachHelm3Step(contents, func(action string, index int, step helm3Step) error {
+		for repoName, repo := range step.Repositories {
+			if repo.Runtime {
+				runtime[repoName] = fmt.Sprintf("step %d (%s) of the %s action", index+1, step.Description, action)
+				continue
+			}
+			existing, ok := repos[repoName]
+			if ok && existing.URL != repo.URL {
+				return errors.Errorf("repository %q is declared with different urls: %s and %s",
+					repoName, existing.URL, repo.URL)
+			}
+			repos[repoName] = repo
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(runtime))
+	for name := range runtime {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if _, ok := repos[name]; ok {
+			return nil, errors.Errorf("runtime repository %q of %s has the name of a repository added when the bundle is built", name, runtime[name])
+		}
+	}
+	return repos, nil
+}
+
+// validateChartRepositories checks that the chart of every release of the helm3 steps, in the form REPO/CHART,
+// references a repository added to the invocation image or a runtime repository of its step. Charts from oci://
+// or other urls, local charts and charts templated at runtime are not checked. An undeclared repository is reported
+// as a warning, since the repository may be added by the base image of the bundle, unless fail is set.
+func (m *Mixin) validateChartRepositories(contents []byte, repositories map[string]Repository, fail bool) error {
+	return forEachHelm3Step(contents, func(action string, index int, step helm3Step) error {
+		if step.Repo != "" {
+			return nil
+		}
+		charts := []string{step.Chart}
+		for _, release := range step.Releases {
+			charts = append(charts, release.Chart)
+		}
+		for _, chart := range charts {
+			repo, ok := chartRepository(chart)
+			if !ok {
+				continue
+			}
+			if _, declared := repositories[repo]; declared {
+				continue
+			}
+			if _, declared := step.Repositories[repo]; declared {
+				continue
+			}
+			if local, _ := m.FileSystem.Exists(chart); local {
+				continue
+			}
+			err := errors.Errorf("the chart %q of step %d (%s) of the %s action references the repository %q, which is not declared in the repositories of the mixin configuration or of the step",
+				chart, index+1, step.Description, action, repo)
+			if fail {
+				return err
+			}
+			fmt.Fprintf(m.Err, "WARNING: %s\n", err)
+		}
+		return nil
+	})
+}
+
+// chartRepository returns the repository of a chart in the form REPO/CHART
+func chartRepository(chart string) (string, bool) {
+	if chart == "" || strings.Contains(chart, "://") || strings.Contains(chart, "{{") ||
+		strings.HasPrefix(chart, ".") || strings.HasPrefix(chart, "/") {
+		return "", false
+	}
+	repo, name, ok := strings.Cut(chart, "/")
+	if !ok || repo == "" || name == "" || strings.Contains(name, "/") {
+		return "", false
+	}
+	return repo, true
+}
+
+// addRuntimeRepositories adds the runtime repositories of a step with their credentials, and adds the private
+// repositories of the image again with the credentials of the step, since they are removed when the bundle is built.
+// The password is passed on stdin so that it is never printed. The returned function removes the runtime repositories
+// and the credentials of the private ones once the step completes, so that they are not kept in the repository config.
+func (m *Mixin) addRuntimeRepositories(ctx context.Context, repositories map[string]Repository) (func(), error) {
+	names := make([]string, 0, len(repositories))
+	for name, repo := range repositories {
+		if repo.Runtime || (repo.Private && (repo.Username != "" || repo.Password != "")) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var added, authenticated []string
+	remove := func() {
+		for _, name := range added {
+			err := m.runCommand(m.helmCommand(ctx, "repo", "remove", name))
+			if err != nil {
+				fmt.Fprintf(m.Err, "WARNING: could not remove repository %s: %s\n", name, err)
+			}
+		}
+		if len(authenticated) > 0 {
+			err := m.clearRepositoryCredentials(authenticated)
+			if err != nil {
+				fmt.Fprintf(m.Err, "WARNING: could not remove the credentials of repositories %s: %s\n", strings.Join(authenticated, ", "), err)
+			}
+		}
+	}
+	for _, name := range names {
+		repo := repositories[name]
+		if repo.URL == "" {
+			remove()
+			return nil, errors.Errorf("runtime repository %q requires its url", name)
+		}
+		if repo.Private && repo.Runtime {
+			remove()
+			return nil, errors.Errorf("repository %q cannot be both private and runtime", name)
+		}
+		if (repo.Username == "") != (repo.Password == "") {
+			remove()
+			if repo.Private {
+				return nil, errors.Errorf("private repository %q requires both its username and password", name)
+			}
+			return nil, errors.Errorf("runtime repository %q requires both its username and password", name)
+		}
+
+		cmd := m.helmCommand(ctx, "repo", "add", name, repo.URL, "--force-update")
+		if repo.Username != "" {
+			cmd.Args = append(cmd.Args, "--username", repo.Username, "--password-stdin")
+			cmd.Stdin = strings.NewReader(repo.Password)
+		}
+		err := m.runCommand(cmd)
+		if err != nil {
+			remove()
+			return nil, errors.Wrapf(err, "could not add repository %s", name)
+		}
+		if m.planMode() {
+			continue
+		}
+		if repo.Private {
+			authenticated = append(authenticated, name)
+		} else {
+			added = append(added, name)
+		}
+	}
+	return remove, nil
+}
+
+// repositoryConfigPath returns the repository definitions used by helm in the step that runs, resolved as helm does
+func (m *Mixin) repositoryConfigPath() string {
+	for _, flag := range m.globalFlags {
+		if flag.name == "--repository-config" && flag.value != "" {
+			return flag.value
+		}
+	}
+	if path := m.Getenv("HELM_REPOSITORY_CONFIG"); path != "" {
+		return path
+	}
+	configHome := m.Getenv("HELM_CONFIG_HOME")
+	if configHome == "" {
+		xdgConfigHome := m.Getenv("XDG_CONFIG_HOME")
+		if xdgConfigHome == "" {
+			xdgConfigHome = filepath.Join(m.Getenv("HOME"), ".config")
+		}
+		configHome = filepath.Join(xdgConfigHome, "helm")
+	}
+	return filepath.Join(configHome, "repositories.yaml")
+}
+
+// clearRepositoryCredentials blanks the username and password that helm saved for repositories in the repository
+// config, as they are when the bundle is built
+func (m *Mixin) clearRepositoryCredentials(names []string) error {
+	path := m.repositoryConfigPath()
+	b, err := m.FileSystem.ReadFile(path)
+	if err != nil {
+		return errors.Wrapf(err, "could not read the repository config %s", path)
+	}
+	var config yaml.MapSlice
+	err = yaml.Unmarshal(b, &config)
+	if err != nil {
+		return errors.Wrapf(err, "could not parse the repository config %s", path)
+	}
+
+	cleared := make(map[string]bool, len(names))
+	for _, name := range names {
+		cleared[name] = true
+	}
+	for _, item := range config {
+		if item.Key != "repositories" {
+			continue
+		}
+		repositories, _ := item.Value.([]interface{})
+		for _, r := range repositories {
+			repo, _ := r.(yaml.MapSlice)
+			name := ""
+			for _, field := range repo {
+				if field.Key == "name" {
+					name, _ = field.Value.(string)
+				}
+			}
+			if !cleared[name] {
+				continue
+			}
+			for i, field := range repo {
+				if field.Key == "username" || field.Key == "password" {
+					repo[i].Value = ""
+				}
+			}
+		}
+	}
+
+	b, err = yaml.Marshal(config)
+	if err != nil {
+		return errors.Wrapf(err, "could not format the repository config %s", path)
+	}
+	return errors.Wrapf(m.FileSystem.WriteFile(path, b, 0600), "could not write the repository config %s", path)
+}
+
+// verifyRepositories checks that the repositories required by a step were added to helm when the bundle was built
+func (m *Mixin) verifyRepositories(ctx context.Context, required map[string]Repository) error {
+	if len(required) == 0 || m.planMode() {
+		return nil
+	}
+
+	cmd := m.helmCommand(ctx, "repo", "list", "--output", "yaml")
+	output := &bytes.Buffer{}
+	cmd.Stdout = output
+	cmd.Stderr = m.Err
+
+	prettyCmd := fmt.Sprintf("%s %s", cmd.Path, formatPlannedCommand(cmd.Args))
+	if m.tracing() {
+		fmt.Fprintln(m.Err, prettyCmd)
+	}
+
+	err := cmd.Run()
+	if err != nil {
+		return errors.Wrapf(err, "could not verify the repositories required by the step, %s", prettyCmd)
+	}
+
+	var added []struct {
+		Name string `yaml:"name"`
+		URL  string `yaml:"url"`
+	}
+	err = yaml.Unmarshal(output.Bytes(), &added)
+	if err != nil {
+		return errors.Wrap(err, "could not parse the helm repositories")
+	}
+	urls := make(map[string]string, len(added))
+	for _, repo := range added {
+		urls[repo.Name] = repo.URL
+	}
+
+	names := make([]string, 0, len(required))
+	for name := range required {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		url, ok := urls[name]
+		if !ok {
+			return errors.Errorf("repository %q required by the step was not added when the bundle was built", name)
+		}
+		if want := required[name].URL; want != "" && want != url {
+			return errors.Errorf("repository %q required by the step has url %s, but %s was added when the bundle was built",
+				name, want, url)
+		}
+	}
+	return nil
+}
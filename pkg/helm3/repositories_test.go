@@ -0,0 +1,262 @@
+package helm3
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"get.porter.sh/porter/pkg/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestMixin_UpgradeRepositories(t *testing.T) {
+	repoList := `- name: bitnami
+  url: https://charts.bitnami.com/bitnami
+`
+
+	testcases := []struct {
+		name         string
+		repositories map[string]Repository
+		wantCommands []string
+		wantError    string
+	}{
+		{
+			name:         "repository added",
+			repositories: map[string]Repository{"bitnami": {URL: "https://charts.bitnami.com/bitnami"}},
+			wantCommands: []string{
+				"helm3 repo list --output yaml",
+				"helm3 upgrade --install mysql bitnami/mysql --atomic --create-namespace --output json",
+			},
+		},
+		{
+			name:         "repository missing",
+			repositories: map[string]Repository{"stable": {URL: "https://charts.helm.sh/stable"}},
+			wantCommands: []string{"helm3 repo list --output yaml"},
+			wantError:    `repository "stable" required by the step was not added when the bundle was built`,
+		},
+		{
+			name:         "repository url mismatch",
+			repositories: map[string]Repository{"bitnami": {URL: "https://example.com/bitnami"}},
+			wantCommands: []string{"helm3 repo list --output yaml"},
+			wantError:    `repository "bitnami" required by the step has url https://example.com/bitnami, but https://charts.bitnami.com/bitnami was added when the bundle was built`,
+		},
+	}
+
+	defer os.Unsetenv(test.ExpectedCommandEnv)
+	defer os.Unsetenv(test.ExpectedCommandOutputEnv)
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			os.Setenv(test.ExpectedCommandEnv, strings.Join(tc.wantCommands, "\n"))
+			os.Setenv(test.ExpectedCommandOutputEnv, repoList)
+
+			action := UpgradeAction{Steps: []UpgradeStep{{
+				UpgradeArguments: UpgradeArguments{
+					Step:         Step{Description: "Upgrade MySQL"},
+					Name:         "mysql",
+					Chart:        "bitnami/mysql",
+					Repositories: tc.repositories,
+				},
+			}}}
+			b, _ := yaml.Marshal(action)
+
+			h := NewTestMixin(t)
+			h.In = bytes.NewReader(b)
+
+			err := h.Upgrade(ctx)
+			if tc.wantError != "" {
+				require.EqualError(t, err, tc.wantError)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestMixin_UpgradeRepositoryPaths(t *testing.T) {
+	ctx := context.Background()
+	defer os.Unsetenv(test.ExpectedCommandEnv)
+	defer os.Unsetenv(test.ExpectedCommandOutputEnv)
+	os.Setenv(test.ExpectedCommandEnv, strings.Join([]string{
+		"helm3 --repository-cache=/mnt/helm/cache --repository-config=/mnt/helm/repositories.yaml repo list --output yaml",
+		"helm3 --repository-cache=/mnt/helm/cache --repository-config=/mnt/helm/repositories.yaml upgrade --install mysql bitnami/mysql --atomic --create-namespace --output json",
+	}, "\n"))
+	os.Setenv(test.ExpectedCommandOutputEnv, "- name: bitnami\n  url: https://charts.bitnami.com/bitnami\n")
+
+	action := UpgradeAction{Steps: []UpgradeStep{{
+		UpgradeArguments: UpgradeArguments{
+			Step:         Step{Description: "Upgrade MySQL"},
+			Name:         "mysql",
+			Chart:        "bitnami/mysql",
+			Repositories: map[string]Repository{"bitnami": {URL: "https://charts.bitnami.com/bitnami"}},
+		},
+	}}}
+	b, _ := yaml.Marshal(action)
+
+	h := NewTestMixin(t)
+	h.In = bytes.NewReader(b)
+	h.Setenv(repositoryConfigEnv, "/mnt/helm/repositories.yaml")
+	h.Setenv(repositoryCacheEnv, "/mnt/helm/cache")
+
+	err := h.Upgrade(ctx)
+	require.NoError(t, err)
+}
+
+func TestValidateRepositoryPaths(t *testing.T) {
+	require.NoError(t, validateRepositoryPaths("/mnt/helm/repositories.yaml", "/mnt/helm/cache"))
+	require.EqualError(t, validateRepositoryPaths("/mnt/helm charts/repositories.yaml", ""),
+		`supplied repositoryConfig "/mnt/helm charts/repositories.yaml" cannot contain spaces`)
+}
+
+func TestMixin_UpgradeRuntimeRepositories(t *testing.T) {
+	testcases := []struct {
+		name         string
+		repository   Repository
+		wantCommands []string
+		wantError    string
+	}{
+		{
+			name:       "repository with credentials",
+			repository: Repository{URL: "https://charts.example.com", Runtime: true, Username: "admin", Password: "secret"},
+			wantCommands: []string{
+				"helm3 repo add private https://charts.example.com --force-update --username admin --password-stdin",
+				"helm3 repo list --output yaml",
+				"helm3 upgrade --install mysql private/mysql --atomic --create-namespace --output json",
+				"helm3 repo remove private",
+			},
+		},
+		{
+			name:       "public repository",
+			repository: Repository{URL: "https://charts.example.com", Runtime: true},
+			wantCommands: []string{
+				"helm3 repo add private https://charts.example.com --force-update",
+				"helm3 repo list --output yaml",
+				"helm3 upgrade --install mysql private/mysql --atomic --create-namespace --output json",
+				"helm3 repo remove private",
+			},
+		},
+		{
+			name:       "missing password",
+			repository: Repository{URL: "https://charts.example.com", Runtime: true, Username: "admin"},
+			wantError:  `runtime repository "private" requires both its username and password`,
+		},
+		{
+			name:       "private repository without password",
+			repository: Repository{URL: "https://charts.example.com", Private: true, Username: "admin"},
+			wantError:  `private repository "private" requires both its username and password`,
+		},
+		{
+			name:       "private repository",
+			repository: Repository{URL: "https://charts.example.com", Runtime: true, Private: true},
+			wantError:  `repository "private" cannot be both private and runtime`,
+		},
+	}
+
+	defer os.Unsetenv(test.ExpectedCommandEnv)
+	defer os.Unsetenv(test.ExpectedCommandOutputEnv)
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			os.Setenv(test.ExpectedCommandEnv, strings.Join(tc.wantCommands, "\n"))
+			os.Setenv(test.ExpectedCommandOutputEnv, "- name: private\n  url: https://charts.example.com\n")
+
+			action := UpgradeAction{Steps: []UpgradeStep{{
+				UpgradeArguments: UpgradeArguments{
+					Step:         Step{Description: "Upgrade MySQL"},
+					Name:         "mysql",
+					Chart:        "private/mysql",
+					Repositories: map[string]Repository{"private": tc.repository},
+				},
+			}}}
+			b, _ := yaml.Marshal(action)
+
+			h := NewTestMixin(t)
+			h.In = bytes.NewReader(b)
+
+			err := h.Upgrade(ctx)
+			if tc.wantError != "" {
+				require.EqualError(t, err, tc.wantError)
+				return
+			}
+			require.NoError(t, err)
+			assert.NotContains(t, h.TestContext.GetOutput(), "secret")
+		})
+	}
+}
+
+func TestMixin_InstallPrivateRepositoryCredentials(t *testing.T) {
+	ctx := context.Background()
+	defer os.Unsetenv(test.ExpectedCommandEnv)
+	defer os.Unsetenv(test.ExpectedCommandOutputEnv)
+	os.Setenv(test.ExpectedCommandEnv, strings.Join([]string{
+		"helm3 repo add my-charts https://charts.example.com --force-update --username admin --password-stdin",
+		"helm3 repo list --output yaml",
+		"helm3 upgrade --install mysql my-charts/mysql --atomic --create-namespace --output json",
+	}, "\n"))
+	os.Setenv(test.ExpectedCommandOutputEnv, "- name: my-charts\n  url: https://charts.example.com\n")
+
+	action := InstallAction{Steps: []InstallStep{{
+		InstallArguments: InstallArguments{
+			Step:  Step{Description: "Install MySQL"},
+			Name:  "mysql",
+			Chart: "my-charts/mysql",
+			Repositories: map[string]Repository{
+				"my-charts": {URL: "https://charts.example.com", Private: true, Username: "admin", Password: "secret"},
+			},
+		},
+	}}}
+	b, _ := yaml.Marshal(action)
+
+	h := NewTestMixin(t)
+	h.In = bytes.NewReader(b)
+	// the credentials saved by helm repo add, which the step removes once it completes
+	h.Setenv("HELM_REPOSITORY_CONFIG", "/root/.config/helm/repositories.yaml")
+	require.NoError(t, h.FileSystem.MkdirAll("/root/.config/helm", 0700))
+	require.NoError(t, h.FileSystem.WriteFile("/root/.config/helm/repositories.yaml", []byte(`apiVersion: ""
+generated: "0001-01-01T00:00:00Z"
+repositories:
+- name: my-charts
+  url: https://charts.example.com
+  username: admin
+  password: secret
+- name: bitnami
+  url: https://charts.bitnami.com/bitnami
+  username: reader
+  password: public
+`), 0600))
+
+	err := h.Install(ctx)
+	require.NoError(t, err)
+	assert.NotContains(t, h.TestContext.GetOutput(), "secret")
+
+	config, err := h.FileSystem.ReadFile("/root/.config/helm/repositories.yaml")
+	require.NoError(t, err)
+	assert.Contains(t, string(config), "- name: my-charts\n  url: https://charts.example.com\n  username: \"\"\n  password: \"\"\n")
+	assert.Contains(t, string(config), "username: reader\n  password: public\n", "the credentials of other repositories are kept")
+}
+
+func TestChartRepository(t *testing.T) {
+	testcases := []struct {
+		chart string
+		repo  string
+		ok    bool
+	}{
+		{chart: "bitnami/mysql", repo: "bitnami", ok: true},
+		{chart: "oci://ghcr.io/example/charts/mysql"},
+		{chart: "https://charts.example.com/mysql-1.0.0.tgz"},
+		{chart: "./charts/mysql"},
+		{chart: "/cnab/app/charts/mysql"},
+		{chart: "charts/mysql/nested"},
+		{chart: "{{ bundle.parameters.chart }}"},
+		{chart: "mysql"},
+	}
+	for _, tc := range testcases {
+		repo, ok := chartRepository(tc.chart)
+		assert.Equal(t, tc.ok, ok, tc.chart)
+		assert.Equal(t, tc.repo, repo, tc.chart)
+	}
+}
@@ -0,0 +1,117 @@
+package helm3
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// repoUpdateTTLEnv enables the update of the repositories of the charts of a step at runtime, when their cached
+	// index is older than the duration, it is set by the repoUpdateTTL field of the mixin configuration
+	repoUpdateTTLEnv = "PORTER_HELM3_REPO_UPDATE_TTL"
+	// failOnRepoUpdateFailEnv fails the update of the repositories at runtime when one of them can't be updated,
+	// instead of using its cached index, it is set by the failOnRepoUpdateFail field of the mixin configuration
+	failOnRepoUpdateFailEnv = "PORTER_HELM3_FAIL_ON_REPO_UPDATE_FAIL"
+	// failOnRepoUpdateFailFlag is the flag of helm repo update that fails when a repository can't be updated
+	failOnRepoUpdateFailFlag = "--fail-on-repo-update-fail"
+)
+
+// validateRepoUpdate checks the repository updates of the mixin configuration against the helm client version
+func (m *Mixin) validateRepoUpdate(ttl string, failOnError bool) error {
+	if ttl != "" {
+		d, err := time.ParseDuration(ttl)
+		if err != nil || d <= 0 {
+			return errors.Errorf("invalid repoUpdateTTL %q, expected a duration such as 1h", ttl)
+		}
+	}
+	if ttl == "" && !failOnError {
+		return nil
+	}
+	selective, err := validate(m.HelmClientVersion, repoUpdateConstraint)
+	if err != nil {
+		return err
+	}
+	if !selective {
+		return errors.Errorf("repoUpdateTTL and failOnRepoUpdateFail require helm %s, got %s", repoUpdateConstraint, m.HelmClientVersion)
+	}
+	return nil
+}
+
+// repositoryCacheDir returns the directory where helm caches the index of the repositories at runtime
+func (m *Mixin) repositoryCacheDir() string {
+	if cache := m.Getenv(repositoryCacheEnv); cache != "" {
+		return cache
+	}
+	if cache := m.Getenv("HELM_REPOSITORY_CACHE"); cache != "" {
+		return cache
+	}
+	if cache := m.Getenv("HELM_CACHE_HOME"); cache != "" {
+		return filepath.Join(cache, "repository")
+	}
+	if cache := m.Getenv("XDG_CACHE_HOME"); cache != "" {
+		return filepath.Join(cache, "helm", "repository")
+	}
+	return filepath.Join(m.Getenv("HOME"), ".cache", "helm", "repository")
+}
+
+// updateRepositories updates the repositories of the charts of a step when the repoUpdateTTL of the mixin configuration
+// is set, skipping the repositories whose cached index is younger than the TTL so that most actions don't depend on the
+// network. The runtime repositories of the step are skipped, since they are downloaded when they are added.
+func (m *Mixin) updateRepositories(ctx context.Context, charts []string, stepRepositories map[string]Repository) error {
+	value := m.Getenv(repoUpdateTTLEnv)
+	if value == "" {
+		return nil
+	}
+	ttl, err := time.ParseDuration(value)
+	if err != nil || ttl <= 0 {
+		return errors.Errorf("invalid %s %q, expected a duration such as 1h", repoUpdateTTLEnv, value)
+	}
+
+	var names []string
+	for _, chart := range charts {
+		name, ok := chartRepository(chart)
+		if ok && !stepRepositories[name].Runtime {
+			names = append(names, name)
+		}
+	}
+	stale := m.staleRepositories(names, ttl, time.Now())
+	if len(stale) == 0 {
+		if m.verbose() && len(names) > 0 {
+			fmt.Fprintf(m.Out, "The index of the repositories is younger than %s, skipping their update\n", ttl)
+		}
+		return nil
+	}
+
+	cmd := m.helmCommand(ctx, append([]string{"repo", "update"}, stale...)...)
+	if m.Getenv(failOnRepoUpdateFailEnv) == "true" {
+		cmd.Args = append(cmd.Args, failOnRepoUpdateFailFlag)
+	}
+	err = m.runCommand(cmd)
+	return errors.Wrapf(err, "could not update the repositories %s", strings.Join(stale, ", "))
+}
+
+// staleRepositories returns the repositories, sorted and without duplicates, whose cached index is missing or older
+// than the ttl
+func (m *Mixin) staleRepositories(names []string, ttl time.Duration, now time.Time) []string {
+	cache := m.repositoryCacheDir()
+	seen := make(map[string]bool)
+	var stale []string
+	for _, name := range names {
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		info, err := m.FileSystem.Stat(filepath.Join(cache, name+"-index.yaml"))
+		if err != nil || now.Sub(info.ModTime()) > ttl {
+			stale = append(stale, name)
+		}
+	}
+	sort.Strings(stale)
+	return stale
+}
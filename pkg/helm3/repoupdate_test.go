@@ -0,0 +1,105 @@
+package helm3
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"get.porter.sh/porter/pkg/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestMixin_InstallRepoUpdate(t *testing.T) {
+	installCommand := "helm3 upgrade --install mysql bitnami/mysql --atomic --create-namespace --output json"
+
+	testcases := []struct {
+		name         string
+		cached       bool
+		failOnError  bool
+		wantCommands []string
+	}{
+		{
+			name:         "missing index",
+			wantCommands: []string{"helm3 repo update bitnami", installCommand},
+		},
+		{
+			name:         "missing index with failOnRepoUpdateFail",
+			failOnError:  true,
+			wantCommands: []string{"helm3 repo update bitnami --fail-on-repo-update-fail", installCommand},
+		},
+		{
+			name:         "index younger than the ttl",
+			cached:       true,
+			wantCommands: []string{installCommand},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			defer os.Unsetenv(test.ExpectedCommandEnv)
+			os.Setenv(test.ExpectedCommandEnv, strings.Join(tc.wantCommands, "\n"))
+
+			action := InstallAction{Steps: []InstallStep{{
+				InstallArguments: InstallArguments{
+					Step:  Step{Description: "Install MySQL"},
+					Name:  "mysql",
+					Chart: "bitnami/mysql",
+				},
+			}}}
+			b, _ := yaml.Marshal(action)
+
+			h := NewTestMixin(t)
+			h.In = bytes.NewReader(b)
+			h.Setenv(repoUpdateTTLEnv, "1h")
+			h.Setenv("HELM_CACHE_HOME", "/helm")
+			if tc.failOnError {
+				h.Setenv(failOnRepoUpdateFailEnv, "true")
+			}
+			if tc.cached {
+				h.TestContext.AddTestFileContents([]byte("apiVersion: v1\n"), "/helm/repository/bitnami-index.yaml")
+			}
+
+			err := h.Install(ctx)
+			require.NoError(t, err)
+			assert.Equal(t, tc.cached, !strings.Contains(h.TestContext.GetOutput(), "repo update"))
+		})
+	}
+}
+
+func TestMixin_StaleRepositories(t *testing.T) {
+	h := NewTestMixin(t)
+	h.Setenv(repositoryCacheEnv, "/cache")
+	h.TestContext.AddTestFileContents([]byte("apiVersion: v1\n"), "/cache/bitnami-index.yaml")
+	h.TestContext.AddTestFileContents([]byte("apiVersion: v1\n"), "/cache/stable-index.yaml")
+
+	now := time.Now()
+	assert.Equal(t, []string{"jetstack"}, h.staleRepositories([]string{"stable", "jetstack", "bitnami", "jetstack"}, time.Hour, now))
+	assert.Equal(t, []string{"bitnami", "jetstack", "stable"}, h.staleRepositories([]string{"stable", "jetstack", "bitnami"}, time.Hour, now.Add(2*time.Hour)))
+}
+
+func TestMixin_RepositoryCacheDir(t *testing.T) {
+	h := NewTestMixin(t)
+	h.Setenv("HOME", "/home/nonroot")
+	assert.Equal(t, "/home/nonroot/.cache/helm/repository", h.repositoryCacheDir())
+	h.Setenv("HELM_CACHE_HOME", "/helm/cache")
+	assert.Equal(t, "/helm/cache/repository", h.repositoryCacheDir())
+	h.Setenv(repositoryCacheEnv, "/mnt/helm/cache")
+	assert.Equal(t, "/mnt/helm/cache", h.repositoryCacheDir())
+}
+
+func TestMixin_ValidateRepoUpdate(t *testing.T) {
+	h := NewTestMixin(t)
+	require.NoError(t, h.validateRepoUpdate("", false))
+	require.NoError(t, h.validateRepoUpdate("30m", true))
+	require.EqualError(t, h.validateRepoUpdate("-1h", false), `invalid repoUpdateTTL "-1h", expected a duration such as 1h`)
+
+	h.HelmClientVersion = "v3.6.3"
+	require.NoError(t, h.validateRepoUpdate("", false))
+	require.EqualError(t, h.validateRepoUpdate("", true), "repoUpdateTTL and failOnRepoUpdateFail require helm >= 3.7.0, got v3.6.3")
+}
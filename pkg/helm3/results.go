@@ -0,0 +1,165 @@
+package helm3
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// releaseFields are the fields of a release result that can be used as an output
+var releaseFields = []string{"name", "namespace", "revision", "status", "manifest", "images", "appVersion"}
+
+// releaseResult is the result of an install or upgrade, decoded from the release printed by helm with --output json
+type releaseResult struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+	Revision  int    `json:"version"`
+	Info      struct {
+		Status string `json:"status"`
+	} `json:"info"`
+	Chart struct {
+		Metadata struct {
+			AppVersion string `json:"appVersion"`
+		} `json:"metadata"`
+	} `json:"chart"`
+
+	// manifest is the manifest of the release, spooled as it is decoded so that it is never held in memory
+	manifest spooledFile
+}
+
+// decodeRelease decodes the release printed by helm with --output json, writing its manifest to manifest as it is
+// read, or skipping it when manifest is nil
+func decodeRelease(r io.Reader, result *releaseResult, manifest io.Writer) error {
+	if manifest == nil {
+		manifest = io.Discard
+	}
+	return decodeJSONObject(r, jsonFields{
+		"name":      decodeJSONValue(&result.Name),
+		"namespace": decodeJSONValue(&result.Namespace),
+		"version":   decodeJSONValue(&result.Revision),
+		"info":      decodeJSONValue(&result.Info),
+		"chart": func(dec *json.Decoder) error {
+			return decodeJSONFields(dec, jsonFields{"metadata": decodeJSONValue(&result.Chart.Metadata)})
+		},
+	}, map[string]io.Writer{"manifest": manifest})
+}
+
+// manifestReader returns a reader of the spooled manifest of the release
+func (r releaseResult) manifestReader() (io.Reader, error) {
+	if r.manifest == nil {
+		return strings.NewReader(""), nil
+	}
+	if err := rewind(r.manifest); err != nil {
+		return nil, errors.Wrapf(err, "could not read the manifest of release %s", r.Name)
+	}
+	return r.manifest, nil
+}
+
+// field returns the value of a release field by the name used in the step outputs
+func (r releaseResult) field(name string) (string, error) {
+	switch name {
+	case "name":
+		return r.Name, nil
+	case "namespace":
+		return r.Namespace, nil
+	case "revision":
+		return strconv.Itoa(r.Revision), nil
+	case "status":
+		return r.Info.Status, nil
+	case "manifest":
+		manifest, err := r.manifestReader()
+		if err != nil {
+			return "", err
+		}
+		b, err := io.ReadAll(manifest)
+		return string(b), errors.Wrapf(err, "could not read the manifest of release %s", r.Name)
+	case "images":
+		// one image per line, so that the output can be fed to scanners as a list
+		manifest, err := r.manifestReader()
+		if err != nil {
+			return "", err
+		}
+		images, err := manifestImages(manifest)
+		if err != nil {
+			return "", errors.Wrapf(err, "could not list the images of release %s", r.Name)
+		}
+		return strings.Join(images, "\n"), nil
+	case "appVersion":
+		return r.Chart.Metadata.AppVersion, nil
+	}
+	return "", errors.Errorf("unsupported release field %s, expected one of %s", name, strings.Join(releaseFields, ", "))
+}
+
+// runReleaseCommand runs a helm install or upgrade that prints the release as json and returns the decoded result.
+// The result is nil when helm does not print a release, for example when a wrapper of the helm binary adds its own output.
+// The release is written to a spooled file, from which it is printed when helm fails, or decoded field by field with
+// its manifest copied to a spooled file of its own, so that neither the release nor its manifest is held in memory.
+func (m *Mixin) runReleaseCommand(ctx context.Context, cmd *exec.Cmd) (*releaseResult, error) {
+	output, err := m.spoolFile("release-*.json")
+	if err != nil {
+		return nil, err
+	}
+	defer output.Close()
+	stderr := &stderrTail{}
+	cmd.Stdout = output
+	cmd.Stderr = io.MultiWriter(m.Err, stderr)
+
+	prettyCmd := fmt.Sprintf("%s %s", cmd.Path, formatPlannedCommand(cmd.Args))
+	m.echoCommand(prettyCmd)
+
+	err = cmd.Start()
+	if err != nil {
+		return nil, fmt.Errorf("could not execute command, %s: %s", prettyCmd, err)
+	}
+	interrupted, err := waitInterruptible(ctx, cmd)
+	if interrupted {
+		return nil, err
+	}
+	if rewindErr := rewind(output); rewindErr != nil {
+		return nil, errors.Wrapf(rewindErr, "could not read the output of %s", prettyCmd)
+	}
+	if err != nil {
+		m.printOutputFrom(output)
+		return nil, classifyHelmError(err, stderr.String())
+	}
+
+	manifest, err := m.spoolFile("manifest-*.yaml")
+	if err != nil {
+		return nil, err
+	}
+	var result releaseResult
+	if err := decodeRelease(output, &result, manifest); err != nil || result.Name == "" {
+		// the output is not printed, it may be the release with its rendered values
+		if size, err := output.Seek(0, io.SeekEnd); err == nil && size > 0 {
+			fmt.Fprintf(m.Out, "Could not read the release printed by helm, its %d bytes of output are not printed\n", size)
+		}
+		return nil, nil
+	}
+	result.manifest = manifest
+
+	fmt.Fprintf(m.Out, "Release %s is %s at revision %d in namespace %s\n", result.Name, result.Info.Status, result.Revision, result.Namespace)
+	return &result, nil
+}
+
+// findReleaseResult returns the result of the release referenced by an output.
+// The release name may be omitted when the step manages a single release.
+func findReleaseResult(output HelmOutput, results []releaseResult) (releaseResult, error) {
+	if output.Release == "" {
+		if len(results) != 1 {
+			return releaseResult{}, errors.Errorf("output %s must set release to select one of the %d release results of the step", output.Name, len(results))
+		}
+		return results[0], nil
+	}
+	for _, r := range results {
+		if r.Name == output.Release {
+			return r, nil
+		}
+	}
+	return releaseResult{}, errors.Errorf("output %s references the release %s, which has no result", output.Name, output.Release)
+}
@@ -0,0 +1,180 @@
+package helm3
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"get.porter.sh/porter/pkg/portercontext"
+	"get.porter.sh/porter/pkg/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestMixin_InstallReleaseOutputs(t *testing.T) {
+	ctx := context.Background()
+	defer os.Unsetenv(test.ExpectedCommandEnv)
+	defer os.Unsetenv(test.ExpectedCommandOutputEnv)
+	os.Setenv(test.ExpectedCommandEnv, "helm3 upgrade --install mysql stable/mysql --namespace db --atomic --create-namespace --output json")
+	os.Setenv(test.ExpectedCommandOutputEnv, `{"name":"mysql","namespace":"db","version":3,"info":{"status":"deployed"}}`)
+
+	action := InstallAction{Steps: []InstallStep{{
+		InstallArguments: InstallArguments{
+			Step: Step{
+				Description: "Install MySQL",
+				Outputs: []HelmOutput{
+					{Name: "mysql-revision", ReleaseField: "revision"},
+					{Name: "mysql-status", Release: "mysql", ReleaseField: "status"},
+				},
+			},
+			Namespace: "db",
+			Name:      "mysql",
+			Chart:     "stable/mysql",
+		},
+	}}}
+	b, _ := yaml.Marshal(action)
+
+	h := NewTestMixin(t)
+	h.In = bytes.NewReader(b)
+
+	err := h.Install(ctx)
+	require.NoError(t, err)
+	assert.Contains(t, h.TestContext.GetOutput(), "Release mysql is deployed at revision 3 in namespace db")
+
+	revision, err := h.FileSystem.ReadFile(filepath.Join(portercontext.MixinOutputsDir, "mysql-revision"))
+	require.NoError(t, err)
+	assert.Equal(t, "3", string(revision))
+	status, err := h.FileSystem.ReadFile(filepath.Join(portercontext.MixinOutputsDir, "mysql-status"))
+	require.NoError(t, err)
+	assert.Equal(t, "deployed", string(status))
+}
+
+func TestMixin_InstallReleaseNotDecoded(t *testing.T) {
+	ctx := context.Background()
+	defer os.Unsetenv(test.ExpectedCommandOutputEnv)
+	os.Setenv(test.ExpectedCommandOutputEnv, "name: mysql\nconfig:\n  rootPassword: s3cr3t\n")
+
+	action := InstallAction{Steps: []InstallStep{{
+		InstallArguments: InstallArguments{
+			Step:  Step{Description: "Install MySQL"},
+			Name:  "mysql",
+			Chart: "stable/mysql",
+		},
+	}}}
+	b, _ := yaml.Marshal(action)
+
+	h := NewTestMixin(t)
+	h.In = bytes.NewReader(b)
+
+	err := h.Install(ctx)
+	require.NoError(t, err)
+	assert.Contains(t, h.TestContext.GetOutput(), "Could not read the release printed by helm, its 43 bytes of output are not printed")
+	assert.NotContains(t, h.TestContext.GetOutput(), "s3cr3t")
+}
+
+func TestFindReleaseResult(t *testing.T) {
+	results := []releaseResult{{Name: "mysql"}, {Name: "redis"}}
+
+	result, err := findReleaseResult(HelmOutput{Name: "cache", Release: "redis", ReleaseField: "name"}, results)
+	require.NoError(t, err)
+	assert.Equal(t, "redis", result.Name)
+
+	_, err = findReleaseResult(HelmOutput{Name: "cache", ReleaseField: "name"}, results)
+	require.EqualError(t, err, "output cache must set release to select one of the 2 release results of the step")
+
+	_, err = findReleaseResult(HelmOutput{Name: "cache", Release: "memcached", ReleaseField: "name"}, results)
+	require.EqualError(t, err, "output cache references the release memcached, which has no result")
+
+	_, err = releaseResult{}.field("chart")
+	require.EqualError(t, err, "unsupported release field chart, expected one of name, namespace, revision, status, manifest, images, appVersion")
+}
+
+func TestManifestImages(t *testing.T) {
+	manifest := `---
+apiVersion: v1
+kind: Service
+metadata:
+  name: mysql
+---
+apiVersion: apps/v1
+kind: StatefulSet
+metadata:
+  name: mysql
+spec:
+  template:
+    spec:
+      initContainers:
+        - name: volume-permissions
+          image: docker.io/bitnami/os-shell:12
+      containers:
+        - name: mysql
+          image: docker.io/bitnami/mysql:8.0.35
+        - name: metrics
+          image: docker.io/bitnami/mysqld-exporter:0.15.1
+---
+apiVersion: batch/v1
+kind: CronJob
+metadata:
+  name: backup
+spec:
+  jobTemplate:
+    spec:
+      template:
+        spec:
+          containers:
+            - name: backup
+              image: docker.io/bitnami/mysql:8.0.35
+`
+	images, err := manifestImages(strings.NewReader(manifest))
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"docker.io/bitnami/mysql:8.0.35",
+		"docker.io/bitnami/mysqld-exporter:0.15.1",
+		"docker.io/bitnami/os-shell:12",
+	}, images)
+
+	_, err = manifestImages(strings.NewReader("kind: ["))
+	require.Error(t, err)
+}
+
+func TestMixin_InstallImagesOutput(t *testing.T) {
+	ctx := context.Background()
+	defer os.Unsetenv(test.ExpectedCommandEnv)
+	defer os.Unsetenv(test.ExpectedCommandOutputEnv)
+	os.Setenv(test.ExpectedCommandEnv, "helm3 upgrade --install redis bitnami/redis --atomic --create-namespace --output json")
+	os.Setenv(test.ExpectedCommandOutputEnv, `{"name":"redis","namespace":"default","version":1,"info":{"status":"deployed"},`+
+		`"chart":{"metadata":{"name":"redis","version":"18.1.0","appVersion":"7.2.1"}},`+
+		`"manifest":"kind: Deployment\nspec:\n  template:\n    spec:\n      containers:\n        - name: redis\n          image: redis:7.2.1\n        - name: sentinel\n          image: redis-sentinel:7.2.1\n"}`)
+
+	action := InstallAction{Steps: []InstallStep{{
+		InstallArguments: InstallArguments{
+			Step: Step{
+				Description: "Install Redis",
+				Outputs: []HelmOutput{
+					{Name: "redis-images", ReleaseField: "images"},
+					{Name: "redis-app-version", ReleaseField: "appVersion"},
+				},
+			},
+			Name:  "redis",
+			Chart: "bitnami/redis",
+		},
+	}}}
+	b, _ := yaml.Marshal(action)
+
+	h := NewTestMixin(t)
+	h.In = bytes.NewReader(b)
+
+	err := h.Install(ctx)
+	require.NoError(t, err)
+
+	images, err := h.FileSystem.ReadFile(filepath.Join(portercontext.MixinOutputsDir, "redis-images"))
+	require.NoError(t, err)
+	assert.Equal(t, "redis-sentinel:7.2.1\nredis:7.2.1", string(images))
+	appVersion, err := h.FileSystem.ReadFile(filepath.Join(portercontext.MixinOutputsDir, "redis-app-version"))
+	require.NoError(t, err)
+	assert.Equal(t, "7.2.1", string(appVersion))
+}
@@ -0,0 +1,138 @@
+package helm3
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/pkg/errors"
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// RevisionDiff compares the manifests of two revisions of a release
+type RevisionDiff struct {
+	Release string `yaml:"release"`
+	// From is the revision compared against
+	From int `yaml:"from"`
+	// To is the revision that is compared, it defaults to the current revision of the release
+	To int `yaml:"to,omitempty"`
+}
+
+// revisionDiff prints a unified diff between the manifests of two revisions of a release and returns it
+func (m *Mixin) revisionDiff(ctx context.Context, step ExecuteStep) (string, error) {
+	diff := *step.RevisionDiff
+	if len(step.Arguments) > 0 || step.Plugin != "" {
+		return "", errors.New("revisionDiff cannot be set together with arguments or plugin")
+	}
+	if diff.Release == "" || diff.From <= 0 {
+		return "", errors.New("revisionDiff requires a release and the revision to compare from")
+	}
+
+	from, err := m.getManifestLines(ctx, diff.Release, step.Namespace, diff.From)
+	if err != nil {
+		return "", err
+	}
+	to, err := m.getManifestLines(ctx, diff.Release, step.Namespace, diff.To)
+	if err != nil {
+		return "", err
+	}
+	if m.planMode() {
+		return "", nil
+	}
+
+	unified, err := diff.unified(from, to)
+	if err != nil {
+		return "", err
+	}
+	fmt.Fprint(m.Out, unified)
+	return unified, nil
+}
+
+// unified returns the unified diff between the lines of the manifests of the two revisions
+func (d RevisionDiff) unified(from []string, to []string) (string, error) {
+	toName := "current"
+	if d.To > 0 {
+		toName = strconv.Itoa(d.To)
+	}
+	unified, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        from,
+		B:        to,
+		FromFile: fmt.Sprintf("%s revision %d", d.Release, d.From),
+		ToFile:   fmt.Sprintf("%s revision %s", d.Release, toName),
+		Context:  3,
+	})
+	return unified, errors.Wrapf(err, "could not compare the revisions of release %s", d.Release)
+}
+
+// getManifestLines returns the lines of the manifest of a release revision, or of the current revision when revision
+// is 0. Both manifests are compared in memory by the diff, so they are read as lines without another copy.
+func (m *Mixin) getManifestLines(ctx context.Context, release string, namespace string, revision int) ([]string, error) {
+	var lines []string
+	err := m.readManifest(ctx, release, namespace, revision, func(r io.Reader) error {
+		var err error
+		lines, err = readLines(r)
+		return err
+	})
+	return lines, err
+}
+
+// readManifest calls read with the manifest of a release revision piped from helm get manifest, read is not called
+// in plan mode
+func (m *Mixin) readManifest(ctx context.Context, release string, namespace string, revision int, read func(r io.Reader) error) error {
+	cmd := m.helmCommand(ctx, "get", "manifest", release)
+	if revision > 0 {
+		cmd.Args = append(cmd.Args, "--revision", strconv.Itoa(revision))
+	}
+	if namespace != "" {
+		cmd.Args = append(cmd.Args, "--namespace", namespace)
+	}
+
+	if m.planMode() {
+		m.printPlannedCommand(cmd)
+		return nil
+	}
+
+	cmd.Stderr = m.Err
+	output, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+
+	prettyCmd := fmt.Sprintf("%s %s", cmd.Path, formatPlannedCommand(cmd.Args))
+	if m.tracing() {
+		fmt.Fprintln(m.Err, prettyCmd)
+	}
+
+	err = cmd.Start()
+	if err != nil {
+		return errors.Wrapf(err, "could not get the manifest of release %s, %s", release, prettyCmd)
+	}
+	readErr := read(output)
+	// the rest of the output is drained so that helm does not block on a full pipe when read stops early
+	io.Copy(io.Discard, output)
+	err = cmd.Wait()
+	if err != nil {
+		return errors.Wrapf(err, "could not get the manifest of release %s, %s", release, prettyCmd)
+	}
+	return errors.Wrapf(readErr, "could not read the manifest of release %s", release)
+}
+
+// readLines reads a manifest as lines that keep their line ending, as expected by difflib
+func readLines(r io.Reader) ([]string, error) {
+	var lines []string
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadString('\n')
+		if line != "" {
+			lines = append(lines, line)
+		}
+		if err == io.EOF {
+			return lines, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package helm3
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"get.porter.sh/porter/pkg/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestRevisionDiff_Unified(t *testing.T) {
+	from, err := readLines(strings.NewReader("kind: Deployment\nspec:\n  replicas: 1\n"))
+	require.NoError(t, err)
+	to, err := readLines(strings.NewReader("kind: Deployment\nspec:\n  replicas: 3\n"))
+	require.NoError(t, err)
+
+	unified, err := RevisionDiff{Release: "mysql", From: 2}.unified(from, to)
+	require.NoError(t, err)
+	assert.Equal(t, `--- mysql revision 2
++++ mysql revision current
+@@ -1,3 +1,3 @@
+ kind: Deployment
+ spec:
+-  replicas: 1
++  replicas: 3
+`, unified)
+
+	unified, err = RevisionDiff{Release: "mysql", From: 2, To: 3}.unified(from, from)
+	require.NoError(t, err)
+	assert.Empty(t, unified)
+}
+
+func TestMixin_ExecuteRevisionDiff(t *testing.T) {
+	ctx := context.Background()
+
+	defer os.Unsetenv(test.ExpectedCommandEnv)
+	os.Setenv(test.ExpectedCommandEnv, strings.Join([]string{
+		"helm3 get manifest mysql --revision 2 --namespace db",
+		"helm3 get manifest mysql --revision 3 --namespace db",
+	}, "\n"))
+
+	executeAction := Action{
+		Steps: []ExecuteSteps{
+			{
+				ExecuteStep: ExecuteStep{
+					Step:         Step{Description: "Compare MySQL revisions"},
+					Namespace:    "db",
+					RevisionDiff: &RevisionDiff{Release: "mysql", From: 2, To: 3},
+				},
+			},
+		},
+	}
+
+	b, _ := yaml.Marshal(executeAction)
+
+	h := NewTestMixin(t)
+	h.In = bytes.NewReader(b)
+
+	err := h.Execute(ctx)
+	require.NoError(t, err)
+}
+
+func TestMixin_ExecuteRevisionDiffWithArguments(t *testing.T) {
+	ctx := context.Background()
+
+	executeAction := Action{
+		Steps: []ExecuteSteps{
+			{
+				ExecuteStep: ExecuteStep{
+					Step:         Step{Description: "Compare MySQL revisions"},
+					Arguments:    []string{"status"},
+					RevisionDiff: &RevisionDiff{Release: "mysql", From: 2},
+				},
+			},
+		},
+	}
+
+	b, _ := yaml.Marshal(executeAction)
+
+	h := NewTestMixin(t)
+	h.In = bytes.NewReader(b)
+
+	err := h.Execute(ctx)
+	require.EqualError(t, err, "revisionDiff cannot be set together with arguments or plugin")
+}
@@ -39,6 +39,8 @@ func TestMixin_ValidateSchema(t *testing.T) {
 		wantError string
 	}{
 		{"install", "testdata/install-input.yaml", ""},
+		{"install releases", "testdata/install-input-releases.yaml", ""},
+		{"install without chart", "testdata/bad-install-input.missing-chart.yaml", "Must validate at least one schema (anyOf)"},
 		{"invalid property", "testdata/invalid-input.yaml", "Additional property args is not allowed"},
 		{"install", "testdata/upgrade-input.yaml", ""},
 		{"invalid property", "testdata/invalid-input.yaml", "Additional property args is not allowed"},
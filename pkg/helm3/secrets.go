@@ -0,0 +1,134 @@
+package helm3
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+)
+
+// gpgKeyEnv holds an armored GPG private key, usually from a Porter credential,
+// that is imported before helm decrypts values files encrypted with SOPS.
+// Age keys don't need to be imported, set SOPS_AGE_KEY instead.
+const gpgKeyEnv = "PORTER_HELM3_GPG_KEY"
+
+// secretsProtocol is handled by the helm-secrets plugin, which decrypts the values file with SOPS
+const secretsProtocol = "secrets://"
+
+// HelmSecrets represents the helm-secrets plugin and SOPS versions installed in the invocation image
+type HelmSecrets struct {
+	Version     string `yaml:"version,omitempty"`
+	SOPSVersion string `yaml:"sopsVersion,omitempty"`
+}
+
+const defaultHelmSecretsVersion = "v4.1.1"
+const defaultSOPSVersion = "v3.7.3"
+
+// buildHelmSecrets writes the Dockerfile lines that install SOPS and the helm-secrets plugin
+func (m *Mixin) buildHelmSecrets(secrets HelmSecrets, helmBinary string) {
+	if secrets.Version == "" {
+		secrets.Version = defaultHelmSecretsVersion
+	}
+	if secrets.SOPSVersion == "" {
+		secrets.SOPSVersion = defaultSOPSVersion
+	}
+
+	fmt.Fprintln(m.Out, "RUN "+aptInstall("gnupg"))
+	fmt.Fprintf(m.Out, "RUN curl -L https://github.com/mozilla/sops/releases/download/%s/sops-%s.%s.%s -o /usr/local/bin/sops &&\\\n",
+		secrets.SOPSVersion, secrets.SOPSVersion, m.HelmClientPlatfrom, m.HelmClientArchitecture)
+	fmt.Fprintln(m.Out, "    chmod a+x /usr/local/bin/sops")
+	// Plugins are installed for the user the container will execute as
+	fmt.Fprintln(m.Out, "USER ${BUNDLE_USER}")
+	fmt.Fprintf(m.Out, "RUN %s plugin install https://github.com/jkroepke/helm-secrets/releases/download/%s/helm-secrets.tar.gz\n",
+		helmBinary, secrets.Version)
+	fmt.Fprintln(m.Out, "USER root")
+}
+
+// importGPGKey imports the GPG key from the environment, if any, so that SOPS can decrypt values files
+func (m *Mixin) importGPGKey(ctx context.Context) error {
+	key := m.Getenv(gpgKeyEnv)
+	if key == "" {
+		return nil
+	}
+
+	cmd := m.NewCommand(ctx, "gpg", "--batch", "--import")
+	cmd.Stdin = strings.NewReader(key)
+	return m.runCommand(cmd)
+}
+
+// valuesFile returns the path of the values file to pass to helm,
+// routing files encrypted with SOPS through the helm-secrets plugin.
+func (m *Mixin) valuesFile(path string) string {
+	if strings.Contains(path, "://") {
+		return path
+	}
+
+	f, err := m.FileSystem.Open(path)
+	if err != nil {
+		// let helm report files that can't be read
+		return path
+	}
+	defer f.Close()
+
+	if !hasSOPSMetadata(f) {
+		return path
+	}
+	return secretsProtocol + path
+}
+
+// hasSOPSMetadata returns whether a values file has the top level sops key of the files encrypted with SOPS.
+// The file is streamed, so that large values files are not loaded in memory: json files token by token,
+// and yaml files line by line, looking for an unindented key, with long lines skipped by chunks.
+func hasSOPSMetadata(r io.Reader) bool {
+	br := bufio.NewReader(r)
+	for {
+		b, err := br.ReadByte()
+		if err != nil {
+			return false
+		}
+		if !unicode.IsSpace(rune(b)) {
+			br.UnreadByte()
+			if b == '{' {
+				return hasJSONSOPSMetadata(json.NewDecoder(br))
+			}
+			break
+		}
+	}
+
+	lineStart := true
+	for {
+		chunk, err := br.ReadSlice('\n')
+		if lineStart && bytes.HasPrefix(chunk, []byte("sops:")) {
+			return true
+		}
+		lineStart = err != bufio.ErrBufferFull
+		if err != nil && err != bufio.ErrBufferFull {
+			return false
+		}
+	}
+}
+
+// hasJSONSOPSMetadata returns whether the json object read by the decoder has a sops key, skipping the values of the
+// other keys without decoding them
+func hasJSONSOPSMetadata(dec *json.Decoder) bool {
+	if t, err := dec.Token(); err != nil || t != json.Delim('{') {
+		return false
+	}
+	for dec.More() {
+		key, err := dec.Token()
+		if err != nil {
+			return false
+		}
+		if key == "sops" {
+			return true
+		}
+		if skipJSONValue(dec) != nil {
+			return false
+		}
+	}
+	return false
+}
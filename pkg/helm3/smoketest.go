@@ -0,0 +1,188 @@
+package helm3
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultSmokeTestStatus  = http.StatusOK
+	defaultSmokeTestRetries = 5
+)
+
+// smokeTestRetryInterval is the time between two probes of a smoke test
+var smokeTestRetryInterval = 5 * time.Second
+
+// portForwardTimeout is how long kubectl port-forward may take to listen on its local port
+var portForwardTimeout = 30 * time.Second
+
+// forwardedPortRegex matches the local port printed by kubectl port-forward, for example Forwarding from 127.0.0.1:40123 -> 80
+var forwardedPortRegex = regexp.MustCompile(`^Forwarding from 127\.0\.0\.1:(\d+) ->`)
+
+// SmokeTest probes a deployed release over HTTP, through a port-forward to one of its services or pods,
+// so that the step fails when the application isn't actually serving
+type SmokeTest struct {
+	// Resource is port-forwarded by kubectl, for example service/wordpress or pod/wordpress-0
+	Resource string `yaml:"resource"`
+	// Port of the resource
+	Port int `yaml:"port"`
+	// Path requested by the probe, / by default
+	Path string `yaml:"path,omitempty"`
+	// Scheme of the probe, http or https, http by default. The certificate is not verified, as it is not issued for localhost.
+	Scheme string `yaml:"scheme,omitempty"`
+	// ExpectedStatus is the status code returned by a healthy application, 200 by default
+	ExpectedStatus int `yaml:"expectedStatus,omitempty"`
+	// Retries is the number of probes after the first one fails, 5 by default
+	Retries *int `yaml:"retries,omitempty"`
+}
+
+// validate checks that the smoke test can be run, before anything is deployed
+func (s SmokeTest) validate() error {
+	kind, name, ok := strings.Cut(s.Resource, "/")
+	if !ok || kind == "" || name == "" {
+		return errors.Errorf("invalid smokeTest resource %q, expected TYPE/NAME such as service/wordpress", s.Resource)
+	}
+	if s.Port <= 0 || s.Port > 65535 {
+		return errors.Errorf("invalid smokeTest port %d", s.Port)
+	}
+	if s.Scheme != "" && s.Scheme != "http" && s.Scheme != "https" {
+		return errors.Errorf("unsupported smokeTest scheme %q, expected http or https", s.Scheme)
+	}
+	if s.ExpectedStatus != 0 && (s.ExpectedStatus < 100 || s.ExpectedStatus > 599) {
+		return errors.Errorf("invalid smokeTest expectedStatus %d", s.ExpectedStatus)
+	}
+	if s.Retries != nil && *s.Retries < 0 {
+		return errors.Errorf("invalid smokeTest retries %d", *s.Retries)
+	}
+	return nil
+}
+
+// runSmokeTest port-forwards to the resource of the smoke test and probes it until it returns the expected status
+func (m *Mixin) runSmokeTest(ctx context.Context, test SmokeTest, namespace string) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	cmd := m.NewCommand(ctx, "kubectl", "port-forward", test.Resource, fmt.Sprintf(":%d", test.Port))
+	if namespace != "" {
+		cmd.Args = append(cmd.Args, "--namespace", namespace)
+	}
+	if m.planMode() {
+		m.printPlannedCommand(cmd)
+		return nil
+	}
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return errors.Wrapf(err, "could not port-forward to %s", test.Resource)
+	}
+	cmd.Stderr = m.Err
+	m.echoCommand(formatPlannedCommand(cmd.Args))
+	err = cmd.Start()
+	if err != nil {
+		return errors.Wrapf(err, "could not port-forward to %s", test.Resource)
+	}
+	defer func() {
+		cancel()
+		cmd.Wait()
+	}()
+
+	port, err := forwardedPort(stdout)
+	if err != nil {
+		return errors.Wrapf(err, "could not port-forward to %s", test.Resource)
+	}
+
+	scheme, path := test.Scheme, test.Path
+	if scheme == "" {
+		scheme = "http"
+	}
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	expected := test.ExpectedStatus
+	if expected == 0 {
+		expected = defaultSmokeTestStatus
+	}
+	retries := defaultSmokeTestRetries
+	if test.Retries != nil {
+		retries = *test.Retries
+	}
+	return m.probe(ctx, test.Resource, fmt.Sprintf("%s://127.0.0.1:%s%s", scheme, port, path), expected, retries)
+}
+
+// forwardedPort reads the local port printed by kubectl port-forward, the rest of its output is discarded
+func forwardedPort(stdout io.Reader) (string, error) {
+	ports := make(chan string, 1)
+	go func() {
+		scanner := bufio.NewScanner(stdout)
+		for scanner.Scan() {
+			if match := forwardedPortRegex.FindStringSubmatch(scanner.Text()); match != nil {
+				ports <- match[1]
+				io.Copy(io.Discard, stdout)
+				return
+			}
+		}
+		close(ports)
+	}()
+
+	select {
+	case port, ok := <-ports:
+		if !ok {
+			return "", errors.New("kubectl port-forward exited before forwarding a port")
+		}
+		return port, nil
+	case <-time.After(portForwardTimeout):
+		return "", errors.Errorf("kubectl port-forward did not forward a port after %s", portForwardTimeout)
+	}
+}
+
+// probe requests the url until it returns the expected status, at most retries times after the first request
+func (m *Mixin) probe(ctx context.Context, resource string, url string, expected int, retries int) error {
+	client := &http.Client{
+		Timeout: 10 * time.Second,
+		// the certificate of the application is not issued for the local address of the port-forward
+		Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}, // #nosec G402
+	}
+	for attempt := 0; ; attempt++ {
+		err := probeStatus(ctx, client, url, expected)
+		if err == nil {
+			fmt.Fprintf(m.Out, "Smoke test of %s passed, %s returned %d\n", resource, url, expected)
+			return nil
+		}
+		if attempt >= retries {
+			return errors.Wrapf(err, "smoke test of %s failed", resource)
+		}
+		fmt.Fprintf(m.Out, "Smoke test of %s failed, %s, retrying in %s\n", resource, err, smokeTestRetryInterval)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(smokeTestRetryInterval):
+		}
+	}
+}
+
+// probeStatus requests the url once and checks its status
+func probeStatus(ctx context.Context, client *http.Client, url string, expected int) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != expected {
+		return errors.Errorf("%s returned %d, expected %d", url, resp.StatusCode, expected)
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package helm3
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"get.porter.sh/porter/pkg/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSmokeTest_Validate(t *testing.T) {
+	negative := -1
+	require.NoError(t, SmokeTest{Resource: "service/wordpress", Port: 80}.validate())
+	require.EqualError(t, SmokeTest{Resource: "wordpress", Port: 80}.validate(),
+		`invalid smokeTest resource "wordpress", expected TYPE/NAME such as service/wordpress`)
+	require.EqualError(t, SmokeTest{Resource: "service/wordpress"}.validate(), "invalid smokeTest port 0")
+	require.EqualError(t, SmokeTest{Resource: "service/wordpress", Port: 80, Scheme: "tcp"}.validate(),
+		`unsupported smokeTest scheme "tcp", expected http or https`)
+	require.EqualError(t, SmokeTest{Resource: "service/wordpress", Port: 80, ExpectedStatus: 42}.validate(),
+		"invalid smokeTest expectedStatus 42")
+	require.EqualError(t, SmokeTest{Resource: "service/wordpress", Port: 80, Retries: &negative}.validate(),
+		"invalid smokeTest retries -1")
+}
+
+func TestMixin_RunSmokeTest(t *testing.T) {
+	defer func(interval time.Duration) { smokeTestRetryInterval = interval }(smokeTestRetryInterval)
+	smokeTestRetryInterval = 10 * time.Millisecond
+
+	// the requests are counted by the goroutines of the server
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		count := atomic.AddInt32(&requests, 1)
+		if r.URL.Path != "/healthz" || count < 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+	u, err := url.Parse(server.URL)
+	require.NoError(t, err)
+
+	defer os.Unsetenv(test.ExpectedCommandEnv)
+	defer os.Unsetenv(test.ExpectedCommandOutputEnv)
+	os.Setenv(test.ExpectedCommandEnv, "kubectl port-forward service/wordpress :80 --namespace web")
+	os.Setenv(test.ExpectedCommandOutputEnv, "Forwarding from 127.0.0.1:"+u.Port()+" -> 80\n")
+
+	t.Run("healthy", func(t *testing.T) {
+		atomic.StoreInt32(&requests, 0)
+		h := NewTestMixin(t)
+		err := h.runSmokeTest(context.Background(), SmokeTest{Resource: "service/wordpress", Port: 80, Path: "healthz"}, "web")
+		require.NoError(t, err)
+		assert.Equal(t, int32(2), atomic.LoadInt32(&requests))
+		assert.Contains(t, h.TestContext.GetOutput(), "Smoke test of service/wordpress passed")
+	})
+
+	t.Run("unhealthy", func(t *testing.T) {
+		atomic.StoreInt32(&requests, 0)
+		retries := 1
+		h := NewTestMixin(t)
+		err := h.runSmokeTest(context.Background(), SmokeTest{Resource: "service/wordpress", Port: 80, Retries: &retries}, "web")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "smoke test of service/wordpress failed")
+		assert.Contains(t, err.Error(), "returned 503, expected 200")
+		assert.Equal(t, int32(2), atomic.LoadInt32(&requests))
+	})
+}
+
+func TestMixin_RunSmokeTestWithoutPort(t *testing.T) {
+	defer os.Unsetenv(test.ExpectedCommandEnv)
+	os.Setenv(test.ExpectedCommandEnv, "kubectl port-forward pod/wordpress-0 :8080")
+
+	h := NewTestMixin(t)
+	err := h.runSmokeTest(context.Background(), SmokeTest{Resource: "pod/wordpress-0", Port: 8080}, "")
+	require.EqualError(t, err, "could not port-forward to pod/wordpress-0: kubectl port-forward exited before forwarding a port")
+}
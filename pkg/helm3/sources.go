@@ -0,0 +1,89 @@
+package helm3
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+var sha256Regex = regexp.MustCompile(`^[a-f0-9]{64}$`)
+
+// BinarySource is an alternate artifact for the helm or kubectl binary, for example an internally built
+// FIPS-validated binary, which is installed in the invocation image instead of the upstream release
+type BinarySource struct {
+	// URL of the binary, or of a tar.gz archive with the binary when Path is set
+	URL string `yaml:"url"`
+	// Path of the binary in the archive
+	Path string `yaml:"path,omitempty"`
+	// Checksum is the sha256 checksum of the downloaded file
+	Checksum string `yaml:"checksum"`
+	// Signature is the URL of an armored detached gpg signature of the downloaded file
+	Signature string `yaml:"signature,omitempty"`
+	// Key is the URL of the armored gpg public key used to verify the signature
+	Key string `yaml:"key,omitempty"`
+}
+
+// validate checks that the source can be downloaded and verified
+func (s BinarySource) validate(name string) error {
+	if s.URL == "" {
+		return errors.Errorf("supplied %s requires a url", name)
+	}
+	u, err := url.Parse(s.URL)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") {
+		return errors.Errorf("supplied %s url %q must be an http or https URL", name, s.URL)
+	}
+	// the download is pinned by its checksum, while the key is trusted as it is downloaded, so the signature and key require https
+	for _, field := range []struct{ name, value string }{{"signature", s.Signature}, {"key", s.Key}} {
+		if field.value == "" {
+			continue
+		}
+		u, err := url.Parse(field.value)
+		if err != nil || u.Scheme != "https" {
+			return errors.Errorf("supplied %s %s %q must be an https URL", name, field.name, field.value)
+		}
+	}
+	if !sha256Regex.MatchString(s.Checksum) {
+		return errors.Errorf("supplied %s checksum %q must be a sha256 checksum", name, s.Checksum)
+	}
+	if (s.Signature == "") != (s.Key == "") {
+		return errors.Errorf("supplied %s requires both a signature and a key to verify the signature", name)
+	}
+	if s.Path != "" && (path.IsAbs(s.Path) || strings.HasPrefix(s.Path, ".") || path.Clean(s.Path) != s.Path) {
+		return errors.Errorf("supplied %s path %q must be relative to the root of the archive", name, s.Path)
+	}
+	return nil
+}
+
+// installCommands returns the commands that download the source to file, verify its checksum and signature,
+// and install the binary to dest, along with the files to remove once it is installed.
+// The values of the source are quoted, since they are written to RUN lines of the Dockerfile.
+func (s BinarySource) installCommands(file string, dest string) (commands []string, cleanup []string) {
+	commands = []string{
+		fmt.Sprintf("curl -fsSL -o %s %s", quoteArg(file), quoteArg(s.URL)),
+		fmt.Sprintf("echo %s | sha256sum -c -", quoteArg(s.Checksum+"  "+file)),
+	}
+	cleanup = []string{quoteArg(file)}
+	if s.Signature != "" {
+		signature := file + ".asc"
+		commands = append(commands,
+			fmt.Sprintf("curl -fsSL -o %s %s", quoteArg(signature), quoteArg(s.Signature)),
+			fmt.Sprintf("curl -fsSL %s | gpg --batch --import", quoteArg(s.Key)),
+			fmt.Sprintf("gpg --batch --verify %s %s", quoteArg(signature), quoteArg(file)),
+		)
+		cleanup = append(cleanup, quoteArg(signature))
+	}
+	if s.Path == "" {
+		commands = append(commands, fmt.Sprintf("install -m 0755 %s %s", quoteArg(file), quoteArg(dest)))
+		return commands, cleanup
+	}
+	commands = append(commands,
+		fmt.Sprintf("tar -xzf %s %s", quoteArg(file), quoteArg(s.Path)),
+		fmt.Sprintf("install -m 0755 %s %s", quoteArg(s.Path), quoteArg(dest)),
+	)
+	// the extracted binary is removed rather than the first directory of its path
+	return commands, append(cleanup, quoteArg(s.Path))
+}
@@ -0,0 +1,60 @@
+package helm3
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBinarySource_ValidatePath(t *testing.T) {
+	source := BinarySource{
+		URL:      "https://artifacts.example.com/helm.tar.gz",
+		Checksum: "9a9b3a3f7b9a6f8a0f8c8c4f2f0c4b8e3a1a2b3c4d5e6f708192a3b4c5d6e7f8",
+	}
+	for _, valid := range []string{"helm", "linux-amd64/helm"} {
+		source.Path = valid
+		assert.NoError(t, source.validate("helmSource"), valid)
+	}
+	for _, invalid := range []string{"/usr/bin/helm", "./helm", "../helm", ".", "linux-amd64/../helm", "linux-amd64//helm"} {
+		source.Path = invalid
+		require.EqualError(t, source.validate("helmSource"),
+			`supplied helmSource path "`+invalid+`" must be relative to the root of the archive`)
+	}
+}
+
+func TestBinarySource_ValidateSignature(t *testing.T) {
+	source := BinarySource{
+		URL:       "http://artifacts.example.com/helm",
+		Checksum:  "9a9b3a3f7b9a6f8a0f8c8c4f2f0c4b8e3a1a2b3c4d5e6f708192a3b4c5d6e7f8",
+		Signature: "https://artifacts.example.com/helm.asc",
+		Key:       "https://artifacts.example.com/KEYS",
+	}
+	assert.NoError(t, source.validate("helmSource"))
+
+	insecureKey := source
+	insecureKey.Key = "http://artifacts.example.com/KEYS"
+	require.EqualError(t, insecureKey.validate("helmSource"),
+		`supplied helmSource key "http://artifacts.example.com/KEYS" must be an https URL`)
+
+	insecureSignature := source
+	insecureSignature.Signature = "http://artifacts.example.com/helm.asc"
+	require.EqualError(t, insecureSignature.validate("helmSource"),
+		`supplied helmSource signature "http://artifacts.example.com/helm.asc" must be an https URL`)
+}
+
+func TestBinarySource_InstallCommandsQuoted(t *testing.T) {
+	source := BinarySource{
+		URL:      "https://artifacts.example.com/helm.tar.gz?token=a&b=c",
+		Path:     "linux amd64/helm",
+		Checksum: "9a9b3a3f7b9a6f8a0f8c8c4f2f0c4b8e3a1a2b3c4d5e6f708192a3b4c5d6e7f8",
+	}
+	commands, cleanup := source.installCommands("helm-source", "/usr/local/bin/helm")
+	assert.Equal(t, []string{
+		"curl -fsSL -o helm-source 'https://artifacts.example.com/helm.tar.gz?token=a&b=c'",
+		"echo '9a9b3a3f7b9a6f8a0f8c8c4f2f0c4b8e3a1a2b3c4d5e6f708192a3b4c5d6e7f8  helm-source' | sha256sum -c -",
+		"tar -xzf helm-source 'linux amd64/helm'",
+		"install -m 0755 'linux amd64/helm' /usr/local/bin/helm",
+	}, commands)
+	assert.Equal(t, []string{"helm-source", "'linux amd64/helm'"}, cleanup)
+}
@@ -0,0 +1,80 @@
+package helm3
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// spoolDir is the directory of the temporary files where the mixin spools the outputs of helm, such as the manifest
+// of a release, until the outputs of the step are written
+var spoolDir = filepath.Join(os.TempDir(), "porter-helm3", "spool")
+
+// spooledFile is a temporary file of the spool directory, read back from the start with Seek
+type spooledFile interface {
+	io.ReadWriteSeeker
+	io.Closer
+	Name() string
+}
+
+// spooledFiles are the spooled files opened by a step, closed when they are removed
+type spooledFiles struct {
+	mu    sync.Mutex
+	files []spooledFile
+}
+
+// spoolFile creates a temporary file in the spool directory, which is closed by removeSpool
+func (m *Mixin) spoolFile(pattern string) (spooledFile, error) {
+	err := m.FileSystem.MkdirAll(spoolDir, 0700)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not create the spool directory %s", spoolDir)
+	}
+	f, err := m.FileSystem.TempFile(spoolDir, pattern)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not create a spool file in %s", spoolDir)
+	}
+	if m.spooled != nil {
+		m.spooled.mu.Lock()
+		m.spooled.files = append(m.spooled.files, f)
+		m.spooled.mu.Unlock()
+	}
+	return f, nil
+}
+
+// removeSpool closes and removes the spooled files once the outputs of a step are written
+func (m *Mixin) removeSpool() {
+	if m.spooled != nil {
+		m.spooled.mu.Lock()
+		for _, f := range m.spooled.files {
+			// the files already closed by the commands that spooled their output return an error that is ignored
+			f.Close()
+		}
+		m.spooled.files = nil
+		m.spooled.mu.Unlock()
+	}
+	if err := m.FileSystem.RemoveAll(spoolDir); err != nil && m.verbose() {
+		fmt.Fprintf(m.Err, "could not remove the spool directory %s: %s\n", spoolDir, err)
+	}
+}
+
+// rewind seeks a spooled file back to its start, to read what was written to it
+func rewind(f io.Seeker) error {
+	_, err := f.Seek(0, io.SeekStart)
+	return err
+}
+
+// printOutputFrom prints the output of a command read from a spooled file. When the output is suppressed, only its
+// last lines are kept in memory and printed, like printOutput.
+func (m *Mixin) printOutputFrom(r io.Reader) {
+	if !m.outputSuppressed() {
+		io.Copy(m.Out, r)
+		return
+	}
+	tail := newOutputTail(quietOutputLines)
+	io.Copy(tail, r)
+	fmt.Fprint(m.Out, tail.String())
+}
@@ -0,0 +1,120 @@
+package helm3
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"get.porter.sh/porter/pkg/portercontext"
+	"get.porter.sh/porter/pkg/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestMixin_PrintOutputFrom(t *testing.T) {
+	var lines []string
+	for i := 1; i <= quietOutputLines+3; i++ {
+		lines = append(lines, fmt.Sprintf("line %d", i))
+	}
+	output := strings.Join(lines, "\n") + "\n"
+
+	h := NewTestMixin(t)
+	h.printOutputFrom(strings.NewReader(output))
+	assert.Equal(t, output, h.TestContext.GetOutput())
+
+	h = NewTestMixin(t)
+	h.suppressOutput = true
+	h.printOutputFrom(strings.NewReader(output))
+	assert.Equal(t, truncateOutput(output, quietOutputLines), h.TestContext.GetOutput())
+}
+
+func TestReadManifestDocuments(t *testing.T) {
+	// a line longer than the default buffer of a bufio.Scanner
+	dashboard := strings.Repeat("x", 100*1024)
+	manifest := "---\n# Source: grafana/templates/dashboards.yaml\nkind: ConfigMap\ndata:\n  dashboard.json: " + dashboard + "\n---\n# Source: grafana/templates/service.yaml\nkind: Service\n"
+
+	var docs []manifestDocument
+	err := readManifestDocuments(strings.NewReader(manifest), func(doc manifestDocument) error {
+		docs = append(docs, doc)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Len(t, docs, 2)
+	assert.Equal(t, "grafana/templates/dashboards.yaml", docs[0].source)
+	assert.Contains(t, docs[0].content, dashboard)
+	assert.Equal(t, "grafana/templates/service.yaml", docs[1].source)
+}
+
+func TestHasSOPSMetadata(t *testing.T) {
+	assert.True(t, hasSOPSMetadata(strings.NewReader("password: ENC[AES256_GCM,data:abc]\nsops:\n  version: 3.7.3\n")))
+	assert.True(t, hasSOPSMetadata(strings.NewReader("{\n\t\"password\": \"ENC[AES256_GCM,data:abc]\",\n\t\"sops\": {\n\t\t\"version\": \"3.7.3\"\n\t}\n}\n")))
+	assert.False(t, hasSOPSMetadata(strings.NewReader("mysql:\n  sops: false\n")))
+	assert.False(t, hasSOPSMetadata(strings.NewReader("{\n\t\"mysql\": {\n\"sops\": false\n\t},\n\t\"tags\": [{\"sops\": 1}, \"sops\"]\n}\n")),
+		"nested sops keys are not the metadata of SOPS")
+	assert.True(t, hasSOPSMetadata(strings.NewReader("  {\"mysql\": {\"sops\": false}, \"tags\": [[]], \"sops\": {\"version\": \"3.7.3\"}}")))
+	// the key is found after lines longer than the buffer of the reader
+	assert.True(t, hasSOPSMetadata(strings.NewReader("certificate: "+strings.Repeat("a", 10000)+"sops:\nsops:\n  version: 3.7.3")))
+	assert.False(t, hasSOPSMetadata(strings.NewReader("certificate: "+strings.Repeat("a", 10000)+"sops:\n")))
+}
+
+func TestMixin_InstallManifestOutput(t *testing.T) {
+	ctx := context.Background()
+	defer os.Unsetenv(test.ExpectedCommandEnv)
+	defer os.Unsetenv(test.ExpectedCommandOutputEnv)
+	os.Setenv(test.ExpectedCommandEnv, "helm3 upgrade --install redis bitnami/redis --atomic --create-namespace --output json")
+	os.Setenv(test.ExpectedCommandOutputEnv, `{"name":"redis","namespace":"default","version":1,"info":{"status":"deployed"},`+
+		`"manifest":"kind: Deployment\nmetadata:\n  name: redis\n"}`)
+
+	action := InstallAction{Steps: []InstallStep{{
+		InstallArguments: InstallArguments{
+			Step: Step{
+				Description: "Install Redis",
+				Outputs: []HelmOutput{
+					{Name: "redis-manifest", ReleaseField: "manifest", Path: "/cnab/app/redis/manifest.yaml"},
+				},
+			},
+			Name:  "redis",
+			Chart: "bitnami/redis",
+		},
+	}}}
+	b, _ := yaml.Marshal(action)
+
+	h := NewTestMixin(t)
+	h.In = bytes.NewReader(b)
+
+	err := h.Install(ctx)
+	require.NoError(t, err)
+
+	manifest, err := h.FileSystem.ReadFile(filepath.Join(portercontext.MixinOutputsDir, "redis-manifest"))
+	require.NoError(t, err)
+	assert.Equal(t, "kind: Deployment\nmetadata:\n  name: redis\n", string(manifest))
+	copied, err := h.FileSystem.ReadFile("/cnab/app/redis/manifest.yaml")
+	require.NoError(t, err)
+	assert.Equal(t, string(manifest), string(copied))
+
+	spooled, err := h.FileSystem.Exists(spoolDir)
+	require.NoError(t, err)
+	assert.False(t, spooled, "the spooled release should be removed once the outputs are written")
+}
+
+func TestMixin_RemoveSpool(t *testing.T) {
+	h := NewTestMixin(t)
+	// the copies of the mixin that run parallel releases share the spooled files of the step
+	f, err := h.withOutput(h.Out, h.Err).spoolFile("manifest-*.yaml")
+	require.NoError(t, err)
+	_, err = io.WriteString(f, "kind: Service\n")
+	require.NoError(t, err)
+
+	h.removeSpool()
+	_, err = io.WriteString(f, "kind: Secret\n")
+	assert.Error(t, err, "the spooled file should be closed")
+	exists, err := h.FileSystem.Exists(spoolDir)
+	require.NoError(t, err)
+	assert.False(t, exists, "the spool directory should be removed")
+}
@@ -0,0 +1,131 @@
+package helm3
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// ReleaseStatus reports the status of a release, with the resources it deployed when ShowResources is set
+type ReleaseStatus struct {
+	Release string `yaml:"release"`
+	// ShowResources lists the resources of the release by kind, with helm status --show-resources
+	ShowResources bool `yaml:"showResources,omitempty"`
+}
+
+// releaseStatusReport is the structured status of a release printed by the status step
+type releaseStatusReport struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+	Revision  int    `json:"revision"`
+	Status    string `json:"status"`
+	// Resources are the names of the resources of the release, by kind, for example v1/Pod(related)
+	Resources map[string][]string `json:"resources,omitempty"`
+}
+
+// resourceObject is a resource listed by helm status, either an object or a table of objects
+type resourceObject struct {
+	Kind     string `json:"kind"`
+	Metadata struct {
+		Name string `json:"name"`
+	} `json:"metadata"`
+	Items []resourceObject `json:"items"`
+	Rows  []struct {
+		Cells  []interface{}  `json:"cells"`
+		Object resourceObject `json:"object"`
+	} `json:"rows"`
+}
+
+// names returns the names of the objects of a resource listed by helm status
+func (o resourceObject) names() []string {
+	var names []string
+	if o.Metadata.Name != "" && o.Kind != "Table" {
+		names = append(names, o.Metadata.Name)
+	}
+	for _, item := range o.Items {
+		names = append(names, item.names()...)
+	}
+	for _, row := range o.Rows {
+		if row.Object.Metadata.Name != "" {
+			names = append(names, row.Object.Metadata.Name)
+		} else if len(row.Cells) > 0 {
+			names = append(names, fmt.Sprint(row.Cells[0]))
+		}
+	}
+	return names
+}
+
+// status prints the status of a release as json and returns it
+func (m *Mixin) status(ctx context.Context, step ExecuteStep) (string, error) {
+	status := *step.Status
+	if len(step.Arguments) > 0 || step.Plugin != "" {
+		return "", errors.New("status cannot be set together with arguments or plugin")
+	}
+	if status.Release == "" {
+		return "", errors.New("status requires a release")
+	}
+
+	cmd := m.helmCommand(ctx, "status", status.Release, "--output", "json")
+	if step.Namespace != "" {
+		cmd.Args = append(cmd.Args, "--namespace", step.Namespace)
+	}
+	if status.ShowResources {
+		cmd.Args = append(cmd.Args, "--show-resources")
+	}
+	if m.planMode() {
+		m.printPlannedCommand(cmd)
+		return "", nil
+	}
+	m.setStepEnv(cmd, step.Env)
+	m.echoCommand(formatPlannedCommand(cmd.Args))
+
+	stderr := &bytes.Buffer{}
+	cmd.Stderr = io.MultiWriter(m.Err, stderr)
+	out, err := cmd.Output()
+	if err != nil {
+		return "", errors.Wrapf(classifyHelmError(err, stderr.String()), "could not get the status of release %s", status.Release)
+	}
+
+	var release struct {
+		Name      string `json:"name"`
+		Namespace string `json:"namespace"`
+		Version   int    `json:"version"`
+		Info      struct {
+			Status    string                      `json:"status"`
+			Resources map[string][]resourceObject `json:"resources"`
+		} `json:"info"`
+	}
+	if err := json.Unmarshal(out, &release); err != nil {
+		return "", errors.Wrapf(err, "could not parse the status of release %s", status.Release)
+	}
+
+	report := releaseStatusReport{
+		Name:      release.Name,
+		Namespace: release.Namespace,
+		Revision:  release.Version,
+		Status:    release.Info.Status,
+	}
+	if status.ShowResources {
+		report.Resources = make(map[string][]string, len(release.Info.Resources))
+		for kind, objects := range release.Info.Resources {
+			names := []string{}
+			for _, o := range objects {
+				names = append(names, o.names()...)
+			}
+			sort.Strings(names)
+			report.Resources[kind] = names
+		}
+	}
+
+	b, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return "", errors.Wrapf(err, "could not print the status of release %s", status.Release)
+	}
+	fmt.Fprintln(m.Out, string(b))
+	return string(b), nil
+}
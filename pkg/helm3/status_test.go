@@ -0,0 +1,95 @@
+package helm3
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"get.porter.sh/porter/pkg/portercontext"
+	"get.porter.sh/porter/pkg/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestMixin_ExecuteStatusShowResources(t *testing.T) {
+	ctx := context.Background()
+
+	defer os.Unsetenv(test.ExpectedCommandEnv)
+	defer os.Unsetenv(test.ExpectedCommandOutputEnv)
+	os.Setenv(test.ExpectedCommandEnv, "helm3 status mysql --output json --namespace db --show-resources")
+	os.Setenv(test.ExpectedCommandOutputEnv, `{"name":"mysql","namespace":"db","version":4,"info":{"status":"deployed","resources":{
+"v1/Service":[{"kind":"Service","metadata":{"name":"mysql-headless"}},{"kind":"Service","metadata":{"name":"mysql"}}],
+"apps/v1/StatefulSet":[{"kind":"StatefulSet","metadata":{"name":"mysql"}}],
+"v1/Pod(related)":[{"kind":"Table","rows":[{"cells":["mysql-0","1/1","Running"],"object":{"kind":"PartialObjectMetadata","metadata":{"name":"mysql-0"}}}]}]}}}`)
+
+	executeAction := Action{
+		Steps: []ExecuteSteps{
+			{
+				ExecuteStep: ExecuteStep{
+					Step: Step{
+						Description: "MySQL status",
+						Outputs:     []HelmOutput{{Name: "mysql-status", Stdout: true}},
+					},
+					Namespace: "db",
+					Status:    &ReleaseStatus{Release: "mysql", ShowResources: true},
+				},
+			},
+		},
+	}
+
+	b, _ := yaml.Marshal(executeAction)
+
+	h := NewTestMixin(t)
+	h.In = bytes.NewReader(b)
+
+	err := h.Execute(ctx)
+	require.NoError(t, err)
+
+	want := `{
+  "name": "mysql",
+  "namespace": "db",
+  "revision": 4,
+  "status": "deployed",
+  "resources": {
+    "apps/v1/StatefulSet": [
+      "mysql"
+    ],
+    "v1/Pod(related)": [
+      "mysql-0"
+    ],
+    "v1/Service": [
+      "mysql",
+      "mysql-headless"
+    ]
+  }
+}`
+	status, err := h.FileSystem.ReadFile(filepath.Join(portercontext.MixinOutputsDir, "mysql-status"))
+	require.NoError(t, err)
+	assert.Equal(t, want, string(status))
+}
+
+func TestMixin_ExecuteStatusRequiresRelease(t *testing.T) {
+	ctx := context.Background()
+
+	executeAction := Action{
+		Steps: []ExecuteSteps{
+			{
+				ExecuteStep: ExecuteStep{
+					Arguments: []string{"status", "mysql"},
+					Status:    &ReleaseStatus{Release: "mysql"},
+				},
+			},
+		},
+	}
+
+	b, _ := yaml.Marshal(executeAction)
+
+	h := NewTestMixin(t)
+	h.In = bytes.NewReader(b)
+
+	err := h.Execute(ctx)
+	require.EqualError(t, err, "status cannot be set together with arguments or plugin")
+}
@@ -1,8 +1,26 @@
 package helm3
 
+import (
+	"fmt"
+	"os/exec"
+	"sort"
+)
+
 type Step struct {
-	Description string       `yaml:"description"`
-	Outputs     []HelmOutput `yaml:"outputs,omitempty"`
+	Description string            `yaml:"description"`
+	Outputs     []HelmOutput      `yaml:"outputs,omitempty"`
+	Env         map[string]string `yaml:"env,omitempty"`
+	// Verbosity controls what the step prints: quiet, normal, verbose or trace
+	Verbosity string `yaml:"verbosity,omitempty"`
+	// SuppressOutput hides the output of helm, such as the notes of the chart, the end of the output is printed when it fails
+	SuppressOutput bool `yaml:"suppressOutput,omitempty"`
+	// GlobalFlags overrides the globalFlags of the mixin configuration for the helm commands of the step,
+	// a null value removes a flag
+	GlobalFlags map[string]*string `yaml:"globalFlags,omitempty"`
+	// Before are helm or kubectl commands run before the helm commands of the step
+	Before []StepHook `yaml:"before,omitempty"`
+	// After are helm or kubectl commands run once the helm commands of the step succeeded
+	After []StepHook `yaml:"after,omitempty"`
 }
 
 type HelmOutput struct {
@@ -13,4 +31,50 @@ type HelmOutput struct {
 	ResourceName string `yaml:"resourceName,omitempty"`
 	Namespace    string `yaml:"namespace,omitempty"`
 	JSONPath     string `yaml:"jsonPath,omitempty"`
+
+	// TLS assembles the keys of the TLS secret into a PEM bundle or a kubeconfig, instead of reading a single key
+	TLS *TLSOutput `yaml:"tls,omitempty"`
+
+	// Path is a file of the invocation image where the value is also written, for the following steps of the action
+	Path string `yaml:"path,omitempty"`
+
+	// WaitTimeout waits until the resource exists and JSONPath returns a value, for at most the duration
+	WaitTimeout string `yaml:"waitTimeout,omitempty"`
+
+	// Address waits until the Ingress or LoadBalancer Service of ResourceType and ResourceName is assigned an address
+	// by its load balancer, and writes its hostname or ip to the output
+	Address bool `yaml:"address,omitempty"`
+
+	// HelmStatusPath is a jsonpath expression evaluated against the release record printed by helm status,
+	// for the release selected by Release
+	HelmStatusPath string `yaml:"helmStatusPath,omitempty"`
+
+	// Stdout writes the output of the command run by a custom action to the output
+	Stdout bool `yaml:"stdout,omitempty"`
+
+	// Release selects the release used by ReleaseField and HelmStatusPath, it may be omitted when the step manages a single release
+	Release string `yaml:"release,omitempty"`
+	// ReleaseField is the field of the release result written to the output: name, namespace, revision, status, manifest, images or appVersion
+	ReleaseField string `yaml:"releaseField,omitempty"`
+}
+
+// setStepEnv exports the environment variables declared on the step to the helm command,
+// in addition to the environment of the mixin.
+func (m *Mixin) setStepEnv(cmd *exec.Cmd, env map[string]string) {
+	if len(env) == 0 {
+		return
+	}
+	if cmd.Env == nil {
+		cmd.Env = m.Environ()
+	}
+
+	// sort the env consistently
+	keys := make([]string, 0, len(env))
+	for k := range env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", k, env[k]))
+	}
 }
@@ -0,0 +1,21 @@
+package helm3
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMixin_SetStepEnv(t *testing.T) {
+	m := NewTestMixin(t)
+
+	cmd := m.NewCommand(context.Background(), "helm3", "version")
+	m.setStepEnv(cmd, map[string]string{
+		"HELM_PLUGINS":       "/cnab/app/plugins",
+		"AWS_DEFAULT_REGION": "us-east-1",
+	})
+
+	n := len(cmd.Env)
+	assert.Equal(t, []string{"AWS_DEFAULT_REGION=us-east-1", "HELM_PLUGINS=/cnab/app/plugins"}, cmd.Env[n-2:])
+}
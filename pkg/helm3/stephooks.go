@@ -0,0 +1,81 @@
+package helm3
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// hookBefore runs the hooks of a step before its helm commands
+	hookBefore = "before"
+	// hookAfter runs the hooks of a step once its helm commands succeeded
+	hookAfter = "after"
+)
+
+// StepHook is a small helm or kubectl command run by a step before or after its helm commands,
+// so that simple orchestration doesn't require a separate exec step
+type StepHook struct {
+	// Command is the binary that is run: helm or kubectl
+	Command string `yaml:"command"`
+	// Arguments are passed as-is to the command
+	Arguments []string `yaml:"arguments,omitempty"`
+	// ContinueOnError prints the failure of the command instead of failing the step
+	ContinueOnError bool `yaml:"continueOnError,omitempty"`
+}
+
+// validateStepHooks checks that every hook of a step can be run, before anything is deployed
+func (m *Mixin) validateStepHooks(step Step) error {
+	phases := []struct {
+		name  string
+		hooks []StepHook
+	}{{hookBefore, step.Before}, {hookAfter, step.After}}
+	for _, phase := range phases {
+		for i, hook := range phase.hooks {
+			switch hook.Command {
+			case "helm":
+				command, ok := helmCommandName(hook.Arguments)
+				if !ok {
+					return errors.Errorf("the arguments of %s hook %d must start with a helm command", phase.name, i+1)
+				}
+				err := m.validateHelmCommand(command)
+				if err != nil {
+					return errors.Wrapf(err, "invalid %s hook %d", phase.name, i+1)
+				}
+			case "kubectl":
+				if len(hook.Arguments) == 0 {
+					return errors.Errorf("%s hook %d requires the arguments of kubectl", phase.name, i+1)
+				}
+			default:
+				return errors.Errorf("unsupported command %q of %s hook %d, expected helm or kubectl", hook.Command, phase.name, i+1)
+			}
+		}
+	}
+	return nil
+}
+
+// runStepHooks runs the hooks of a step for a phase, in order, with the environment of the step.
+// A failed hook fails the step unless it continues on error.
+func (m *Mixin) runStepHooks(ctx context.Context, step Step, hooks []StepHook, phase string) error {
+	for i, hook := range hooks {
+		var cmd *exec.Cmd
+		if hook.Command == "helm" {
+			cmd = m.helmCommand(ctx, hook.Arguments...)
+		} else {
+			cmd = m.NewCommand(ctx, "kubectl", hook.Arguments...)
+		}
+		m.setStepEnv(cmd, step.Env)
+
+		err := m.runCommand(cmd)
+		if err != nil {
+			if hook.ContinueOnError {
+				fmt.Fprintf(m.Err, "Ignoring the failure of %s hook %d: %s\n", phase, i+1, err)
+				continue
+			}
+			return errors.Wrapf(err, "%s hook %d failed", phase, i+1)
+		}
+	}
+	return nil
+}
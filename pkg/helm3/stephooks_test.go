@@ -0,0 +1,123 @@
+package helm3
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"get.porter.sh/porter/pkg/test"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestMixin_InstallStepHooks(t *testing.T) {
+	ctx := context.Background()
+	defer os.Unsetenv(test.ExpectedCommandEnv)
+	os.Setenv(test.ExpectedCommandEnv, strings.Join([]string{
+		"helm3 repo update",
+		"kubectl label namespace db team=data --overwrite",
+		"helm3 upgrade --install mysql stable/mysql --namespace db --atomic --create-namespace --output json",
+		"kubectl rollout restart deployment/api --namespace db",
+	}, "\n"))
+
+	action := InstallAction{Steps: []InstallStep{{
+		InstallArguments: InstallArguments{
+			Step: Step{
+				Description: "Install MySQL",
+				Before: []StepHook{
+					{Command: "helm", Arguments: []string{"repo", "update"}},
+					{Command: "kubectl", Arguments: []string{"label", "namespace", "db", "team=data", "--overwrite"}},
+				},
+				After: []StepHook{
+					{Command: "kubectl", Arguments: []string{"rollout", "restart", "deployment/api", "--namespace", "db"}},
+				},
+			},
+			Name:      "mysql",
+			Chart:     "stable/mysql",
+			Namespace: "db",
+		},
+	}}}
+	b, _ := yaml.Marshal(action)
+
+	h := NewTestMixin(t)
+	h.In = bytes.NewReader(b)
+
+	err := h.Install(ctx)
+	require.NoError(t, err)
+}
+
+func TestMixin_UninstallStepHooks(t *testing.T) {
+	ctx := context.Background()
+	defer os.Unsetenv(test.ExpectedCommandEnv)
+	os.Setenv(test.ExpectedCommandEnv, strings.Join([]string{
+		"kubectl scale deployment/api --replicas 0 --namespace db",
+		"helm3 uninstall mysql --namespace db",
+		"kubectl delete namespace db",
+	}, "\n"))
+
+	action := UninstallAction{Steps: []UninstallStep{{
+		UninstallArguments: UninstallArguments{
+			Step: Step{
+				Description: "Uninstall MySQL",
+				Before:      []StepHook{{Command: "kubectl", Arguments: []string{"scale", "deployment/api", "--replicas", "0", "--namespace", "db"}}},
+				After:       []StepHook{{Command: "kubectl", Arguments: []string{"delete", "namespace", "db"}}},
+			},
+			Namespace: "db",
+			Releases:  []string{"mysql"},
+		},
+	}}}
+	b, _ := yaml.Marshal(action)
+
+	h := NewTestMixin(t)
+	h.In = bytes.NewReader(b)
+
+	err := h.Uninstall(ctx)
+	require.NoError(t, err)
+}
+
+func TestMixin_RunStepHooksFailure(t *testing.T) {
+	ctx := context.Background()
+	defer os.Unsetenv(test.ExpectedCommandEnv)
+	defer os.Unsetenv(test.ExpectedCommandExitCodeEnv)
+	os.Setenv(test.ExpectedCommandEnv, "kubectl delete job/migrate --namespace db")
+	os.Setenv(test.ExpectedCommandExitCodeEnv, "1")
+
+	hook := StepHook{Command: "kubectl", Arguments: []string{"delete", "job/migrate", "--namespace", "db"}}
+
+	t.Run("fails the step", func(t *testing.T) {
+		h := NewTestMixin(t)
+		err := h.runStepHooks(ctx, Step{}, []StepHook{hook}, hookBefore)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "before hook 1 failed")
+	})
+
+	t.Run("continue on error", func(t *testing.T) {
+		hook := hook
+		hook.ContinueOnError = true
+		h := NewTestMixin(t)
+		err := h.runStepHooks(ctx, Step{}, []StepHook{hook}, hookBefore)
+		require.NoError(t, err)
+		require.Contains(t, h.TestContext.GetError(), "Ignoring the failure of before hook 1")
+	})
+}
+
+func TestMixin_ValidateStepHooks(t *testing.T) {
+	h := NewTestMixin(t)
+
+	require.NoError(t, h.validateStepHooks(Step{
+		Before: []StepHook{{Command: "helm", Arguments: []string{"repo", "update"}}},
+		After:  []StepHook{{Command: "kubectl", Arguments: []string{"get", "pods"}}},
+	}))
+	require.EqualError(t, h.validateStepHooks(Step{After: []StepHook{{Command: "curl", Arguments: []string{"example.com"}}}}),
+		`unsupported command "curl" of after hook 1, expected helm or kubectl`)
+	require.EqualError(t, h.validateStepHooks(Step{Before: []StepHook{{Command: "helm", Arguments: []string{"--debug"}}}}),
+		"the arguments of before hook 1 must start with a helm command")
+	require.EqualError(t, h.validateStepHooks(Step{Before: []StepHook{{Command: "kubectl"}}}),
+		"before hook 1 requires the arguments of kubectl")
+
+	err := h.validateStepHooks(Step{Before: []StepHook{{Command: "helm", Arguments: []string{"unittest", "./charts/mysql"}}}})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), `invalid before hook 1: helm command "unittest" is not allowed`)
+}
@@ -0,0 +1,35 @@
+package helm3
+
+import (
+	"strconv"
+)
+
+// Subchart enables or disables a dependency of an umbrella chart and sets values scoped to it
+type Subchart struct {
+	// Enabled sets the enabled value of the subchart, it is left to the chart default when unset
+	Enabled *bool `yaml:"enabled,omitempty"`
+	// Set values relative to the subchart, without the subchart name as a prefix
+	Set map[string]string `yaml:"set,omitempty"`
+}
+
+// subchartValues returns the set values of the release merged with the values of its subcharts,
+// prefixed with the subchart name. Values set on the release take precedence over the subchart values.
+func subchartValues(subcharts map[string]Subchart, set map[string]string) map[string]string {
+	if len(subcharts) == 0 {
+		return set
+	}
+
+	values := make(map[string]string, len(set))
+	for name, subchart := range subcharts {
+		if subchart.Enabled != nil {
+			values[name+".enabled"] = strconv.FormatBool(*subchart.Enabled)
+		}
+		for k, v := range subchart.Set {
+			values[name+"."+k] = v
+		}
+	}
+	for k, v := range set {
+		values[k] = v
+	}
+	return values
+}
@@ -0,0 +1,63 @@
+package helm3
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"testing"
+
+	"get.porter.sh/porter/pkg/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestSubchartValues(t *testing.T) {
+	enabled, disabled := true, false
+	subcharts := map[string]Subchart{
+		"redis":      {Enabled: &disabled},
+		"postgresql": {Enabled: &enabled, Set: map[string]string{"auth.database": "platform", "auth.username": "porter"}},
+		"kafka":      {Set: map[string]string{"replicaCount": "3"}},
+	}
+
+	values := subchartValues(subcharts, map[string]string{"postgresql.auth.username": "admin", "image.tag": "1.0"})
+	assert.Equal(t, map[string]string{
+		"redis.enabled":            "false",
+		"postgresql.enabled":       "true",
+		"postgresql.auth.database": "platform",
+		"postgresql.auth.username": "admin",
+		"kafka.replicaCount":       "3",
+		"image.tag":                "1.0",
+	}, values)
+
+	set := map[string]string{"image.tag": "1.0"}
+	assert.Equal(t, set, subchartValues(nil, set))
+}
+
+func TestMixin_UpgradeSubcharts(t *testing.T) {
+	ctx := context.Background()
+	defer os.Unsetenv(test.ExpectedCommandEnv)
+	os.Setenv(test.ExpectedCommandEnv, "helm3 upgrade --install platform example/platform --atomic --create-namespace --output json "+
+		"--set image.tag=1.0 --set postgresql.auth.database=platform --set postgresql.enabled=true --set redis.enabled=false")
+
+	disabled, enabled := false, true
+	action := UpgradeAction{Steps: []UpgradeStep{{
+		UpgradeArguments: UpgradeArguments{
+			Step:  Step{Description: "Upgrade the platform"},
+			Name:  "platform",
+			Chart: "example/platform",
+			Set:   map[string]string{"image.tag": "1.0"},
+			Subcharts: map[string]Subchart{
+				"redis":      {Enabled: &disabled},
+				"postgresql": {Enabled: &enabled, Set: map[string]string{"auth.database": "platform"}},
+			},
+		},
+	}}}
+	b, _ := yaml.Marshal(action)
+
+	h := NewTestMixin(t)
+	h.In = bytes.NewReader(b)
+
+	err := h.Upgrade(ctx)
+	require.NoError(t, err)
+}
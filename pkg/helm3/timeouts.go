@@ -0,0 +1,66 @@
+package helm3
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// timeoutGracePeriod is added to the timeout of a command for the deadline of its context,
+// so that helm and kubectl report their own timeout before the mixin stops them
+const timeoutGracePeriod = 30 * time.Second
+
+// timeoutField is a timeout of a step and the name of its field, for example canary.timeout
+type timeoutField struct {
+	name  string
+	value string
+}
+
+// parseTimeout parses a timeout field as a Go duration, for example 90s or 5m30s. An empty field has no timeout.
+func parseTimeout(field string, value string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+	timeout, err := time.ParseDuration(value)
+	if err != nil || timeout <= 0 {
+		return 0, errors.Errorf("invalid %s %q, expected a positive duration such as 90s or 5m30s", field, value)
+	}
+	return timeout, nil
+}
+
+// validateTimeouts checks every timeout of a step before anything is deployed
+func validateTimeouts(fields ...timeoutField) error {
+	for _, field := range fields {
+		if _, err := parseTimeout(field.name, field.value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// outputTimeouts returns the waitTimeout field of each output
+func outputTimeouts(outputs []HelmOutput) []timeoutField {
+	fields := make([]timeoutField, 0, len(outputs))
+	for _, output := range outputs {
+		fields = append(fields, timeoutField{"waitTimeout of output " + output.Name, output.WaitTimeout})
+	}
+	return fields
+}
+
+// appendTimeout appends the --timeout flag of helm or kubectl, which parse the duration the same way as the mixin
+func appendTimeout(args []string, timeout string) []string {
+	if timeout == "" {
+		return args
+	}
+	return append(args, "--timeout", timeout)
+}
+
+// withTimeout bounds a context to a timeout and its grace period, the context is left unchanged without a timeout
+func withTimeout(ctx context.Context, timeout string) (context.Context, context.CancelFunc) {
+	d, err := time.ParseDuration(timeout)
+	if timeout == "" || err != nil || d <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, d+timeoutGracePeriod)
+}
@@ -0,0 +1,78 @@
+package helm3
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"get.porter.sh/porter/pkg/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestParseTimeout(t *testing.T) {
+	testcases := []struct {
+		value   string
+		want    time.Duration
+		wantErr string
+	}{
+		{value: "", want: 0},
+		{value: "90s", want: 90 * time.Second},
+		{value: "5m30s", want: 5*time.Minute + 30*time.Second},
+		{value: "600", wantErr: `invalid timeout "600", expected a positive duration such as 90s or 5m30s`},
+		{value: "-5m", wantErr: `invalid timeout "-5m", expected a positive duration such as 90s or 5m30s`},
+		{value: "five minutes", wantErr: `invalid timeout "five minutes", expected a positive duration such as 90s or 5m30s`},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.value, func(t *testing.T) {
+			got, err := parseTimeout("timeout", tc.value)
+			if tc.wantErr != "" {
+				require.EqualError(t, err, tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestWithTimeout(t *testing.T) {
+	ctx, cancel := withTimeout(context.Background(), "90s")
+	defer cancel()
+	deadline, ok := ctx.Deadline()
+	require.True(t, ok, "expected the context to have a deadline")
+	assert.WithinDuration(t, time.Now().Add(90*time.Second+timeoutGracePeriod), deadline, time.Second)
+
+	ctx, cancel = withTimeout(context.Background(), "")
+	defer cancel()
+	_, ok = ctx.Deadline()
+	assert.False(t, ok, "expected no deadline without a timeout")
+}
+
+func TestMixin_UpgradeInvalidTimeout(t *testing.T) {
+	ctx := context.Background()
+	defer os.Unsetenv(test.ExpectedCommandEnv)
+	// no command is expected, the step fails before anything is deployed
+	os.Setenv(test.ExpectedCommandEnv, "")
+
+	action := UpgradeAction{Steps: []UpgradeStep{{
+		UpgradeArguments: UpgradeArguments{
+			Step:    Step{Description: "Upgrade MySQL"},
+			Name:    "mysql",
+			Chart:   "bitnami/mysql",
+			Timeout: "5m",
+			Canary:  &Canary{Weight: 10, Timeout: "300"},
+		},
+	}}}
+	b, _ := yaml.Marshal(action)
+
+	h := NewTestMixin(t)
+	h.In = bytes.NewReader(b)
+
+	err := h.Upgrade(ctx)
+	require.EqualError(t, err, `invalid canary.timeout "300", expected a positive duration such as 90s or 5m30s`)
+}
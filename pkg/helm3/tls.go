@@ -0,0 +1,129 @@
+package helm3
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Keys of the kubernetes.io/tls secrets created by cert-manager and most charts that generate certificates
+const (
+	tlsCertKey = "tls.crt"
+	tlsKeyKey  = "tls.key"
+	tlsCAKey   = "ca.crt"
+)
+
+// TLSOutput assembles the certificate, key and certificate authority of a TLS secret into a single output
+type TLSOutput struct {
+	// Format of the output: pem for a PEM bundle, or kubeconfig for a kubeconfig using the certificate to authenticate
+	Format string `yaml:"format"`
+	// Server is the address of the kubernetes api server written to a kubeconfig
+	Server string `yaml:"server,omitempty"`
+}
+
+// kubeconfig is the subset of the kubeconfig format needed to authenticate with a client certificate
+type kubeconfig struct {
+	APIVersion     string              `yaml:"apiVersion"`
+	Kind           string              `yaml:"kind"`
+	Clusters       []kubeconfigCluster `yaml:"clusters"`
+	Users          []kubeconfigUser    `yaml:"users"`
+	Contexts       []kubeconfigContext `yaml:"contexts"`
+	CurrentContext string              `yaml:"current-context"`
+}
+
+type kubeconfigCluster struct {
+	Name    string `yaml:"name"`
+	Cluster struct {
+		Server                   string `yaml:"server"`
+		CertificateAuthorityData string `yaml:"certificate-authority-data,omitempty"`
+	} `yaml:"cluster"`
+}
+
+type kubeconfigUser struct {
+	Name string `yaml:"name"`
+	User struct {
+		ClientCertificateData string `yaml:"client-certificate-data"`
+		ClientKeyData         string `yaml:"client-key-data"`
+	} `yaml:"user"`
+}
+
+type kubeconfigContext struct {
+	Name    string `yaml:"name"`
+	Context struct {
+		Cluster string `yaml:"cluster"`
+		User    string `yaml:"user"`
+	} `yaml:"context"`
+}
+
+// getTLSOutput reads a TLS secret and assembles its keys in the format of the output
+func (m *Mixin) getTLSOutput(ctx context.Context, client kubernetes.Interface, namespace string, output HelmOutput) ([]byte, error) {
+	if namespace == "" {
+		namespace = "default"
+	}
+	secret, err := client.CoreV1().Secrets(namespace).Get(ctx, output.Secret, metav1.GetOptions{})
+	if err != nil {
+		return nil, errors.Wrapf(err, "error getting secret %s/%s", namespace, output.Secret)
+	}
+	for _, key := range []string{tlsCertKey, tlsKeyKey} {
+		if len(secret.Data[key]) == 0 {
+			return nil, errors.Errorf("couldn't find key %s in secret %s/%s", key, namespace, output.Secret)
+		}
+	}
+
+	switch output.TLS.Format {
+	case "pem":
+		return pemBundle(secret.Data[tlsCertKey], secret.Data[tlsCAKey], secret.Data[tlsKeyKey]), nil
+	case "kubeconfig":
+		if output.TLS.Server == "" {
+			return nil, errors.Errorf("output %s must set the server of the kubeconfig", output.Name)
+		}
+		return tlsKubeconfig(output.Name, output.TLS.Server, secret.Data[tlsCertKey], secret.Data[tlsKeyKey], secret.Data[tlsCAKey])
+	}
+	return nil, errors.Errorf("unsupported tls format %q for output %s, expected pem or kubeconfig", output.TLS.Format, output.Name)
+}
+
+// pemBundle concatenates the certificate, its certificate authority and the key, each ending with a new line
+func pemBundle(blocks ...[]byte) []byte {
+	bundle := &bytes.Buffer{}
+	for _, block := range blocks {
+		if len(block) == 0 {
+			continue
+		}
+		bundle.Write(block)
+		if !bytes.HasSuffix(block, []byte("\n")) {
+			bundle.WriteString("\n")
+		}
+	}
+	return bundle.Bytes()
+}
+
+// tlsKubeconfig returns a kubeconfig that authenticates to the server with the client certificate
+func tlsKubeconfig(name, server string, cert, key, ca []byte) ([]byte, error) {
+	cluster := kubeconfigCluster{Name: name}
+	cluster.Cluster.Server = server
+	if len(ca) > 0 {
+		cluster.Cluster.CertificateAuthorityData = base64.StdEncoding.EncodeToString(ca)
+	}
+	user := kubeconfigUser{Name: name}
+	user.User.ClientCertificateData = base64.StdEncoding.EncodeToString(cert)
+	user.User.ClientKeyData = base64.StdEncoding.EncodeToString(key)
+	kubeContext := kubeconfigContext{Name: name}
+	kubeContext.Context.Cluster = name
+	kubeContext.Context.User = name
+
+	config := kubeconfig{
+		APIVersion:     "v1",
+		Kind:           "Config",
+		Clusters:       []kubeconfigCluster{cluster},
+		Users:          []kubeconfigUser{user},
+		Contexts:       []kubeconfigContext{kubeContext},
+		CurrentContext: name,
+	}
+	b, err := yaml.Marshal(config)
+	return b, errors.Wrap(err, "could not marshal the kubeconfig")
+}
@@ -0,0 +1,33 @@
+package helm3
+
+import (
+	"fmt"
+)
+
+const defaultYqVersion = "v4.30.8"
+
+// supportedTools are the helper tools that can be installed in the invocation image with tools
+var supportedTools = []string{"jq", "yq"}
+
+func isSupportedTool(tool string) bool {
+	for _, supported := range supportedTools {
+		if tool == supported {
+			return true
+		}
+	}
+	return false
+}
+
+// buildTools writes the Dockerfile lines that install the helper tools
+func (m *Mixin) buildTools(tools []string) {
+	for _, tool := range tools {
+		switch tool {
+		case "jq":
+			fmt.Fprintln(m.Out, "RUN "+aptInstall("jq"))
+		case "yq":
+			fmt.Fprintf(m.Out, "RUN curl -L https://github.com/mikefarah/yq/releases/download/%s/yq_%s_%s -o /usr/local/bin/yq &&\\\n",
+				defaultYqVersion, m.HelmClientPlatfrom, m.HelmClientArchitecture)
+			fmt.Fprintln(m.Out, "    chmod a+x /usr/local/bin/yq")
+		}
+	}
+}
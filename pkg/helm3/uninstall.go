@@ -9,7 +9,6 @@ import (
 
 	"github.com/hashicorp/go-multierror"
 	"github.com/pkg/errors"
-	"gopkg.in/yaml.v2"
 )
 
 type UninstallAction struct {
@@ -30,6 +29,25 @@ type UninstallArguments struct {
 	Wait      bool     `yaml:"wait"`
 	Timeout   string   `yaml:"timeout"`
 	Debug     bool     `yaml:"debug"`
+
+	// GenerateName uninstalls the release named from the installation name, for a release installed with generateName
+	GenerateName string `yaml:"generateName,omitempty"`
+
+	// ReleaseNameFile reads the name of a release to uninstall from a file, for a release installed with randomName
+	ReleaseNameFile string `yaml:"releaseNameFile,omitempty"`
+
+	// ReleasesFile reads the releases recorded by install and upgrade steps, which are uninstalled in reverse order
+	// after the releases of the step
+	ReleasesFile string `yaml:"releasesFile,omitempty"`
+
+	// Namespaces uninstalls every release of the step from each namespace, instead of the namespace of the step
+	Namespaces NamespaceList `yaml:"namespaces,omitempty"`
+
+	// LockTimeout retries helm while another operation holds the lock of a release, for at most the duration
+	LockTimeout string `yaml:"lockTimeout,omitempty"`
+
+	// ExtraFlags are passed as-is to helm, after the flags managed by the mixin
+	ExtraFlags []string `yaml:"extraFlags,omitempty"`
 }
 
 // Uninstall deletes a provided set of Helm releases, supplying optional flags/params
@@ -40,7 +58,7 @@ func (m *Mixin) Uninstall(ctx context.Context) error {
 	}
 
 	var action UninstallAction
-	err = yaml.Unmarshal(payload, &action)
+	err = m.unmarshal(payload, &action)
 	if err != nil {
 		return err
 	}
@@ -49,54 +67,234 @@ func (m *Mixin) Uninstall(ctx context.Context) error {
 	}
 	step := action.Steps[0]
 
+	err = m.setVerbosity(step.Step)
+	if err != nil {
+		return err
+	}
+
+	err = m.setGlobalFlags(step.Step)
+	if err != nil {
+		return err
+	}
+
+	err = m.validateStepHooks(step.Step)
+	if err != nil {
+		return err
+	}
+
+	err = m.checkStorageDriver()
+	if err != nil {
+		return err
+	}
+
+	removeKubeconfig, err := m.configureKubeToken()
+	if err != nil {
+		return err
+	}
+	defer removeKubeconfig()
+
+	err = m.templateNamespaces(&step.Namespace, step.Namespaces, nil)
+	if err != nil {
+		return err
+	}
+
+	err = validateTimeouts(step.timeouts()...)
+	if err != nil {
+		return err
+	}
+
+	if step.GenerateName != "" {
+		name, err := m.generateReleaseName(step.GenerateName)
+		if err != nil {
+			return err
+		}
+		step.Releases = append(step.Releases, name)
+	}
+
+	if step.ReleaseNameFile != "" {
+		name, exists, err := m.readReleaseName(step.ReleaseNameFile)
+		if err != nil {
+			return err
+		}
+		if exists {
+			step.Releases = append(step.Releases, name)
+		} else {
+			fmt.Fprintf(m.Out, "The release name file %s does not exist, the release was not installed\n", step.ReleaseNameFile)
+		}
+	}
+
+	// Outputs are collected before the releases are deleted, along with the resources they are read from
+	if len(step.Outputs) > 0 && !m.planMode() {
+		kubeClient, err := m.getKubernetesClient()
+		if err != nil {
+			return errors.Wrap(err, "couldn't get kubernetes client")
+		}
+		err = m.handleOutputs(ctx, kubeClient, step.Namespace, step.Outputs, nil)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = validateNamespaces(step.Namespaces, step.Namespace, nil)
+	if err != nil {
+		return err
+	}
+
+	err = m.runStepHooks(ctx, step.Step, step.Before, hookBefore)
+	if err != nil {
+		return err
+	}
+
+	uninstall := func(release string, args UninstallArguments) error {
+		err := m.retryOperationInProgress(ctx, release, args.LockTimeout, func() error {
+			return m.withReleaseLock(release, args.Namespace, step.Description, func() error {
+				return m.delete(ctx, release, args)
+			})
+		})
+		m.recordEvent(ctx, step.Description, "uninstall", release, args.Namespace, err)
+		return err
+	}
+
 	// Delete each release one at a time, because helm stops on first error
 	// This gives us more fine-grained error recovery and handling
 	var result error
-	for _, release := range step.Releases {
-		err = m.delete(ctx, release, step.Namespace, step.NoHooks, step.Wait, step.Timeout, step.Debug)
+	for _, args := range step.namespaced() {
+		for _, release := range args.Releases {
+			err = uninstall(namespacedReleaseName(release, args.Namespace), args)
+			if err != nil {
+				result = multierror.Append(result, err)
+			}
+		}
+	}
+
+	if step.ReleasesFile != "" {
+		err = m.uninstallReleasesFile(step.UninstallArguments, uninstall)
+		if err != nil {
+			result = multierror.Append(result, err)
+		}
+	}
+	if result != nil {
+		return result
+	}
+	return m.runStepHooks(ctx, step.Step, step.After, hookAfter)
+}
+
+// uninstallReleasesFile uninstalls the releases recorded in the releases file of the step in reverse order, in their
+// own namespace or else in the namespace of the step. The releases that could not be uninstalled are kept in the file.
+func (m *Mixin) uninstallReleasesFile(step UninstallArguments, uninstall func(string, UninstallArguments) error) error {
+	releases, exists, err := m.readReleasesFile(step.ReleasesFile)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		fmt.Fprintf(m.Out, "The releases file %s does not exist, no release was installed\n", step.ReleasesFile)
+		return nil
+	}
+
+	var result error
+	var remaining []installedRelease
+	for i := len(releases) - 1; i >= 0; i-- {
+		args := step
+		args.Namespaces = nil
+		if releases[i].Namespace != "" {
+			args.Namespace = releases[i].Namespace
+		}
+		err = uninstall(releases[i].Name, args)
 		if err != nil {
 			result = multierror.Append(result, err)
+			remaining = append([]installedRelease{releases[i]}, remaining...)
 		}
 	}
+
+	if m.planMode() {
+		return result
+	}
+	if len(remaining) == 0 {
+		err = m.FileSystem.Remove(step.ReleasesFile)
+	} else {
+		err = m.writeReleasesFile(step.ReleasesFile, remaining)
+	}
+	if err != nil {
+		result = multierror.Append(result, errors.Wrapf(err, "could not update the releases file %s", step.ReleasesFile))
+	}
 	return result
 }
 
-func (m *Mixin) delete(ctx context.Context, release string, namespace string, noHooks bool, wait bool, timeout string, debug bool) error {
-	cmd := m.NewCommand(ctx, "helm3", "uninstall")
+// timeouts returns the timeout fields of the step
+func (s UninstallArguments) timeouts() []timeoutField {
+	fields := []timeoutField{{"timeout", s.Timeout}, {"lockTimeout", s.LockTimeout}}
+	return append(fields, outputTimeouts(s.Outputs)...)
+}
+
+// namespaced returns the arguments of the step for each of its namespaces, in order
+func (s UninstallArguments) namespaced() []UninstallArguments {
+	if len(s.Namespaces) == 0 {
+		return []UninstallArguments{s}
+	}
+	namespaced := make([]UninstallArguments, 0, len(s.Namespaces))
+	for _, namespace := range s.Namespaces {
+		args := s
+		args.Namespace, args.Namespaces = namespace, nil
+		namespaced = append(namespaced, args)
+	}
+	return namespaced
+}
+
+func (m *Mixin) delete(ctx context.Context, release string, step UninstallArguments) error {
+	ctx, cancel := withTimeout(ctx, step.Timeout)
+	defer cancel()
+
+	// helm is interrupted instead of killed when the step is cancelled, so that it can release the lock of the release
+	cmd := m.helmCommand(uncancelledContext{ctx}, "uninstall")
 
 	cmd.Args = append(cmd.Args, release)
 
-	if namespace != "" {
-		cmd.Args = append(cmd.Args, "--namespace", namespace)
+	if step.Namespace != "" {
+		cmd.Args = append(cmd.Args, "--namespace", step.Namespace)
 	}
 
-	if noHooks {
+	if step.NoHooks {
 		cmd.Args = append(cmd.Args, "--no-hooks")
 	}
 
-	if wait {
+	if step.Wait {
 		cmd.Args = append(cmd.Args, "--wait")
 	}
 
-	if timeout != "" {
-		cmd.Args = append(cmd.Args, "--timeout", timeout)
-	}
+	cmd.Args = appendTimeout(cmd.Args, step.Timeout)
 
-	if debug {
+	if step.Debug || m.verbose() {
 		cmd.Args = append(cmd.Args, "--debug")
 	}
+
+	var err error
+	cmd.Args, err = appendExtraFlags(cmd.Args, step.ExtraFlags, uninstallManagedFlags)
+	if err != nil {
+		return err
+	}
+
+	if m.planMode() {
+		m.printPlannedCommand(cmd)
+		return nil
+	}
+
+	m.setStepEnv(cmd, step.Env)
+
 	output := &bytes.Buffer{}
-	cmd.Stdout = io.MultiWriter(m.Out, output)
+	cmd.Stdout = io.MultiWriter(m.commandStdout(), output)
 	cmd.Stderr = io.MultiWriter(m.Err, output)
 
-	prettyCmd := fmt.Sprintf("%s %s", cmd.Path, strings.Join(cmd.Args, " "))
-	fmt.Fprintln(m.Out, prettyCmd)
+	prettyCmd := fmt.Sprintf("%s %s", cmd.Path, formatPlannedCommand(cmd.Args))
+	m.echoCommand(prettyCmd)
 
-	err := cmd.Start()
+	err = cmd.Start()
 	if err != nil {
 		return fmt.Errorf("could not execute command, %s: %s", prettyCmd, err)
 	}
-	err = cmd.Wait()
+	interrupted, err := waitInterruptible(ctx, cmd)
+	if interrupted {
+		return m.interruptedError("uninstall", release, step.Namespace, err)
+	}
 	if err != nil {
 		// Gracefully handle the error being a release not loaded or found
 		outputBuffer := strings.ToLower(output.String())
@@ -109,7 +307,10 @@ func (m *Mixin) delete(ctx context.Context, release string, namespace string, no
 			strings.Contains(outputBuffer, "not found") {
 			return nil
 		}
-		return err
+		if m.outputSuppressed() {
+			m.printOutput(output.String())
+		}
+		return classifyHelmError(err, output.String())
 	}
 
 	return nil
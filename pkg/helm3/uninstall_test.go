@@ -5,12 +5,17 @@ import (
 	"context"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"testing"
 
+	"get.porter.sh/porter/pkg/portercontext"
 	"get.porter.sh/porter/pkg/test"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"gopkg.in/yaml.v2"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	testclient "k8s.io/client-go/kubernetes/fake"
 )
 
 type UninstallTest struct {
@@ -75,18 +80,29 @@ func TestMixin_Uninstall(t *testing.T) {
 			},
 		},
 		{
-			expectedCommand: "helm3 uninstall foo --namespace my-namespace --no-hooks --timeout 600 --debug",
+			expectedCommand: "helm3 uninstall foo --namespace my-namespace --no-hooks --timeout 600s --debug",
 			uninstallStep: UninstallStep{
 				UninstallArguments: UninstallArguments{
 					Step:      Step{Description: "Uninstall Foo"},
 					Releases:  releases,
 					Namespace: namespace,
 					NoHooks:   noHooks,
-					Timeout:   "600",
+					Timeout:   "600s",
 					Debug:     true,
 				},
 			},
 		},
+		{
+			expectedCommand: "helm3 uninstall foo --namespace my-namespace --keep-history",
+			uninstallStep: UninstallStep{
+				UninstallArguments: UninstallArguments{
+					Step:       Step{Description: "Uninstall Foo"},
+					Releases:   releases,
+					Namespace:  namespace,
+					ExtraFlags: []string{"--keep-history"},
+				},
+			},
+		},
 	}
 
 	defer os.Unsetenv(test.ExpectedCommandEnv)
@@ -107,3 +123,36 @@ func TestMixin_Uninstall(t *testing.T) {
 		})
 	}
 }
+
+func TestMixin_UninstallOutputs(t *testing.T) {
+	ctx := context.Background()
+	defer os.Unsetenv(test.ExpectedCommandEnv)
+	os.Setenv(test.ExpectedCommandEnv, "helm3 uninstall mysql --namespace db")
+
+	action := UninstallAction{Steps: []UninstallStep{{
+		UninstallArguments: UninstallArguments{
+			Step: Step{
+				Description: "Uninstall MySQL",
+				Outputs:     []HelmOutput{{Name: "mysql-backup", Secret: "mysql-backup", Key: "location"}},
+			},
+			Namespace: "db",
+			Releases:  []string{"mysql"},
+		},
+	}}}
+	b, _ := yaml.Marshal(action)
+
+	client := testclient.NewSimpleClientset(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "mysql-backup", Namespace: "db"},
+		Data:       map[string][]byte{"location": []byte("s3://backups/mysql")},
+	})
+	h := NewTestMixin(t)
+	h.ClientFactory = &staticKubernetesFactory{client: client}
+	h.In = bytes.NewReader(b)
+
+	err := h.Uninstall(ctx)
+	require.NoError(t, err)
+
+	location, err := h.FileSystem.ReadFile(filepath.Join(portercontext.MixinOutputsDir, "mysql-backup"))
+	require.NoError(t, err)
+	assert.Equal(t, "s3://backups/mysql", string(location))
+}
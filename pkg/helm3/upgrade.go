@@ -8,7 +8,6 @@ import (
 	"strings"
 
 	"github.com/pkg/errors"
-	"gopkg.in/yaml.v2"
 )
 
 type UpgradeAction struct {
@@ -28,7 +27,7 @@ type UpgradeArguments struct {
 	Name        string            `yaml:"name"`
 	Chart       string            `yaml:"chart"`
 	Version     string            `yaml:"version"`
-	NoHooks     bool              `yaml:"nohooks"`
+	NoHooks     bool              `yaml:"noHooks"`
 	Set         map[string]string `yaml:"set"`
 	Values      []string          `yaml:"values"`
 	Wait        bool              `yaml:"wait"`
@@ -40,6 +39,248 @@ type UpgradeArguments struct {
 	Username    string            `yaml:"username"`
 	Timeout     string            `yaml:"timeout"`
 	Debug       bool              `yaml:"debug"`
+
+	// ResetThenReuseValues resets the values to the chart defaults and then merges the values of the last release, it requires helm 3.14 or later
+	ResetThenReuseValues bool `yaml:"resetThenReuseValues,omitempty"`
+
+	// Subcharts enables or disables the dependencies of an umbrella chart and sets values scoped to them
+	Subcharts map[string]Subchart `yaml:"subcharts,omitempty"`
+
+	// EscapeSet escapes the dots of label and annotation keys and the commas of values in set, it is enabled by default
+	EscapeSet *bool `yaml:"escapeSet,omitempty"`
+
+	// SetTyped sets chart values that keep their yaml type, such as numbers and booleans, with --set-json
+	SetTyped map[string]interface{} `yaml:"setTyped,omitempty"`
+
+	// SetFileFromParameter sets chart values to the content of bundle file parameters, mapping each value to a parameter name
+	SetFileFromParameter map[string]string `yaml:"setFileFromParameter,omitempty"`
+
+	// SetFromOutput sets chart values to the outputs of the previous steps of the action, mapping each value to an output name
+	SetFromOutput map[string]string `yaml:"setFromOutput,omitempty"`
+
+	// FixDeprecatedAPIs runs the mapkubeapis plugin against existing releases before they are upgraded
+	FixDeprecatedAPIs bool `yaml:"fixDeprecatedAPIs,omitempty"`
+
+	// InstallIfMissing installs releases that do not exist yet, set it to false to fail the upgrade instead (default true)
+	InstallIfMissing *bool `yaml:"installIfMissing,omitempty"`
+
+	// NamespaceMetadata labels and annotates the namespace of each release before helm runs
+	NamespaceMetadata *NamespaceMetadata `yaml:"namespaceMetadata,omitempty"`
+
+	// TakeOwnership adopts existing resources that were not created by helm, it requires helm 3.17 or later
+	TakeOwnership bool `yaml:"takeOwnership,omitempty"`
+
+	// SkipSchemaValidation ignores the values.schema.json of the chart, it requires helm 3.16 or later
+	SkipSchemaValidation bool `yaml:"skipSchemaValidation,omitempty"`
+
+	// CheckPermissions checks that the bundle credentials can manage the resources of each release before it is upgraded
+	CheckPermissions bool `yaml:"checkPermissions,omitempty"`
+
+	// Policy evaluates the rendered manifest of each release against policies of the bundle before it is upgraded
+	Policy *PolicyCheck `yaml:"policy,omitempty"`
+
+	// LockTimeout retries helm while another operation holds the lock of a release, for at most the duration
+	LockTimeout string `yaml:"lockTimeout,omitempty"`
+
+	// ExtraFlags are passed as-is to helm, after the flags managed by the mixin
+	ExtraFlags []string `yaml:"extraFlags,omitempty"`
+
+	// Namespaces upgrades every release of the step once in each namespace, instead of the namespace of the step
+	Namespaces NamespaceList `yaml:"namespaces,omitempty"`
+
+	// GenerateName derives the release name from the installation name, instead of the name of the release
+	GenerateName string `yaml:"generateName,omitempty"`
+
+	// ReleaseNameFile reads the name of the release from a file, for a release installed with randomName
+	ReleaseNameFile string `yaml:"releaseNameFile,omitempty"`
+
+	// Releases are upgraded in order instead of the single release defined by Name and Chart
+	Releases []Release `yaml:"releases,omitempty"`
+
+	// CRDs are applied before the release, or on their own when the step has no release
+	CRDs *CRDArguments `yaml:"crds,omitempty"`
+
+	// Manifests of the bundle are applied with kubectl before or after the releases of the step
+	Manifests []Manifest `yaml:"manifests,omitempty"`
+
+	// ReleasesFile records each release deployed by the step in a file kept with the state of the bundle,
+	// so that an uninstall step tears them down in reverse order
+	ReleasesFile string `yaml:"releasesFile,omitempty"`
+
+	// Jobs deployed by the releases of the step are waited for once the releases are deployed, and their logs written to outputs
+	Jobs []JobWait `yaml:"jobs,omitempty"`
+
+	// SmokeTest probes the application over HTTP through a port-forward once the releases of the step are deployed
+	SmokeTest *SmokeTest `yaml:"smokeTest,omitempty"`
+
+	// Repositories required by the step, added when the bundle is built
+	Repositories map[string]Repository `yaml:"repositories,omitempty"`
+
+	// Verify the cosign signature of OCI charts before they are upgraded
+	Verify *SignatureVerification `yaml:"verify,omitempty"`
+
+	// Canary deploys each release as a parallel canary release first, and promotes it once it is healthy
+	Canary *Canary `yaml:"canary,omitempty"`
+
+	// BlueGreen upgrades the -blue or -green release that does not receive the traffic of the router release
+	BlueGreen *BlueGreen `yaml:"blueGreen,omitempty"`
+
+	// ProtectedValues are chart values, such as a storage class, that the upgrade of a release fails to change
+	// unless AllowProtectedChanges is set
+	ProtectedValues       []string `yaml:"protectedValues,omitempty"`
+	AllowProtectedChanges bool     `yaml:"allowProtectedChanges,omitempty"`
+
+	// Protect aborts the upgrade of a release that would delete or replace one of its resources of a KIND, or KIND/NAME,
+	// such as PersistentVolumeClaim or Secret/mysql-credentials
+	Protect []string `yaml:"protect,omitempty"`
+
+	// Prune deletes the resources kept by helm that the upgraded chart no longer renders, when their kind is allowed
+	Prune *Prune `yaml:"prune,omitempty"`
+
+	// PorterValues sets the porter.action, porter.installation and porter.revision values on every release
+	PorterValues bool `yaml:"porterValues,omitempty"`
+
+	// ImageRegistryOverride sets the registry of the images of every release to a mirror registry
+	ImageRegistryOverride *ImageRegistryOverride `yaml:"imageRegistryOverride,omitempty"`
+
+	// OnInterrupt is rollback to roll the release back to its previous revision when the upgrade is interrupted,
+	// for example when the runtime is evicted, or none by default
+	OnInterrupt string `yaml:"onInterrupt,omitempty"`
+
+	// DryRun renders each release with helm instead of upgrading it, client, server or none
+	DryRun string `yaml:"dryRun,omitempty"`
+
+	// dryRunFlags are the helm flags of the dry run, resolved when the step runs
+	dryRunFlags []string
+}
+
+// validateExclusiveFields checks the fields of an upgrade step that cannot be set together, which lint reports too
+func (s UpgradeArguments) validateExclusiveFields() error {
+	if strategies := s.valuesStrategies(); len(strategies) > 1 {
+		return errors.Errorf("only one of resetValues, reuseValues and resetThenReuseValues can be set, got %s", strings.Join(strategies, ", "))
+	}
+	if s.GenerateName != "" && (s.Name != "" || len(s.Releases) > 0) {
+		return errors.New("generateName cannot be set together with name or releases")
+	}
+	if s.ReleaseNameFile != "" && (s.Name != "" || s.GenerateName != "" || len(s.Releases) > 0) {
+		return errors.New("releaseNameFile cannot be set together with name, generateName or releases")
+	}
+	if s.BlueGreen != nil && (len(s.Releases) > 0 || len(s.Namespaces) > 0 || s.Canary != nil) {
+		return errors.New("blueGreen cannot be set together with releases, namespaces or canary")
+	}
+	if isDryRun(s.DryRun) && s.Canary != nil {
+		return errors.New("dryRun cannot be set together with canary")
+	}
+	return nil
+}
+
+// valuesStrategies returns the fields that decide how the values of the last release are handled
+func (s UpgradeArguments) valuesStrategies() []string {
+	var strategies []string
+	if s.ResetValues {
+		strategies = append(strategies, "resetValues")
+	}
+	if s.ReuseValues {
+		strategies = append(strategies, "reuseValues")
+	}
+	if s.ResetThenReuseValues {
+		strategies = append(strategies, "resetThenReuseValues")
+	}
+	return strategies
+}
+
+// escapeSet returns whether the keys and values of set are escaped, which is the default
+func (s UpgradeArguments) escapeSet() bool {
+	return s.EscapeSet == nil || *s.EscapeSet
+}
+
+// repositoryCharts returns the charts of the releases of the step that are pulled from the repositories of the
+// invocation image, instead of the repo of the step
+func (s UpgradeArguments) repositoryCharts() []string {
+	if s.Repo != "" {
+		return nil
+	}
+	var charts []string
+	for _, release := range s.releases() {
+		charts = append(charts, release.Chart)
+	}
+	return charts
+}
+
+// releaseStep returns the fields of the step used by the release pipeline
+func (s UpgradeArguments) releaseStep() releaseStep {
+	return releaseStep{
+		Step:              s.Step,
+		Namespace:         s.Namespace,
+		DryRun:            s.DryRun,
+		CRDs:              s.CRDs,
+		Manifests:         s.Manifests,
+		Jobs:              s.Jobs,
+		SmokeTest:         s.SmokeTest,
+		Repositories:      s.Repositories,
+		RepositoryCharts:  s.repositoryCharts(),
+		NamespaceMetadata: s.NamespaceMetadata,
+		Verify:            s.Verify,
+		CheckPermissions:  s.CheckPermissions,
+		Policy:            s.Policy,
+		ReleasesFile:      s.ReleasesFile,
+	}
+}
+
+// timeouts returns the timeout fields of the step
+func (s UpgradeArguments) timeouts() []timeoutField {
+	fields := []timeoutField{{"timeout", s.Timeout}, {"lockTimeout", s.LockTimeout}}
+	if s.CRDs != nil {
+		fields = append(fields, timeoutField{"crds.timeout", s.CRDs.Timeout})
+	}
+	if s.Canary != nil {
+		fields = append(fields, timeoutField{"canary.timeout", s.Canary.Timeout})
+	}
+	fields = append(fields, jobTimeouts(s.Jobs)...)
+	return append(fields, outputTimeouts(s.Outputs)...)
+}
+
+// installIfMissing returns whether releases that do not exist yet are installed
+func (s UpgradeArguments) installIfMissing() bool {
+	return s.InstallIfMissing == nil || *s.InstallIfMissing
+}
+
+// releases returns the arguments for each release upgraded by the step, in order
+func (s UpgradeArguments) releases() []UpgradeArguments {
+	releases := []UpgradeArguments{s}
+	if len(s.Releases) == 0 && s.CRDs != nil && s.Name == "" && s.Chart == "" {
+		// the step only manages custom resource definitions
+		return nil
+	}
+	if len(s.Releases) > 0 {
+		defaults := releaseDefaults{Namespace: s.Namespace, Version: s.Version, Set: s.Set, Values: s.Values}
+		releases = make([]UpgradeArguments, 0, len(s.Releases))
+		for _, r := range s.Releases {
+			r = r.apply(defaults)
+			args := s
+			args.Releases = nil
+			args.Name, args.Chart, args.Version, args.Namespace, args.Set, args.Values = r.Name, r.Chart, r.Version, r.Namespace, r.Set, r.Values
+			releases = append(releases, args)
+		}
+	}
+
+	// Every release is upgraded once per namespace, in the order of the namespaces
+	namespaces := []string{""}
+	if len(s.Namespaces) > 0 {
+		namespaces = s.Namespaces
+	}
+	expanded := make([]UpgradeArguments, 0, len(namespaces)*len(releases))
+	for _, namespace := range namespaces {
+		for _, args := range releases {
+			args.Namespaces = nil
+			if namespace != "" {
+				args.Namespace = namespace
+			}
+			args.Name = namespacedReleaseName(args.Name, args.Namespace)
+			expanded = append(expanded, args)
+		}
+	}
+	return expanded
 }
 
 // Upgrade issues a helm upgrade command for a release using the provided UpgradeArguments
@@ -49,13 +290,8 @@ func (m *Mixin) Upgrade(ctx context.Context) error {
 		return err
 	}
 
-	kubeClient, err := m.getKubernetesClient()
-	if err != nil {
-		return errors.Wrap(err, "couldn't get kubernetes client")
-	}
-
 	var action UpgradeAction
-	err = yaml.Unmarshal(payload, &action)
+	err = m.unmarshal(payload, &action)
 	if err != nil {
 		return err
 	}
@@ -64,7 +300,272 @@ func (m *Mixin) Upgrade(ctx context.Context) error {
 	}
 	step := action.Steps[0]
 
-	cmd := m.NewCommand(ctx, "helm3", "upgrade", "--install", step.Name, step.Chart)
+	pipeline := m.newReleasePipeline(step.Step)
+	// the release results and rendered manifests of the step are spooled until its outputs are written
+	defer pipeline.close()
+
+	err = pipeline.start()
+	if err != nil {
+		return err
+	}
+
+	err = step.validateExclusiveFields()
+	if err != nil {
+		return err
+	}
+
+	if step.GenerateName != "" {
+		step.Name, err = m.resolveGeneratedName(step.GenerateName, step.Name, len(step.Releases))
+		if err != nil {
+			return err
+		}
+	}
+
+	if step.ReleaseNameFile != "" {
+		var exists bool
+		step.Name, exists, err = m.readReleaseName(step.ReleaseNameFile)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return errors.Errorf("the release name file %s does not exist", step.ReleaseNameFile)
+		}
+	}
+
+	err = m.templateNamespaces(&step.Namespace, step.Namespaces, step.Releases)
+	if err != nil {
+		return err
+	}
+
+	err = validateNamespaces(step.Namespaces, step.Namespace, step.Releases)
+	if err != nil {
+		return err
+	}
+
+	if step.Canary != nil {
+		err = step.Canary.validate()
+		if err != nil {
+			return err
+		}
+	}
+
+	if step.BlueGreen != nil {
+		if step.BlueGreen.Router == "" {
+			return errors.New("blueGreen requires the router release")
+		}
+	}
+
+	err = step.releaseStep().validate()
+	if err != nil {
+		return err
+	}
+
+	err = validateTimeouts(step.timeouts()...)
+	if err != nil {
+		return err
+	}
+
+	err = validateProtect(step.Protect)
+	if err != nil {
+		return err
+	}
+
+	err = m.validateNamespaceScoped(step.CRDs, step.NamespaceMetadata, step.Namespace, step.Namespaces, step.Releases, step.Prune)
+	if err != nil {
+		return err
+	}
+
+	if step.Prune != nil {
+		err = step.Prune.validate()
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, release := range step.releases() {
+		err = validateChartDigest(release.Chart)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = validateOnInterrupt(step.OnInterrupt)
+	if err != nil {
+		return err
+	}
+
+	if step.ImageRegistryOverride != nil {
+		err = step.ImageRegistryOverride.validate()
+		if err != nil {
+			return err
+		}
+		step.Set = step.ImageRegistryOverride.apply(step.Set)
+	}
+
+	if step.PorterValues {
+		step.Set = m.applyPorterValues(step.Set)
+	}
+
+	if step.FixDeprecatedAPIs && !m.allowedCommands()[mapKubeAPIsPlugin] {
+		return errors.Errorf("fixDeprecatedAPIs requires the %s plugin in the mixin configuration", mapKubeAPIsPlugin)
+	}
+
+	err = pipeline.prepare(ctx, step.releaseStep())
+	if err != nil {
+		return err
+	}
+	step.dryRunFlags = pipeline.dryRunFlags
+	dryRun := pipeline.dryRun
+
+	var pruned []prunedResource
+	for _, release := range step.releases() {
+		if step.BlueGreen != nil {
+			release, err = m.blueGreenRelease(ctx, *step.BlueGreen, release)
+			if err != nil {
+				return err
+			}
+		}
+
+		err = pipeline.prepareNamespace(ctx, release.Namespace)
+		if err != nil {
+			return err
+		}
+
+		exists := true
+		if (!step.installIfMissing() || step.FixDeprecatedAPIs) && !m.planMode() {
+			exists, err = m.releaseExists(ctx, release.Name, release.Namespace)
+			if err != nil {
+				return err
+			}
+			if !exists && !step.installIfMissing() {
+				return errors.Errorf("release %s does not exist and installIfMissing is false", release.Name)
+			}
+		}
+
+		if step.FixDeprecatedAPIs && exists && !dryRun {
+			err = m.mapDeprecatedAPIs(ctx, release)
+			if err != nil {
+				return err
+			}
+		}
+
+		err = pipeline.readyChart(ctx, release.Name, release.Namespace, &release.Chart, &release.Version, func(ctx context.Context) (*exec.Cmd, error) {
+			return m.upgradeCommand(ctx, release)
+		})
+		if err != nil {
+			return err
+		}
+
+		if len(step.ProtectedValues) > 0 && !m.planMode() {
+			err = m.checkProtectedValues(ctx, release, step.ProtectedValues, step.AllowProtectedChanges)
+			if err != nil {
+				return err
+			}
+		}
+
+		if len(step.Protect) > 0 && !m.planMode() {
+			err = m.checkProtectedResources(ctx, release, step.Protect)
+			if err != nil {
+				return err
+			}
+		}
+
+		var previous map[manifestObject]bool
+		if step.Prune != nil && !dryRun && !m.planMode() {
+			previous, err = m.releaseObjects(ctx, release)
+			if err != nil {
+				return err
+			}
+		}
+
+		if step.Canary != nil {
+			err = m.deployCanary(ctx, *step.Canary, release)
+			if err != nil {
+				return err
+			}
+		}
+
+		var result *releaseResult
+		err = m.retryOperationInProgress(ctx, release.Name, step.LockTimeout, func() error {
+			return m.withReleaseLock(release.Name, release.Namespace, step.Description, func() error {
+				result, err = m.upgrade(ctx, release)
+				return err
+			})
+		})
+		if step.Canary != nil {
+			err = m.promoteCanary(ctx, *step.Canary, release, err)
+		}
+		if !dryRun {
+			m.recordEvent(ctx, step.Description, "upgrade", release.Name, release.Namespace, err)
+		}
+		if err != nil {
+			return err
+		}
+		if result != nil {
+			err = pipeline.record(*result)
+			if err != nil {
+				return err
+			}
+		}
+		if previous != nil {
+			releasePruned, err := m.pruneOrphans(ctx, release, previous, *step.Prune, step.Protect)
+			pruned = append(pruned, releasePruned...)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if step.Prune != nil && step.Prune.Output != "" && !dryRun && !m.planMode() {
+		err = m.writePrunedOutput(*step.Prune, pruned)
+		if err != nil {
+			return err
+		}
+	}
+
+	return pipeline.finish(ctx)
+}
+
+// upgrade runs helm for a single release of an upgrade step
+func (m *Mixin) upgrade(ctx context.Context, step UpgradeArguments) (*releaseResult, error) {
+	ctx, cancel := withTimeout(ctx, step.Timeout)
+	defer cancel()
+
+	// helm is interrupted instead of killed when the step is cancelled, so that it can release the lock of the release
+	cmd, err := m.upgradeCommand(uncancelledContext{ctx}, step)
+	if err != nil {
+		return nil, err
+	}
+
+	if m.planMode() {
+		m.printPlannedCommand(cmd)
+		return nil, nil
+	}
+
+	if step.Wait && !isDryRun(step.DryRun) {
+		stop := m.watchProgress(ctx, step.Name, step.Namespace)
+		defer stop()
+	}
+
+	result, err := m.runReleaseCommand(ctx, cmd)
+	if err != nil && ctx.Err() != nil {
+		err = m.interruptedError("upgrade", step.Name, step.Namespace, err)
+		if step.OnInterrupt == onInterruptRollback {
+			err = m.rollbackInterrupted(step, err)
+		}
+		return nil, err
+	}
+	return result, err
+}
+
+// upgradeCommand builds the helm command that upgrades a single release of an upgrade step
+func (m *Mixin) upgradeCommand(ctx context.Context, step UpgradeArguments) (*exec.Cmd, error) {
+	var err error
+	cmd := m.helmCommand(ctx, "upgrade")
+	if step.installIfMissing() {
+		cmd.Args = append(cmd.Args, "--install")
+	}
+	cmd.Args = append(cmd.Args, step.Name, step.Chart)
 
 	if step.Namespace != "" {
 		cmd.Args = append(cmd.Args, "--namespace", step.Namespace)
@@ -82,46 +583,74 @@ func (m *Mixin) Upgrade(ctx context.Context) error {
 		cmd.Args = append(cmd.Args, "--reuse-values")
 	}
 
+	if step.ResetThenReuseValues {
+		cmd.Args = append(cmd.Args, "--reset-then-reuse-values")
+	}
+
 	if step.Wait {
 		cmd.Args = append(cmd.Args, "--wait")
 	}
 
 	for _, v := range step.Values {
-		cmd.Args = append(cmd.Args, "--values", v)
+		cmd.Args = append(cmd.Args, "--values", m.valuesFile(v))
 	}
 
-	if step.Timeout != "" {
-		cmd.Args = append(cmd.Args, "--timeout", step.Timeout)
+	if step.SkipCrds {
+		cmd.Args = append(cmd.Args, "--skip-crds")
 	}
 
-	if step.Debug {
-		cmd.Args = append(cmd.Args, "--debug")
+	if step.NoHooks {
+		cmd.Args = append(cmd.Args, "--no-hooks")
 	}
 
-	// This will upgrade process rolls back changes made in case of failed upgrade.
-	cmd.Args = append(cmd.Args, "--atomic")
-	// This will ensure the creation of the release namespace if not present.
-	cmd.Args = append(cmd.Args, "--create-namespace")
+	if step.Repo != "" && step.Username != "" && step.Password != "" {
+		cmd.Args = append(cmd.Args, "--repo", step.Repo, "--username", step.Username, "--password", step.Password)
+	}
 
-	cmd.Args = HandleSettingChartValuesForUpgrade(step, cmd)
+	cmd.Args = appendTimeout(cmd.Args, step.Timeout)
 
-	cmd.Stdout = m.Out
-	cmd.Stderr = m.Err
+	if step.Debug || m.verbose() {
+		cmd.Args = append(cmd.Args, "--debug")
+	}
+	if step.TakeOwnership {
+		cmd.Args = append(cmd.Args, "--take-ownership")
+	}
+	if step.SkipSchemaValidation {
+		cmd.Args = append(cmd.Args, "--skip-schema-validation")
+	}
 
-	prettyCmd := fmt.Sprintf("%s %s", cmd.Path, strings.Join(cmd.Args, " "))
-	fmt.Fprintln(m.Out, prettyCmd)
+	// This will upgrade process rolls back changes made in case of failed upgrade.
+	cmd.Args = append(cmd.Args, m.helmFlag("--atomic"))
+	// This will ensure the creation of the release namespace if not present.
+	cmd.Args = m.appendNamespaceFlags(cmd.Args)
+	// Print the release as json so that its result can be used by the step outputs
+	cmd.Args = append(cmd.Args, "--output", "json")
 
-	err = cmd.Start()
+	step.Set = subchartValues(step.Subcharts, step.Set)
+	cmd.Args = HandleSettingChartValuesForUpgrade(UpgradeStep{step}, cmd)
+	setTypedArgs, err := setTypedArgs(step.SetTyped)
 	if err != nil {
-		return fmt.Errorf("could not execute command, %s: %s", prettyCmd, err)
+		return nil, err
 	}
-	err = cmd.Wait()
+	cmd.Args = append(cmd.Args, setTypedArgs...)
+	setFileArgs, err := m.setFileArgs(step.SetFileFromParameter)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	cmd.Args = append(cmd.Args, setFileArgs...)
+	setFromOutputArgs, err := m.setFromOutputArgs(step.SetFromOutput)
+	if err != nil {
+		return nil, err
+	}
+	cmd.Args = append(cmd.Args, setFromOutputArgs...)
+	cmd.Args = append(cmd.Args, step.dryRunFlags...)
+	cmd.Args, err = appendExtraFlags(cmd.Args, m.helmFlags(step.ExtraFlags), upgradeManagedFlags)
+	if err != nil {
+		return nil, err
 	}
 
-	err = m.handleOutputs(ctx, kubeClient, step.Namespace, step.Outputs)
-	return err
+	m.setStepEnv(cmd, step.Env)
+	return cmd, nil
 }
 
 // Prepare set arguments
@@ -135,7 +664,11 @@ func HandleSettingChartValuesForUpgrade(step UpgradeStep, cmd *exec.Cmd) []strin
 	sort.Strings(setKeys)
 
 	for _, k := range setKeys {
-		cmd.Args = append(cmd.Args, "--set", fmt.Sprintf("%s=%s", k, step.Set[k]))
+		key, value := k, step.Set[k]
+		if step.escapeSet() {
+			key, value = escapeSetKey(key), escapeSetValue(value)
+		}
+		cmd.Args = append(cmd.Args, "--set", fmt.Sprintf("%s=%s", key, value))
 	}
 	return cmd.Args
 }
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"strings"
 	"testing"
 
 	"get.porter.sh/porter/pkg/test"
@@ -23,21 +24,24 @@ func TestMixin_UnmarshalUpgradeStep(t *testing.T) {
 	b, err := ioutil.ReadFile("testdata/upgrade-input.yaml")
 	require.NoError(t, err)
 
+	// the step is decoded strictly, as it is when the upgrade runs
 	var action UpgradeAction
-	err = yaml.Unmarshal(b, &action)
+	err = NewTestMixin(t).unmarshal(b, &action)
 	require.NoError(t, err)
 	require.Len(t, action.Steps, 1)
 	step := action.Steps[0]
 
 	assert.Equal(t, "Upgrade MySQL", step.Description)
 	assert.NotEmpty(t, step.Outputs)
-	assert.Equal(t, HelmOutput{"mysql-root-password", "porter-ci-mysql", "mysql-root-password", "", "", "", ""}, step.Outputs[0])
-	assert.Equal(t, HelmOutput{"mysql-cluster-ip", "", "", "service", "porter-ci-mysql-service", "default", "{.spec.clusterIP}"}, step.Outputs[2])
+	assert.Equal(t, HelmOutput{Name: "mysql-root-password", Secret: "porter-ci-mysql", Key: "mysql-root-password"}, step.Outputs[0])
+	assert.Equal(t, HelmOutput{Name: "mysql-cluster-ip", ResourceType: "service", ResourceName: "porter-ci-mysql-service", Namespace: "default", JSONPath: "{.spec.clusterIP}"}, step.Outputs[2])
 	assert.Equal(t, "stable/mysql", step.Chart)
 	assert.Equal(t, "0.10.2", step.Version)
 	assert.True(t, step.Wait)
 	assert.True(t, step.ResetValues)
 	assert.True(t, step.ResetValues)
+	assert.True(t, step.NoHooks)
+	assert.True(t, step.SkipCrds)
 	assert.Equal(t, map[string]string{"mysqlDatabase": "mydb", "mysqlUser": "myuser",
 		"livenessProbe.initialDelaySeconds": "30", "persistence.enabled": "true"}, step.Set)
 }
@@ -59,7 +63,7 @@ func TestMixin_Upgrade(t *testing.T) {
 	baseUpgrade := fmt.Sprintf(`helm3 upgrade --install %s %s --namespace %s --version %s`, name, chart, namespace, version)
 	baseValues := `--values /tmp/val1.yaml --values /tmp/val2.yaml`
 	baseSetArgs := `--set baz=qux --set foo=bar`
-	baseAddFlags := `--atomic --create-namespace`
+	baseAddFlags := `--atomic --create-namespace --output json`
 
 	upgradeTests := []UpgradeTest{
 		{
@@ -106,6 +110,21 @@ func TestMixin_Upgrade(t *testing.T) {
 				},
 			},
 		},
+		{
+			expectedCommand: fmt.Sprintf(`%s %s %s %s %s`, baseUpgrade, `--reset-then-reuse-values`, baseValues, baseAddFlags, baseSetArgs),
+			upgradeStep: UpgradeStep{
+				UpgradeArguments: UpgradeArguments{
+					Step:                 Step{Description: "Upgrade Foo"},
+					Namespace:            namespace,
+					Name:                 name,
+					Chart:                chart,
+					Version:              version,
+					Set:                  setArgs,
+					Values:               values,
+					ResetThenReuseValues: true,
+				},
+			},
+		},
 		{
 			expectedCommand: fmt.Sprintf(`%s %s %s %s %s`, baseUpgrade, `--wait`, baseValues, baseAddFlags, baseSetArgs),
 			upgradeStep: UpgradeStep{
@@ -122,7 +141,7 @@ func TestMixin_Upgrade(t *testing.T) {
 			},
 		},
 		{
-			expectedCommand: fmt.Sprintf(`%s %s %s %s %s`, baseUpgrade, baseValues, `--timeout 600 --debug`, baseAddFlags, baseSetArgs),
+			expectedCommand: fmt.Sprintf(`%s %s %s %s %s`, baseUpgrade, baseValues, `--timeout 600s --debug`, baseAddFlags, baseSetArgs),
 			upgradeStep: UpgradeStep{
 				UpgradeArguments: UpgradeArguments{
 					Step:      Step{Description: "Upgrade Foo"},
@@ -132,11 +151,72 @@ func TestMixin_Upgrade(t *testing.T) {
 					Version:   version,
 					Set:       setArgs,
 					Values:    values,
-					Timeout:   "600",
+					Timeout:   "600s",
 					Debug:     true,
 				},
 			},
 		},
+		{
+			expectedCommand: fmt.Sprintf(`%s %s %s %s %s`, baseUpgrade, baseValues, `--skip-crds --no-hooks`, baseAddFlags, baseSetArgs),
+			upgradeStep: UpgradeStep{
+				UpgradeArguments: UpgradeArguments{
+					Step:      Step{Description: "Upgrade Foo"},
+					Namespace: namespace,
+					Name:      name,
+					Chart:     chart,
+					Version:   version,
+					Set:       setArgs,
+					Values:    values,
+					SkipCrds:  true,
+					NoHooks:   true,
+				},
+			},
+		},
+		{
+			expectedCommand: fmt.Sprintf(`%s %s %s %s %s`, baseUpgrade, baseValues, `--take-ownership`, baseAddFlags, baseSetArgs),
+			upgradeStep: UpgradeStep{
+				UpgradeArguments: UpgradeArguments{
+					Step:          Step{Description: "Upgrade Foo"},
+					Namespace:     namespace,
+					Name:          name,
+					Chart:         chart,
+					Version:       version,
+					Set:           setArgs,
+					Values:        values,
+					TakeOwnership: true,
+				},
+			},
+		},
+		{
+			expectedCommand: fmt.Sprintf(`%s %s %s %s %s`, baseUpgrade, baseValues, `--skip-schema-validation`, baseAddFlags, baseSetArgs),
+			upgradeStep: UpgradeStep{
+				UpgradeArguments: UpgradeArguments{
+					Step:                 Step{Description: "Upgrade Foo"},
+					Namespace:            namespace,
+					Name:                 name,
+					Chart:                chart,
+					Version:              version,
+					Set:                  setArgs,
+					Values:               values,
+					SkipSchemaValidation: true,
+				},
+			},
+		},
+		{
+			expectedCommand: fmt.Sprintf(`%s %s %s %s %s`, baseUpgrade, baseValues, baseAddFlags, baseSetArgs, `--cleanup-on-fail`),
+			upgradeStep: UpgradeStep{
+				UpgradeArguments: UpgradeArguments{
+					Step:       Step{Description: "Upgrade Foo"},
+					Namespace:  namespace,
+					Name:       name,
+					Chart:      chart,
+					Version:    version,
+					Set:        setArgs,
+					Values:     values,
+					ExtraFlags: []string{"--cleanup-on-fail"},
+				},
+			},
+		},
 	}
 
 	defer os.Unsetenv(test.ExpectedCommandEnv)
@@ -158,3 +238,182 @@ func TestMixin_Upgrade(t *testing.T) {
 		})
 	}
 }
+
+func TestMixin_UpgradeInstallIfMissing(t *testing.T) {
+	listCommand := "helm3 list --all --short --filter ^mysql$ --namespace db"
+	installIfMissing := false
+
+	testcases := []struct {
+		name         string
+		listOutput   string
+		wantCommands []string
+		wantError    string
+	}{
+		{name: "upgrade existing release", listOutput: "mysql\n",
+			wantCommands: []string{listCommand, "helm3 upgrade mysql stable/mysql --namespace db --atomic --create-namespace --output json"}},
+		{name: "fail on missing release", listOutput: "",
+			wantCommands: []string{listCommand}, wantError: "release mysql does not exist and installIfMissing is false"},
+	}
+
+	defer os.Unsetenv(test.ExpectedCommandEnv)
+	defer os.Unsetenv(test.ExpectedCommandOutputEnv)
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			os.Setenv(test.ExpectedCommandEnv, strings.Join(tc.wantCommands, "\n"))
+			os.Setenv(test.ExpectedCommandOutputEnv, tc.listOutput)
+
+			action := UpgradeAction{Steps: []UpgradeStep{{
+				UpgradeArguments: UpgradeArguments{
+					Step:             Step{Description: "Upgrade MySQL"},
+					Namespace:        "db",
+					Name:             "mysql",
+					Chart:            "stable/mysql",
+					InstallIfMissing: &installIfMissing,
+				},
+			}}}
+			b, _ := yaml.Marshal(action)
+
+			h := NewTestMixin(t)
+			h.In = bytes.NewReader(b)
+
+			err := h.Upgrade(ctx)
+			if tc.wantError != "" {
+				require.EqualError(t, err, tc.wantError)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestMixin_UpgradeRepo(t *testing.T) {
+	testcases := []struct {
+		name        string
+		password    string
+		wantCommand string
+	}{
+		{name: "repo with credentials", password: "s3cr3t",
+			wantCommand: "helm3 upgrade --install mysql mysql --repo https://charts.example.com --username admin --password s3cr3t --atomic --create-namespace --output json"},
+		{name: "repo without password",
+			wantCommand: "helm3 upgrade --install mysql mysql --atomic --create-namespace --output json"},
+	}
+
+	defer os.Unsetenv(test.ExpectedCommandEnv)
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			os.Setenv(test.ExpectedCommandEnv, tc.wantCommand)
+
+			action := UpgradeAction{Steps: []UpgradeStep{{
+				UpgradeArguments: UpgradeArguments{
+					Step:     Step{Description: "Upgrade MySQL"},
+					Name:     "mysql",
+					Chart:    "mysql",
+					Repo:     "https://charts.example.com",
+					Username: "admin",
+					Password: tc.password,
+				},
+			}}}
+			b, _ := yaml.Marshal(action)
+
+			h := NewTestMixin(t)
+			h.In = bytes.NewReader(b)
+
+			err := h.Upgrade(ctx)
+			require.NoError(t, err)
+			assert.NotContains(t, h.TestContext.GetOutput(), "s3cr3t")
+		})
+	}
+}
+
+func TestMixin_UpgradeValuesStrategies(t *testing.T) {
+	ctx := context.Background()
+	action := UpgradeAction{Steps: []UpgradeStep{{
+		UpgradeArguments: UpgradeArguments{
+			Step:                 Step{Description: "Upgrade MySQL"},
+			Name:                 "mysql",
+			Chart:                "stable/mysql",
+			ReuseValues:          true,
+			ResetThenReuseValues: true,
+		},
+	}}}
+	b, _ := yaml.Marshal(action)
+
+	h := NewTestMixin(t)
+	h.In = bytes.NewReader(b)
+
+	err := h.Upgrade(ctx)
+	require.EqualError(t, err, "only one of resetValues, reuseValues and resetThenReuseValues can be set, got reuseValues, resetThenReuseValues")
+}
+
+func TestMixin_UpgradeOutputFlagConflict(t *testing.T) {
+	ctx := context.Background()
+	action := UpgradeAction{Steps: []UpgradeStep{{
+		UpgradeArguments: UpgradeArguments{
+			Step:       Step{Description: "Upgrade MySQL"},
+			Name:       "mysql",
+			Chart:      "stable/mysql",
+			ExtraFlags: []string{"-o", "yaml"},
+		},
+	}}}
+	b, _ := yaml.Marshal(action)
+
+	h := NewTestMixin(t)
+	h.In = bytes.NewReader(b)
+
+	err := h.Upgrade(ctx)
+	require.EqualError(t, err, "extra flag -o conflicts with a flag that is always set by the mixin")
+}
+
+func TestMixin_UpgradeFixDeprecatedAPIs(t *testing.T) {
+	listCommand := "helm3 list --all --short --filter ^mysql$ --namespace db"
+	upgradeCommand := "helm3 upgrade --install mysql stable/mysql --namespace db --atomic --create-namespace --output json"
+
+	testcases := []struct {
+		name         string
+		plugins      string
+		listOutput   string
+		wantCommands []string
+		wantError    string
+	}{
+		{name: "existing release", plugins: "mapkubeapis", listOutput: "mysql\n",
+			wantCommands: []string{listCommand, "helm3 mapkubeapis mysql --namespace db", upgradeCommand}},
+		{name: "missing release", plugins: "mapkubeapis", listOutput: "",
+			wantCommands: []string{listCommand, upgradeCommand}},
+		{name: "plugin not configured",
+			wantError: "fixDeprecatedAPIs requires the mapkubeapis plugin in the mixin configuration"},
+	}
+
+	defer os.Unsetenv(test.ExpectedCommandEnv)
+	defer os.Unsetenv(test.ExpectedCommandOutputEnv)
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			os.Setenv(test.ExpectedCommandEnv, strings.Join(tc.wantCommands, "\n"))
+			os.Setenv(test.ExpectedCommandOutputEnv, tc.listOutput)
+
+			action := UpgradeAction{Steps: []UpgradeStep{{
+				UpgradeArguments: UpgradeArguments{
+					Step:              Step{Description: "Upgrade MySQL"},
+					Namespace:         "db",
+					Name:              "mysql",
+					Chart:             "stable/mysql",
+					FixDeprecatedAPIs: true,
+				},
+			}}}
+			b, _ := yaml.Marshal(action)
+
+			h := NewTestMixin(t)
+			h.Setenv(allowedCommandsEnv, tc.plugins)
+			h.In = bytes.NewReader(b)
+
+			err := h.Upgrade(ctx)
+			if tc.wantError != "" {
+				require.EqualError(t, err, tc.wantError)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
@@ -0,0 +1,251 @@
+package helm3
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os/exec"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+// unsetValue is printed for protected values that are not set
+const unsetValue = "<unset>"
+
+// releaseValuesCommand builds the helm command that prints the values supplied to a release as json
+func (m *Mixin) releaseValuesCommand(ctx context.Context, release string, namespace string) *exec.Cmd {
+	cmd := m.helmCommand(ctx, "get", "values", release, "--output", "json")
+	if namespace != "" {
+		cmd.Args = append(cmd.Args, "--namespace", namespace)
+	}
+	return cmd
+}
+
+// releaseValues returns the values supplied to the last revision of a release, nil when the release does not exist
+func (m *Mixin) releaseValues(ctx context.Context, release string, namespace string) (map[string]interface{}, error) {
+	cmd := m.releaseValuesCommand(ctx, release, namespace)
+	stderr := &bytes.Buffer{}
+	cmd.Stderr = io.MultiWriter(m.Err, stderr)
+	out, err := cmd.Output()
+	if err != nil {
+		err = classifyHelmError(err, stderr.String())
+		var helmErr *HelmError
+		if errors.As(err, &helmErr) && helmErr.Reason == ReasonReleaseNotFound {
+			return nil, nil
+		}
+		return nil, errors.Wrapf(err, "could not get the values of release %s", release)
+	}
+
+	values := map[string]interface{}{}
+	if err := json.Unmarshal(out, &values); err != nil {
+		return nil, errors.Wrapf(err, "could not parse the values of release %s", release)
+	}
+	return values, nil
+}
+
+// lookupValue returns the value at a dotted path of chart values, for example router.activeColor,
+// or nil when it is not set
+func lookupValue(values map[string]interface{}, path string) interface{} {
+	var value interface{} = values
+	for _, key := range strings.Split(path, ".") {
+		switch nested := value.(type) {
+		case map[string]interface{}:
+			value = nested[key]
+		case map[interface{}]interface{}:
+			value = nested[key]
+		default:
+			return nil
+		}
+	}
+	return value
+}
+
+// literalKeyParents are the suffixes of the values, such as podAnnotations or nodeSelector, whose keys are
+// kubernetes labels and annotations, like nginx.ingress.kubernetes.io/rewrite-target
+var literalKeyParents = []string{"annotations", "labels", "selector"}
+
+// escapeSetKey escapes the dots of a --set key that are part of a key instead of separating nested keys.
+// Dots are escaped in a quoted part of the key, for example config."app.properties", and in the label or annotation
+// key that follows annotations, labels or selector values when it contains a slash.
+func escapeSetKey(key string) string {
+	if strings.Contains(key, `"`) {
+		var escaped strings.Builder
+		quoted := false
+		for _, c := range key {
+			switch {
+			case c == '"':
+				quoted = !quoted
+			case c == '.' && quoted:
+				escaped.WriteString(`\.`)
+			default:
+				escaped.WriteRune(c)
+			}
+		}
+		return escaped.String()
+	}
+
+	if !strings.Contains(key, "/") || strings.Contains(key, `\.`) {
+		return key
+	}
+	segments := strings.Split(key, ".")
+	for i := len(segments) - 2; i >= 0; i-- {
+		for _, parent := range literalKeyParents {
+			if strings.HasSuffix(strings.ToLower(segments[i]), parent) {
+				return strings.Join(segments[:i+1], ".") + "." + strings.Join(segments[i+1:], `\.`)
+			}
+		}
+	}
+	return key
+}
+
+// escapeSetValue escapes the commas of a --set value, which would otherwise separate several values.
+// Commas that are already escaped, and lists written as {a,b}, are left unchanged.
+func escapeSetValue(value string) string {
+	if strings.HasPrefix(value, "{") && strings.HasSuffix(value, "}") {
+		return value
+	}
+	var escaped strings.Builder
+	for i, c := range value {
+		if c == ',' && (i == 0 || value[i-1] != '\\') {
+			escaped.WriteByte('\\')
+		}
+		escaped.WriteRune(c)
+	}
+	return escaped.String()
+}
+
+// setTypedArgs returns the --set-json arguments that set chart values with the type they have in the step,
+// so that numbers and booleans are not passed to the chart as strings
+func setTypedArgs(setTyped map[string]interface{}) ([]string, error) {
+	keys := make([]string, 0, len(setTyped))
+	for k := range setTyped {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	args := make([]string, 0, len(keys)*2)
+	for _, k := range keys {
+		b, err := json.Marshal(jsonValue(setTyped[k]))
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not convert the typed value %s to json", k)
+		}
+		args = append(args, "--set-json", fmt.Sprintf("%s=%s", k, b))
+	}
+	return args, nil
+}
+
+// jsonValue converts the maps decoded from yaml, which are keyed by interface{}, to maps that can be encoded to json
+func jsonValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		converted := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			converted[fmt.Sprint(key)] = jsonValue(item)
+		}
+		return converted
+	case []interface{}:
+		converted := make([]interface{}, len(v))
+		for i, item := range v {
+			converted[i] = jsonValue(item)
+		}
+		return converted
+	}
+	return value
+}
+
+// formatValue prints a chart value to compare the values of a release with the values of its upgrade
+func formatValue(value interface{}) string {
+	if value == nil {
+		return unsetValue
+	}
+	return fmt.Sprint(value)
+}
+
+// upgradeValue returns the value set by the upgrade of a release, from its values files and set values, and whether it
+// is known. Values files that can't be read here, such as remote or encrypted files, are left to helm, and the value
+// is unknown when one of them may set it.
+func (m *Mixin) upgradeValue(release UpgradeArguments, path string) (interface{}, bool) {
+	var value interface{}
+	known := true
+	for _, file := range release.Values {
+		if m.valuesFile(file) != file {
+			known = false
+			continue
+		}
+		doc, err := m.readValuesFile(file)
+		if err != nil {
+			known = false
+			continue
+		}
+		if v := lookupValue(doc, path); v != nil {
+			// the value of a file overrides the values of the files before it
+			value, known = v, true
+		}
+	}
+	if v, ok := subchartValues(release.Subcharts, release.Set)[path]; ok {
+		value, known = v, true
+	}
+	if v, ok := release.SetTyped[path]; ok {
+		value, known = v, true
+	}
+	return value, known
+}
+
+// readValuesFile decodes a values file from the file system, without reading it in memory first
+func (m *Mixin) readValuesFile(path string) (map[string]interface{}, error) {
+	f, err := m.FileSystem.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	doc := map[string]interface{}{}
+	err = yaml.NewDecoder(f).Decode(&doc)
+	if err == io.EOF {
+		// an empty values file
+		return doc, nil
+	}
+	return doc, err
+}
+
+// checkProtectedValues compares the protected values of a release with the values of its upgrade, and fails
+// when they change unless the changes are allowed. Values that are not set by the upgrade are kept when the values
+// of the release are reused, and reset to the defaults of the chart otherwise.
+func (m *Mixin) checkProtectedValues(ctx context.Context, release UpgradeArguments, protected []string, allowChanges bool) error {
+	deployed, err := m.releaseValues(ctx, release.Name, release.Namespace)
+	if err != nil || deployed == nil {
+		return err
+	}
+
+	var changes []string
+	for _, path := range protected {
+		from := lookupValue(deployed, path)
+		to, known := m.upgradeValue(release, path)
+		if !known {
+			fmt.Fprintf(m.Out, "Skipping the protected value %s of release %s, it may be set by a values file that can't be read before the upgrade\n", path, release.Name)
+			continue
+		}
+		if to == nil && (release.ReuseValues || release.ResetThenReuseValues) {
+			continue
+		}
+		if formatValue(from) != formatValue(to) {
+			changes = append(changes, fmt.Sprintf("%s: %s -> %s", path, formatValue(from), formatValue(to)))
+		}
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+	sort.Strings(changes)
+
+	if allowChanges {
+		fmt.Fprintf(m.Out, "Changing the protected values of release %s:\n\t* %s\n", release.Name, strings.Join(changes, "\n\t* "))
+		return nil
+	}
+	return errors.Errorf("the upgrade of release %s changes protected values, set allowProtectedChanges to upgrade it anyway:\n\t* %s",
+		release.Name, strings.Join(changes, "\n\t* "))
+}
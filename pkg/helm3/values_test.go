@@ -0,0 +1,205 @@
+package helm3
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"testing"
+
+	"get.porter.sh/porter/pkg/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestMixin_UpgradeProtectedValues(t *testing.T) {
+	testcases := []struct {
+		name         string
+		set          map[string]string
+		values       []string
+		reuseValues  bool
+		allowChanges bool
+		upgradeCmd   string
+		wantOutput   string
+		wantErr      string
+	}{
+		{
+			name:    "changed",
+			set:     map[string]string{"persistence.storageClass": "gp3"},
+			wantErr: "the upgrade of release mysql changes protected values, set allowProtectedChanges to upgrade it anyway:\n\t* persistence.storageClass: standard -> gp3",
+		},
+		{
+			name:    "reset",
+			wantErr: "persistence.storageClass: standard -> <unset>",
+		},
+		{
+			name:         "allowed",
+			set:          map[string]string{"persistence.storageClass": "gp3"},
+			allowChanges: true,
+			upgradeCmd:   "helm3 upgrade --install mysql stable/mysql --namespace db --atomic --create-namespace --output json --set persistence.storageClass=gp3",
+		},
+		{
+			name:        "reused",
+			reuseValues: true,
+			upgradeCmd:  "helm3 upgrade --install mysql stable/mysql --namespace db --reuse-values --atomic --create-namespace --output json",
+		},
+		{
+			name:       "unknown in remote values file",
+			values:     []string{"https://example.com/values.yaml"},
+			upgradeCmd: "helm3 upgrade --install mysql stable/mysql --namespace db --values https://example.com/values.yaml --atomic --create-namespace --output json",
+			wantOutput: "Skipping the protected value persistence.storageClass of release mysql, it may be set by a values file that can't be read before the upgrade",
+		},
+		{
+			name:    "changed after remote values file",
+			values:  []string{"https://example.com/values.yaml", "/cnab/app/values.yaml"},
+			set:     map[string]string{"persistence.storageClass": "gp3"},
+			wantErr: "persistence.storageClass: standard -> gp3",
+		},
+		{
+			name:       "unchanged in values file",
+			values:     []string{"/cnab/app/values.yaml"},
+			upgradeCmd: "helm3 upgrade --install mysql stable/mysql --namespace db --values /cnab/app/values.yaml --atomic --create-namespace --output json",
+		},
+	}
+
+	defer os.Unsetenv(test.ExpectedCommandEnv)
+	defer os.Unsetenv(test.ExpectedCommandOutputEnv)
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			os.Setenv(test.ExpectedCommandEnv, "helm3 get values mysql --output json --namespace db\n"+tc.upgradeCmd)
+			os.Setenv(test.ExpectedCommandOutputEnv, `{"persistence":{"storageClass":"standard"}}`)
+
+			action := UpgradeAction{Steps: []UpgradeStep{{
+				UpgradeArguments: UpgradeArguments{
+					Step:                  Step{Description: "Upgrade MySQL"},
+					Name:                  "mysql",
+					Chart:                 "stable/mysql",
+					Namespace:             "db",
+					Set:                   tc.set,
+					Values:                tc.values,
+					ReuseValues:           tc.reuseValues,
+					ProtectedValues:       []string{"persistence.storageClass"},
+					AllowProtectedChanges: tc.allowChanges,
+				},
+			}}}
+			b, _ := yaml.Marshal(action)
+
+			h := NewTestMixin(t)
+			h.In = bytes.NewReader(b)
+			h.TestContext.AddTestFile("testdata/protected-values.yaml", "/cnab/app/values.yaml")
+
+			err := h.Upgrade(ctx)
+			if tc.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Contains(t, h.TestContext.GetOutput(), tc.wantOutput)
+		})
+	}
+}
+
+func TestSetTypedArgs(t *testing.T) {
+	var setTyped map[string]interface{}
+	require.NoError(t, yaml.Unmarshal([]byte(`
+metrics.enabled: true
+primary.service.ports.mysql: 3306
+auth.username: "007"
+primary.resources: {limits: {memory: 512Mi}}
+primary.extraFlags: ["--max-connections=500"]
+`), &setTyped))
+
+	args, err := setTypedArgs(setTyped)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"--set-json", `auth.username="007"`,
+		"--set-json", "metrics.enabled=true",
+		"--set-json", `primary.extraFlags=["--max-connections=500"]`,
+		"--set-json", `primary.resources={"limits":{"memory":"512Mi"}}`,
+		"--set-json", "primary.service.ports.mysql=3306",
+	}, args)
+}
+
+func TestMixin_InstallSetTyped(t *testing.T) {
+	ctx := context.Background()
+	defer os.Unsetenv(test.ExpectedCommandEnv)
+	os.Setenv(test.ExpectedCommandEnv, "helm3 upgrade --install mysql bitnami/mysql --atomic --create-namespace --output json "+
+		"--set auth.database=app --set-json metrics.enabled=true --set-json primary.service.ports.mysql=3306")
+
+	b := []byte(`install:
+- helm3:
+    description: Install MySQL
+    name: mysql
+    chart: bitnami/mysql
+    set:
+      auth.database: app
+    setTyped:
+      metrics.enabled: true
+      primary.service.ports.mysql: 3306
+`)
+
+	h := NewTestMixin(t)
+	h.In = bytes.NewReader(b)
+
+	err := h.Install(ctx)
+	require.NoError(t, err)
+}
+
+func TestEscapeSetKey(t *testing.T) {
+	testcases := map[string]string{
+		"image.tag": "image.tag",
+		"ingress.annotations.nginx.ingress.kubernetes.io/rewrite-target":  `ingress.annotations.nginx\.ingress\.kubernetes\.io/rewrite-target`,
+		"controller.podLabels.app.kubernetes.io/part-of":                  `controller.podLabels.app\.kubernetes\.io/part-of`,
+		"nodeSelector.kubernetes.io/os":                                   `nodeSelector.kubernetes\.io/os`,
+		`ingress.annotations.nginx\.ingress\.kubernetes\.io/ssl-redirect`: `ingress.annotations.nginx\.ingress\.kubernetes\.io/ssl-redirect`,
+		`configuration."app.properties"`:                                  `configuration.app\.properties`,
+		"extraEnv.path/to":                                                "extraEnv.path/to",
+	}
+	for key, want := range testcases {
+		assert.Equal(t, want, escapeSetKey(key), key)
+	}
+}
+
+func TestEscapeSetValue(t *testing.T) {
+	assert.Equal(t, `a\,b\,c`, escapeSetValue("a,b,c"))
+	assert.Equal(t, `a\,b`, escapeSetValue(`a\,b`), "escaped commas should be left unchanged")
+	assert.Equal(t, "{a,b}", escapeSetValue("{a,b}"), "lists should be left unchanged")
+	assert.Equal(t, "plain", escapeSetValue("plain"))
+}
+
+func TestMixin_InstallEscapeSet(t *testing.T) {
+	testcases := []struct {
+		name      string
+		escapeSet *bool
+		wantSet   string
+	}{
+		{name: "escaped by default", wantSet: `--set ingress.annotations.nginx\.ingress\.kubernetes\.io/whitelist-source-range=10.0.0.0/8\,192.168.0.0/16`},
+		{name: "disabled", escapeSet: new(bool), wantSet: "--set ingress.annotations.nginx.ingress.kubernetes.io/whitelist-source-range=10.0.0.0/8,192.168.0.0/16"},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			defer os.Unsetenv(test.ExpectedCommandEnv)
+			os.Setenv(test.ExpectedCommandEnv, "helm3 upgrade --install web bitnami/nginx --atomic --create-namespace --output json "+tc.wantSet)
+
+			action := InstallAction{Steps: []InstallStep{{
+				InstallArguments: InstallArguments{
+					Step:      Step{Description: "Install nginx"},
+					Name:      "web",
+					Chart:     "bitnami/nginx",
+					Set:       map[string]string{"ingress.annotations.nginx.ingress.kubernetes.io/whitelist-source-range": "10.0.0.0/8,192.168.0.0/16"},
+					EscapeSet: tc.escapeSet,
+				},
+			}}}
+			b, _ := yaml.Marshal(action)
+
+			h := NewTestMixin(t)
+			h.In = bytes.NewReader(b)
+
+			err := h.Install(ctx)
+			require.NoError(t, err)
+		})
+	}
+}
@@ -0,0 +1,185 @@
+package helm3
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// vendoredChartDir holds the charts pinned to a digest that are pulled and verified when the bundle is built,
+// in a directory named after the digest
+const vendoredChartDir = "/opt/porter-helm3/charts"
+
+// vendoredChartMetadata is the file written next to a vendored chart, recording what was verified at build time
+const vendoredChartMetadata = "verified.json"
+
+// vendoredChart is a chart pinned to a digest that is pulled into the invocation image, along with the signature
+// verifications of the steps that deploy it, which are run when the bundle is built
+type vendoredChart struct {
+	Chart    string                 `json:"chart"`
+	Digest   string                 `json:"digest"`
+	Version  string                 `json:"version,omitempty"`
+	Verified []vendoredVerification `json:"verified,omitempty"`
+}
+
+// vendoredVerification is a signature verification of a vendored chart
+type vendoredVerification struct {
+	Key      string `json:"key,omitempty"`
+	Identity string `json:"identity,omitempty"`
+	Issuer   string `json:"issuer,omitempty"`
+}
+
+func newVendoredVerification(verify SignatureVerification) vendoredVerification {
+	return vendoredVerification{Key: verify.Key, Identity: verify.Identity, Issuer: verify.Issuer}
+}
+
+// dir returns the directory of the image where the chart is extracted
+func (c vendoredChart) dir() string {
+	return path.Join(vendoredChartDir, strings.TrimPrefix(c.Digest, "sha256:"))
+}
+
+// pinnedCharts returns the charts pinned to a digest by the helm3 steps of every action, sorted by chart, with the
+// signature verifications of their steps
+func pinnedCharts(contents []byte) ([]vendoredChart, error) {
+	charts := make(map[string]*vendoredChart)
+	err := forEachHelm3Step(contents, func(action string, index int, step helm3Step) error {
+		releases := append([]stepRelease{{Chart: step.Chart}}, step.Releases...)
+		for _, release := range releases {
+			if release.Version == "" {
+				release.Version = step.Version
+			}
+			ref, digest, pinned := splitChartDigest(release.Chart)
+			// the charts templated at runtime are pulled by the steps
+			if !pinned || strings.Contains(release.Chart+release.Version, "{{") {
+				continue
+			}
+			err := validateChartDigest(release.Chart)
+			if err != nil {
+				return err
+			}
+			chart, ok := charts[release.Chart]
+			if !ok {
+				chart = &vendoredChart{Chart: ref, Digest: digest, Version: release.Version}
+				charts[release.Chart] = chart
+			}
+			if chart.Version != release.Version {
+				return errors.Errorf("chart %s is pinned with the versions %q and %q, which cannot both be vendored", release.Chart, chart.Version, release.Version)
+			}
+			if step.Verify == nil {
+				continue
+			}
+			err = validateBuildVerification(release.Chart, *step.Verify)
+			if err != nil {
+				return err
+			}
+			verification := newVendoredVerification(*step.Verify)
+			if !chart.verified(verification) {
+				chart.Verified = append(chart.Verified, verification)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(charts))
+	for name := range charts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	result := make([]vendoredChart, 0, len(names))
+	for _, name := range names {
+		result = append(result, *charts[name])
+	}
+	return result, nil
+}
+
+// validateBuildVerification checks that the signature of a chart can be verified when the bundle is built, before
+// the files of the bundle are copied into the image and without the credentials of the bundle
+func validateBuildVerification(chart string, verify SignatureVerification) error {
+	if verify.Key == "" {
+		if verify.Identity == "" || verify.Issuer == "" {
+			return errors.New("signature verification requires either a key, or an identity and an issuer")
+		}
+		return nil
+	}
+	if !strings.Contains(verify.Key, "://") || strings.HasPrefix(verify.Key, "env://") || strings.HasPrefix(verify.Key, "k8s://") {
+		return errors.Errorf("the verify key %q of chart %s cannot be read when the bundle is built, use a key url, a KMS key or keyless verification to vendor the chart", verify.Key, chart)
+	}
+	return nil
+}
+
+// verified checks if the signature of the chart was verified the same way at build time
+func (c vendoredChart) verified(verification vendoredVerification) bool {
+	for _, v := range c.Verified {
+		if v == verification {
+			return true
+		}
+	}
+	return false
+}
+
+// buildVendoredCharts writes the Dockerfile lines that pull the charts pinned to a digest into the image, check
+// that the registry served the chart of the digest, verify its signatures with cosign, and record what was verified
+// next to the chart, so that the steps deploy the charts that were verified
+func (m *Mixin) buildVendoredCharts(helmBinary string, charts []vendoredChart) error {
+	for _, chart := range charts {
+		metadata, err := json.Marshal(chart)
+		if err != nil {
+			return errors.Wrapf(err, "could not record the verification of chart %s", chart.Chart)
+		}
+		pull := fmt.Sprintf("%s pull %s --untar --destination %s", helmBinary, quoteArg(chart.Chart+"@"+chart.Digest), chart.dir())
+		if chart.Version != "" {
+			pull += " --version " + quoteArg(chart.Version)
+		}
+		commands := []string{
+			"mkdir -p " + chart.dir(),
+			fmt.Sprintf("%s | grep -F %s", pull, quoteArg("Digest: "+chart.Digest)),
+		}
+		image := strings.TrimPrefix(chart.Chart, "oci://") + "@" + chart.Digest
+		for _, v := range chart.Verified {
+			if v.Key != "" {
+				commands = append(commands, fmt.Sprintf("cosign verify --key %s %s", quoteArg(v.Key), quoteArg(image)))
+			} else {
+				commands = append(commands, fmt.Sprintf("cosign verify --certificate-identity %s --certificate-oidc-issuer %s %s",
+					quoteArg(v.Identity), quoteArg(v.Issuer), quoteArg(image)))
+			}
+		}
+		commands = append(commands, fmt.Sprintf("echo %s > %s", quoteArg(string(metadata)), path.Join(chart.dir(), vendoredChartMetadata)))
+		fmt.Fprintf(m.Out, "RUN %s\n", strings.Join(commands, " &&\\\n    "))
+	}
+	return nil
+}
+
+// readVendoredChart returns the chart pinned to a digest that was vendored into the image when the bundle was built,
+// and false when it was not vendored
+func (m *Mixin) readVendoredChart(chart string) (vendoredChart, bool, error) {
+	ref, digest, pinned := splitChartDigest(chart)
+	if !pinned {
+		return vendoredChart{}, false, nil
+	}
+	file := filepath.Join(vendoredChartDir, strings.TrimPrefix(digest, "sha256:"), vendoredChartMetadata)
+	exists, err := m.FileSystem.Exists(file)
+	if err != nil || !exists {
+		return vendoredChart{}, false, err
+	}
+	b, err := m.FileSystem.ReadFile(file)
+	if err != nil {
+		return vendoredChart{}, false, errors.Wrapf(err, "could not read the verification of the vendored chart %s", chart)
+	}
+	var vendored vendoredChart
+	err = json.Unmarshal(b, &vendored)
+	if err != nil {
+		return vendoredChart{}, false, errors.Wrapf(err, "could not read the verification of the vendored chart %s", chart)
+	}
+	if vendored.Chart != ref || vendored.Digest != digest {
+		return vendoredChart{}, false, errors.Errorf("the chart vendored for %s is %s@%s, which does not match the pinned chart", chart, vendored.Chart, vendored.Digest)
+	}
+	return vendored, true, nil
+}
@@ -0,0 +1,134 @@
+package helm3
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"get.porter.sh/porter/pkg/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestMixin_BuildVendoredCharts(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("vendored charts", func(t *testing.T) {
+		b, err := ioutil.ReadFile("testdata/build-input-with-vendored-charts.yaml")
+		require.NoError(t, err)
+
+		m := NewTestMixin(t)
+		m.DebugMode = false
+		m.In = bytes.NewReader(b)
+		err = m.Build(ctx)
+		require.NoError(t, err, "build failed")
+
+		mysqlDir := "/opt/porter-helm3/charts/" + strings.TrimPrefix(mysqlChartDigest, "sha256:")
+		redisDir := "/opt/porter-helm3/charts/" + strings.TrimPrefix(otherChartDigest, "sha256:")
+		assert.Contains(t, m.TestContext.GetOutput(), `RUN mkdir -p `+mysqlDir+` &&\
+    helm3 pull oci://registry.example.com/charts/mysql@`+mysqlChartDigest+` --untar --destination `+mysqlDir+` | grep -F 'Digest: `+mysqlChartDigest+`' &&\
+    cosign verify --certificate-identity release@example.com --certificate-oidc-issuer https://accounts.google.com registry.example.com/charts/mysql@`+mysqlChartDigest+` &&\
+    cosign verify --key https://keys.example.com/cosign.pub registry.example.com/charts/mysql@`+mysqlChartDigest+` &&\
+    echo '{"chart":"oci://registry.example.com/charts/mysql","digest":"`+mysqlChartDigest+`","verified":[{"identity":"release@example.com","issuer":"https://accounts.google.com"},{"key":"https://keys.example.com/cosign.pub"}]}' > `+mysqlDir+`/verified.json
+RUN mkdir -p `+redisDir+` &&\
+    helm3 pull oci://registry.example.com/charts/redis@`+otherChartDigest+` --untar --destination `+redisDir+` --version 17.3.7 | grep -F 'Digest: `+otherChartDigest+`' &&\
+    echo '{"chart":"oci://registry.example.com/charts/redis","digest":"`+otherChartDigest+`","version":"17.3.7"}' > `+redisDir+`/verified.json
+`)
+	})
+
+	t.Run("charts are not vendored by default", func(t *testing.T) {
+		b, err := ioutil.ReadFile("testdata/build-input-with-vendored-charts.yaml")
+		require.NoError(t, err)
+		b = bytes.Replace(b, []byte("vendorPinnedCharts: true"), []byte("vendorPinnedCharts: false"), 1)
+
+		m := NewTestMixin(t)
+		m.In = bytes.NewReader(b)
+		err = m.Build(ctx)
+		require.NoError(t, err, "build failed")
+		assert.NotContains(t, m.TestContext.GetOutput(), "verified.json")
+	})
+
+	t.Run("verification without cosign", func(t *testing.T) {
+		b, err := ioutil.ReadFile("testdata/build-input-with-vendored-charts.yaml")
+		require.NoError(t, err)
+		b = bytes.Replace(b, []byte("  cosign:\n    version: v2.2.4\n"), nil, 1)
+
+		m := NewTestMixin(t)
+		m.In = bytes.NewReader(b)
+		err = m.Build(ctx)
+		require.EqualError(t, err, "chart oci://registry.example.com/charts/mysql@"+mysqlChartDigest+" is verified when it is vendored, which requires cosign in the mixin configuration")
+	})
+
+	t.Run("key only available at runtime", func(t *testing.T) {
+		b, err := ioutil.ReadFile("testdata/build-input-with-vendored-charts.yaml")
+		require.NoError(t, err)
+		b = bytes.Replace(b, []byte("https://keys.example.com/cosign.pub"), []byte("env://COSIGN_PUBLIC_KEY"), 1)
+
+		m := NewTestMixin(t)
+		m.In = bytes.NewReader(b)
+		err = m.Build(ctx)
+		require.EqualError(t, err, `the verify key "env://COSIGN_PUBLIC_KEY" of chart oci://registry.example.com/charts/mysql@`+mysqlChartDigest+
+			` cannot be read when the bundle is built, use a key url, a KMS key or keyless verification to vendor the chart`)
+	})
+}
+
+func TestMixin_InstallVendoredChart(t *testing.T) {
+	chart := "oci://registry.example.com/charts/mysql@" + mysqlChartDigest
+	vendoredDir := filepath.Join(vendoredChartDir, strings.TrimPrefix(mysqlChartDigest, "sha256:"))
+	metadata := `{"chart":"oci://registry.example.com/charts/mysql","digest":"` + mysqlChartDigest +
+		`","verified":[{"identity":"release@example.com","issuer":"https://accounts.google.com"}]}`
+
+	testcases := []struct {
+		name         string
+		verify       SignatureVerification
+		wantCommands []string
+	}{
+		{
+			name:   "verified at build time",
+			verify: SignatureVerification{Identity: "release@example.com", Issuer: "https://accounts.google.com"},
+			wantCommands: []string{
+				"helm3 upgrade --install mysql " + filepath.Join(vendoredDir, "mysql") + " --atomic --create-namespace --output json",
+			},
+		},
+		{
+			name:   "verified differently at build time",
+			verify: SignatureVerification{Key: "env://COSIGN_PUBLIC_KEY"},
+			wantCommands: []string{
+				"cosign verify --key env://COSIGN_PUBLIC_KEY registry.example.com/charts/mysql@" + mysqlChartDigest,
+				"helm3 upgrade --install mysql " + filepath.Join(vendoredDir, "mysql") + " --atomic --create-namespace --output json",
+			},
+		},
+	}
+
+	defer os.Unsetenv(test.ExpectedCommandEnv)
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			os.Setenv(test.ExpectedCommandEnv, strings.Join(tc.wantCommands, "\n"))
+
+			action := InstallAction{Steps: []InstallStep{{
+				InstallArguments: InstallArguments{
+					Step:   Step{Description: "Install MySQL"},
+					Name:   "mysql",
+					Chart:  chart,
+					Verify: &tc.verify,
+				},
+			}}}
+			b, _ := yaml.Marshal(action)
+
+			h := NewTestMixin(t)
+			require.NoError(t, h.FileSystem.MkdirAll(vendoredDir, 0755))
+			require.NoError(t, h.FileSystem.WriteFile(filepath.Join(vendoredDir, vendoredChartMetadata), []byte(metadata), 0644))
+			h.In = bytes.NewReader(b)
+
+			err := h.Install(ctx)
+			require.NoError(t, err)
+			assert.Contains(t, h.TestContext.GetOutput(), "Using chart oci://registry.example.com/charts/mysql vendored with the pinned digest")
+		})
+	}
+}
@@ -0,0 +1,159 @@
+package helm3
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// verbosityQuiet hides the commands and their output, only the end of the output of failed commands is printed
+	verbosityQuiet = "quiet"
+	// verbosityNormal prints the commands and their output
+	verbosityNormal = "normal"
+	// verbosityVerbose also runs helm with --debug
+	verbosityVerbose = "verbose"
+	// verbosityTrace also prints the commands the mixin runs to inspect releases, repositories and resources
+	verbosityTrace = "trace"
+)
+
+// verbosityLevels are the values accepted by verbosity, from the least to the most verbose
+var verbosityLevels = []string{verbosityQuiet, verbosityNormal, verbosityVerbose, verbosityTrace}
+
+// quietOutputLines is the number of lines printed from the output of a failed command of a quiet step
+const quietOutputLines = 20
+
+// setVerbosity selects the verbosity of the step that runs, when the step does not set it porter's debug flag selects trace
+func (m *Mixin) setVerbosity(step Step) error {
+	if step.Verbosity != "" && verbosityLevel(step.Verbosity) < 0 {
+		return errors.Errorf("unsupported verbosity %s, expected one of %s", step.Verbosity, strings.Join(verbosityLevels, ", "))
+	}
+	m.verbosity = step.Verbosity
+	m.suppressOutput = step.SuppressOutput
+	return nil
+}
+
+func verbosityLevel(verbosity string) int {
+	for i, level := range verbosityLevels {
+		if verbosity == level {
+			return i
+		}
+	}
+	return -1
+}
+
+// verbosityLevel returns the position of the verbosity of the step in verbosityLevels
+func (m *Mixin) verbosityLevel() int {
+	if m.verbosity != "" {
+		return verbosityLevel(m.verbosity)
+	}
+	if m.DebugMode {
+		return verbosityLevel(verbosityTrace)
+	}
+	return verbosityLevel(verbosityNormal)
+}
+
+func (m *Mixin) quiet() bool {
+	return m.verbosityLevel() == verbosityLevel(verbosityQuiet)
+}
+
+// verbose checks if helm runs with --debug
+func (m *Mixin) verbose() bool {
+	return m.verbosityLevel() >= verbosityLevel(verbosityVerbose)
+}
+
+// tracing checks if the mixin prints the commands it runs to inspect the cluster
+func (m *Mixin) tracing() bool {
+	return m.verbosityLevel() >= verbosityLevel(verbosityTrace)
+}
+
+// echoCommand prints a command before it runs, unless the step is quiet
+func (m *Mixin) echoCommand(prettyCmd string) {
+	if !m.quiet() {
+		fmt.Fprintln(m.Out, prettyCmd)
+	}
+}
+
+// outputSuppressed checks if the output of the commands is hidden, for quiet steps and steps that suppress it
+func (m *Mixin) outputSuppressed() bool {
+	return m.quiet() || m.suppressOutput
+}
+
+// commandStdout returns where the output of a command is streamed, nowhere when the output is suppressed
+func (m *Mixin) commandStdout() io.Writer {
+	if m.outputSuppressed() {
+		return io.Discard
+	}
+	return m.Out
+}
+
+// printOutput prints the output of a command, truncated to its last lines when the output is suppressed
+func (m *Mixin) printOutput(output string) {
+	if m.outputSuppressed() {
+		output = truncateOutput(output, quietOutputLines)
+	}
+	fmt.Fprint(m.Out, output)
+}
+
+// outputTail keeps the last lines written to it, so that the output of a command whose output is suppressed can be
+// printed when it fails, like truncateOutput, without holding the whole output in memory
+type outputTail struct {
+	max     int
+	lines   [][]byte
+	partial []byte
+	dropped int
+}
+
+func newOutputTail(max int) *outputTail {
+	return &outputTail{max: max}
+}
+
+func (t *outputTail) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 {
+		i := bytes.IndexByte(p, '\n')
+		if i < 0 {
+			t.partial = append(t.partial, p...)
+			break
+		}
+		t.lines = append(t.lines, append(t.partial, p[:i+1]...))
+		t.partial = nil
+		if len(t.lines) > t.max {
+			t.lines = t.lines[1:]
+			t.dropped++
+		}
+		p = p[i+1:]
+	}
+	return n, nil
+}
+
+// String returns the last lines, after the number of lines that were truncated
+func (t *outputTail) String() string {
+	lines := t.lines
+	if len(t.partial) > 0 {
+		lines = append(lines[:len(lines):len(lines)], t.partial)
+	}
+	dropped := t.dropped
+	if len(lines) > t.max {
+		dropped += len(lines) - t.max
+		lines = lines[len(lines)-t.max:]
+	}
+	output := string(bytes.Join(lines, nil))
+	if dropped == 0 {
+		return output
+	}
+	return fmt.Sprintf("... %d lines truncated\n", dropped) + strings.TrimSuffix(output, "\n") + "\n"
+}
+
+// truncateOutput keeps the last lines of an output
+func truncateOutput(output string, maxLines int) string {
+	lines := strings.SplitAfter(strings.TrimSuffix(output, "\n"), "\n")
+	if len(lines) <= maxLines {
+		return output
+	}
+	truncated := len(lines) - maxLines
+	return fmt.Sprintf("... %d lines truncated\n", truncated) + strings.Join(lines[truncated:], "") + "\n"
+}
@@ -0,0 +1,131 @@
+package helm3
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"get.porter.sh/porter/pkg/portercontext"
+	"get.porter.sh/porter/pkg/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestTruncateOutput(t *testing.T) {
+	assert.Equal(t, "a\nb\n", truncateOutput("a\nb\n", 2))
+
+	var lines []string
+	for i := 1; i <= 5; i++ {
+		lines = append(lines, fmt.Sprintf("line %d", i))
+	}
+	assert.Equal(t, "... 3 lines truncated\nline 4\nline 5\n", truncateOutput(strings.Join(lines, "\n")+"\n", 2))
+}
+
+func TestOutputTail(t *testing.T) {
+	tail := newOutputTail(2)
+	fmt.Fprint(tail, "a\nb\n")
+	assert.Equal(t, "a\nb\n", tail.String())
+
+	tail = newOutputTail(2)
+	for i := 1; i <= 5; i++ {
+		// lines written in pieces are kept whole
+		fmt.Fprintf(tail, "line %d", i)
+		fmt.Fprint(tail, "\n")
+	}
+	assert.Equal(t, "... 3 lines truncated\nline 4\nline 5\n", tail.String())
+
+	fmt.Fprint(tail, "line 6")
+	assert.Equal(t, "... 4 lines truncated\nline 5\nline 6\n", tail.String())
+}
+
+func TestMixin_SetVerbosity(t *testing.T) {
+	h := NewTestMixin(t)
+	h.DebugMode = true
+	require.NoError(t, h.setVerbosity(Step{}))
+	assert.True(t, h.tracing(), "porter's debug flag should trace steps that do not set their verbosity")
+
+	require.NoError(t, h.setVerbosity(Step{Verbosity: "normal"}))
+	assert.False(t, h.verbose())
+	assert.False(t, h.quiet())
+
+	require.EqualError(t, h.setVerbosity(Step{Verbosity: "loud"}), "unsupported verbosity loud, expected one of quiet, normal, verbose, trace")
+}
+
+func TestMixin_InstallVerbosity(t *testing.T) {
+	testcases := []struct {
+		verbosity   string
+		wantCommand string
+		wantEcho    bool
+	}{
+		{verbosity: "quiet", wantCommand: "helm3 upgrade --install mysql stable/mysql --atomic --create-namespace --output json"},
+		{verbosity: "normal", wantCommand: "helm3 upgrade --install mysql stable/mysql --atomic --create-namespace --output json", wantEcho: true},
+		{verbosity: "verbose", wantCommand: "helm3 upgrade --install mysql stable/mysql --debug --atomic --create-namespace --output json", wantEcho: true},
+	}
+
+	defer os.Unsetenv(test.ExpectedCommandEnv)
+	for _, tc := range testcases {
+		t.Run(tc.verbosity, func(t *testing.T) {
+			ctx := context.Background()
+			os.Setenv(test.ExpectedCommandEnv, tc.wantCommand)
+
+			action := InstallAction{Steps: []InstallStep{{
+				InstallArguments: InstallArguments{
+					Step:  Step{Description: "Install MySQL", Verbosity: tc.verbosity},
+					Name:  "mysql",
+					Chart: "stable/mysql",
+				},
+			}}}
+			b, _ := yaml.Marshal(action)
+
+			h := NewTestMixin(t)
+			h.In = bytes.NewReader(b)
+
+			err := h.Install(ctx)
+			require.NoError(t, err)
+			if tc.wantEcho {
+				assert.Contains(t, h.TestContext.GetOutput(), "upgrade --install mysql")
+			} else {
+				assert.NotContains(t, h.TestContext.GetOutput(), "upgrade --install mysql")
+			}
+		})
+	}
+}
+
+func TestMixin_ExecuteSuppressOutput(t *testing.T) {
+	ctx := context.Background()
+	defer os.Unsetenv(test.ExpectedCommandEnv)
+	defer os.Unsetenv(test.ExpectedCommandOutputEnv)
+	os.Setenv(test.ExpectedCommandEnv, "helm3 template mysql stable/mysql")
+	os.Setenv(test.ExpectedCommandOutputEnv, "kind: StatefulSet\nmetadata:\n  name: mysql\n")
+
+	action := Action{Steps: []ExecuteSteps{{
+		ExecuteStep: ExecuteStep{
+			Step: Step{
+				Description:    "Render MySQL",
+				SuppressOutput: true,
+				Outputs:        []HelmOutput{{Name: "manifest", Stdout: true}},
+			},
+			Arguments: []string{"template", "mysql", "stable/mysql"},
+		},
+	}}}
+	b, _ := yaml.Marshal(action)
+
+	h := NewTestMixin(t)
+	h.In = bytes.NewReader(b)
+
+	err := h.Execute(ctx)
+	require.NoError(t, err)
+
+	output := h.TestContext.GetOutput()
+	assert.Contains(t, output, "helm3 template mysql stable/mysql", "the command should still be printed")
+	assert.NotContains(t, output, "kind: StatefulSet", "the output of helm should be suppressed")
+
+	manifest, err := h.FileSystem.ReadFile(filepath.Join(portercontext.MixinOutputsDir, "manifest"))
+	require.NoError(t, err)
+	assert.Equal(t, "kind: StatefulSet\nmetadata:\n  name: mysql\n", string(manifest))
+}
@@ -0,0 +1,104 @@
+package helm3
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"get.porter.sh/porter/pkg/test"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestMixin_InstallVerify(t *testing.T) {
+	testcases := []struct {
+		name         string
+		chart        string
+		version      string
+		verify       SignatureVerification
+		wantCommands []string
+		wantError    string
+	}{
+		{
+			name:    "public key",
+			chart:   "oci://ghcr.io/example/charts/mysql",
+			version: "0.10.2",
+			verify:  SignatureVerification{Key: "env://COSIGN_PUBLIC_KEY"},
+			wantCommands: []string{
+				"cosign verify --key env://COSIGN_PUBLIC_KEY ghcr.io/example/charts/mysql:0.10.2",
+				"helm3 upgrade --install mysql oci://ghcr.io/example/charts/mysql --version 0.10.2 --atomic --create-namespace --output json",
+			},
+		},
+		{
+			name:    "keyless",
+			chart:   "oci://ghcr.io/example/charts/mysql",
+			version: "0.10.2",
+			verify:  SignatureVerification{Identity: "release@example.com", Issuer: "https://accounts.google.com"},
+			wantCommands: []string{
+				"cosign verify --certificate-identity release@example.com --certificate-oidc-issuer https://accounts.google.com ghcr.io/example/charts/mysql:0.10.2",
+				"helm3 upgrade --install mysql oci://ghcr.io/example/charts/mysql --version 0.10.2 --atomic --create-namespace --output json",
+			},
+		},
+		{
+			name:      "not an oci chart",
+			chart:     "stable/mysql",
+			version:   "0.10.2",
+			verify:    SignatureVerification{Key: "env://COSIGN_PUBLIC_KEY"},
+			wantError: "cannot verify the signature of chart stable/mysql, only oci:// charts are supported",
+		},
+		{
+			name:      "missing version",
+			chart:     "oci://ghcr.io/example/charts/mysql",
+			verify:    SignatureVerification{Key: "env://COSIGN_PUBLIC_KEY"},
+			wantError: "cannot verify the signature of chart oci://ghcr.io/example/charts/mysql without a version",
+		},
+		{
+			name:      "missing issuer",
+			chart:     "oci://ghcr.io/example/charts/mysql",
+			version:   "0.10.2",
+			verify:    SignatureVerification{Identity: "release@example.com"},
+			wantError: "signature verification requires either a key, or an identity and an issuer",
+		},
+		{
+			name:         "verification failure",
+			chart:        "oci://ghcr.io/example/charts/mysql",
+			version:      "0.10.2",
+			verify:       SignatureVerification{Key: "cosign.pub"},
+			wantCommands: []string{"cosign verify --key env://COSIGN_PUBLIC_KEY ghcr.io/example/charts/mysql:0.10.2"},
+			wantError:    "signature verification of chart oci://ghcr.io/example/charts/mysql failed",
+		},
+	}
+
+	defer os.Unsetenv(test.ExpectedCommandEnv)
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			os.Setenv(test.ExpectedCommandEnv, strings.Join(tc.wantCommands, "\n"))
+
+			verify := tc.verify
+			action := InstallAction{Steps: []InstallStep{{
+				InstallArguments: InstallArguments{
+					Step:    Step{Description: "Install MySQL"},
+					Name:    "mysql",
+					Chart:   tc.chart,
+					Version: tc.version,
+					Verify:  &verify,
+				},
+			}}}
+			b, _ := yaml.Marshal(action)
+
+			h := NewTestMixin(t)
+			h.In = bytes.NewReader(b)
+
+			err := h.Install(ctx)
+			if tc.wantError != "" {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), tc.wantError)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
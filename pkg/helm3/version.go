@@ -1,9 +1,12 @@
 package helm3
 
 import (
+	"fmt"
+
 	"get.porter.sh/porter/pkg/mixin"
 	"get.porter.sh/porter/pkg/pkgmgmt"
 	"get.porter.sh/porter/pkg/porter/version"
+	"get.porter.sh/porter/pkg/printer"
 	"github.com/MChorfa/porter-helm3/pkg"
 )
 
@@ -16,5 +19,14 @@ func (m *Mixin) PrintVersion(opts version.Options) error {
 			Author:  "Mohamed Chorfa",
 		},
 	}
-	return version.PrintVersion(m.Context, opts, metadata)
+	err := version.PrintVersion(m.Context, opts, metadata)
+	if err != nil {
+		return err
+	}
+
+	// Inside an invocation image, also report the kubectl version that was installed
+	if kubectlVersion := m.Getenv(kubectlVersionEnv); kubectlVersion != "" && opts.Format == printer.FormatPlaintext {
+		fmt.Fprintf(m.Out, "kubectl %s\n", kubectlVersion)
+	}
+	return nil
 }
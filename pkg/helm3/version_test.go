@@ -53,3 +53,22 @@ func TestPrintJsonVersion(t *testing.T) {
 		t.Fatalf("invalid output:\nWANT:\t%q\nGOT:\t%q\n", wantOutput, gotOutput)
 	}
 }
+
+func TestPrintVersionWithKubectl(t *testing.T) {
+	pkg.Commit = "abc123"
+	pkg.Version = "v1.2.3"
+
+	m := NewTestMixin(t)
+	m.Setenv(kubectlVersionEnv, "v1.25.4")
+
+	opts := version.Options{}
+	err := opts.Validate()
+	require.NoError(t, err)
+	m.PrintVersion(opts)
+
+	gotOutput := m.TestContext.GetOutput()
+	wantOutput := "helm3 v1.2.3 (abc123) by Mohamed Chorfa\nkubectl v1.25.4\n"
+	if !strings.Contains(gotOutput, wantOutput) {
+		t.Fatalf("invalid output:\nWANT:\t%q\nGOT:\t%q\n", wantOutput, gotOutput)
+	}
+}
@@ -5,6 +5,7 @@ import (
 
 	"github.com/pkg/errors"
 	k8s "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 
 	// Needed for cluster that require authentication to negotiate a OAuth token
@@ -18,11 +19,19 @@ type ClientFactory interface {
 
 // ClientFactory struct
 type clientFactory struct {
+	// kubeconfig is read instead of the default kubeconfig when it is set
+	kubeconfig string
 }
 
 // GetClient: Read the config and create Kubernetes Clients
 func (f *clientFactory) GetClient() (k8s.Interface, error) {
-	config, err := clientcmd.DefaultClientConfig.ClientConfig()
+	var config *rest.Config
+	var err error
+	if f.kubeconfig != "" {
+		config, err = clientcmd.BuildConfigFromFlags("", f.kubeconfig)
+	} else {
+		config, err = clientcmd.DefaultClientConfig.ClientConfig()
+	}
 	if err != nil {
 		return nil, fmt.Errorf("couldn't build kubernetes config: %s", err)
 	}
@@ -37,3 +46,8 @@ func (f *clientFactory) GetClient() (k8s.Interface, error) {
 func New() ClientFactory {
 	return &clientFactory{}
 }
+
+// NewForKubeconfig returns a ClientFactory that reads the kubeconfig at the given path
+func NewForKubeconfig(kubeconfig string) ClientFactory {
+	return &clientFactory{kubeconfig: kubeconfig}
+}